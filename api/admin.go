@@ -0,0 +1,447 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/siapool/p2pool/pow"
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/stratum"
+)
+
+//requireAdmin only calls the handler for requests authenticated with the admin password using http basic auth,
+// the username is ignored.
+func (pa *PoolAPI) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || pa.AdminPassword == "" || subtle.ConstantTimeCompare([]byte(password), []byte(pa.AdminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="siapool admin"`)
+			http.Error(w, "admin authentication required", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+//TemplateInfo describes the block template the pool hands out to the miners
+type TemplateInfo struct {
+	Height      types.BlockHeight `json:"height"`
+	ParentID    types.BlockID     `json:"parentid"`
+	Target      types.Target      `json:"target"`
+	ShareTarget types.Target      `json:"sharetarget"`
+	//ShareDifficulty is the stratum difficulty of the share target
+	ShareDifficulty float64 `json:"sharedifficulty"`
+	Transactions    int     `json:"transactions"`
+	//Size is the size of the encoded block in bytes
+	Size    int      `json:"size"`
+	Payouts []Payout `json:"payouts"`
+	//Age is the time since the template was created in seconds
+	Age float64 `json:"age"`
+}
+
+//TemplateHandler writes the current block template, a new template is created if there is none
+func (pa *PoolAPI) TemplateHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	template, err := pa.ShareChain.BlockTemplate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TemplateInfo{
+		Height:          template.Height,
+		ParentID:        template.Block.ParentID,
+		Target:          template.Target,
+		ShareTarget:     template.ShareTarget,
+		ShareDifficulty: pow.DifficultyFromTarget(template.ShareTarget),
+		Transactions:    len(template.Block.Transactions),
+		Size:            template.Size,
+		Payouts:         formatPayouts(template.Block.MinerPayouts, unit),
+		Age:             time.Since(template.Created).Seconds(),
+	})
+}
+
+//defaultAuditRange is the time range of the audit log if no start is requested
+const defaultAuditRange = 24 * time.Hour
+
+//AuditHandler writes the audit log entries since the requested time (for example ?since=2017-01-02T15:04:05Z or a unix timestamp)
+func (pa *PoolAPI) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since, err := auditSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := pa.ShareChain.Audit(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]AuditInfo, 0, len(entries))
+	for _, entry := range entries {
+		infos = append(infos, AuditInfo{AuditEntry: entry, Value: formatCurrency(entry.Value, unit)})
+	}
+	writeJSON(w, infos)
+}
+
+//RejectsHandler writes the rejected shares since the requested time, of the miner ?address if it is given
+func (pa *PoolAPI) RejectsHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := auditSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var address types.UnlockHash
+	if value := r.URL.Query().Get("address"); value != "" {
+		if err = address.LoadString(value); err != nil {
+			http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	rejects, err := pa.ShareChain.Rejects(address, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, rejects)
+}
+
+//AuditInfo is an entry of the audit log with the value rendered in the requested unit
+type AuditInfo struct {
+	sharechain.AuditEntry
+	Value string `json:"value"`
+}
+
+func auditSince(r *http.Request) (since time.Time, err error) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return time.Now().Add(-defaultAuditRange), nil
+	}
+	return parseTime("since", value)
+}
+
+// parseTime parses the time of a query parameter, given in RFC 3339 or as a
+// unix timestamp.
+func parseTime(param, value string) (t time.Time, err error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		err = fmt.Errorf("invalid %s %s", param, value)
+	}
+	return
+}
+
+//PeerInfo is a peer of the embedded gateway together with its reputation for relaying shares,
+// Pool is set for the peers that are nodes of the pool network instead of only the Sia network
+type PeerInfo struct {
+	modules.Peer
+	sharechain.PeerReputation
+	Score float64 `json:"score"`
+	Pool  bool    `json:"pool"`
+}
+
+//PeersHandler writes the peers of the embedded gateway with their reputation
+func (pa *PoolAPI) PeersHandler(w http.ResponseWriter, r *http.Request) {
+	peers := make([]PeerInfo, 0)
+	for _, peer := range pa.ShareChain.Siad.Gateway().Peers() {
+		reputation := pa.ShareChain.Reputation(peer.NetAddress)
+		peers = append(peers, PeerInfo{Peer: peer, PeerReputation: reputation, Score: reputation.Score(), Pool: pa.ShareChain.IsPoolPeer(peer.NetAddress)})
+	}
+	writeJSON(w, peers)
+}
+
+//PoolPeersHandler writes the known nodes of the pool network, the configured seeds and the peers that relayed valid shares,
+// whether the gateway is connected to them or not
+func (pa *PoolAPI) PoolPeersHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, pa.ShareChain.PoolPeers())
+}
+
+//CheckpointsHandler writes the configured sharechain checkpoints and if the sharechain matches them
+func (pa *PoolAPI) CheckpointsHandler(w http.ResponseWriter, r *http.Request) {
+	checkpoints, err := pa.ShareChain.Checkpoints()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, checkpoints)
+}
+
+//BackupHandler writes a snapshot of the sharechain database
+func (pa *PoolAPI) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+sharechain.BackupFilename+`"`)
+	if _, err := pa.ShareChain.Backup(w); err != nil {
+		log.Errorln("Error writing sharechain backup:", err)
+	}
+}
+
+//MOTDInfo is the message of the day shown to the miners
+type MOTDInfo struct {
+	Message string `json:"message"`
+}
+
+//MOTDHandler writes the current message of the day
+func (pa *PoolAPI) MOTDHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, MOTDInfo{Message: pa.Stratum.MOTD()})
+}
+
+//SetMOTDHandler replaces the message of the day by the one in the request body ({"message": "..."}),
+// it is sent to all subscribed miners right away. An empty message disables it.
+func (pa *PoolAPI) SetMOTDHandler(w http.ResponseWriter, r *http.Request) {
+	var motd MOTDInfo
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4*stratum.MaxMOTDLength)).Decode(&motd); err != nil {
+		http.Error(w, "invalid message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := pa.Stratum.SetMOTD(motd.Message); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Infoln("Message of the day set to", strconv.Quote(motd.Message))
+	writeJSON(w, motd)
+}
+
+//ShareRatioInfo is the body of a request to change the share ratio
+type ShareRatioInfo struct {
+	ShareRatio float64 `json:"shareratio"`
+}
+
+//SetShareRatioHandler changes the ratio between the share and the network difficulty to the one in the request body
+// ({"shareratio": 0.001}), 0 uses the fixed start difficulty. Connections below the new share difficulty are raised to it.
+func (pa *PoolAPI) SetShareRatioHandler(w http.ResponseWriter, r *http.Request) {
+	var body ShareRatioInfo
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid share ratio: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := pa.ShareChain.SetShareRatio(body.ShareRatio); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pa.Stratum.ApplyMinimumDifficulty()
+	log.Infoln("Share ratio set to", body.ShareRatio)
+	pa.DifficultyHandler(w, r)
+}
+
+//ConfigSource tells where the value of a configuration setting comes from
+type ConfigSource string
+
+const (
+	//ConfigDefault is the source of a setting that is not configured
+	ConfigDefault ConfigSource = "default"
+	//ConfigFlag is the source of a setting given on the command line
+	ConfigFlag ConfigSource = "flag"
+	//ConfigEnv is the source of a setting taken from an environment variable
+	ConfigEnv ConfigSource = "env"
+)
+
+//Redacted replaces the value of a secret setting that is set
+const Redacted = "[redacted]"
+
+//ConfigEntry is a setting of the effective configuration
+type ConfigEntry struct {
+	Value  interface{}  `json:"value"`
+	Source ConfigSource `json:"source"`
+}
+
+//ConfigHandler writes the effective configuration of the node, the values of secrets are redacted
+func (pa *PoolAPI) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	config := pa.Config
+	if config == nil {
+		config = map[string]ConfigEntry{}
+	}
+	writeJSON(w, config)
+}
+
+//AuthorizedAddressesHandler writes the miner addresses that are allowed to mine when authorization is required
+func (pa *PoolAPI) AuthorizedAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	addresses, err := pa.ShareChain.AuthorizedAddresses()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, addresses)
+}
+
+//AuthorizedAddress is the body of a request to authorize a miner address
+type AuthorizedAddress struct {
+	Address string `json:"address"`
+}
+
+//AuthorizeAddressHandler adds the miner address in the request body ({"address": "..."}) to the authorized addresses
+func (pa *PoolAPI) AuthorizeAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var body AuthorizedAddress
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var address types.UnlockHash
+	if err := address.LoadString(body.Address); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := pa.ShareChain.AuthorizeAddress(address); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infoln("Miner address", address, "authorized")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//RevokeAddressHandler removes a miner address from the authorized addresses
+func (pa *PoolAPI) RevokeAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var address types.UnlockHash
+	if err := address.LoadString(mux.Vars(r)["address"]); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	authorized, err := pa.ShareChain.IsAuthorized(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !authorized {
+		http.Error(w, "address is not authorized", http.StatusNotFound)
+		return
+	}
+	if err = pa.ShareChain.RevokeAddress(address); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infoln("Miner address", address, "no longer authorized")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//ResubmitBlockHandler submits a found block of which the submission failed to the consensus set again
+func (pa *PoolAPI) ResubmitBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var hash crypto.Hash
+	if err := hash.LoadString(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "invalid block id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := types.BlockID(hash)
+	_, exists, err := pa.ShareChain.FailedSubmission(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "no failed submission of this block", http.StatusNotFound)
+		return
+	}
+	if err = pa.ShareChain.ResubmitBlock(id); err != nil {
+		http.Error(w, "resubmission failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Infoln("Found block", id, "resubmitted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//ConnectionsHandler writes the open stratum connections
+func (pa *PoolAPI) ConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, pa.Stratum.Connections())
+}
+
+//ConnectionHandler writes the details of an open stratum connection
+func (pa *PoolAPI) ConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid connection id", http.StatusBadRequest)
+		return
+	}
+	info, ok := pa.Stratum.Connection(id)
+	if !ok {
+		http.Error(w, "connection not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, info)
+}
+
+//AdjustmentRequest is the body of a request to adjust the earnings of a miner address, a negative amount is a debit
+type AdjustmentRequest struct {
+	Amount string `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+//AdjustmentInfo is an adjustment of the earnings with the value rendered in the requested unit
+type AdjustmentInfo struct {
+	sharechain.Adjustment
+	Value string `json:"value"`
+	//Earnings are the earnings of the address after the adjustment, they are only set in the answer to an adjustment
+	Earnings string `json:"earnings,omitempty"`
+}
+
+//AdjustEarningsHandler credits or debits the earnings of a miner address with the amount in the request body, given
+// in the requested unit ({"amount": "-1.5", "reason": "..."} with ?unit=SC). A debit can not exceed the earnings.
+func (pa *PoolAPI) AdjustEarningsHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var address types.UnlockHash
+	if err = address.LoadString(mux.Vars(r)["address"]); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body AdjustmentRequest
+	if err = json.NewDecoder(io.LimitReader(r.Body, 4*sharechain.MaxAdjustmentReasonLength)).Decode(&body); err != nil {
+		http.Error(w, "invalid adjustment: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	value, debit, err := parseCurrency(body.Amount, unit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	adjustment := sharechain.Adjustment{Address: address, Value: value, Debit: debit, Reason: body.Reason}
+	earnings, err := pa.ShareChain.AdjustEarnings(adjustment)
+	if err == sharechain.ErrInvalidAdjustment || err == sharechain.ErrNegativeEarnings {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Warnln("Earnings of", address, "adjusted by", body.Amount, unit, "to", formatCurrency(earnings, unit), unit, "from", r.RemoteAddr, "because", strconv.Quote(body.Reason))
+	writeJSON(w, AdjustmentInfo{Adjustment: adjustment, Value: formatCurrency(value, unit), Earnings: formatCurrency(earnings, unit)})
+}
+
+//AdjustmentsHandler writes all adjustments of the earnings, oldest first
+func (pa *PoolAPI) AdjustmentsHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	adjustments, err := pa.ShareChain.Adjustments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]AdjustmentInfo, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		infos = append(infos, AdjustmentInfo{Adjustment: adjustment, Value: formatCurrency(adjustment.Value, unit)})
+	}
+	writeJSON(w, infos)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/siapool/p2pool/stratum"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	pa := &PoolAPI{}
+	r := mux.NewRouter()
+	if err := pa.Register(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/template", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Error("Admin endpoint served without an admin password, got", w.Code)
+	}
+
+	pa.AdminPassword = "secret"
+	handler := pa.requireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+	for password, expected := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"wrong":  http.StatusUnauthorized,
+		"secret": http.StatusOK,
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/template", nil)
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+		handler(w, req)
+		if w.Code != expected {
+			t.Error("Expected status", expected, "for password", password, "got", w.Code)
+		}
+	}
+}
+
+func TestAuditSince(t *testing.T) {
+	for query, expected := range map[string]int64{
+		"?since=1000":                 1000,
+		"?since=1970-01-01T00:20:00Z": 1200,
+	} {
+		req, _ := http.NewRequest("GET", "/audit"+query, nil)
+		since, err := auditSince(req)
+		if err != nil || since.Unix() != expected {
+			t.Error("Expected", expected, "for", query, "got", since.Unix(), err)
+		}
+	}
+	req, _ := http.NewRequest("GET", "/audit?since=yesterday", nil)
+	if _, err := auditSince(req); err == nil {
+		t.Error("Invalid since accepted")
+	}
+}
+
+func TestSetMOTD(t *testing.T) {
+	pa := &PoolAPI{Stratum: &stratum.Server{}}
+	for body, expected := range map[string]int{
+		`{"message": "Maintenance at 12:00 UTC"}`:                             http.StatusOK,
+		`{"message": "` + strings.Repeat("x", stratum.MaxMOTDLength+1) + `"}`: http.StatusBadRequest,
+		`Maintenance`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/motd", strings.NewReader(body))
+		pa.SetMOTDHandler(w, req)
+		if w.Code != expected {
+			t.Error("Expected status", expected, "for", body, "got", w.Code)
+		}
+	}
+	if motd := pa.Stratum.MOTD(); motd != "Maintenance at 12:00 UTC" {
+		t.Error("Expected the motd to be set, got", motd)
+	}
+}
+
+func TestAuthorizeInvalidAddress(t *testing.T) {
+	pa := &PoolAPI{}
+	for _, body := range []string{`{"address": "invalid"}`, `invalid`, `{}`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/authorized", strings.NewReader(body))
+		pa.AuthorizeAddressHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Error("Expected status", http.StatusBadRequest, "for", body, "got", w.Code)
+		}
+	}
+}
+
+func TestUnknownConnection(t *testing.T) {
+	pa := &PoolAPI{Stratum: &stratum.Server{}, AdminPassword: "secret"}
+	r := mux.NewRouter()
+	if err := pa.Register(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]int{"/connections/1": http.StatusNotFound, "/connections/x": http.StatusBadRequest} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.SetBasicAuth("", "secret")
+		r.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Error("Expected status", expected, "for", path, "got", w.Code)
+		}
+	}
+}
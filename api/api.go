@@ -1,20 +1,64 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/gorilla/mux"
+	"github.com/siapool/p2pool/pow"
 	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/stratum"
+	"github.com/siapool/p2pool/webhooks"
 )
 
+//defaultHistoryRange is the time range of the hashrate history if none is requested
+const defaultHistoryRange = time.Hour
+
+//PayoutScheme is the way block rewards are distributed over the miners
+const PayoutScheme = "pplns"
+
 //PoolAPI implements the http handlers
 type PoolAPI struct {
 	//Fee is the poolfee in 0.01%
 	Fee int
+	//FeeAddress is the address the pool fee is paid to
+	FeeAddress string
 	//ShareChain for getting work and posting shares
 	ShareChain *sharechain.ShareChain
 	//Version is the poolversion
 	Version string
+	//Network is the sia network the pool mines on
+	Network string
+	//Stratum is the stratum server for getting miner statistics
+	Stratum *stratum.Server
+	//AdminPassword protects the admin endpoints, they are not served if it is empty
+	AdminPassword string
+	//Prefix is the path all endpoints are served under, for running behind a reverse proxy that mounts the api on a subpath
+	Prefix string
+	//ProbesAtRoot serves the monitoring endpoints like /metrics at the root instead of under the Prefix
+	ProbesAtRoot bool
+	//Unit is the default unit of monetary fields, UnitHastings if empty
+	Unit string
+	//CacheTTLs overrides the time the responses of endpoints are cached, by path
+	CacheTTLs map[string]time.Duration
+	//Compress gzips responses of at least CompressMinSize bytes (DefaultCompressMinSize if 0) for clients that accept it
+	Compress        bool
+	CompressMinSize int
+	//Webhooks validates the urls of new webhooks, the webhook endpoints are not available if it is nil
+	Webhooks *webhooks.Dispatcher
+	//Config is the effective configuration of the node by setting name, secrets have to be redacted already
+	Config map[string]ConfigEntry
+	//WebUI serves the built-in status page at the root of the api
+	WebUI bool
+
+	cache responseCache
 }
 
 //FeeHandler writes the fee applied by the pool
@@ -22,7 +66,454 @@ func (pa *PoolAPI) FeeHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "%.2f%%", float64(pa.Fee)/100)
 }
 
-//VersionHandler writes the software version of the pool
+//VersionHandler writes the software version of the pool, followed by the sia network
 func (pa *PoolAPI) VersionHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Print(w, pa.Version)
+	if pa.Network == "" {
+		fmt.Fprint(w, pa.Version)
+		return
+	}
+	fmt.Fprintf(w, "%s (%s)", pa.Version, pa.Network)
+}
+
+//PoolInfo is the public configuration of the pool, it does not contain any secrets
+type PoolInfo struct {
+	//Fee is the poolfee in %
+	Fee          float64 `json:"fee"`
+	FeeAddress   string  `json:"feeaddress"`
+	PayoutScheme string  `json:"payoutscheme"`
+	//SolverWeight is the number of shares the share that solved a block counts for, it is missing if every share counts once
+	SolverWeight uint64 `json:"solverweight,omitempty"`
+	//DifficultyRatio is the share difficulty divided by the network difficulty
+	DifficultyRatio float64 `json:"difficultyratio"`
+	//PPLNSWindow is the number of shares in the pplns window of the next block
+	PPLNSWindow int    `json:"pplnswindow"`
+	Version     string `json:"version"`
+	Network     string `json:"network"`
+}
+
+//PoolHandler writes the public configuration of the pool so miners can verify the terms of the pool
+func (pa *PoolAPI) PoolHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, PoolInfo{
+		Fee:             float64(pa.Fee) / 100,
+		FeeAddress:      pa.FeeAddress,
+		PayoutScheme:    PayoutScheme,
+		SolverWeight:    pa.ShareChain.SolverWeight(),
+		DifficultyRatio: pa.ShareChain.DifficultyRatio(),
+		PPLNSWindow:     pa.ShareChain.PPLNSWindow(),
+		Version:         pa.Version,
+		Network:         pa.Network,
+	})
+}
+
+//BlockInfo is a block found by the pool with the payouts rendered in the requested unit
+type BlockInfo struct {
+	sharechain.FoundBlock
+	Payouts []Payout `json:"payouts"`
+	//MaturesIn is the number of confirmations a pending block still needs before its payouts are final
+	MaturesIn types.BlockHeight `json:"maturesin,omitempty"`
+	//SubmissionError is the last error submitting a block with the status submissionfailed
+	SubmissionError string `json:"submissionerror,omitempty"`
+}
+
+// blockInfo renders a found block, the error of a failed submission is
+// looked up.
+func (pa *PoolAPI) blockInfo(fb sharechain.FoundBlock, unit string) BlockInfo {
+	info := BlockInfo{FoundBlock: fb, Payouts: formatPayouts(fb.Payouts, unit), MaturesIn: fb.MaturesIn(pa.ShareChain.BlockMaturity())}
+	if fb.Status == sharechain.BlockSubmissionFailed {
+		if failed, exists, err := pa.ShareChain.FailedSubmission(fb.ID); err == nil && exists {
+			info.SubmissionError = failed.Error
+		}
+	}
+	return info
+}
+
+//BlocksHandler writes the blocks found by the pool, pending blocks do not have the required number of confirmations yet.
+// Every block is paid by its own miner payouts, pending blocks that overlap mature independently.
+func (pa *PoolAPI) BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	blocks, err := pa.ShareChain.FoundBlocks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]BlockInfo, 0, len(blocks))
+	for _, fb := range blocks {
+		infos = append(infos, pa.blockInfo(fb, unit))
+	}
+	writeJSON(w, infos)
+}
+
+//RewardSplitInfo is the distribution of the reward of a found block at the time it was found,
+// the parts add up to the subsidy minus the fee
+type RewardSplitInfo struct {
+	Subsidy string `json:"subsidy"`
+	//FeePercentage is the pool fee in % that applied to the block
+	FeePercentage float64          `json:"feepercentage"`
+	Fee           string           `json:"fee"`
+	FeeAddress    types.UnlockHash `json:"feeaddress"`
+	Parts         []Payout         `json:"parts"`
+}
+
+//BlockRewardInfo is a found block with its reward split, the split is missing for blocks found by older versions
+type BlockRewardInfo struct {
+	BlockInfo
+	RewardSplit *RewardSplitInfo `json:"rewardsplit"`
+}
+
+//BlockHandler writes the blocks found by the pool at a height with the exact distribution of their reward,
+// more than one block is returned if an orphaned block was found at the same height.
+func (pa *PoolAPI) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	height, err := strconv.ParseUint(mux.Vars(r)["height"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid height", http.StatusBadRequest)
+		return
+	}
+	blocks, err := pa.ShareChain.FoundBlocks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]BlockRewardInfo, 0)
+	for _, fb := range blocks {
+		if fb.Height != types.BlockHeight(height) {
+			continue
+		}
+		info := BlockRewardInfo{BlockInfo: pa.blockInfo(fb, unit)}
+		split, err := pa.ShareChain.RewardSplit(fb.ID)
+		if err == nil {
+			info.RewardSplit = &RewardSplitInfo{
+				Subsidy:       formatCurrency(split.Subsidy, unit),
+				FeePercentage: float64(split.FeePercentage) / 100,
+				Fee:           formatCurrency(split.Fee, unit),
+				FeeAddress:    split.FeeAddress,
+				Parts:         formatPayouts(split.Payouts, unit),
+			}
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		http.Error(w, "no block found by the pool at this height", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, infos)
+}
+
+//TotalsInfo are the lifetime counters of the pool with the total paid rendered in the requested unit
+type TotalsInfo struct {
+	Paid        string `json:"paid"`
+	BlocksFound uint64 `json:"blocksfound"`
+	Shares      uint64 `json:"shares"`
+	//Uptime is the number of seconds the pool has been running across restarts
+	Uptime  float64   `json:"uptime"`
+	Started time.Time `json:"started"`
+}
+
+//TotalsHandler writes the lifetime counters of the pool
+func (pa *PoolAPI) TotalsHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	totals, err := pa.ShareChain.Totals()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, TotalsInfo{
+		Paid:        formatCurrency(totals.Paid, unit),
+		BlocksFound: totals.BlocksFound,
+		Shares:      totals.Shares,
+		Uptime:      totals.Uptime.Seconds(),
+		Started:     totals.Started,
+	})
+}
+
+//DifficultyInfo is the difficulty of a share compared to the difficulty of the sia network
+type DifficultyInfo struct {
+	ShareDifficulty   float64 `json:"sharedifficulty"`
+	NetworkDifficulty float64 `json:"networkdifficulty"`
+	//Ratio is the share difficulty divided by the network difficulty
+	Ratio float64 `json:"ratio"`
+	//ShareRatio is the configured ratio, 0 if shares have a fixed difficulty
+	ShareRatio float64 `json:"shareratio"`
+}
+
+//DifficultyHandler writes the current share and network difficulty
+func (pa *PoolAPI) DifficultyHandler(w http.ResponseWriter, r *http.Request) {
+	shareDifficulty := pow.DifficultyFromTarget(pa.ShareChain.ShareTarget())
+	networkDifficulty := pow.DifficultyFromTarget(pa.ShareChain.NetworkTarget())
+	writeJSON(w, DifficultyInfo{
+		ShareDifficulty:   shareDifficulty,
+		NetworkDifficulty: networkDifficulty,
+		Ratio:             shareDifficulty / networkDifficulty,
+		ShareRatio:        pa.ShareChain.ShareRatio(),
+	})
+}
+
+//PoolHistoryHandler writes the pool hashrate samples within the requested range (for example ?range=6h)
+func (pa *PoolAPI) PoolHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	span, err := historyRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, pa.Stratum.PoolHistory(span))
+}
+
+//MinersHandler writes the statistics of the miners, recently disconnected miners are marked inactive
+func (pa *PoolAPI) MinersHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, pa.Stratum.Miners())
+}
+
+//MinerHistoryHandler writes the hashrate samples of a miner address within the requested range (for example ?range=6h)
+func (pa *PoolAPI) MinerHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	span, err := historyRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, pa.Stratum.MinerHistory(mux.Vars(r)["address"], span))
+}
+
+//EarningsInfo splits what a miner address is paid by state in the requested unit, Earnings is Matured plus Credits
+// minus Debits and Total adds the Pending payouts to the Earnings
+type EarningsInfo struct {
+	//Pending are the payouts in found blocks that did not mature yet
+	Pending string `json:"pending"`
+	//Matured are the payouts in matured blocks
+	Matured string `json:"matured"`
+	//Credits and Debits are the adjustments the operator settles with the miner outside of the pool
+	Credits  string `json:"credits"`
+	Debits   string `json:"debits"`
+	Earnings string `json:"earnings"`
+	Total    string `json:"total"`
+}
+
+//MinerEarningsHandler writes the pending and matured payouts, the adjustments and the earnings of a miner address
+func (pa *PoolAPI) MinerEarningsHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var address types.UnlockHash
+	if err = address.LoadString(mux.Vars(r)["address"]); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	breakdown, err := pa.ShareChain.EarningsBreakdown(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, EarningsInfo{
+		Pending:  formatCurrency(breakdown.Pending, unit),
+		Matured:  formatCurrency(breakdown.Matured, unit),
+		Credits:  formatCurrency(breakdown.Credits, unit),
+		Debits:   formatCurrency(breakdown.Debits, unit),
+		Earnings: formatCurrency(breakdown.Earnings, unit),
+		Total:    formatCurrency(breakdown.Earnings.Add(breakdown.Pending), unit),
+	})
+}
+
+//MetricsHandler writes the sizes of the in-memory state of the stratum server, the template build times, the share
+// latencies and the consensus lag in the prometheus text format
+func (pa *PoolAPI) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range pa.Stratum.Metrics() {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", m.Name, m.Help, m.Name, m.Name, m.Value)
+	}
+	writeTemplateMetrics(w, pa.ShareChain.TemplateBuilds())
+	writeShareLatencyMetrics(w, pa.Stratum.ShareLatencies())
+	writeConsensusMetrics(w, pa.ShareChain)
+}
+
+//writeConsensusMetrics writes how far the processing of the consensus changes lags behind the consensus set and the
+// number of entries left out of the journal
+func writeConsensusMetrics(w io.Writer, sc *sharechain.ShareChain) {
+	for _, m := range []stratum.Metric{
+		{Name: "sharechain_consensus_queued_changes", Help: "Number of consensus changes waiting to be processed", Value: float64(sc.QueuedConsensusChanges())},
+		{Name: "sharechain_consensus_lag_blocks", Help: "Number of blocks the sharechain is behind the consensus set", Value: float64(sc.ConsensusLag())},
+		{Name: "sharechain_journal_dropped_entries", Help: "Number of shares and audit entries left out of the journal since the start", Value: float64(sc.JournalDropped())},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", m.Name, m.Help, m.Name, m.Name, m.Value)
+	}
+}
+
+//templateQuantiles are the quantiles of the template build times reported in the metrics
+var templateQuantiles = []float64{0.5, 0.9, 0.99}
+
+//templatePhases are the phases of a template build reported in the metrics
+var templatePhases = []struct {
+	name     string
+	duration func(sharechain.TemplateBuild) time.Duration
+}{
+	{"mempool", func(tb sharechain.TemplateBuild) time.Duration { return tb.Mempool }},
+	{"coinbase", func(tb sharechain.TemplateBuild) time.Duration { return tb.Coinbase }},
+	{"serialization", func(tb sharechain.TemplateBuild) time.Duration { return tb.Serialization }},
+	{"total", sharechain.TemplateBuild.Total},
+}
+
+//writeTemplateMetrics writes the quantiles of the build times of the recent templates as a prometheus summary
+func writeTemplateMetrics(w io.Writer, builds []sharechain.TemplateBuild) {
+	const name = "sharechain_template_build_seconds"
+	fmt.Fprintf(w, "# HELP %s Time spent building the recent block templates per phase\n# TYPE %s summary\n", name, name)
+	for _, phase := range templatePhases {
+		durations := make([]float64, 0, len(builds))
+		var sum float64
+		for _, build := range builds {
+			d := phase.duration(build).Seconds()
+			durations = append(durations, d)
+			sum += d
+		}
+		sort.Float64s(durations)
+		for _, q := range templateQuantiles {
+			fmt.Fprintf(w, "%s{phase=%q,quantile=\"%v\"} %v\n", name, phase.name, q, quantile(durations, q))
+		}
+		fmt.Fprintf(w, "%s_sum{phase=%q} %v\n%s_count{phase=%q} %v\n", name, phase.name, sum, name, phase.name, len(durations))
+	}
+}
+
+//writeShareLatencyMetrics writes the quantiles of the latencies of the recent shares as a prometheus summary
+func writeShareLatencyMetrics(w io.Writer, samples []stratum.ShareLatency) {
+	const name = "stratum_share_latency_seconds"
+	fmt.Fprintf(w, "# HELP %s Time from receiving the recent shares to their verdict\n# TYPE %s summary\n", name, name)
+	latencies, sum := sortedLatencies(samples)
+	for _, q := range templateQuantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%v\"} %v\n", name, q, quantile(latencies, q))
+	}
+	fmt.Fprintf(w, "%s_sum %v\n%s_count %v\n", name, sum, name, len(latencies))
+}
+
+//sortedLatencies returns the latencies of the shares in seconds in ascending order and their sum
+func sortedLatencies(samples []stratum.ShareLatency) (latencies []float64, sum float64) {
+	latencies = make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		latencies = append(latencies, sample.Latency.Seconds())
+		sum += sample.Latency.Seconds()
+	}
+	sort.Float64s(latencies)
+	return
+}
+
+//quantile returns the q-quantile of sorted values using the nearest rank, NaN if there are no values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+//TemplateBuildInfo is the time spent building a block template, durations are in seconds
+type TemplateBuildInfo struct {
+	Created       time.Time `json:"created"`
+	Total         float64   `json:"total"`
+	Mempool       float64   `json:"mempool"`
+	Coinbase      float64   `json:"coinbase"`
+	Serialization float64   `json:"serialization"`
+	Transactions  int       `json:"transactions"`
+	Size          int       `json:"size"`
+}
+
+//StatsInfo holds the operational statistics of the node
+type StatsInfo struct {
+	//LastTemplateBuild is missing if no template was built yet
+	LastTemplateBuild *TemplateBuildInfo `json:"lasttemplatebuild"`
+}
+
+//StatsHandler writes the operational statistics of the node
+func (pa *PoolAPI) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats StatsInfo
+	if builds := pa.ShareChain.TemplateBuilds(); len(builds) > 0 {
+		last := builds[len(builds)-1]
+		stats.LastTemplateBuild = &TemplateBuildInfo{
+			Created:       last.Created,
+			Total:         last.Total().Seconds(),
+			Mempool:       last.Mempool.Seconds(),
+			Coinbase:      last.Coinbase.Seconds(),
+			Serialization: last.Serialization.Seconds(),
+			Transactions:  last.Transactions,
+			Size:          last.Size,
+		}
+	}
+	writeJSON(w, stats)
+}
+
+//LatencyInfo holds the percentiles of the time from receiving a share to its verdict, in seconds,
+// over the Samples most recent shares received between From and To. The percentiles are 0 without samples.
+type LatencyInfo struct {
+	Samples int       `json:"samples"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	P50     float64   `json:"p50"`
+	P90     float64   `json:"p90"`
+	P99     float64   `json:"p99"`
+}
+
+//LatencyHandler writes the percentiles of the latencies of the recent shares, the same shares as in the metrics
+func (pa *PoolAPI) LatencyHandler(w http.ResponseWriter, r *http.Request) {
+	samples := pa.Stratum.ShareLatencies()
+	info := LatencyInfo{Samples: len(samples)}
+	if len(samples) > 0 {
+		latencies, _ := sortedLatencies(samples)
+		info.From, info.To = samples[0].Received, samples[len(samples)-1].Received
+		info.P50, info.P90, info.P99 = quantile(latencies, 0.5), quantile(latencies, 0.9), quantile(latencies, 0.99)
+	}
+	writeJSON(w, info)
+}
+
+//ConsensusHandler writes the height of the sharechain and if it is catching up with the network
+func (pa *PoolAPI) ConsensusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, pa.ShareChain.ConsensusStatus())
+}
+
+//ReadyHandler reports if the node is ready to serve miners, it fails while catching up with the network,
+// when the accounting stopped because of a deep reorg or while the gateway has too few peers.
+// A node that lags behind the consensus set or hands out templates without transactions because the transaction pool
+// is unavailable is ready but degraded.
+func (pa *PoolAPI) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := pa.ShareChain.Ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := pa.ShareChain.Lagging(); err != nil {
+		fmt.Fprint(w, "ready, degraded: ", err)
+		return
+	}
+	if err := pa.ShareChain.Degraded(); err != nil {
+		fmt.Fprint(w, "ready, degraded: block templates have no transactions, ", err)
+		return
+	}
+	fmt.Fprint(w, "ready")
+}
+
+func historyRange(r *http.Request) (span time.Duration, err error) {
+	value := r.URL.Query().Get("range")
+	if value == "" {
+		return defaultHistoryRange, nil
+	}
+	span, err = time.ParseDuration(value)
+	if err == nil && span <= 0 {
+		err = fmt.Errorf("invalid range %s", value)
+	}
+	return
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
 }
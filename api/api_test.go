@@ -1 +1,81 @@
 package api
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/stratum"
+)
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for q, expected := range map[float64]float64{0: 1, 0.5: 5, 0.9: 9, 0.99: 10, 1: 10} {
+		if v := quantile(sorted, q); v != expected {
+			t.Error("Expected", expected, "for quantile", q, "got", v)
+		}
+	}
+	if !math.IsNaN(quantile(nil, 0.5)) {
+		t.Error("Quantile of no values is not NaN")
+	}
+}
+
+func TestTemplateMetrics(t *testing.T) {
+	var builds []sharechain.TemplateBuild
+	for i := 1; i <= 4; i++ {
+		builds = append(builds, sharechain.TemplateBuild{Mempool: time.Duration(i) * time.Second, Coinbase: time.Second})
+	}
+	var w bytes.Buffer
+	writeTemplateMetrics(&w, builds)
+	for _, line := range []string{
+		`sharechain_template_build_seconds{phase="mempool",quantile="0.5"} 2`,
+		`sharechain_template_build_seconds{phase="mempool",quantile="0.99"} 4`,
+		`sharechain_template_build_seconds{phase="total",quantile="0.9"} 5`,
+		`sharechain_template_build_seconds_sum{phase="coinbase"} 4`,
+		`sharechain_template_build_seconds_count{phase="serialization"} 4`,
+	} {
+		if !strings.Contains(w.String(), line+"\n") {
+			t.Error("Missing", line, "in", w.String())
+		}
+	}
+}
+
+func TestShareLatencyMetrics(t *testing.T) {
+	var samples []stratum.ShareLatency
+	for i := 1; i <= 10; i++ {
+		samples = append(samples, stratum.ShareLatency{Latency: time.Duration(11-i) * time.Second})
+	}
+	var w bytes.Buffer
+	writeShareLatencyMetrics(&w, samples)
+	for _, line := range []string{
+		`stratum_share_latency_seconds{quantile="0.5"} 5`,
+		`stratum_share_latency_seconds{quantile="0.9"} 9`,
+		`stratum_share_latency_seconds_sum 55`,
+		`stratum_share_latency_seconds_count 10`,
+	} {
+		if !strings.Contains(w.String(), line+"\n") {
+			t.Error("Missing", line, "in", w.String())
+		}
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/shares?from=1000&to=1970-01-01T00:20:00Z&limit=10&cursor=abc", nil)
+	q, err := pageQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.From != 1000 || q.To != 1200 || q.Limit != 10 || q.Cursor != "abc" {
+		t.Error("Unexpected page query", q)
+	}
+	for _, query := range []string{"?limit=0", "?limit=1001", "?limit=ten", "?from=yesterday", "?to=tomorrow"} {
+		req, _ = http.NewRequest("GET", "/shares"+query, nil)
+		if _, err = pageQuery(req); err == nil {
+			t.Error("Invalid query accepted:", query)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//maxCacheEntries bounds the number of cached responses, requests with many different urls are not all cached
+const maxCacheEntries = 1000
+
+//cachedResponse is a response of a handler that is served again until it expires
+type cachedResponse struct {
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+//responseCache holds the responses of the cached endpoints keyed by request uri, so per-miner data is cached per miner
+type responseCache struct {
+	mutex     sync.Mutex
+	responses map[string]cachedResponse
+}
+
+//get returns the cached response for a key if it did not expire yet
+func (c *responseCache) get(key string, now time.Time) (response cachedResponse, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	response, ok = c.responses[key]
+	return response, ok && now.Before(response.expires)
+}
+
+//put caches a response, expired responses are removed if the cache is full and nothing is cached if it is still full
+func (c *responseCache) put(key string, response cachedResponse, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.responses == nil {
+		c.responses = make(map[string]cachedResponse)
+	}
+	if _, exists := c.responses[key]; !exists && len(c.responses) >= maxCacheEntries {
+		for k, r := range c.responses {
+			if !now.Before(r.expires) {
+				delete(c.responses, k)
+			}
+		}
+		if len(c.responses) >= maxCacheEntries {
+			return
+		}
+	}
+	c.responses[key] = response
+}
+
+//cached serves the responses of the handler from the cache for ttl, only successful responses are cached.
+// The Cache-Control header tells clients how long the response stays the same.
+func (c *responseCache) cached(handler http.HandlerFunc, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		key := r.URL.RequestURI()
+		response, ok := c.get(key, now)
+		if !ok {
+			recorder := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+			handler(recorder, r)
+			if recorder.status != http.StatusOK {
+				copyHeader(w.Header(), recorder.header)
+				w.WriteHeader(recorder.status)
+				w.Write(recorder.body.Bytes())
+				return
+			}
+			response = cachedResponse{header: recorder.header, body: recorder.body.Bytes(), expires: now.Add(ttl)}
+			c.put(key, response, now)
+		}
+		copyHeader(w.Header(), response.header)
+		maxAge := int((response.expires.Sub(now) + time.Second - 1) / time.Second)
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+		w.Write(response.body)
+	}
+}
+
+//responseRecorder captures the response of a handler so it can be cached
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header         { return r.header }
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *responseRecorder) WriteHeader(status int)      { r.status = status }
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+//ParseCacheTTLs parses the cache time of endpoints given as <path>=<duration> (for example /pool=30s),
+// multiple entries can be separated by a ','. A duration of 0 disables the cache of the endpoint.
+func ParseCacheTTLs(entries []string) (ttls map[string]time.Duration, err error) {
+	ttls = make(map[string]time.Duration)
+	for _, entry := range entries {
+		for _, value := range strings.Split(entry, ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 {
+				return nil, errors.New("invalid cache time " + value + ", use <path>=<duration>")
+			}
+			ttl, err := time.ParseDuration(parts[1])
+			if err != nil || ttl < 0 {
+				return nil, errors.New("invalid cache time " + value + ", use <path>=<duration>")
+			}
+			ttls["/"+strings.TrimPrefix(strings.TrimSpace(parts[0]), "/")] = ttl
+		}
+	}
+	return
+}
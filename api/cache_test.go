@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCachedResponses(t *testing.T) {
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(r.URL.RawQuery))
+	}
+	var c responseCache
+	cached := c.cached(handler, time.Minute)
+	for i, test := range []struct {
+		url   string
+		body  string
+		calls int
+	}{
+		{url: "/pool?a=1", body: "a=1", calls: 1},
+		{url: "/pool?a=1", body: "a=1", calls: 1},
+		{url: "/pool?a=2", body: "a=2", calls: 2},
+		{url: "/pool?fail=1", body: "failed\n", calls: 3},
+		{url: "/pool?fail=1", body: "failed\n", calls: 4},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		cached(w, req)
+		if w.Body.String() != test.body || calls != test.calls {
+			t.Error("Request", i, "expected", test.body, "after", test.calls, "calls, got", w.Body.String(), "after", calls)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("Cache-Control") != "public, max-age=60" {
+			t.Error("Unexpected Cache-Control header", w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestRegisterCacheTTLs(t *testing.T) {
+	pa := &PoolAPI{Fee: 200, Version: "test", CacheTTLs: map[string]time.Duration{"/fee": 0}}
+	r := mux.NewRouter()
+	if err := pa.Register(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"/fee": "", "/version": "public, max-age=60"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		if w.Header().Get("Cache-Control") != expected {
+			t.Error("Expected Cache-Control", expected, "for", path, "got", w.Header().Get("Cache-Control"))
+		}
+	}
+
+	pa = &PoolAPI{AdminPassword: "secret", CacheTTLs: map[string]time.Duration{"/motd": time.Second}}
+	if err := pa.Register(mux.NewRouter(), nil); err == nil {
+		t.Error("Cache time accepted for an admin endpoint")
+	}
+}
+
+func TestParseCacheTTLs(t *testing.T) {
+	ttls, err := ParseCacheTTLs([]string{"/pool=30s,stats=0", "/fee=1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]time.Duration{"/pool": 30 * time.Second, "/stats": 0, "/fee": time.Minute} {
+		if ttl, ok := ttls[path]; !ok || ttl != expected {
+			t.Error("Expected", expected, "for", path, "got", ttl)
+		}
+	}
+	for _, invalid := range []string{"/pool", "/pool=abc", "/pool=-1s"} {
+		if _, err := ParseCacheTTLs([]string{invalid}); err == nil {
+			t.Error("Invalid cache time", invalid, "accepted")
+		}
+	}
+}
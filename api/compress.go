@@ -0,0 +1,101 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+//DefaultCompressMinSize is the default size in bytes below which responses are not compressed
+const DefaultCompressMinSize = 1024
+
+//compressed gzips the responses of the handler of at least minSize bytes for clients accepting gzip.
+// Smaller responses and responses that already have a Content-Encoding are sent as they are,
+// larger responses are compressed while they are written so streamed responses like a backup are not buffered.
+func compressed(handler http.HandlerFunc, minSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			handler(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+		defer cw.close()
+		handler(cw, r)
+	}
+}
+
+//acceptsGzip returns if the Accept-Encoding header of the request lists gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(encoding, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+//compressWriter holds back the response until it reaches the minimum size and gzips it from then on
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	// raw is set once the response is sent uncompressed
+	raw bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) { cw.status = status }
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	switch {
+	case cw.gz != nil:
+		return cw.gz.Write(b)
+	case cw.raw:
+		return cw.ResponseWriter.Write(b)
+	case cw.Header().Get("Content-Encoding") != "":
+		cw.sendRaw()
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) < cw.minSize {
+		return len(b), nil
+	}
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", "gzip")
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.gz = gzip.NewWriter(cw.ResponseWriter)
+	if _, err := cw.gz.Write(cw.buf); err != nil {
+		return 0, err
+	}
+	cw.buf = nil
+	return len(b), nil
+}
+
+//sendRaw sends the status and the held back part of the response uncompressed
+func (cw *compressWriter) sendRaw() {
+	cw.raw = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(cw.buf)
+	cw.buf = nil
+}
+
+//close finishes the gzip stream or sends a response that stayed below the minimum size
+func (cw *compressWriter) close() {
+	switch {
+	case cw.gz != nil:
+		cw.gz.Close()
+	case !cw.raw:
+		cw.sendRaw()
+	}
+}
+
+//compressMinSize returns the CompressMinSize or the default if it is not set
+func (pa *PoolAPI) compressMinSize() int {
+	if pa.CompressMinSize <= 0 {
+		return DefaultCompressMinSize
+	}
+	return pa.CompressMinSize
+}
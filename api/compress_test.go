@@ -0,0 +1,85 @@
+package api
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCompressedResponses(t *testing.T) {
+	large := strings.Repeat("hashrate ", 200)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("size") == "small" {
+			w.Write([]byte("small"))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		// Written in parts to cross the threshold halfway through.
+		w.Write([]byte(large[:100]))
+		w.Write([]byte(large[100:]))
+	}
+	compress := compressed(handler, DefaultCompressMinSize)
+	for _, test := range []struct {
+		url, accept string
+		gzipped     bool
+	}{
+		{url: "/miners", accept: "gzip, deflate", gzipped: true},
+		{url: "/miners", accept: "deflate;q=1, gzip;q=0.5", gzipped: true},
+		{url: "/miners", accept: "", gzipped: false},
+		{url: "/miners?size=small", accept: "gzip", gzipped: false},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		req.Header.Set("Accept-Encoding", test.accept)
+		compress(w, req)
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Error("Missing Vary header for", test.url, test.accept)
+		}
+		if gzipped := w.Header().Get("Content-Encoding") == "gzip"; gzipped != test.gzipped {
+			t.Error("Expected gzipped", test.gzipped, "for", test.url, test.accept, "got", gzipped)
+			continue
+		}
+		body := w.Body.String()
+		if test.gzipped {
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := ioutil.ReadAll(gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = string(raw)
+		}
+		if expected := test.url == "/miners"; expected && (body != large || w.Code != http.StatusCreated) {
+			t.Error("Unexpected response", w.Code, len(body), "for", test.url, test.accept)
+		} else if !expected && body != "small" {
+			t.Error("Unexpected small response", body)
+		}
+	}
+}
+
+func TestRegisterCompress(t *testing.T) {
+	pa := &PoolAPI{Fee: 200, Version: "test", AdminPassword: "secret", Compress: true, CompressMinSize: 1}
+	r := mux.NewRouter()
+	if err := pa.Register(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Cached and unauthorized responses are compressed as well.
+	for path, status := range map[string]int{"/version": http.StatusOK, "/connections": http.StatusUnauthorized} {
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			r.ServeHTTP(w, req)
+			if w.Code != status || w.Header().Get("Content-Encoding") != "gzip" {
+				t.Error("Expected a gzipped", status, "response for", path, "got", w.Code, w.Header())
+			}
+		}
+	}
+}
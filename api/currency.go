@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/currency"
+)
+
+//Currency units of the monetary fields in api responses
+const (
+	//UnitHastings renders amounts as the raw number of hastings
+	UnitHastings = "H"
+	//UnitSiacoin renders amounts in SC with all significant decimals
+	UnitSiacoin = "SC"
+)
+
+var errUnknownUnit = errors.New("unknown unit, use SC or H")
+
+//Payout is a miner payout with the value rendered in the requested unit
+type Payout struct {
+	UnlockHash types.UnlockHash `json:"unlockhash"`
+	Value      string           `json:"value"`
+}
+
+//responseUnit returns the unit requested with ?unit= or the default unit of the api
+func (pa *PoolAPI) responseUnit(r *http.Request) (unit string, err error) {
+	unit = r.URL.Query().Get("unit")
+	if unit == "" {
+		unit = pa.Unit
+	}
+	switch strings.ToUpper(unit) {
+	case "", UnitHastings:
+		return UnitHastings, nil
+	case UnitSiacoin:
+		return UnitSiacoin, nil
+	}
+	return "", errUnknownUnit
+}
+
+//formatCurrency renders an amount in the given unit
+func formatCurrency(c types.Currency, unit string) string {
+	if unit != UnitSiacoin {
+		return currency.FormatHastings(c)
+	}
+	return currency.FormatSC(c)
+}
+
+//formatPayouts renders the values of the payouts in the given unit
+func formatPayouts(payouts []types.SiacoinOutput, unit string) []Payout {
+	formatted := make([]Payout, 0, len(payouts))
+	for _, payout := range payouts {
+		formatted = append(formatted, Payout{UnlockHash: payout.UnlockHash, Value: formatCurrency(payout.Value, unit)})
+	}
+	return formatted
+}
+
+//parseCurrency parses an amount in the given unit, the inverse of formatCurrency. A leading '-' makes it negative.
+func parseCurrency(value, unit string) (c types.Currency, negative bool, err error) {
+	if strings.HasPrefix(value, "-") {
+		negative, value = true, value[1:]
+	}
+	if unit == UnitSiacoin {
+		c, err = currency.ParseSC(value)
+	} else {
+		c, err = currency.ParseHastings(value)
+	}
+	return
+}
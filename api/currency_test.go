@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/currency"
+)
+
+func TestFormatCurrency(t *testing.T) {
+	for _, test := range []struct {
+		value    types.Currency
+		unit     string
+		expected string
+	}{
+		{types.NewCurrency64(1234), UnitHastings, "1234"},
+		{types.SiacoinPrecision.Mul64(300000), UnitSiacoin, "300000"},
+		{types.SiacoinPrecision.Mul64(3).Add(types.SiacoinPrecision.Div64(4)), UnitSiacoin, "3.25"},
+		{types.NewCurrency64(1), UnitSiacoin, "0.000000000000000000000001"},
+		{types.ZeroCurrency, UnitSiacoin, "0"},
+	} {
+		if formatted := formatCurrency(test.value, test.unit); formatted != test.expected {
+			t.Error("Expected", test.expected, "for", test.value, test.unit, "got", formatted)
+		}
+	}
+}
+
+func TestResponseUnit(t *testing.T) {
+	pa := &PoolAPI{Unit: UnitSiacoin}
+	for query, expected := range map[string]string{
+		"":         UnitSiacoin,
+		"?unit=h":  UnitHastings,
+		"?unit=SC": UnitSiacoin,
+	} {
+		req, _ := http.NewRequest("GET", "/blocks"+query, nil)
+		if unit, err := pa.responseUnit(req); err != nil || unit != expected {
+			t.Error("Expected unit", expected, "for", query, "got", unit, err)
+		}
+	}
+	req, _ := http.NewRequest("GET", "/blocks?unit=KS", nil)
+	if _, err := pa.responseUnit(req); err != errUnknownUnit {
+		t.Error("Expected", errUnknownUnit, "got", err)
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		unit     string
+		expected types.Currency
+		negative bool
+	}{
+		{"1234", UnitHastings, types.NewCurrency64(1234), false},
+		{"-3.25", UnitSiacoin, types.SiacoinPrecision.Mul64(3).Add(types.SiacoinPrecision.Div64(4)), true},
+		{"0.000000000000000000000001", UnitSiacoin, types.NewCurrency64(1), false},
+		{"300000", UnitSiacoin, types.SiacoinPrecision.Mul64(300000), false},
+	} {
+		c, negative, err := parseCurrency(test.value, test.unit)
+		if err != nil || c.Cmp(test.expected) != 0 || negative != test.negative {
+			t.Error("Expected", test.expected, test.negative, "for", test.value, test.unit, "got", c, negative, err)
+		}
+	}
+	for _, value := range []string{"", "-", "1.5", "+1", "1e3", "0x10"} {
+		if _, _, err := parseCurrency(value, UnitHastings); err != currency.ErrInvalidAmount {
+			t.Error("Expected", currency.ErrInvalidAmount, "for", value, "got", err)
+		}
+	}
+	if _, _, err := parseCurrency("0.0000000000000000000000001", UnitSiacoin); err != currency.ErrInvalidAmount {
+		t.Error("Expected", currency.ErrInvalidAmount, "for more decimals than hastings, got", err)
+	}
+}
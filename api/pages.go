@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+//Page is a page of a paged list, NextCursor is passed as ?cursor= to get the next page, it is left out after the last page
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+//ShareInfo is an accepted share
+type ShareInfo struct {
+	BlockID   types.BlockID   `json:"blockid"`
+	ParentID  types.BlockID   `json:"parentid"`
+	Timestamp types.Timestamp `json:"timestamp"`
+	Miner     string          `json:"miner"`
+}
+
+//SharesHandler writes a page of the accepted shares (for example ?from=2017-01-02T15:04:05Z&limit=100), oldest first
+func (pa *PoolAPI) SharesHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := pageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	shares, next, err := pa.ShareChain.SharePage(q)
+	if err == sharechain.ErrInvalidCursor {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]ShareInfo, 0, len(shares))
+	for _, share := range shares {
+		infos = append(infos, ShareInfo{BlockID: share.BlockID, ParentID: share.ParentID, Timestamp: share.Timestamp, Miner: share.Miner})
+	}
+	writeJSON(w, Page{Items: infos, NextCursor: next})
+}
+
+//PayoutsHandler writes a page of the payouts of the matured blocks, oldest first, only those to an address with ?address=
+func (pa *PoolAPI) PayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	unit, err := pa.responseUnit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, err := pageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var address *types.UnlockHash
+	if value := r.URL.Query().Get("address"); value != "" {
+		address = new(types.UnlockHash)
+		if err = address.LoadString(value); err != nil {
+			http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	payouts, next, err := pa.ShareChain.PayoutPage(q, address)
+	if err == sharechain.ErrInvalidCursor {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]AuditInfo, 0, len(payouts))
+	for _, payout := range payouts {
+		infos = append(infos, AuditInfo{AuditEntry: payout, Value: formatCurrency(payout.Value, unit)})
+	}
+	writeJSON(w, Page{Items: infos, NextCursor: next})
+}
+
+// pageQuery reads the ?from=, ?to=, ?cursor= and ?limit= parameters of a
+// paged list.
+func pageQuery(r *http.Request) (q sharechain.PageQuery, err error) {
+	values := r.URL.Query()
+	for _, bound := range []struct {
+		param string
+		t     *types.Timestamp
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if value := values.Get(bound.param); value != "" {
+			t, err := parseTime(bound.param, value)
+			if err != nil {
+				return q, err
+			}
+			*bound.t = types.Timestamp(t.Unix())
+		}
+	}
+	q.Cursor = values.Get("cursor")
+	if value := values.Get("limit"); value != "" {
+		if q.Limit, err = strconv.Atoi(value); err != nil || q.Limit <= 0 || q.Limit > sharechain.MaxPageSize {
+			return q, errors.New("invalid limit " + value + ", use 1 to " + strconv.Itoa(sharechain.MaxPageSize))
+		}
+	}
+	return
+}
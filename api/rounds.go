@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/siapool/p2pool/sharechain"
+)
+
+//RoundInfo is a round with its duration in seconds and its luck.
+// The luck of a completed round is the expected shares divided by its shares, above 1 the block was found faster
+// than average. The luck of the current round is not known yet, its progress is the shares divided by the expected shares.
+type RoundInfo struct {
+	sharechain.Round
+	Duration int64   `json:"duration"`
+	Luck     float64 `json:"luck,omitempty"`
+	Progress float64 `json:"progress,omitempty"`
+}
+
+//RoundsHandler writes the completed rounds, oldest first
+func (pa *PoolAPI) RoundsHandler(w http.ResponseWriter, r *http.Request) {
+	rounds, err := pa.ShareChain.Rounds()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infos := make([]RoundInfo, 0, len(rounds))
+	for _, round := range rounds {
+		infos = append(infos, RoundInfo{Round: round, Duration: int64(round.End - round.Start), Luck: round.Luck()})
+	}
+	writeJSON(w, infos)
+}
+
+//CurrentRoundHandler writes the round in progress, it started when the last block was found
+func (pa *PoolAPI) CurrentRoundHandler(w http.ResponseWriter, r *http.Request) {
+	round := pa.ShareChain.CurrentRound()
+	info := RoundInfo{Round: round, Duration: int64(round.End - round.Start)}
+	if round.ExpectedShares > 0 {
+		info.Progress = float64(round.Shares) / float64(round.ExpectedShares)
+	}
+	writeJSON(w, info)
+}
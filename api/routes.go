@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Route is an endpoint of the pool api
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+	//Core routes can not be disabled
+	Core bool
+	//Admin routes require the admin password and are only served if it is set
+	Admin bool
+	//Probe routes are used by monitoring systems, they can be served at the root instead of under the api prefix
+	Probe bool
+	//CacheTTL is the default time the responses of a public GET route are cached, 0 disables the cache
+	CacheTTL time.Duration
+}
+
+//Routes returns all endpoints of the pool api, the status page is only included if WebUI is set
+func (pa *PoolAPI) Routes() []Route {
+	routes := []Route{
+		{Method: "GET", Path: "/fee", Handler: pa.FeeHandler, CacheTTL: time.Minute},
+		{Method: "GET", Path: "/version", Handler: pa.VersionHandler, Core: true, CacheTTL: time.Minute},
+		{Method: "GET", Path: "/pool", Handler: pa.PoolHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/pool/totals", Handler: pa.TotalsHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/blocks", Handler: pa.BlocksHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/blocks/{height}", Handler: pa.BlockHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/shares", Handler: pa.SharesHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/payouts", Handler: pa.PayoutsHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/rounds", Handler: pa.RoundsHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/rounds/current", Handler: pa.CurrentRoundHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/difficulty", Handler: pa.DifficultyHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/consensus", Handler: pa.ConsensusHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/stats", Handler: pa.StatsHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/stats/latency", Handler: pa.LatencyHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/stats/history", Handler: pa.PoolHistoryHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/miners", Handler: pa.MinersHandler, CacheTTL: 5 * time.Second},
+		{Method: "GET", Path: "/miners/{address}/history", Handler: pa.MinerHistoryHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/miners/{address}/earnings", Handler: pa.MinerEarningsHandler, CacheTTL: 10 * time.Second},
+		{Method: "GET", Path: "/webhooks/{id}", Handler: pa.WebhookHandler},
+		{Method: "GET", Path: "/metrics", Handler: pa.MetricsHandler, Core: true, Probe: true},
+		{Method: "GET", Path: "/health/ready", Handler: pa.ReadyHandler, Core: true, Probe: true},
+		{Method: "GET", Path: "/template", Handler: pa.TemplateHandler, Admin: true},
+		{Method: "GET", Path: "/audit", Handler: pa.AuditHandler, Admin: true},
+		{Method: "GET", Path: "/audit/rejects", Handler: pa.RejectsHandler, Admin: true},
+		{Method: "GET", Path: "/peers", Handler: pa.PeersHandler, Admin: true},
+		{Method: "GET", Path: "/peers/pool", Handler: pa.PoolPeersHandler, Admin: true},
+		{Method: "GET", Path: "/connections", Handler: pa.ConnectionsHandler, Admin: true},
+		{Method: "GET", Path: "/connections/{id}", Handler: pa.ConnectionHandler, Admin: true},
+		{Method: "GET", Path: "/authorized", Handler: pa.AuthorizedAddressesHandler, Admin: true},
+		{Method: "POST", Path: "/authorized", Handler: pa.AuthorizeAddressHandler, Admin: true},
+		{Method: "DELETE", Path: "/authorized/{address}", Handler: pa.RevokeAddressHandler, Admin: true},
+		{Method: "POST", Path: "/blocks/{id}/resubmit", Handler: pa.ResubmitBlockHandler, Admin: true},
+		{Method: "GET", Path: "/webhooks", Handler: pa.WebhooksHandler, Admin: true},
+		{Method: "POST", Path: "/miners/{address}/webhooks", Handler: pa.AddWebhookHandler, Admin: true},
+		{Method: "DELETE", Path: "/webhooks/{id}", Handler: pa.DeleteWebhookHandler, Admin: true},
+		{Method: "GET", Path: "/config", Handler: pa.ConfigHandler, Admin: true},
+		{Method: "GET", Path: "/checkpoints", Handler: pa.CheckpointsHandler, Admin: true},
+		{Method: "GET", Path: "/backup", Handler: pa.BackupHandler, Admin: true},
+		{Method: "GET", Path: "/motd", Handler: pa.MOTDHandler, Admin: true},
+		{Method: "PUT", Path: "/motd", Handler: pa.SetMOTDHandler, Admin: true},
+		{Method: "PUT", Path: "/difficulty", Handler: pa.SetShareRatioHandler, Admin: true},
+		{Method: "POST", Path: "/miners/{address}/adjust", Handler: pa.AdjustEarningsHandler, Admin: true},
+		{Method: "GET", Path: "/adjustments", Handler: pa.AdjustmentsHandler, Admin: true},
+	}
+	if pa.WebUI {
+		routes = append(routes, Route{Method: "GET", Path: "/", Handler: pa.StatusPageHandler, CacheTTL: time.Minute})
+	}
+	return routes
+}
+
+//Register adds the endpoints of the pool api to the router under the Prefix, except the disabled ones.
+// Responses of public endpoints with a cache time are served from a cache, they are compressed afterwards if Compress is set.
+// Disabled endpoints are given by their path, with or without leading '/', multiple paths can be separated by a ','.
+// An error is returned if a disabled endpoint does not exist or is a core endpoint, or if a cache time is set for an endpoint that can not be cached.
+func (pa *PoolAPI) Register(r *mux.Router, disabled []string) error {
+	routes := pa.Routes()
+	var paths []string
+	for _, entry := range disabled {
+		for _, path := range strings.Split(entry, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, "/"+strings.TrimPrefix(path, "/"))
+			}
+		}
+	}
+	for path := range pa.CacheTTLs {
+		known := false
+		for _, route := range routes {
+			known = known || (route.Path == path && route.Method == "GET" && !route.Admin)
+		}
+		if !known {
+			return errors.New("endpoint " + path + " can not be cached")
+		}
+	}
+	skip := make(map[string]bool)
+	for _, path := range paths {
+		known := false
+		for _, route := range routes {
+			if route.Path != path {
+				continue
+			}
+			if route.Core {
+				return errors.New("core endpoint " + path + " can not be disabled")
+			}
+			known = true
+		}
+		if !known {
+			return errors.New("unknown endpoint " + path)
+		}
+		skip[path] = true
+	}
+	prefixed := r
+	if prefix := pa.prefix(); prefix != "" {
+		prefixed = r.PathPrefix(prefix).Subrouter()
+	}
+	for _, route := range routes {
+		router := prefixed
+		if route.Probe && pa.ProbesAtRoot {
+			router = r
+		}
+		if skip[route.Path] || (route.Admin && pa.AdminPassword == "") {
+			continue
+		}
+		handler := route.Handler
+		if route.Admin {
+			handler = pa.requireAdmin(handler)
+		} else if ttl := pa.cacheTTL(route); ttl > 0 && route.Method == "GET" {
+			handler = pa.cache.cached(handler, ttl)
+		}
+		if pa.Compress {
+			handler = compressed(handler, pa.compressMinSize())
+		}
+		router.Path(route.Path).Methods(route.Method).Handler(handler)
+	}
+	return nil
+}
+
+//cacheTTL returns the time the responses of a route are cached, the CacheTTLs override the default of the route
+func (pa *PoolAPI) cacheTTL(route Route) time.Duration {
+	if ttl, ok := pa.CacheTTLs[route.Path]; ok {
+		return ttl
+	}
+	return route.CacheTTL
+}
+
+//prefix returns the Prefix with a leading and without a trailing '/'
+func (pa *PoolAPI) prefix() string {
+	prefix := strings.Trim(pa.Prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
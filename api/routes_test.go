@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/stratum"
+)
+
+func TestRegisterDisabledEndpoints(t *testing.T) {
+	pa := &PoolAPI{Fee: 200, Version: "test"}
+	r := mux.NewRouter()
+	if err := pa.Register(r, []string{"fee,/rounds"}); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]int{
+		"/fee":     http.StatusNotFound,
+		"/rounds":  http.StatusNotFound,
+		"/version": http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Error("Expected status", expected, "for", path, "got", w.Code)
+		}
+	}
+}
+
+func TestRegisterInvalidDisabledEndpoints(t *testing.T) {
+	pa := &PoolAPI{}
+	if err := pa.Register(mux.NewRouter(), []string{"/unknown"}); err == nil {
+		t.Error("Unknown endpoint accepted")
+	}
+	for _, path := range []string{"/version", "/health/ready", "metrics"} {
+		if err := pa.Register(mux.NewRouter(), []string{path}); err == nil {
+			t.Error("Core endpoint", path, "disabled")
+		}
+	}
+}
+
+func TestRegisterPrefix(t *testing.T) {
+	for _, test := range []struct {
+		probesAtRoot bool
+		expected     map[string]int
+	}{
+		{probesAtRoot: false, expected: map[string]int{
+			"/pool1/version": http.StatusOK,
+			"/version":       http.StatusNotFound,
+			"/pool1/metrics": http.StatusOK,
+			"/metrics":       http.StatusNotFound,
+		}},
+		{probesAtRoot: true, expected: map[string]int{
+			"/pool1/version": http.StatusOK,
+			"/pool1/metrics": http.StatusNotFound,
+			"/metrics":       http.StatusOK,
+		}},
+	} {
+		pa := &PoolAPI{Version: "test", Stratum: &stratum.Server{}, ShareChain: &sharechain.ShareChain{}, Prefix: "/pool1/", ProbesAtRoot: test.probesAtRoot}
+		r := mux.NewRouter()
+		if err := pa.Register(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		for path, expected := range test.expected {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(w, req)
+			if w.Code != expected {
+				t.Error("Expected status", expected, "for", path, "with probes at root", test.probesAtRoot, "got", w.Code)
+			}
+		}
+	}
+}
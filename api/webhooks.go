@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+//WebhookRequest is the body of a request to register a webhook
+type WebhookRequest struct {
+	URL string `json:"url"`
+}
+
+//AddWebhookHandler registers the url in the request body ({"url": "..."}) as a webhook of the miner address.
+// The reply contains the id of the webhook, it is needed to look it up or delete it later.
+func (pa *PoolAPI) AddWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if pa.Webhooks == nil {
+		http.Error(w, "webhooks are not enabled", http.StatusNotFound)
+		return
+	}
+	var address types.UnlockHash
+	if err := address.LoadString(mux.Vars(r)["address"]); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body WebhookRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := pa.Webhooks.ValidateURL(body.URL); err != nil {
+		http.Error(w, "invalid url: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	hook, err := pa.ShareChain.AddWebhook(address, body.URL)
+	if err == sharechain.ErrTooManyWebhooks || err == sharechain.ErrWebhookLimit {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infoln("Webhook", hook.ID, "registered for", address)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+//WebhookHandler writes a registered webhook
+func (pa *PoolAPI) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, exists, err := pa.ShareChain.Webhook(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "unknown webhook", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, hook)
+}
+
+//DeleteWebhookHandler removes a registered webhook
+func (pa *PoolAPI) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	_, exists, err := pa.ShareChain.Webhook(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "unknown webhook", http.StatusNotFound)
+		return
+	}
+	if err = pa.ShareChain.DeleteWebhook(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infoln("Webhook", id, "deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//WebhooksHandler writes all registered webhooks
+func (pa *PoolAPI) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	hooks, err := pa.ShareChain.Webhooks(nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, hooks)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//StatusPageHandler writes the built-in status page, it reads the public /stats, /miners and /blocks endpoints in the browser
+func (pa *PoolAPI) StatusPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page only talks to the api it is served by.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	fmt.Fprint(w, statusPage)
+}
+
+// statusPage is the html of the status page. It is kept in the source instead
+// of a separate asset so the binary stays self-contained. Values from the api
+// are only ever set as text, miner names are chosen by the miners.
+const statusPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>siapool status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.3em 1em 0.3em 0; border-bottom: 1px solid #ddd; }
+td { font-family: monospace; }
+#error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>siapool status</h1>
+<p id="error"></p>
+<h2>Last block template</h2>
+<table id="stats"></table>
+<h2>Miners</h2>
+<table id="miners"><tr><th>Miner</th><th>Active</th><th>Stale shares</th><th>Near stale shares</th><th>Flagged shares</th></tr></table>
+<h2>Found blocks</h2>
+<table id="blocks"><tr><th>Height</th><th>Block</th><th>Status</th><th>Confirmations</th><th>Found</th></tr></table>
+<script>
+function row(table, values) {
+	var tr = document.createElement("tr");
+	values.forEach(function(value) {
+		var td = document.createElement("td");
+		td.textContent = value;
+		tr.appendChild(td);
+	});
+	table.appendChild(tr);
+}
+function clear(table) {
+	while (table.rows.length > (table.id == "stats" ? 0 : 1)) {
+		table.deleteRow(-1);
+	}
+}
+function get(path, render) {
+	var req = new XMLHttpRequest();
+	req.open("GET", path);
+	req.onload = function() {
+		if (req.status != 200) {
+			document.getElementById("error").textContent = path + ": " + req.status + " " + req.responseText;
+			return;
+		}
+		render(JSON.parse(req.responseText));
+	};
+	req.send();
+}
+function refresh() {
+	document.getElementById("error").textContent = "";
+	get("stats", function(stats) {
+		var table = document.getElementById("stats");
+		clear(table);
+		var build = stats.lasttemplatebuild;
+		if (!build) {
+			row(table, ["No template built yet"]);
+			return;
+		}
+		row(table, ["Created", new Date(build.created).toLocaleString()]);
+		row(table, ["Build time", build.total.toFixed(3) + " s"]);
+		row(table, ["Transactions", build.transactions]);
+		row(table, ["Size", build.size + " bytes"]);
+	});
+	get("miners", function(miners) {
+		var table = document.getElementById("miners");
+		clear(table);
+		miners.forEach(function(m) {
+			row(table, [m.user, m.active ? "yes" : "no", m.staleshares, m.nearstaleshares, m.flaggedshares]);
+		});
+	});
+	get("blocks", function(blocks) {
+		var table = document.getElementById("blocks");
+		clear(table);
+		blocks.slice().reverse().forEach(function(b) {
+			row(table, [b.height, b.id, b.status, b.confirmations, new Date(b.timestamp * 1000).toLocaleString()]);
+		});
+	});
+}
+refresh();
+setInterval(refresh, 30000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStatusPage(t *testing.T) {
+	for _, webUI := range []bool{false, true} {
+		pa := &PoolAPI{Prefix: "/pool1", WebUI: webUI}
+		r := mux.NewRouter()
+		if err := pa.Register(r, nil); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/pool1/", nil)
+		r.ServeHTTP(w, req)
+		if !webUI {
+			if w.Code != http.StatusNotFound {
+				t.Error("Status page served without WebUI, got", w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Fatal("Expected the status page, got", w.Code, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Content-Security-Policy") == "" {
+			t.Error("Status page served without a content security policy")
+		}
+		for _, endpoint := range []string{`"stats"`, `"miners"`, `"blocks"`} {
+			if !strings.Contains(w.Body.String(), endpoint) {
+				t.Error("Status page does not read", endpoint)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/siapool/p2pool/api"
+)
+
+// secretFlags are the flags of which the value is never shown by the config
+// endpoint.
+var secretFlags = map[string]bool{
+	"admin-password": true,
+}
+
+//effectiveConfig returns the value of every global flag as the running process resolved it, with the secrets redacted
+func effectiveConfig(c *cli.Context) map[string]api.ConfigEntry {
+	config := make(map[string]api.ConfigEntry)
+	for _, flag := range c.App.Flags {
+		name := strings.TrimSpace(strings.Split(flag.GetName(), ",")[0])
+		if name == "help" || name == "version" {
+			continue
+		}
+		entry := api.ConfigEntry{Source: api.ConfigDefault}
+		if c.GlobalIsSet(name) {
+			entry.Source = api.ConfigFlag
+		} else if f, ok := flag.(cli.StringFlag); ok && envSet(f.EnvVar) {
+			entry.Source = api.ConfigEnv
+		}
+		switch {
+		case secretFlags[name]:
+			if c.GlobalString(name) != "" {
+				entry.Value = api.Redacted
+			} else {
+				entry.Value = ""
+			}
+		default:
+			if _, ok := flag.(cli.StringSliceFlag); ok {
+				entry.Value = c.GlobalStringSlice(name)
+			} else {
+				entry.Value = c.GlobalString(name)
+			}
+		}
+		config[name] = entry
+	}
+	return config
+}
+
+func envSet(envVars string) bool {
+	for _, envVar := range strings.Split(envVars, ",") {
+		if envVar = strings.TrimSpace(envVar); envVar != "" && os.Getenv(envVar) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+//Package currency formats and parses amounts of siacoins.
+// It works on the integer number of hastings, never on floats, so amounts are exact at any size.
+package currency
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//ErrInvalidAmount is returned for an amount that is not a non-negative number in the expected unit
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// decimals is the number of decimals of a siacoin, 1 SC is 10^24 hastings.
+var decimals = len(types.SiacoinPrecision.String()) - 1
+
+//FormatHastings renders an amount as the integer number of hastings
+func FormatHastings(c types.Currency) string {
+	return c.String()
+}
+
+//FormatSC renders an amount in SC with all significant decimals and without trailing zeros, for example 3.25
+func FormatSC(c types.Currency) string {
+	sc, hastings := new(big.Int).QuoRem(c.Big(), types.SiacoinPrecision.Big(), new(big.Int))
+	if hastings.Sign() == 0 {
+		return sc.String()
+	}
+	fraction := hastings.String()
+	fraction = strings.Repeat("0", decimals-len(fraction)) + fraction
+	return sc.String() + "." + strings.TrimRight(fraction, "0")
+}
+
+//ParseHastings parses an integer number of hastings
+func ParseHastings(s string) (types.Currency, error) {
+	return parseDigits(s)
+}
+
+//ParseSC parses an amount in SC with at most 24 decimals, for example 3.25, the inverse of FormatSC
+func ParseSC(s string) (types.Currency, error) {
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" || len(fraction) > decimals || strings.Trim(fraction, "0123456789") != "" {
+		return types.ZeroCurrency, ErrInvalidAmount
+	}
+	return parseDigits(whole + fraction + strings.Repeat("0", decimals-len(fraction)))
+}
+
+// parseDigits parses a non-empty string of decimal digits.
+func parseDigits(s string) (types.Currency, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return types.ZeroCurrency, ErrInvalidAmount
+	}
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return types.ZeroCurrency, ErrInvalidAmount
+	}
+	return types.NewCurrency(i), nil
+}
@@ -0,0 +1,55 @@
+package currency
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestFormatAndParse(t *testing.T) {
+	// Far above the supply of siacoins, 2^256 hastings is about 1.16e53 SC.
+	huge := types.NewCurrency(new(big.Int).Lsh(big.NewInt(1), 256))
+	for _, test := range []struct {
+		value    types.Currency
+		sc       string
+		hastings string
+	}{
+		{types.ZeroCurrency, "0", "0"},
+		{types.NewCurrency64(1), "0.000000000000000000000001", "1"},
+		{types.SiacoinPrecision.Mul64(3).Add(types.SiacoinPrecision.Div64(4)), "3.25", "3250000000000000000000000"},
+		{types.SiacoinPrecision.Mul64(300000), "300000", "300000000000000000000000000000"},
+		// More than 100 billion SC, beyond the supply for decades, off by one hasting.
+		{types.SiacoinPrecision.Mul64(1e11).Sub(types.NewCurrency64(1)), "99999999999.999999999999999999999999", "99999999999999999999999999999999999"},
+		{huge, "115792089237316195423570985008687907853269984665640564.039457584007913129639936", "115792089237316195423570985008687907853269984665640564039457584007913129639936"},
+	} {
+		if sc := FormatSC(test.value); sc != test.sc {
+			t.Error("Expected", test.sc, "got", sc)
+		}
+		if hastings := FormatHastings(test.value); hastings != test.hastings {
+			t.Error("Expected", test.hastings, "got", hastings)
+		}
+		if parsed, err := ParseSC(test.sc); err != nil || parsed.Cmp(test.value) != 0 {
+			t.Error("Expected", test.value, "for", test.sc, "got", parsed, err)
+		}
+		if parsed, err := ParseHastings(test.hastings); err != nil || parsed.Cmp(test.value) != 0 {
+			t.Error("Expected", test.value, "for", test.hastings, "got", parsed, err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{"", ".", ".5", "-1", "+1", "1e3", "0x10", "1.2.3", " 1", "1,5", "0.0000000000000000000000001"} {
+		if _, err := ParseSC(value); err != ErrInvalidAmount {
+			t.Error("Expected", ErrInvalidAmount, "for", value, "SC, got", err)
+		}
+	}
+	for _, value := range []string{"", "1.5", "-1", "1e3"} {
+		if _, err := ParseHastings(value); err != ErrInvalidAmount {
+			t.Error("Expected", ErrInvalidAmount, "for", value, "H, got", err)
+		}
+	}
+	if c, err := ParseSC("1."); err != nil || c.Cmp(types.SiacoinPrecision) != 0 {
+		t.Error("Expected 1 SC for 1., got", c, err)
+	}
+}
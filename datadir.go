@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/modules/consensus"
+	log "github.com/Sirupsen/logrus"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+// minDatabaseSize is the size of the smallest valid bolt database: two meta
+// pages, a freelist and a root page of at least 4KB each. A smaller file is
+// left over from an interrupted initialization.
+const minDatabaseSize = 4 * 4096
+
+//inspectDataDir reports which of the sharechain and consensus databases exist from a previous run,
+// a database that exists but can not be complete is an error
+func inspectDataDir(consensusDir, sharechainDir string) (sharechainExists, consensusExists bool, err error) {
+	for _, db := range []struct {
+		filename string
+		exists   *bool
+	}{
+		{filepath.Join(sharechainDir, sharechain.DatabaseFilename), &sharechainExists},
+		{filepath.Join(consensusDir, consensus.DatabaseFilename), &consensusExists},
+	} {
+		info, err := os.Stat(db.filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, false, err
+		}
+		if info.IsDir() {
+			return false, false, fmt.Errorf("%s is a directory instead of a database", db.filename)
+		}
+		if info.Size() < minDatabaseSize {
+			return false, false, fmt.Errorf("%s is incomplete (%d bytes), a previous initialization was interrupted: remove it to initialize it again or restore a backup", db.filename, info.Size())
+		}
+		*db.exists = true
+	}
+	return
+}
+
+//logDataDirState tells if the node initializes fresh state or resumes from a previous run
+func logDataDirState(dataDir string, sharechainExists, consensusExists bool) {
+	switch {
+	case sharechainExists && consensusExists:
+		log.Infoln("Resuming from the sharechain and consensus set in", dataDir)
+	case sharechainExists:
+		log.Warnln("Resuming the sharechain in", dataDir, "without a consensus set, the embedded siad downloads the blockchain again and the sharechain replays it from the start")
+	case consensusExists:
+		log.Infoln("Initializing a new sharechain on the existing consensus set in", dataDir)
+	default:
+		log.Infoln("Initializing fresh state in", dataDir, ": a new sharechain database is created and the embedded siad downloads the blockchain, this takes a while")
+	}
+}
@@ -0,0 +1,130 @@
+//Package events is an in-process publish/subscribe bus for the events of the sharechain.
+// The sharechain publishes typed events, subscribers like the webhooks consume them from a bounded buffer.
+// Publishing never blocks: an event is dropped for a subscriber whose buffer is full, so a slow subscriber
+// can not stall accepting shares or processing blocks.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//Event is an event published on a Bus, it is one of the event types of this package
+type Event interface {
+	event()
+}
+
+//ShareAccepted is published for every share the sharechain accepts
+type ShareAccepted struct {
+	BlockID   types.BlockID
+	Miner     string
+	Timestamp types.Timestamp
+}
+
+//BlockFound is published when a block found by the pool is written to the database
+type BlockFound struct {
+	BlockID   types.BlockID
+	Finder    types.UnlockHash
+	Reward    types.Currency
+	Timestamp types.Timestamp
+}
+
+//BlockOrphaned is published when a found block is reverted before it matured
+type BlockOrphaned struct {
+	BlockID   types.BlockID
+	Timestamp types.Timestamp
+}
+
+//Payout is published for every payout of a found block once the block matured
+type Payout struct {
+	BlockID   types.BlockID
+	Address   types.UnlockHash
+	Value     types.Currency
+	Timestamp types.Timestamp
+}
+
+//Reorg is published when a consensus change reverts blocks, Height is the height of the sharechain afterwards
+type Reorg struct {
+	Reverted  int
+	Applied   int
+	Height    types.BlockHeight
+	Timestamp types.Timestamp
+}
+
+func (ShareAccepted) event() {}
+func (BlockFound) event()    {}
+func (BlockOrphaned) event() {}
+func (Payout) event()        {}
+func (Reorg) event()         {}
+
+//Bus passes every published event to all subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+//NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+//Publish passes an event to the subscribers, it never blocks. Publishing on a nil bus does nothing.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subscribers {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+//Subscribe returns a subscription that receives the events published from now on for which filter returns true,
+// or all of them if filter is nil. Buffer is the number of events that are kept for it before new ones are dropped.
+func (b *Bus) Subscribe(buffer int, filter func(Event) bool) *Subscription {
+	s := &Subscription{bus: b, events: make(chan Event, buffer), filter: filter}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+//Subscription receives the events of a bus
+type Subscription struct {
+	// dropped is accessed atomically, it comes first to be 64-bit aligned on
+	// 32-bit platforms.
+	dropped uint64
+	bus     *Bus
+	events  chan Event
+	filter  func(Event) bool
+}
+
+//Events returns the channel of the events, it is closed when the subscription is closed
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+//Dropped returns the number of events that were dropped because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Close stops the subscription, it can be called more than once
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
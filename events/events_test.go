@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestFanOut(t *testing.T) {
+	bus := NewBus()
+	first, second := bus.Subscribe(10, nil), bus.Subscribe(10, nil)
+	bus.Publish(BlockFound{BlockID: types.BlockID{1}})
+	bus.Publish(Payout{Address: types.UnlockHash{2}})
+	for _, s := range []*Subscription{first, second} {
+		if e, ok := (<-s.Events()).(BlockFound); !ok || e.BlockID != (types.BlockID{1}) {
+			t.Error("Expected the found block first, got", e)
+		}
+		if e, ok := (<-s.Events()).(Payout); !ok || e.Address != (types.UnlockHash{2}) {
+			t.Error("Expected the payout second, got", e)
+		}
+	}
+
+	first.Close()
+	first.Close()
+	if _, open := <-first.Events(); open {
+		t.Error("Events of a closed subscription not closed")
+	}
+	bus.Publish(Reorg{Reverted: 1})
+	if e := <-second.Events(); e != (Reorg{Reverted: 1}) {
+		t.Error("Expected the reorg, got", e)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	bus := NewBus()
+	payouts := bus.Subscribe(1, func(e Event) bool {
+		_, ok := e.(Payout)
+		return ok
+	})
+	bus.Publish(ShareAccepted{})
+	bus.Publish(Payout{Value: types.NewCurrency64(1)})
+	bus.Publish(ShareAccepted{})
+	if e, ok := (<-payouts.Events()).(Payout); !ok || len(payouts.Events()) != 0 || payouts.Dropped() != 0 {
+		t.Error("Expected only the payout, got", e, "and", len(payouts.Events()), "more")
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	slow, fast := bus.Subscribe(1, nil), bus.Subscribe(100, nil)
+	// The slow subscriber does not read, publishing continues regardless.
+	for i := 0; i < 100; i++ {
+		bus.Publish(ShareAccepted{Miner: "a"})
+	}
+	if dropped := slow.Dropped(); dropped != 99 {
+		t.Error("Expected 99 dropped events for the slow subscriber, got", dropped)
+	}
+	if dropped := fast.Dropped(); dropped != 0 || len(fast.Events()) != 100 {
+		t.Error("Expected all events for the fast subscriber, got", len(fast.Events()), "and", dropped, "dropped")
+	}
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	bus := NewBus()
+	s := bus.Subscribe(1000, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish(ShareAccepted{})
+			}
+		}()
+	}
+	closer := bus.Subscribe(0, nil)
+	closer.Close()
+	wg.Wait()
+	if n := len(s.Events()); n != 1000 {
+		t.Error("Expected 1000 events, got", n)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(ShareAccepted{})
+}
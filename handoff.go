@@ -0,0 +1,108 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// listenersEnv names the listeners a new process inherits on a binary
+	// upgrade, in the order of their file descriptors starting at 3.
+	listenersEnv = "P2POOL_LISTENERS"
+	// parentEnv is the pid of the process that handed off its listeners, the
+	// new process waits for it to exit before it opens the databases.
+	parentEnv = "P2POOL_HANDOFF_PARENT"
+)
+
+// handoffSignal triggers the handoff of the listeners to a new process.
+var handoffSignal os.Signal = syscall.SIGUSR2
+
+// fileListener is a listener that has a file descriptor.
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// listenerFile turns a function returning the file descriptor of a listener
+// into a fileListener.
+type listenerFile func() (*os.File, error)
+
+func (f listenerFile) File() (*os.File, error) { return f() }
+
+// inheritedListeners returns the listeners handed off by the previous
+// process, keyed by name. It returns nothing if the process was started
+// normally.
+func inheritedListeners() (listeners map[string]net.Listener, err error) {
+	listeners = make(map[string]net.Listener)
+	names := os.Getenv(listenersEnv)
+	if names == "" {
+		return
+	}
+	os.Unsetenv(listenersEnv)
+	for i, name := range strings.Split(names, ",") {
+		f := os.NewFile(uintptr(3+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.New("invalid inherited listener " + name + ": " + err.Error())
+		}
+		listeners[name] = l
+	}
+	return
+}
+
+// waitForParent blocks until the process that handed off the listeners has
+// exited and released the databases, or until the timeout passed.
+func waitForParent(timeout time.Duration) error {
+	pid, err := strconv.Atoi(os.Getenv(parentEnv))
+	os.Unsetenv(parentEnv)
+	if err != nil {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			return errors.New("previous process " + strconv.Itoa(pid) + " is still running")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+// handoff starts a new instance of the binary with the same arguments that
+// inherits the listeners. The caller has to drain and exit afterwards.
+func handoff(names []string, listeners []fileListener) (*os.Process, error) {
+	executable, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*os.File, 0, len(listeners))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		f, err := l.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(), listenersEnv+"="+strings.Join(names, ","), parentEnv+"="+strconv.Itoa(os.Getpid()))
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// handoffSignal is nil, there is no signal to trigger a handoff on windows.
+var handoffSignal os.Signal
+
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+type listenerFile func() (*os.File, error)
+
+func (f listenerFile) File() (*os.File, error) { return f() }
+
+func inheritedListeners() (map[string]net.Listener, error) {
+	return make(map[string]net.Listener), nil
+}
+
+func waitForParent(timeout time.Duration) error {
+	return nil
+}
+
+func handoff(names []string, listeners []fileListener) (*os.Process, error) {
+	return nil, errors.New("handing off the listeners is not supported on windows")
+}
@@ -0,0 +1,186 @@
+//Package loadtest simulates stratum miners against a running node to benchmark it
+package loadtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//DefaultTimeout is the default time a simulated miner waits for the reply to a request
+const DefaultTimeout = 10 * time.Second
+
+//UserAgent is sent with mining.subscribe so the node can tell simulated miners apart in its logs
+const UserAgent = "p2pool-loadtest/0.1"
+
+//Config holds the settings of a load test
+type Config struct {
+	//Address is the stratum address of the node, host:port
+	Address string
+	//Miners is the number of simulated miners running at the same time
+	Miners int
+	//Duration is the time the load test runs
+	Duration time.Duration
+	//User is the mining address the simulated miners authorize with, every miner gets its own rigname
+	User string
+	//Timeout is the time a miner waits for the reply to a request, DefaultTimeout is used if it is 0
+	Timeout time.Duration
+}
+
+//Report is the outcome of a load test
+type Report struct {
+	Requests int
+	//Rejected is the number of requests the node replied an error or false to
+	Rejected int
+	//Failed is the number of requests without a reply because the connection failed or timed out
+	Failed int
+	//Latencies of the replied requests, the percentiles are zero if no request was replied
+	P50, P90, P99, Max time.Duration
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%v requests, %v rejected, %v failed, latency p50 %v p90 %v p99 %v max %v",
+		r.Requests, r.Rejected, r.Failed, r.P50, r.P90, r.P99, r.Max)
+}
+
+type message struct {
+	Method string        `json:"method,omitempty"`
+	Params []interface{} `json:"params,omitempty"`
+	ID     uint64        `json:"id,omitempty"`
+	Result interface{}   `json:"result,omitempty"`
+	Error  []interface{} `json:"error,omitempty"`
+}
+
+// result is the outcome of a single request of a simulated miner.
+type result struct {
+	latency  time.Duration
+	rejected bool
+	failed   bool
+}
+
+//Run starts the simulated miners and returns the report once the Duration has passed.
+// Every miner connects, subscribes and authorizes, disconnects and starts again until the load test is over.
+func Run(config Config) (report Report, err error) {
+	if config.Miners <= 0 {
+		return report, errors.New("at least one miner is required")
+	}
+	if config.Duration <= 0 {
+		return report, errors.New("the duration has to be positive")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(config.Duration)
+	results := make(chan result, config.Miners)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Miners; i++ {
+		wg.Add(1)
+		go func(rig string) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				handshake(config, rig, results)
+			}
+		}(config.User + ".loadtest" + strconv.Itoa(i))
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var latencies []time.Duration
+	for r := range results {
+		report.Requests++
+		switch {
+		case r.failed:
+			report.Failed++
+		case r.rejected:
+			report.Rejected++
+		}
+		if !r.failed {
+			latencies = append(latencies, r.latency)
+		}
+	}
+	report.P50, report.P90, report.P99, report.Max = percentiles(latencies)
+	return
+}
+
+// handshake opens a connection, subscribes and authorizes as user and closes
+// the connection again. The outcome of every request is sent on results.
+func handshake(config Config, user string, results chan<- result) {
+	conn, err := net.DialTimeout("tcp", config.Address, config.Timeout)
+	if err != nil {
+		results <- result{failed: true}
+		// Do not hammer a node that refuses connections.
+		time.Sleep(100 * time.Millisecond)
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	requests := []message{
+		{ID: 1, Method: "mining.subscribe", Params: []interface{}{UserAgent}},
+		{ID: 2, Method: "mining.authorize", Params: []interface{}{user, "x"}},
+	}
+	for _, request := range requests {
+		r := call(conn, reader, request, config.Timeout)
+		results <- r
+		if r.failed || r.rejected {
+			return
+		}
+	}
+}
+
+// call sends a request and waits for its reply, notifications sent by the
+// node in the meantime are skipped.
+func call(conn net.Conn, reader *bufio.Reader, request message, timeout time.Duration) result {
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return result{failed: true}
+	}
+	start := time.Now()
+	conn.SetDeadline(start.Add(timeout))
+	if _, err = conn.Write(append(raw, '\n')); err != nil {
+		return result{failed: true}
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return result{failed: true}
+		}
+		var reply message
+		if err = json.Unmarshal([]byte(line), &reply); err != nil {
+			return result{failed: true}
+		}
+		if reply.Method != "" || reply.ID != request.ID {
+			continue
+		}
+		return result{
+			latency:  time.Since(start),
+			rejected: reply.Error != nil || reply.Result == false,
+		}
+	}
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+
+// percentiles returns the 50th, 90th and 99th percentile and the maximum of
+// the latencies.
+func percentiles(latencies []time.Duration) (p50, p90, p99, max time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Sort(durations(latencies))
+	at := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+	return at(50), at(90), at(99), latencies[len(latencies)-1]
+}
@@ -0,0 +1,58 @@
+package loadtest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/siapool/p2pool/stratum"
+)
+
+func TestPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	p50, p90, p99, max := percentiles(latencies)
+	if p50 != 50*time.Millisecond || p90 != 90*time.Millisecond || p99 != 99*time.Millisecond || max != 100*time.Millisecond {
+		t.Error("Unexpected percentiles", p50, p90, p99, max)
+	}
+	if p50, _, _, max = percentiles(nil); p50 != 0 || max != 0 {
+		t.Error("Expected zero percentiles without latencies, got", p50, max)
+	}
+}
+
+func TestRun(t *testing.T) {
+	// Serve the connections with a stratum server without a sharechain.
+	server := stratum.NewServer("", nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go server.NewClientConnection(conn).Listen()
+		}
+	}()
+	address := lis.Addr().String()
+
+	report, err := Run(Config{Address: address, Miners: 4, Duration: 200 * time.Millisecond, User: "miner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Rejected != 0 || report.Failed != 0 {
+		t.Error("Expected only accepted requests, got", report)
+	}
+	if report.P50 == 0 || report.P50 > report.Max {
+		t.Error("Unexpected latencies", report)
+	}
+
+	if _, err = Run(Config{Address: address, Duration: time.Second}); err == nil {
+		t.Error("Load test without miners started")
+	}
+}
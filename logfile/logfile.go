@@ -0,0 +1,148 @@
+//Package logfile implements a log file that is rotated when it reaches a maximum size.
+// Rotated files are kept next to the log file with the time of the rotation appended to the name,
+// the oldest are removed once there are more than MaxBackups or they are older than MaxAge.
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//backupTimeFormat is appended to the filename of a rotated log file, it sorts in chronological order
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+//Writer appends to the log file and rotates it, it is safe for concurrent use.
+// Every Write ends up in a single file so log lines are never split over two files.
+type Writer struct {
+	//Filename is the path of the log file
+	Filename string
+	//MaxSize is the size in bytes after which the log file is rotated, 0 disables rotation
+	MaxSize int64
+	//MaxBackups is the number of rotated files that are kept, 0 keeps all of them
+	MaxBackups int
+	//MaxAge is the time rotated files are kept, 0 keeps them regardless of their age
+	MaxAge time.Duration
+
+	mutex sync.Mutex // protects following
+	file  *os.File
+	size  int64
+}
+
+//Write appends p to the log file, the file is rotated first if p would make it exceed the MaxSize
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		if err = w.open(); err != nil {
+			return
+		}
+	}
+	if w.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.MaxSize {
+		if err = w.rotate(time.Now()); err != nil {
+			return
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return
+}
+
+//Close closes the log file, a later Write opens it again
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open opens the log file for appending.
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.Filename), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current log file aside, opens a new one and removes the
+// backups that are no longer kept.
+func (w *Writer) rotate(now time.Time) error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	// Never overwrite a backup of a rotation within the same millisecond.
+	backup := w.Filename + "." + now.UTC().Format(backupTimeFormat)
+	for rotated := now; fileExists(backup); {
+		rotated = rotated.Add(time.Millisecond)
+		backup = w.Filename + "." + rotated.UTC().Format(backupTimeFormat)
+	}
+	if err := os.Rename(w.Filename, backup); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.removeBackups(now)
+}
+
+// backups returns the rotated log files, the oldest first.
+func (w *Writer) backups() (backups []string, err error) {
+	backups, err = filepath.Glob(w.Filename + ".*")
+	if err != nil {
+		return
+	}
+	prefix := w.Filename + "."
+	filtered := backups[:0]
+	for _, backup := range backups {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(backup, prefix)); err == nil {
+			filtered = append(filtered, backup)
+		}
+	}
+	sort.Strings(filtered)
+	return filtered, nil
+}
+
+// removeBackups removes the rotated files beyond MaxBackups or older than
+// MaxAge.
+func (w *Writer) removeBackups(now time.Time) error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	prefix := w.Filename + "."
+	for i, backup := range backups {
+		remove := w.MaxBackups > 0 && len(backups)-i > w.MaxBackups
+		if rotated, err := time.Parse(backupTimeFormat, strings.TrimPrefix(backup, prefix)); err == nil && w.MaxAge > 0 && now.Sub(rotated) > w.MaxAge {
+			remove = true
+		}
+		if remove {
+			if err := os.Remove(backup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
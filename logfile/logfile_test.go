@@ -0,0 +1,82 @@
+package logfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := &Writer{Filename: filepath.Join(dir, "p2pool.log"), MaxSize: 100, MaxBackups: 2}
+	defer w.Close()
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Write(line); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Error("Expected 2 backups, got", backups)
+	}
+	for _, file := range append(backups, w.Filename) {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) > 100 {
+			t.Error(file, "exceeds the maximum size:", len(content))
+		}
+		// Lines are never split over two files.
+		if len(bytes.Replace(content, line, nil, -1)) != 0 {
+			t.Error(file, "contains a partial line")
+		}
+	}
+}
+
+func TestRemoveOldBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := &Writer{Filename: filepath.Join(dir, "p2pool.log"), MaxAge: time.Hour}
+	now := time.Now()
+	old := w.Filename + "." + now.Add(-2*time.Hour).UTC().Format(backupTimeFormat)
+	recent := w.Filename + "." + now.Add(-time.Minute).UTC().Format(backupTimeFormat)
+	unrelated := w.Filename + ".bck"
+	for _, file := range []string{old, recent, unrelated} {
+		if err = ioutil.WriteFile(file, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.removeBackups(now); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]bool{old: false, recent: true, unrelated: true} {
+		if _, err := os.Stat(file); (err == nil) != expected {
+			t.Error("Expected", file, "to exist", expected)
+		}
+	}
+}
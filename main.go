@@ -1,18 +1,30 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
 	"github.com/gorilla/mux"
 	"github.com/siapool/p2pool/api"
+	"github.com/siapool/p2pool/loadtest"
+	"github.com/siapool/p2pool/logfile"
 	"github.com/siapool/p2pool/sharechain"
 	"github.com/siapool/p2pool/siad"
 	"github.com/siapool/p2pool/stratum"
+	"github.com/siapool/p2pool/webhooks"
 )
 
 func main() {
@@ -23,16 +35,57 @@ func main() {
 
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 
-	var debugLogging bool
-	var bindAddress, apiAddr, rpcAddr, stratumAddress string
-	var poolFee int
+	var debugLogging, apiProbesAtRoot, apiCompress, webUI, recoverDB, requireAuthorization, hideInactiveMiners, selfCheckHalt, initOnly bool
+	var webhooksHTTPSOnly, webhooksAllowInternal, forgetPoolPeers, journal, logRejects bool
+	var bindAddress, apiAddr, rpcAddr, stratumAddress, feeAddress, adminPassword, apiPrefix, apiUnit, motd, network, duplicateWorkers, pplnsWindow, lateShares, shareWeighting, mempoolUnavailable, shareStrictness string
+	var dataDir, consensusDir, gatewayDir, sharechainDir, logFile string
+	var logMaxSize, logMaxBackups int
+	var logMaxAge time.Duration
+	var poolFee, blockMaturity, maxReorgDepth, shareBatchSize, consensusQueueSize, minPeers, maxConnections, maxConnectionsPerIP, maxMinerHistories, submitAttempts, solverWeight, maxConsensusLag, maxTemplateSize, maxTemplateTransactions int
+	var keepaliveInterval, shareFlushInterval, slowTemplateThreshold, staleGraceWindow, clockSkewTolerance time.Duration
+	var acceptInterval, maxAcceptInterval, inactiveMinerRetention, handoffDrain, selfCheckInterval, submitTimeout, templateRefreshInterval, mempoolTimeout, rejectRetention time.Duration
+	var startDifficulty, maxDifficulty, fixedDifficulty, maxShareDifficulty, shareRatio float64
+	var poolFeeAddress types.UnlockHash
+	disabledEndpoints := &cli.StringSlice{}
+	apiCacheTTLs := &cli.StringSlice{}
+	checkpointFlags := &cli.StringSlice{}
+	difficultyBands := &cli.StringSlice{}
+	poolPeerFlags := &cli.StringSlice{}
 
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "network",
+			Usage:       "Sia network the pool mines on (standard, dev or testing), it has to match the network the binary is built for",
+			Value:       "standard",
+			Destination: &network,
+		},
 		cli.BoolFlag{
 			Name:        "debug, d",
 			Usage:       "Enable debug logging",
 			Destination: &debugLogging,
 		},
+		cli.StringFlag{
+			Name:        "log-file",
+			Usage:       "File the log is written to in addition to stderr",
+			Destination: &logFile,
+		},
+		cli.IntFlag{
+			Name:        "log-max-size",
+			Usage:       "Size in MB after which the log file is rotated (0 to disable rotation)",
+			Value:       100,
+			Destination: &logMaxSize,
+		},
+		cli.IntFlag{
+			Name:        "log-max-backups",
+			Usage:       "Number of rotated log files that are kept (0 to keep all)",
+			Value:       5,
+			Destination: &logMaxBackups,
+		},
+		cli.DurationFlag{
+			Name:        "log-max-age",
+			Usage:       "Time rotated log files are kept (0 to keep them regardless of their age)",
+			Destination: &logMaxAge,
+		},
 		cli.StringFlag{
 			Name:        "bind, b",
 			Usage:       "Pool public api bind address",
@@ -47,10 +100,370 @@ func main() {
 		},
 		cli.IntFlag{
 			Name:        "fee, f",
-			Usage:       "Pool fee, in 0.01%",
+			Usage:       "Pool fee, in 0.01%, between 0 and 10000",
 			Value:       200,
 			Destination: &poolFee,
 		},
+		cli.StringFlag{
+			Name:        "fee-address",
+			Usage:       "Address the pool fee is paid to",
+			Destination: &feeAddress,
+		},
+		cli.IntFlag{
+			Name:        "block-maturity",
+			Usage:       "Number of confirmations before the payouts of a found block are final",
+			Value:       sharechain.DefaultBlockMaturity,
+			Destination: &blockMaturity,
+		},
+		cli.IntFlag{
+			Name:        "max-reorg-depth",
+			Usage:       "Maximum number of blocks a reorg can revert, the accounting stops and /health/ready fails on deeper reorgs until the node is restarted with a higher limit",
+			Value:       sharechain.DefaultMaxReorgDepth,
+			Destination: &maxReorgDepth,
+		},
+		cli.DurationFlag{
+			Name:        "share-flush-interval",
+			Usage:       "Maximum time accepted shares are buffered before they are written to disk",
+			Value:       sharechain.DefaultShareFlushInterval,
+			Destination: &shareFlushInterval,
+		},
+		cli.DurationFlag{
+			Name:        "slow-template-threshold",
+			Usage:       "Build time of a block template above which a warning is logged to the sharechain log",
+			Value:       sharechain.DefaultSlowTemplateThreshold,
+			Destination: &slowTemplateThreshold,
+		},
+		cli.IntFlag{
+			Name:        "share-batch-size",
+			Usage:       "Number of buffered shares that triggers a write to disk before the flush interval",
+			Value:       sharechain.DefaultShareBatchSize,
+			Destination: &shareBatchSize,
+		},
+		cli.Float64Flag{
+			Name:        "start-difficulty",
+			Usage:       "Difficulty assigned to new stratum connections, the sharechain difficulty is used if lower",
+			Destination: &startDifficulty,
+		},
+		cli.Float64Flag{
+			Name:        "max-difficulty",
+			Usage:       "Highest difficulty a miner can request with mining.suggest_difficulty (0 for no limit)",
+			Destination: &maxDifficulty,
+		},
+		cli.Float64Flag{
+			Name:        "fixed-difficulty",
+			Usage:       "Difficulty assigned to every stratum connection, difficulty suggestions and --start-difficulty are ignored (0 to disable), the sharechain difficulty is used if higher",
+			Destination: &fixedDifficulty,
+		},
+		cli.Float64Flag{
+			Name:        "max-share-difficulty",
+			Usage:       "Highest difficulty of any stratum connection, including --start-difficulty, --fixed-difficulty and suggestions, so large miners submit shares regularly (0 for no cap), the sharechain difficulty is used if higher",
+			Destination: &maxShareDifficulty,
+		},
+		cli.StringSliceFlag{
+			Name:  "difficulty-band",
+			Usage: "Minimum difficulty of the connections of a miner address that reaches a hashrate, given as <hashes per second>:<difficulty>, applied during the first minutes of a connection, can be repeated",
+			Value: difficultyBands,
+		},
+		cli.StringFlag{
+			Name:        "motd",
+			Usage:       "Message shown to miners when they connect (client.show_message), at most 256 bytes, it can be changed at runtime through the admin api",
+			Destination: &motd,
+		},
+		cli.DurationFlag{
+			Name:        "stale-grace-window",
+			Usage:       "Time shares for the previous job are still accepted after a new job is sent to the miners",
+			Value:       stratum.DefaultStaleGraceWindow,
+			Destination: &staleGraceWindow,
+		},
+		cli.DurationFlag{
+			Name:        "clock-skew-tolerance",
+			Usage:       "Maximum difference between the timestamp of a share and the time of the node, shares outside it are rejected (0 to disable)",
+			Value:       stratum.DefaultClockSkewTolerance,
+			Destination: &clockSkewTolerance,
+		},
+		cli.StringFlag{
+			Name:        "share-strictness",
+			Usage:       "What to do with borderline shares (near-stale or slightly skewed): strict (reject), standard (flag near-stale, reject skewed), flag (flag both) or lenient (accept both)",
+			Value:       string(stratum.StrictnessStandard),
+			Destination: &shareStrictness,
+		},
+		cli.DurationFlag{
+			Name:        "keepalive",
+			Usage:       "Idle time after which stratum clients are pinged, clients not responding within the same interval are disconnected (0 to disable)",
+			Value:       5 * time.Minute,
+			Destination: &keepaliveInterval,
+		},
+		cli.BoolFlag{
+			Name:        "require-authorization",
+			Usage:       "Only accept miners with an address authorized through the admin api, for private pools",
+			Destination: &requireAuthorization,
+		},
+		cli.IntFlag{
+			Name:        "max-connections",
+			Usage:       "Maximum number of simultaneous stratum connections, new connections are dropped above it",
+			Value:       stratum.DefaultMaxConnections,
+			Destination: &maxConnections,
+		},
+		cli.IntFlag{
+			Name:        "max-connections-per-ip",
+			Usage:       "Maximum number of simultaneous stratum connections from a single ip address (0 for no limit)",
+			Value:       stratum.DefaultMaxConnectionsPerIP,
+			Destination: &maxConnectionsPerIP,
+		},
+		cli.DurationFlag{
+			Name:        "accept-interval",
+			Usage:       "Minimum time between accepting two stratum connections, doubled for every short lived connection",
+			Value:       stratum.DefaultAcceptInterval,
+			Destination: &acceptInterval,
+		},
+		cli.DurationFlag{
+			Name:        "max-accept-interval",
+			Usage:       "Upper bound of the time between accepting two stratum connections during a connection flood",
+			Value:       stratum.DefaultMaxAcceptInterval,
+			Destination: &maxAcceptInterval,
+		},
+		cli.DurationFlag{
+			Name:        "handoff-drain",
+			Usage:       "Time the stratum connections get to finish after the listeners are handed off to a new process with SIGUSR2",
+			Value:       5 * time.Second,
+			Destination: &handoffDrain,
+		},
+		cli.IntFlag{
+			Name:        "max-miner-histories",
+			Usage:       "Maximum number of miner addresses for which the hashrate history is kept, the least recently active is evicted first",
+			Value:       stratum.DefaultMaxMinerHistories,
+			Destination: &maxMinerHistories,
+		},
+		cli.DurationFlag{
+			Name:        "inactive-miner-retention",
+			Usage:       "Time the statistics of a disconnected miner are kept and listed as inactive",
+			Value:       stratum.DefaultInactiveMinerRetention,
+			Destination: &inactiveMinerRetention,
+		},
+		cli.BoolFlag{
+			Name:        "hide-inactive-miners",
+			Usage:       "Only list miners with an open connection in the miner statistics",
+			Destination: &hideInactiveMiners,
+		},
+		cli.Float64Flag{
+			Name:        "share-ratio",
+			Usage:       "Share difficulty as a fraction of the network difficulty (0 for the fixed start difficulty), shares are never easier than the start difficulty",
+			Destination: &shareRatio,
+		},
+		cli.StringFlag{
+			Name:        "pplns-window",
+			Usage:       "Number of shares in the pplns window of a block (8640) or a multiple of the expected shares per block (2x), changes only apply to blocks found afterwards",
+			Value:       strconv.Itoa(sharechain.DefaultPPLNSWindow),
+			Destination: &pplnsWindow,
+		},
+		cli.StringFlag{
+			Name:        "late-shares",
+			Usage:       "What to do with a share for the job of a block the pool just found that arrives after the find: next (count it toward the next block) or drop",
+			Value:       string(sharechain.LateSharesNext),
+			Destination: &lateShares,
+		},
+		cli.StringFlag{
+			Name:        "share-weighting",
+			Usage:       "How much the shares count in the pplns distribution: linear (every share once) or solver (the share that solved the block counts --solver-weight times)",
+			Value:       string(sharechain.WeightingLinear),
+			Destination: &shareWeighting,
+		},
+		cli.IntFlag{
+			Name:        "solver-weight",
+			Usage:       "Number of shares the share that solved a block counts for with --share-weighting solver",
+			Value:       sharechain.DefaultSolverWeight,
+			Destination: &solverWeight,
+		},
+		cli.StringFlag{
+			Name:        "duplicate-workers",
+			Usage:       "What to do when a worker name connects more than once: merge (share the statistics), rename (number the new connection) or reject",
+			Value:       string(stratum.DuplicateWorkersMerge),
+			Destination: &duplicateWorkers,
+		},
+		cli.StringSliceFlag{
+			Name:  "disable-endpoints",
+			Usage: "Pool api endpoint that is not served, can be repeated",
+			Value: disabledEndpoints,
+		},
+		cli.BoolFlag{
+			Name:        "compress",
+			Usage:       "Gzip api responses of at least 1KB for clients that accept it",
+			Destination: &apiCompress,
+		},
+		cli.BoolFlag{
+			Name:        "web-ui",
+			Usage:       "Serve a read-only status page of the pool, miners and found blocks at the root of the public api",
+			Destination: &webUI,
+		},
+		cli.BoolFlag{
+			Name:        "webhooks-https-only",
+			Usage:       "Only accept https urls for miner webhooks",
+			Destination: &webhooksHTTPSOnly,
+		},
+		cli.BoolFlag{
+			Name:        "webhooks-allow-internal",
+			Usage:       "Allow miner webhooks on loopback, private and link-local addresses",
+			Destination: &webhooksAllowInternal,
+		},
+		cli.StringSliceFlag{
+			Name:  "api-cache-ttl",
+			Usage: "Time the responses of a public pool api endpoint are cached given as <path>=<duration> (0 to disable), can be repeated",
+			Value: apiCacheTTLs,
+		},
+		cli.IntFlag{
+			Name:        "min-peers",
+			Usage:       "Number of gateway peers required before work is handed out to the miners (0 to disable)",
+			Value:       sharechain.DefaultMinPeers,
+			Destination: &minPeers,
+		},
+		cli.StringSliceFlag{
+			Name:  "pool-peer",
+			Usage: "Address of another node of the pool network the gateway connects to, in addition to the Sia bootstrap peers, can be repeated",
+			Value: poolPeerFlags,
+		},
+		cli.BoolFlag{
+			Name:        "forget-pool-peers",
+			Usage:       "Do not store the peers that relay valid shares, after a restart only the --pool-peer addresses are connected",
+			Destination: &forgetPoolPeers,
+		},
+		cli.DurationFlag{
+			Name:        "submit-timeout",
+			Usage:       "Time a single submission of a found block to the consensus set can take before it is retried",
+			Value:       sharechain.DefaultSubmitTimeout,
+			Destination: &submitTimeout,
+		},
+		cli.IntFlag{
+			Name:        "submit-attempts",
+			Usage:       "Number of times a found block is submitted before it is recorded as submissionfailed",
+			Value:       sharechain.DefaultSubmitAttempts,
+			Destination: &submitAttempts,
+		},
+		cli.DurationFlag{
+			Name:        "template-refresh-interval",
+			Usage:       "Age at which the block template is rebuilt with the current transactions, miners keep their work on the previous template (0 to only rebuild on a new block)",
+			Value:       sharechain.DefaultTemplateRefreshInterval,
+			Destination: &templateRefreshInterval,
+		},
+		cli.IntFlag{
+			Name:        "max-template-size",
+			Usage:       "Size in bytes a block template can have, the transactions with the highest fee per byte are included first",
+			Value:       int(types.BlockSizeLimit),
+			Destination: &maxTemplateSize,
+		},
+		cli.IntFlag{
+			Name:        "max-template-transactions",
+			Usage:       "Number of transactions a block template can have (0 for no limit beyond the size)",
+			Destination: &maxTemplateTransactions,
+		},
+		cli.DurationFlag{
+			Name:        "mempool-timeout",
+			Usage:       "Time listing the transactions of the transaction pool can take while building a block template",
+			Value:       sharechain.DefaultMempoolTimeout,
+			Destination: &mempoolTimeout,
+		},
+		cli.StringFlag{
+			Name:        "mempool-unavailable",
+			Usage:       "What to do with a block template while the transaction pool fails or times out: empty (hand out work without transactions) or fail",
+			Value:       string(sharechain.MempoolEmpty),
+			Destination: &mempoolUnavailable,
+		},
+		cli.DurationFlag{
+			Name:        "self-check-interval",
+			Usage:       "Time between two consistency checks of the sharechain, a failed check makes /health/ready fail (0 to disable)",
+			Value:       sharechain.DefaultSelfCheckInterval,
+			Destination: &selfCheckInterval,
+		},
+		cli.BoolFlag{
+			Name:        "init-only",
+			Usage:       "Create the data directories and databases, then exit without serving miners, for provisioning",
+			Destination: &initOnly,
+		},
+		cli.BoolFlag{
+			Name:        "journal",
+			Usage:       "Journal the accepted shares that are not written to the sharechain database yet, they are replayed after a crash",
+			Destination: &journal,
+		},
+		cli.BoolFlag{
+			Name:        "log-rejects",
+			Usage:       "Record every rejected share with its miner, reason and job in the reject log of /audit/rejects",
+			Destination: &logRejects,
+		},
+		cli.DurationFlag{
+			Name:        "reject-retention",
+			Usage:       "Time rejected shares are kept in the reject log",
+			Value:       sharechain.DefaultRejectRetention,
+			Destination: &rejectRetention,
+		},
+		cli.BoolFlag{
+			Name:        "self-check-halt",
+			Usage:       "Stop the accounting and the payouts when a consistency check of the sharechain fails",
+			Destination: &selfCheckHalt,
+		},
+		cli.IntFlag{
+			Name:        "consensus-queue-size",
+			Usage:       "Number of consensus changes queued for processing, the embedded siad waits for the pool when the queue is full",
+			Value:       sharechain.DefaultConsensusQueueSize,
+			Destination: &consensusQueueSize,
+		},
+		cli.IntFlag{
+			Name:        "max-consensus-lag",
+			Usage:       "Number of blocks the processing of the consensus changes can lag behind the embedded siad before /health/ready reports the node degraded (0 to disable)",
+			Value:       sharechain.DefaultMaxConsensusLag,
+			Destination: &maxConsensusLag,
+		},
+		cli.StringSliceFlag{
+			Name:  "checkpoint",
+			Usage: "Trusted share given as <height>:<share block id> the sharechain database has to match, can be repeated",
+			Value: checkpointFlags,
+		},
+		cli.StringFlag{
+			Name:        "datadir",
+			Value:       "p2pooldata",
+			Usage:       "Directory the pool and the embedded siad store their data in",
+			Destination: &dataDir,
+		},
+		cli.StringFlag{
+			Name:        "consensus-dir",
+			Usage:       "Directory of the consensus database, defaults to siad/consensus in the datadir",
+			Destination: &consensusDir,
+		},
+		cli.StringFlag{
+			Name:        "gateway-dir",
+			Usage:       "Directory of the gateway data, defaults to siad/gateway in the datadir",
+			Destination: &gatewayDir,
+		},
+		cli.StringFlag{
+			Name:        "sharechain-dir",
+			Usage:       "Directory of the sharechain database, defaults to sharechain in the datadir",
+			Destination: &sharechainDir,
+		},
+		cli.BoolFlag{
+			Name:        "recover",
+			Usage:       "Move an unreadable sharechain database aside and restore the backup in the sharechain directory, or start empty without a backup",
+			Destination: &recoverDB,
+		},
+		cli.StringFlag{
+			Name:        "api-prefix",
+			Usage:       "Path the pool api is served under, for a reverse proxy that mounts it on a subpath (for example /pool1)",
+			Destination: &apiPrefix,
+		},
+		cli.StringFlag{
+			Name:        "api-unit",
+			Value:       api.UnitHastings,
+			Usage:       "Default unit of amounts in pool api responses, SC or H (hastings), can be overridden per request with ?unit=",
+			Destination: &apiUnit,
+		},
+		cli.BoolFlag{
+			Name:        "api-probes-at-root",
+			Usage:       "Serve the monitoring endpoints like /metrics at the root instead of under the api prefix",
+			Destination: &apiProbesAtRoot,
+		},
+		cli.StringFlag{
+			Name:        "admin-password",
+			Usage:       "Password for the admin endpoints of the pool api (http basic auth), they are disabled if not set",
+			EnvVar:      "SIAPOOL_ADMIN_PASSWORD",
+			Destination: &adminPassword,
+		},
 		cli.StringFlag{
 			Name:  "api-addr",
 			Value: "localhost:9980", Usage: "which host:port the API server listens on",
@@ -64,12 +477,145 @@ func main() {
 		},
 	}
 
+	app.Commands = []cli.Command{
+		{
+			Name:  "recompute",
+			Usage: "Recompute the earnings of all miners from the stored shares and found blocks, the node can not be running",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "fix",
+					Usage: "Replace the stored earnings by the recomputed ones",
+				},
+			},
+			Action: func(c *cli.Context) {
+				discrepancies, err := sharechain.Recompute(sharechainDir, sharechain.Config{
+					Fee:        poolFee,
+					FeeAddress: poolFeeAddress,
+				}, c.Bool("fix"))
+				if err != nil {
+					log.Fatal("Error recomputing earnings: ", err)
+				}
+				for _, d := range discrepancies {
+					log.Warnln("Address", d.Address, "has stored earnings of", d.Stored, "instead of", d.Expected)
+				}
+				switch {
+				case len(discrepancies) == 0:
+					log.Infoln("No discrepancies found")
+				case c.Bool("fix"):
+					log.Infoln("Fixed", len(discrepancies), "discrepancies")
+				default:
+					log.Infoln("Found", len(discrepancies), "discrepancies, use --fix to replace the stored earnings")
+				}
+			},
+		},
+		{
+			Name:      "backup",
+			Usage:     "Snapshot the sharechain database of the running node through the admin api, it is restored by --recover if written to the default file",
+			ArgsUsage: "[file]",
+			Action: func(c *cli.Context) {
+				file := c.Args().First()
+				if file == "" {
+					file = filepath.Join(sharechainDir, sharechain.BackupFilename)
+				}
+				if err := backup(bindAddress, apiPrefix, adminPassword, file); err != nil {
+					log.Fatal("Error creating backup: ", err)
+				}
+				log.Infoln("Sharechain database backed up to", file)
+			},
+		},
+		{
+			Name:      "restore",
+			Usage:     "Replace the sharechain database by a backup, the node can not be running",
+			ArgsUsage: "<file>",
+			Action: func(c *cli.Context) {
+				if c.Args().First() == "" {
+					log.Fatal("The backup file to restore is required")
+				}
+				if err := sharechain.Restore(sharechainDir, c.Args().First()); err != nil {
+					log.Fatal("Error restoring backup: ", err)
+				}
+				log.Infoln("Sharechain database restored from", c.Args().First())
+			},
+		},
+		{
+			Name:  "loadtest",
+			Usage: "Benchmark a running node with simulated stratum miners that connect, subscribe and authorize over and over",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "url",
+					Usage: "Stratum address of the node",
+					Value: "localhost:3333",
+				},
+				cli.IntFlag{
+					Name:  "miners",
+					Usage: "Number of simulated miners running at the same time",
+					Value: 100,
+				},
+				cli.DurationFlag{
+					Name:  "duration",
+					Usage: "Time the load test runs",
+					Value: time.Minute,
+				},
+				cli.StringFlag{
+					Name:  "user",
+					Usage: "Mining address the simulated miners authorize with, every miner gets its own rigname",
+				},
+			},
+			Action: func(c *cli.Context) {
+				if c.String("user") == "" {
+					log.Fatal("The mining address of the simulated miners is required (--user)")
+				}
+				log.Infoln("Running", c.Int("miners"), "simulated miners against", c.String("url"), "for", c.Duration("duration"))
+				report, err := loadtest.Run(loadtest.Config{
+					Address:  c.String("url"),
+					Miners:   c.Int("miners"),
+					Duration: c.Duration("duration"),
+					User:     c.String("user"),
+				})
+				if err != nil {
+					log.Fatal("Error running the load test: ", err)
+				}
+				log.Infoln(report)
+			},
+		},
+	}
+
 	app.Before = func(c *cli.Context) error {
+		if logFile != "" {
+			// Terminal colors would end up in the log file.
+			log.SetFormatter(&log.TextFormatter{FullTimestamp: true, DisableColors: true})
+			log.SetOutput(io.MultiWriter(os.Stderr, &logfile.Writer{
+				Filename:   logFile,
+				MaxSize:    int64(logMaxSize) * 1e6,
+				MaxBackups: logMaxBackups,
+				MaxAge:     logMaxAge,
+			}))
+		}
 		log.Infoln(app.Name, "-", app.Version)
 		if debugLogging {
 			log.SetLevel(log.DebugLevel)
 			log.Debugln("Debug logging enabled")
 		}
+		if consensusDir == "" {
+			consensusDir = filepath.Join(dataDir, "siad", modules.ConsensusDir)
+		}
+		if gatewayDir == "" {
+			gatewayDir = filepath.Join(dataDir, "siad", modules.GatewayDir)
+		}
+		if sharechainDir == "" {
+			sharechainDir = filepath.Join(dataDir, "sharechain")
+		}
+		if poolFee < 0 || poolFee > sharechain.MaxFee {
+			return errors.New("invalid fee: must be between 0 and " + strconv.Itoa(sharechain.MaxFee) + " (in 0.01%)")
+		}
+		if feeAddress != "" {
+			if err := poolFeeAddress.LoadString(feeAddress); err != nil {
+				return errors.New("invalid fee address: " + err.Error())
+			}
+		} else if poolFee > 0 {
+			log.Warnln("No fee address configured, no pool fee is taken and the whole reward goes to the miners")
+			poolFee = 0
+		}
 		return nil
 	}
 
@@ -77,43 +623,248 @@ func main() {
 		// Print a startup message.
 		log.Infoln("Loading...")
 
-		// Create the listener for the server
-		l, err := net.Listen("tcp", bindAddress)
+		// Create the listener for the server, or take over the one of the
+		// process that handed off its listeners.
+		inherited, err := inheritedListeners()
 		if err != nil {
-			log.Fatal("Error listening on", bindAddress, err)
+			log.Fatal("Error inheriting listeners: ", err)
+		}
+		l, ok := inherited["api"]
+		if !ok && !initOnly {
+			if l, err = listen(bindAddress, "bind"); err != nil {
+				log.Fatal("Error opening the public api: ", err)
+			}
+		}
+		// The stratum listener is opened before loading siad as well, so a
+		// port in use is reported right away.
+		stratumListener, ok := inherited["stratum"]
+		if !ok && !initOnly {
+			if stratumListener, err = listen(stratumAddress, "stratumaddress"); err != nil {
+				log.Fatal("Error opening the stratum server: ", err)
+			}
+		}
+		if len(inherited) > 0 {
+			log.Infoln("Inherited the listeners of the previous process, waiting for it to exit...")
+			if err = waitForParent(handoffDrain + time.Minute); err != nil {
+				log.Fatal("Error taking over from the previous process: ", err)
+			}
 		}
 
-		dc := &siad.Siad{RPCAddr: rpcAddr, APIAddr: apiAddr}
+		tpoolDir := filepath.Join(dataDir, "siad", modules.TransactionPoolDir)
+		for _, dir := range []string{consensusDir, gatewayDir, tpoolDir, sharechainDir} {
+			if err = checkWritable(dir); err != nil {
+				log.Fatal("Data directory ", dir, " is not writable: ", err)
+			}
+		}
+		sharechainExists, consensusExists, err := inspectDataDir(consensusDir, sharechainDir)
+		if err != nil {
+			log.Fatal("Data directory ", dataDir, " is partially initialized: ", err)
+		}
+		logDataDirState(dataDir, sharechainExists, consensusExists)
+
+		if network != siad.Network {
+			log.Fatal("The pool is configured for the ", network, " network but this binary is built for the ", siad.Network, " network")
+		}
+
+		var checkpoints []sharechain.Checkpoint
+		for _, value := range checkpointFlags.Value() {
+			checkpoint, err := sharechain.ParseCheckpoint(value)
+			if err != nil {
+				log.Fatal("Invalid --checkpoint ", value, ": ", err)
+			}
+			checkpoints = append(checkpoints, checkpoint)
+		}
+
+		var poolPeers []modules.NetAddress
+		for _, value := range poolPeerFlags.Value() {
+			addr := modules.NetAddress(value)
+			if err := addr.IsStdValid(); err != nil {
+				log.Fatal("Invalid --pool-peer ", value, ": ", err)
+			}
+			poolPeers = append(poolPeers, addr)
+		}
+
+		dc := &siad.Siad{
+			RPCAddr:            rpcAddr,
+			APIAddr:            apiAddr,
+			GatewayDir:         gatewayDir,
+			ConsensusDir:       consensusDir,
+			TransactionPoolDir: tpoolDir,
+		}
 		err = dc.Start()
 		if err != nil {
 			log.Fatal("Error running embedded siad: ", err)
 		}
+		if err = siad.CheckNetwork(network, dc.ConsensusSet()); err != nil {
+			log.Fatal("Wrong sia network: ", err)
+		}
+		log.Infoln("Mining on the", siad.Network, "sia network")
 
+		if maxConsensusLag < 0 {
+			log.Fatal("Invalid --max-consensus-lag ", maxConsensusLag, ", use a number of blocks or 0 to disable")
+		}
+		if maxTemplateSize < sharechain.MinTemplateSize || uint64(maxTemplateSize) > types.BlockSizeLimit {
+			log.Fatal("Invalid --max-template-size ", maxTemplateSize, ", use between ", sharechain.MinTemplateSize, " and ", types.BlockSizeLimit, " bytes")
+		}
+		if maxTemplateTransactions < 0 {
+			log.Fatal("Invalid --max-template-transactions ", maxTemplateTransactions, ", use a number of transactions or 0 for no limit")
+		}
+		windowShares, windowMultiple, err := sharechain.ParsePPLNSWindow(pplnsWindow)
+		if err != nil {
+			log.Fatal("Invalid --pplns-window: ", err)
+		}
 		log.Infoln("Loading sharechain...")
-		sc, err := sharechain.New(dc, "p2pooldata/sharechain")
+		sc, err := sharechain.New(dc, sharechainDir, sharechain.Config{
+			BlockMaturity:           types.BlockHeight(blockMaturity),
+			MaxReorgDepth:           types.BlockHeight(maxReorgDepth),
+			ShareRatio:              shareRatio,
+			PPLNSWindow:             windowShares,
+			PPLNSWindowMultiple:     windowMultiple,
+			LateShares:              sharechain.LateSharePolicy(lateShares),
+			ShareWeighting:          sharechain.ShareWeighting(shareWeighting),
+			SolverWeight:            solverWeight,
+			ConsensusQueueSize:      consensusQueueSize,
+			MaxConsensusLag:         types.BlockHeight(maxConsensusLag),
+			MinPeers:                minPeers,
+			PoolPeers:               poolPeers,
+			ForgetPoolPeers:         forgetPoolPeers,
+			SubmitTimeout:           submitTimeout,
+			SubmitAttempts:          submitAttempts,
+			SelfCheckInterval:       selfCheckInterval,
+			TemplateRefreshInterval: templateRefreshInterval,
+			MaxTemplateSize:         uint64(maxTemplateSize),
+			MaxTemplateTransactions: maxTemplateTransactions,
+			MempoolTimeout:          mempoolTimeout,
+			MempoolUnavailable:      sharechain.MempoolPolicy(mempoolUnavailable),
+			SelfCheckHalt:           selfCheckHalt,
+			Journal:                 journal,
+			LogRejects:              logRejects,
+			RejectRetention:         rejectRetention,
+			ShareFlushInterval:      shareFlushInterval,
+			SlowTemplateThreshold:   slowTemplateThreshold,
+			ShareBatchSize:          shareBatchSize,
+			Fee:                     poolFee,
+			FeeAddress:              poolFeeAddress,
+			Recover:                 recoverDB,
+			Checkpoints:             checkpoints,
+		})
 		if err != nil {
 			log.Fatal("Error initializing sharechain: ", err)
 		}
-		poolapi := api.PoolAPI{Fee: poolFee, ShareChain: sc}
-		r := mux.NewRouter()
-		r.Path("/fee").Methods("GET").Handler(http.HandlerFunc(poolapi.FeeHandler))
-		r.Path("/version").Methods("GET").Handler(http.HandlerFunc(poolapi.VersionHandler))
-
+		if initOnly {
+			if err = sc.Close(); err != nil {
+				log.Fatal("Error closing sharechain: ", err)
+			}
+			if err = dc.Close(); err != nil {
+				log.Fatal("Error closing embedded siad: ", err)
+			}
+			log.Infoln("Data directory", dataDir, "initialized, exiting")
+			return
+		}
 		stratumsrv := stratum.NewServer(stratumAddress, sc)
+		stratumsrv.KeepaliveInterval = keepaliveInterval
+		stratumsrv.RequireAuthorization = requireAuthorization
+		switch policy := stratum.DuplicateWorkerPolicy(duplicateWorkers); policy {
+		case stratum.DuplicateWorkersMerge, stratum.DuplicateWorkersRename, stratum.DuplicateWorkersReject:
+			stratumsrv.DuplicateWorkers = policy
+		default:
+			log.Fatal("Invalid --duplicate-workers ", duplicateWorkers, ", use merge, rename or reject")
+		}
+		stratumsrv.StartDifficulty = startDifficulty
+		stratumsrv.MaxDifficulty = maxDifficulty
+		if fixedDifficulty < 0 {
+			log.Fatal("Invalid --fixed-difficulty ", fixedDifficulty, ", use a positive difficulty or 0 to disable")
+		}
+		stratumsrv.FixedDifficulty = fixedDifficulty
+		if maxShareDifficulty < 0 {
+			log.Fatal("Invalid --max-share-difficulty ", maxShareDifficulty, ", use a positive difficulty or 0 to disable")
+		}
+		stratumsrv.MaxShareDifficulty = maxShareDifficulty
+		for _, value := range difficultyBands.Value() {
+			band, err := stratum.ParseDifficultyBand(value)
+			if err != nil {
+				log.Fatal("Invalid --difficulty-band ", value, ": ", err)
+			}
+			stratumsrv.DifficultyBands = append(stratumsrv.DifficultyBands, band)
+		}
+		stratumsrv.StaleGraceWindow = staleGraceWindow
+		stratumsrv.ClockSkewTolerance = clockSkewTolerance
+		if _, err = stratum.NewSharePolicy(stratum.Strictness(shareStrictness)); err != nil {
+			log.Fatal("Invalid --share-strictness ", shareStrictness, ", use strict, standard, flag or lenient")
+		}
+		stratumsrv.Strictness = stratum.Strictness(shareStrictness)
+		stratumsrv.MaxConnections = maxConnections
+		stratumsrv.MaxConnectionsPerIP = maxConnectionsPerIP
+		stratumsrv.AcceptInterval = acceptInterval
+		stratumsrv.MaxAcceptInterval = maxAcceptInterval
+		stratumsrv.MaxMinerHistories = maxMinerHistories
+		stratumsrv.InactiveMinerRetention = inactiveMinerRetention
+		stratumsrv.HideInactiveMiners = hideInactiveMiners
+		if err = stratumsrv.SetMOTD(motd); err != nil {
+			log.Fatal("Invalid --motd: ", err)
+		}
+
+		dispatcher := webhooks.New(sc, webhooks.Config{HTTPSOnly: webhooksHTTPSOnly, AllowInternal: webhooksAllowInternal})
 
-		// stop the server if a kill signal is caught
+		cacheTTLs, err := api.ParseCacheTTLs(apiCacheTTLs.Value())
+		if err != nil {
+			log.Fatal("Invalid --api-cache-ttl: ", err)
+		}
+		poolapi := api.PoolAPI{
+			Fee:           poolFee,
+			FeeAddress:    feeAddress,
+			ShareChain:    sc,
+			Stratum:       stratumsrv,
+			Version:       app.Version,
+			Network:       siad.Network,
+			AdminPassword: adminPassword,
+			Prefix:        apiPrefix,
+			ProbesAtRoot:  apiProbesAtRoot,
+			Unit:          apiUnit,
+			CacheTTLs:     cacheTTLs,
+			Compress:      apiCompress,
+			WebUI:         webUI,
+			Webhooks:      dispatcher,
+			Config:        effectiveConfig(c),
+		}
+		r := mux.NewRouter()
+		if err = poolapi.Register(r, disabledEndpoints.Value()); err != nil {
+			log.Fatal("Error registering the api endpoints: ", err)
+		}
+
+		// stop the server if a kill signal is caught, hand off the listeners
+		// to a new process on the handoff signal
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, os.Kill)
+		handoffChan := make(chan os.Signal, 1)
+		if handoffSignal != nil {
+			signal.Notify(handoffChan, handoffSignal)
+		}
 		go func() {
-			<-sigChan
-			log.Infoln("\rCaught stop signal, quitting...")
-			stratumsrv.Close()
-			dc.Close()
-			l.Close()
+			for {
+				select {
+				case <-sigChan:
+					log.Infoln("\rCaught stop signal, quitting...")
+					stratumsrv.Close()
+				case <-handoffChan:
+					process, err := handoff([]string{"api", "stratum"}, []fileListener{l.(fileListener), listenerFile(stratumsrv.ListenerFile)})
+					if err != nil {
+						log.Errorln("Error handing off the listeners:", err)
+						continue
+					}
+					log.Infoln("Handed off the listeners to process", process.Pid, "- draining the stratum connections")
+					stratumsrv.Drain(handoffDrain)
+				}
+				dispatcher.Close()
+				sc.Close()
+				dc.Close()
+				l.Close()
+				return
+			}
 		}()
 
 		go func() {
-			err = stratumsrv.Accept()
+			err = stratumsrv.Serve(stratumListener)
 			log.Errorln("ERROR accepting connections:", err)
 		}()
 
@@ -127,3 +878,67 @@ func main() {
 
 	app.Run(os.Args)
 }
+
+//listen listens on a tcp address, if another process listens on it the error names the flag to choose another address
+func listen(address, flag string) (net.Listener, error) {
+	l, err := net.Listen("tcp", address)
+	if siad.AddressInUse(err) {
+		return nil, errors.New("address " + address + " is already in use, is another pool instance running or still shutting down? Stop it or choose another address with --" + flag)
+	}
+	return l, err
+}
+
+//checkWritable creates dir if it does not exist and verifies files can be created in it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".writable")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+//backup downloads a snapshot of the sharechain database from the admin api of the running node to file
+func backup(bindAddress, prefix, password, file string) error {
+	host, port, err := net.SplitHostPort(bindAddress)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix = "/" + prefix
+	}
+	req, err := http.NewRequest("GET", "http://"+net.JoinHostPort(host, port)+prefix+"/backup", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("", password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("pool api returned " + resp.Status + ", is --admin-password set?")
+	}
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
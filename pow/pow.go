@@ -0,0 +1,47 @@
+//Package pow contains the proof of work math shared by share validation, template building and the stratum server.
+// Sia uses blake2b as proof of work: a block (or share) is valid if the id of the block, the blake2b hash of its
+// header, interpreted as a big endian number is less than or equal to the target, the same check as the consensus set of Sia does.
+package pow
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//DifficultyOne is the target of a share with difficulty 1 as used by the stratum protocol (mining.set_difficulty),
+// the expected number of hashes to find a share of difficulty 1 is 2^32.
+var DifficultyOne = types.Target{0, 0, 0, 0, 0xff, 0xff}
+
+//MeetsTarget returns if hash is less than or equal to target
+func MeetsTarget(hash crypto.Hash, target types.Target) bool {
+	return bytes.Compare(hash[:], target[:]) <= 0
+}
+
+//TargetFromDifficulty returns the target of a share with the given stratum difficulty (DifficultyOne / difficulty).
+// The target is rounded down so a hash that meets it always has at least the requested difficulty,
+// a difficulty below the one of the highest target or that is not a positive number results in the highest target.
+func TargetFromDifficulty(difficulty float64) types.Target {
+	if !(difficulty > 0) || math.IsInf(difficulty, 0) {
+		if math.IsInf(difficulty, 1) {
+			return types.Target{}
+		}
+		return types.RootDepth
+	}
+	// SetFloat64 is exact, the only rounding is the final truncation to an integer target.
+	d := new(big.Rat).SetFloat64(difficulty)
+	return types.RatToTarget(new(big.Rat).Quo(DifficultyOne.Rat(), d))
+}
+
+//DifficultyFromTarget returns the stratum difficulty of a target (DifficultyOne / target),
+// the zero target, that can not be met, has an infinite difficulty.
+func DifficultyFromTarget(target types.Target) (difficulty float64) {
+	if target == (types.Target{}) {
+		return math.Inf(1)
+	}
+	difficulty, _ = new(big.Rat).Quo(DifficultyOne.Rat(), target.Rat()).Float64()
+	return
+}
@@ -0,0 +1,69 @@
+package pow
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestMeetsTarget(t *testing.T) {
+	target := types.Target{0, 0, 0, 0, 0xff, 0xff}
+	for _, test := range []struct {
+		hash     crypto.Hash
+		expected bool
+	}{
+		{crypto.Hash{}, true},
+		{crypto.Hash(target), true},
+		{crypto.Hash{0, 0, 0, 0, 0xff, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, true},
+		{crypto.Hash{0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, false},
+		{crypto.Hash{0, 0, 0, 1}, false},
+	} {
+		if MeetsTarget(test.hash, target) != test.expected {
+			t.Error("Expected", test.expected, "for", test.hash)
+		}
+	}
+}
+
+func TestTargetFromDifficulty(t *testing.T) {
+	if target := TargetFromDifficulty(1); target != DifficultyOne {
+		t.Error("Difficulty 1 returned", target)
+	}
+	if target := TargetFromDifficulty(256); target != (types.Target{0, 0, 0, 0, 0, 0xff, 0xff}) {
+		t.Error("Difficulty 256 returned", target)
+	}
+	// Rounded down: the target of difficulty 3 must not be met by a hash of a lower difficulty.
+	target := TargetFromDifficulty(3)
+	if DifficultyFromTarget(target) < 3 {
+		t.Error("Target of difficulty 3 has difficulty", DifficultyFromTarget(target))
+	}
+	for _, difficulty := range []float64{0, -1, math.NaN(), 1e-80} {
+		if target := TargetFromDifficulty(difficulty); target != types.RootDepth {
+			t.Error("Difficulty", difficulty, "returned", target)
+		}
+	}
+	if target := TargetFromDifficulty(math.Inf(1)); target != (types.Target{}) {
+		t.Error("Infinite difficulty returned", target)
+	}
+}
+
+func TestDifficultyFromTarget(t *testing.T) {
+	expectedDiff, _ := strconv.ParseFloat("0.99998474121094105", 64)
+	var target types.Target
+	targetSlice, _ := hex.DecodeString("00000000fffffffffffefffeffff00000001000200020000fffefffcfffbfffd")
+	copy(target[:], targetSlice[:])
+	if diff := DifficultyFromTarget(target); diff != expectedDiff {
+		t.Error(diff, "returned instead of", expectedDiff)
+	}
+	for _, difficulty := range []float64{1, 2, 1024, 1e6} {
+		if diff := DifficultyFromTarget(TargetFromDifficulty(difficulty)); math.Abs(diff-difficulty)/difficulty > 1e-9 {
+			t.Error("Difficulty", difficulty, "returned", diff, "after conversion to a target")
+		}
+	}
+	if !math.IsInf(DifficultyFromTarget(types.Target{}), 1) {
+		t.Error("Zero target has a finite difficulty")
+	}
+}
@@ -0,0 +1,110 @@
+package sharechain
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//MaxAdjustmentReasonLength is the maximum length of the reason of an adjustment
+const MaxAdjustmentReasonLength = 256
+
+var (
+	//ErrNegativeEarnings is returned for a debit that is larger than the earnings of the miner address
+	ErrNegativeEarnings = errors.New("the debit exceeds the earnings of the address")
+	//ErrInvalidAdjustment is returned for an adjustment without a value or a reason
+	ErrInvalidAdjustment = errors.New("an adjustment needs a value and a reason of at most 256 characters")
+)
+
+//Adjustment is a manual correction of the earnings of a miner address, for example to refund a wrongly rejected share.
+// A debit subtracts the Value from the earnings, otherwise it is added.
+type Adjustment struct {
+	Timestamp types.Timestamp  `json:"timestamp"`
+	Address   types.UnlockHash `json:"address"`
+	Value     types.Currency   `json:"value"`
+	Debit     bool             `json:"debit"`
+	Reason    string           `json:"reason"`
+}
+
+// apply returns the earnings after the adjustment, ok is false if a debit
+// exceeds them.
+func (a Adjustment) apply(earnings types.Currency) (adjusted types.Currency, ok bool) {
+	if !a.Debit {
+		return earnings.Add(a.Value), true
+	}
+	if earnings.Cmp(a.Value) < 0 {
+		return earnings, false
+	}
+	return earnings.Sub(a.Value), true
+}
+
+//AdjustEarnings applies an adjustment to the earnings of its miner address, ErrNegativeEarnings is returned for a debit
+// that exceeds them. The adjustment is stored, recorded in the audit log and replayed by Recompute, so the earnings
+// keep adding up to the matured payouts plus the adjustments. Payouts are coinbase outputs of the found blocks,
+// the operator settles an adjustment with the miner outside of the pool.
+func (sc *ShareChain) AdjustEarnings(adjustment Adjustment) (earnings types.Currency, err error) {
+	if adjustment.Value.IsZero() || adjustment.Reason == "" || len(adjustment.Reason) > MaxAdjustmentReasonLength {
+		return types.ZeroCurrency, ErrInvalidAdjustment
+	}
+	if adjustment.Timestamp == 0 {
+		adjustment.Timestamp = types.CurrentTimestamp()
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	err = sc.db.Update(func(tx *bolt.Tx) error {
+		current := types.ZeroCurrency
+		if raw := tx.Bucket(Earnings).Get(adjustment.Address[:]); raw != nil {
+			if err := encoding.Unmarshal(raw, &current); err != nil {
+				return err
+			}
+		}
+		var ok bool
+		if earnings, ok = adjustment.apply(current); !ok {
+			return ErrNegativeEarnings
+		}
+		if err := tx.Bucket(Earnings).Put(adjustment.Address[:], encoding.Marshal(earnings)); err != nil {
+			return err
+		}
+		b := tx.Bucket(Adjustments)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err = b.Put(auditKey(adjustment.Timestamp, seq), encoding.Marshal(adjustment)); err != nil {
+			return err
+		}
+		event := AuditCredit
+		if adjustment.Debit {
+			event = AuditDebit
+		}
+		return putAudit(tx, []AuditEntry{{Timestamp: adjustment.Timestamp, Event: event, Address: adjustment.Address, Value: adjustment.Value}})
+	})
+	return
+}
+
+//Adjustments returns all adjustments of the earnings, oldest first
+func (sc *ShareChain) Adjustments() (adjustments []Adjustment, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		adjustments, err = storedAdjustments(tx)
+		return err
+	})
+	return
+}
+
+// storedAdjustments decodes the adjustments in the database, oldest first.
+func storedAdjustments(tx *bolt.Tx) (adjustments []Adjustment, err error) {
+	adjustments = make([]Adjustment, 0)
+	err = tx.Bucket(Adjustments).ForEach(func(k, v []byte) error {
+		var adjustment Adjustment
+		if err := encoding.Unmarshal(v, &adjustment); err != nil {
+			return err
+		}
+		adjustments = append(adjustments, adjustment)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,88 @@
+package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestAdjustEarnings(t *testing.T) {
+	config := Config{BlockMaturity: 1}
+	sc, cleanup := newTestShareChain(t, config)
+	defer cleanup()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	for _, share := range testShares(a, b) {
+		sc.AddShare(share)
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	block := types.Block{Nonce: types.BlockNonce{1}}
+	block.MinerPayouts, _ = sc.GenerateMinerPayouts(a, types.NewCurrency64(1e6))
+	if err := sc.AddFoundBlock(block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	before, err := sc.Earnings(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	earnings, err := sc.AdjustEarnings(Adjustment{Address: b, Value: types.NewCurrency64(100), Reason: "wrongly rejected share"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if earnings.Cmp(before.Add(types.NewCurrency64(100))) != 0 {
+		t.Error("Expected the credit to be added to", before, "got", earnings)
+	}
+	if _, err = sc.AdjustEarnings(Adjustment{Address: b, Value: earnings.Add(types.NewCurrency64(1)), Debit: true, Reason: "too much"}); err != ErrNegativeEarnings {
+		t.Error("Expected", ErrNegativeEarnings, "got", err)
+	}
+	if earnings, err = sc.AdjustEarnings(Adjustment{Address: b, Value: types.NewCurrency64(30), Debit: true, Reason: "duplicate payout"}); err != nil {
+		t.Fatal(err)
+	}
+	if earnings.Cmp(before.Add(types.NewCurrency64(70))) != 0 {
+		t.Error("Expected the debit to be subtracted, got", earnings)
+	}
+	for _, invalid := range []Adjustment{{Address: b, Reason: "zero"}, {Address: b, Value: types.NewCurrency64(1)}} {
+		if _, err = sc.AdjustEarnings(invalid); err != ErrInvalidAdjustment {
+			t.Error("Expected", ErrInvalidAdjustment, "got", err)
+		}
+	}
+	if err = sc.SelfCheck(); err != nil {
+		t.Error("Adjusted earnings inconsistent:", err)
+	}
+
+	adjustments, err := sc.Adjustments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 2 || adjustments[0].Debit || !adjustments[1].Debit || adjustments[1].Reason != "duplicate payout" {
+		t.Error("Expected the credit and the debit, got", adjustments)
+	}
+	entries, err := sc.Audit(time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []AuditEvent
+	for _, entry := range entries {
+		if entry.Event == AuditCredit || entry.Event == AuditDebit {
+			events = append(events, entry.Event)
+		}
+	}
+	if len(events) != 2 || events[0] != AuditCredit || events[1] != AuditDebit {
+		t.Error("Expected the adjustments to be audited, got", events)
+	}
+
+	sc.db.Close()
+	discrepancies, err := Recompute(sc.persistDir, config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Error("Adjustments not replayed by the recompute:", discrepancies)
+	}
+}
@@ -0,0 +1,129 @@
+package sharechain
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//AuditEvent is the kind of event recorded in the audit log
+type AuditEvent string
+
+const (
+	//AuditShare is recorded for every accepted share
+	AuditShare AuditEvent = "share"
+	//AuditBlockFound is recorded for every block found by the pool
+	AuditBlockFound AuditEvent = "blockfound"
+	//AuditBlockOrphaned is recorded when a found block is reverted before it matured
+	AuditBlockOrphaned AuditEvent = "blockorphaned"
+	//AuditPayout is recorded for every payout of a found block once the block matured
+	AuditPayout AuditEvent = "payout"
+	//AuditCatchUpStarted is recorded when the consensus set is no longer synced with the network
+	AuditCatchUpStarted AuditEvent = "catchupstarted"
+	//AuditCatchUpFinished is recorded when the consensus set is synced again
+	AuditCatchUpFinished AuditEvent = "catchupfinished"
+	//AuditCredit is recorded when an operator adds to the earnings of a miner address
+	AuditCredit AuditEvent = "credit"
+	//AuditDebit is recorded when an operator subtracts from the earnings of a miner address
+	AuditDebit AuditEvent = "debit"
+)
+
+//MaxAuditEntries is the maximum number of entries returned by a single Audit call
+const MaxAuditEntries = 10000
+
+//AuditEntry is an event in the append-only audit log
+type AuditEntry struct {
+	Timestamp types.Timestamp  `json:"timestamp"`
+	Event     AuditEvent       `json:"event"`
+	BlockID   types.BlockID    `json:"blockid"`
+	Miner     string           `json:"miner,omitempty"`
+	Address   types.UnlockHash `json:"address"`
+	Value     types.Currency   `json:"value"`
+}
+
+// recordAudit adds an entry to the audit log, it is written to disk together
+// with the buffered shares so it never delays accepting a share. The caller
+// needs to hold the lock of the sharechain.
+func (sc *ShareChain) recordAudit(entry AuditEntry) {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = types.CurrentTimestamp()
+	}
+	sc.unsavedAudit = append(sc.unsavedAudit, entry)
+	sc.journalAdd(journalEntry{Audit: entry})
+}
+
+//Audit returns the entries of the audit log since the given time, oldest first and at most MaxAuditEntries
+func (sc *ShareChain) Audit(since time.Time) (entries []AuditEntry, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	entries = make([]AuditEntry, 0)
+	start := auditKey(types.Timestamp(since.Unix()), 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(Audit).Cursor()
+		for k, v := c.Seek(start); k != nil && len(entries) < MaxAuditEntries; k, v = c.Next() {
+			var entry AuditEntry
+			if err := encoding.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	for _, entry := range sc.unsavedAudit {
+		if len(entries) >= MaxAuditEntries {
+			break
+		}
+		if int64(entry.Timestamp) >= since.Unix() {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+// putAudit appends the entries to the audit log, payouts are indexed in the
+// Payouts bucket as well.
+func putAudit(tx *bolt.Tx, entries []AuditEntry) error {
+	b := tx.Bucket(Audit)
+	for _, entry := range entries {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		k, v := auditKey(entry.Timestamp, seq), encoding.Marshal(entry)
+		if err = b.Put(k, v); err != nil {
+			return err
+		}
+		if entry.Event == AuditPayout {
+			if err = tx.Bucket(Payouts).Put(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexPayouts adds the payouts in the audit log to the Payouts bucket.
+func indexPayouts(tx *bolt.Tx) error {
+	payouts := tx.Bucket(Payouts)
+	return tx.Bucket(Audit).ForEach(func(k, v []byte) error {
+		var entry AuditEntry
+		if err := encoding.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+		if entry.Event != AuditPayout {
+			return nil
+		}
+		return payouts.Put(k, v)
+	})
+}
+
+//auditKey sorts the audit log by time, the sequence number keeps entries with the same timestamp unique and in order
+func auditKey(timestamp types.Timestamp, seq uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k, uint64(timestamp))
+	binary.BigEndian.PutUint64(k[8:], seq)
+	return k
+}
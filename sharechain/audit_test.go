@@ -0,0 +1,72 @@
+package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestAudit(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 1})
+	defer cleanup()
+
+	start := time.Now().Add(-time.Second)
+	sc.AddShare(Share{Miner: "a"})
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{found}})
+
+	expected := []AuditEvent{AuditShare, AuditBlockFound, AuditPayout}
+	check := func(when string) {
+		entries, err := sc.Audit(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(expected) {
+			t.Fatal("Expected", len(expected), "audit entries", when, "got", entries)
+		}
+		for i, entry := range entries {
+			if entry.Event != expected[i] {
+				t.Error("Expected event", expected[i], when, "got", entry.Event)
+			}
+		}
+	}
+	check("before a flush")
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.unsavedAudit) != 0 {
+		t.Error("Audit entries still buffered after a flush")
+	}
+	check("after a flush")
+
+	if entries, _ := sc.Audit(time.Now().Add(time.Hour)); len(entries) != 0 {
+		t.Error("Expected no audit entries in the future, got", entries)
+	}
+}
+
+func TestAuditKeysSortedByTime(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		return putAudit(tx, []AuditEntry{{Timestamp: 300, Event: AuditShare}, {Timestamp: 100, Event: AuditPayout}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := sc.Audit(time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Timestamp != 100 || entries[1].Timestamp != 300 {
+		t.Error("Audit entries not sorted by time:", entries)
+	}
+	if entries, _ = sc.Audit(time.Unix(200, 0)); len(entries) != 1 || entries[0].Timestamp != 300 {
+		t.Error("Expected only the entry after the requested time, got", entries)
+	}
+}
@@ -0,0 +1,55 @@
+package sharechain
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//AuthorizeAddress adds a miner address to the addresses that are allowed to mine on a pool that requires authorization
+func (sc *ShareChain) AuthorizeAddress(address types.UnlockHash) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(AuthorizedAddresses).Put(address[:], []byte{1})
+	})
+}
+
+//RevokeAddress removes a miner address from the authorized addresses, errNilItem is returned if it is not authorized
+func (sc *ShareChain) RevokeAddress(address types.UnlockHash) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(AuthorizedAddresses)
+		if b.Get(address[:]) == nil {
+			return errNilItem
+		}
+		return b.Delete(address[:])
+	})
+}
+
+//IsAuthorized returns if a miner address is authorized
+func (sc *ShareChain) IsAuthorized(address types.UnlockHash) (authorized bool, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		authorized = tx.Bucket(AuthorizedAddresses).Get(address[:]) != nil
+		return nil
+	})
+	return
+}
+
+//AuthorizedAddresses returns all authorized miner addresses
+func (sc *ShareChain) AuthorizedAddresses() (addresses []types.UnlockHash, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	addresses = make([]types.UnlockHash, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(AuthorizedAddresses).ForEach(func(k, v []byte) error {
+			var address types.UnlockHash
+			copy(address[:], k)
+			addresses = append(addresses, address)
+			return nil
+		})
+	})
+	return
+}
@@ -0,0 +1,36 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestAuthorizedAddresses(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+
+	if err := sc.AuthorizeAddress(a); err != nil {
+		t.Fatal(err)
+	}
+	if authorized, err := sc.IsAuthorized(a); err != nil || !authorized {
+		t.Error("Authorized address rejected", err)
+	}
+	if authorized, _ := sc.IsAuthorized(b); authorized {
+		t.Error("Unknown address authorized")
+	}
+	if addresses, _ := sc.AuthorizedAddresses(); len(addresses) != 1 || addresses[0] != a {
+		t.Error("Expected only", a, "to be authorized, got", addresses)
+	}
+
+	if err := sc.RevokeAddress(b); err != errNilItem {
+		t.Error("Expected", errNilItem, "revoking an unknown address, got", err)
+	}
+	if err := sc.RevokeAddress(a); err != nil {
+		t.Fatal(err)
+	}
+	if authorized, _ := sc.IsAuthorized(a); authorized {
+		t.Error("Revoked address still authorized")
+	}
+}
@@ -0,0 +1,102 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/bolt"
+)
+
+//BackupFilename is the name of the backup in the sharechain directory that is restored by a recovery
+const BackupFilename = DatabaseFilename + ".backup"
+
+var errInvalidBackup = errors.New("not a sharechain database")
+
+//Backup writes a consistent snapshot of the sharechain database to w while the sharechain is in use.
+// The buffered shares are written to the database first.
+func (sc *ShareChain) Backup(w io.Writer) (n int64, err error) {
+	if err = sc.flushShares(); err != nil {
+		return
+	}
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return
+}
+
+//Restore replaces the sharechain database in persistDir by a backup, the existing database is kept with a .bck extension.
+// The sharechain can not be in use.
+func Restore(persistDir, backup string) error {
+	if err := checkBackup(backup); err != nil {
+		return err
+	}
+	filename := filepath.Join(persistDir, DatabaseFilename)
+	if _, err := os.Stat(filename); err == nil {
+		if err = os.Rename(filename, filename+".bck"); err != nil {
+			return errors.New("error while backing up sharechain database: " + err.Error())
+		}
+	}
+	return copyFile(backup, filename)
+}
+
+// checkBackup verifies a backup is a readable sharechain database.
+func checkBackup(backup string) (err error) {
+	if _, err = os.Stat(backup); err != nil {
+		return
+	}
+	db, err := persist.OpenDatabase(dbMetadata, backup)
+	if err != nil {
+		return fmt.Errorf("backup %s can not be opened: %v", backup, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		if !dbInitialized(tx) {
+			return errInvalidBackup
+		}
+		return nil
+	})
+}
+
+// recoverDB moves an unreadable database aside and restores the last backup
+// in the persist directory if there is one. Without a backup the sharechain
+// starts over with an empty database.
+func (sc *ShareChain) recoverDB(filename string, cause error) error {
+	corrupt := filename + ".corrupt"
+	sc.log.Println("Recovering from unreadable sharechain database:", cause, "- moving it to", corrupt)
+	if err := os.Rename(filename, corrupt); err != nil {
+		return errors.New("error moving the unreadable sharechain database: " + err.Error())
+	}
+	backup := filepath.Join(sc.persistDir, BackupFilename)
+	if err := checkBackup(backup); err != nil {
+		sc.log.Println("No usable backup", backup, "(", err, "), starting with an empty sharechain database")
+		return nil
+	}
+	sc.log.Println("Restoring backup", backup)
+	return copyFile(backup, filename)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,73 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	sc.AddShare(Share{Miner: "a"})
+
+	backup := filepath.Join(sc.persistDir, BackupFilename)
+	f, err := os.Create(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sc.Backup(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	sc.db.Close()
+
+	restoreDir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(restoreDir)
+	if err = Restore(restoreDir, backup); err != nil {
+		t.Fatal(err)
+	}
+	restored := &ShareChain{persistDir: restoreDir, config: sc.config}
+	if err = restored.initPersist(); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.db.Close()
+	if len(restored.shares) != 1 || restored.shares[0].Miner != "a" {
+		t.Error("Shares not restored from the backup:", restored.shares)
+	}
+
+	if err = Restore(restoreDir, filepath.Join(sc.persistDir, logFile)); err == nil {
+		t.Error("Restored a file that is not a sharechain database")
+	}
+}
+
+func TestCorruptedDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, DatabaseFilename)
+	if err = ioutil.WriteFile(filename, []byte(strings.Repeat("corrupt", 1000)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := &ShareChain{persistDir: dir}
+	if err = sc.initPersist(); err == nil || !strings.Contains(err.Error(), "--recover") {
+		t.Fatal("Expected an actionable error for a corrupted database, got", err)
+	}
+
+	sc = &ShareChain{persistDir: dir, config: Config{Recover: true}}
+	if err = sc.initPersist(); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.db.Close()
+	if _, err = os.Stat(filename + ".corrupt"); err != nil {
+		t.Error("Corrupted database not moved aside:", err)
+	}
+}
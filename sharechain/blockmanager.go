@@ -1,6 +1,244 @@
 package sharechain
 
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//DefaultTemplateRefreshInterval is the default age at which a block template is rebuilt with the current transactions
+const DefaultTemplateRefreshInterval = 30 * time.Second
+
+//Template is the block the pool hands out to the miners. The payouts of the Block are generated without a finder, the whole
+// reward after the fee goes to the miners in the pplns window, it has no payouts while there is nobody to pay yet.
+// Every miner works on the block MinerBlock returns for its address, that pays it the FinderBonus from the same window.
+// A solution that meets the ShareTarget is a share, one that also meets the Target is a block.
+type Template struct {
+	Block       types.Block
+	Height      types.BlockHeight
+	Target      types.Target
+	ShareTarget types.Target
+	//Size is the size of the encoded block in bytes, the block of a miner is at most one payout and the
+	// MinerTransactionSize larger, both are at most the MaxTemplateSize
+	Size    int
+	Created time.Time
+	//Clean is false if the template refreshes the transactions of the previous template,
+	// work on the previous template is still valid and does not need to be abandoned
+	Clean bool
+	//ShareIndex is the sequence number of the last share in the pplns window of the template
+	ShareIndex uint64
+
+	// window are the shares in the pplns window when the template was built,
+	// windowSize is the size of the window at the time.
+	window     []Share
+	windowSize int
+}
+
+//BlockTemplate returns the current template, a new one is created if there is none, the consensus set changed or
+// the template is older than the TemplateRefreshInterval.
+// No template is handed out while the gateway has fewer than MinPeers peers.
+func (sc *ShareChain) BlockTemplate() (template Template, err error) {
+	if err = sc.checkPeers(); err != nil {
+		return
+	}
+	sc.mu.RLock()
+	current := sc.template
+	sc.mu.RUnlock()
+	if current != nil && !sc.templateExpired(*current, time.Now()) {
+		return *current, nil
+	}
+	return sc.newSourceBlock()
+}
+
+//SetTemplateHandler sets the function that is called with every new block template
+func (sc *ShareChain) SetTemplateHandler(handler func(Template)) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.templateHandler = handler
+}
+
+// templateExpired returns true if a template is due for a refresh.
+func (sc *ShareChain) templateExpired(template Template, now time.Time) bool {
+	return sc.config.TemplateRefreshInterval > 0 && now.Sub(template.Created) >= sc.config.TemplateRefreshInterval
+}
+
+// invalidateTemplate clears the current template, templates that are being
+// built from the previous state are not stored. The caller needs to hold the
+// lock.
+func (sc *ShareChain) invalidateTemplate() {
+	sc.template = nil
+	sc.templateGeneration++
+}
+
 // newSourceBlock creates a new source block for the block manager so that new
-// headers will use the updated source block.
-func (sc *ShareChain) newSourceBlock() {
+// headers will use the updated source block. A template that is a refresh of
+// the current one, on the same parent and with the same share target, is not
+// clean. If the template is invalidated while it is built, it is built again.
+func (sc *ShareChain) newSourceBlock() (template Template, err error) {
+	for {
+		sc.mu.RLock()
+		previous, generation := sc.template, sc.templateGeneration
+		sc.mu.RUnlock()
+
+		if template, err = sc.buildTemplate(); err != nil {
+			return
+		}
+		template.Clean = previous == nil || previous.Block.ParentID != template.Block.ParentID || previous.ShareTarget != template.ShareTarget
+
+		sc.mu.Lock()
+		if sc.templateGeneration != generation {
+			sc.mu.Unlock()
+			continue
+		}
+		sc.template = &template
+		handler := sc.templateHandler
+		sc.mu.Unlock()
+		if handler != nil {
+			handler(template)
+		}
+		return
+	}
+}
+
+// buildTemplate builds a template on top of the current block of the consensus
+// set with the transactions of the transaction pool that fit within the
+// MaxTemplateSize and MaxTemplateTransactions. If the transaction pool
+// is unavailable, the template only has the miner payouts unless the
+// MempoolUnavailable policy is MempoolFail.
+func (sc *ShareChain) buildTemplate() (template Template, err error) {
+	cs := sc.Siad.ConsensusSet()
+	parent := cs.CurrentBlock()
+	height := cs.Height() + 1
+	target, _ := cs.ChildTarget(parent.ID())
+	shareTarget := sc.updateShareTarget(target)
+
+	start := time.Now()
+	txns, err := sc.mempoolTransactions()
+	if err != nil && sc.config.MempoolUnavailable == MempoolFail {
+		return
+	}
+	sc.setMempoolDegraded(err)
+	build := TemplateBuild{Mempool: time.Since(start)}
+
+	start = time.Now()
+	window, windowSize, shareIndex := sc.windowShares()
+	// The transactions get the room the header and the miner payouts leave,
+	// the payouts grow a little when the fees are added to the reward and the
+	// blocks of the miners add the payout of the finder and a transaction.
+	b := sourceBlock(parent.ID(), nil)
+	b.MinerPayouts, err = sc.templatePayouts(window, b.CalculateSubsidy(height))
+	if err != nil {
+		return
+	}
+	minerRoom := uint64(len(encoding.Marshal(types.SiacoinOutput{Value: b.CalculateSubsidy(height)}))) + MinerTransactionSize
+	overhead := uint64(len(encoding.Marshal(b))+payoutSlack*(len(b.MinerPayouts)+1)) + minerRoom
+	if overhead > sc.config.MaxTemplateSize {
+		err = errTemplateTooLarge
+		return
+	}
+	build.Coinbase = time.Since(start)
+
+	start = time.Now()
+	b = sourceBlock(parent.ID(), selectTransactions(txns, sc.config.MaxTemplateTransactions, sc.config.MaxTemplateSize-overhead))
+	build.Mempool += time.Since(start)
+	build.Transactions = len(b.Transactions)
+
+	start = time.Now()
+	b.MinerPayouts, err = sc.templatePayouts(window, b.CalculateSubsidy(height))
+	if err != nil {
+		return
+	}
+	build.Coinbase += time.Since(start)
+
+	start = time.Now()
+	build.Size = len(encoding.Marshal(b))
+	build.Serialization = time.Since(start)
+	if uint64(build.Size)+minerRoom > sc.config.MaxTemplateSize {
+		err = errTemplateTooLarge
+		return
+	}
+	build.Created = time.Now()
+	sc.recordTemplateBuild(build)
+
+	template = Template{Block: b, Height: height, Target: target, ShareTarget: shareTarget, Size: build.Size, Created: build.Created, ShareIndex: shareIndex, window: window, windowSize: windowSize}
+	return
+}
+
+// templatePayouts generates the payouts of a template, without a finder. A
+// template without anybody to pay has no payouts, the blocks of the miners
+// always pay their finder.
+func (sc *ShareChain) templatePayouts(window []Share, subsidy types.Currency) (payouts []types.SiacoinOutput, err error) {
+	payouts, err = sc.generatePayouts(window, types.UnlockHash{}, subsidy)
+	if err == errNoPayee {
+		return nil, nil
+	}
+	return
+}
+
+// templateAfterChange builds a template on the new tip for the template
+// handler once the queued consensus changes are processed. Without a handler
+// nobody waits for work and the template is built on demand.
+func (sc *ShareChain) templateAfterChange() {
+	sc.mu.RLock()
+	handler := sc.templateHandler
+	sc.mu.RUnlock()
+	if handler == nil || len(sc.consensusChanges) > 0 || sc.checkPeers() != nil {
+		return
+	}
+	if _, err := sc.newSourceBlock(); err != nil {
+		sc.log.Println("Error building the block template:", err)
+	}
+}
+
+// threadedRefreshTemplate rebuilds the current template once it is older than
+// the TemplateRefreshInterval, so the miners get the transactions that arrived
+// since. A template that was cleared is built again on demand instead.
+func (sc *ShareChain) threadedRefreshTemplate() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	wait := sc.config.TemplateRefreshInterval
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case now := <-time.After(wait):
+			wait = sc.config.TemplateRefreshInterval
+			sc.mu.RLock()
+			current := sc.template
+			sc.mu.RUnlock()
+			if current == nil || sc.checkPeers() != nil {
+				continue
+			}
+			if age := now.Sub(current.Created); age < wait {
+				// The template was built in the meantime.
+				wait -= age
+				continue
+			}
+			if _, err := sc.newSourceBlock(); err != nil {
+				sc.log.Println("Error refreshing the block template:", err)
+			}
+		}
+	}
+}
+
+// sourceBlock creates a block on top of parent without miner payouts.
+func sourceBlock(parent types.BlockID, txns []types.Transaction) types.Block {
+	b := types.Block{
+		ParentID:  parent,
+		Timestamp: types.CurrentTimestamp(),
+	}
+	// Limit the transactions to what fits in a block, leaving room for the
+	// header and the miner payouts.
+	var size uint64
+	for _, txn := range txns {
+		size += uint64(len(encoding.Marshal(txn)))
+		if size > types.BlockSizeLimit-5e3 {
+			break
+		}
+		b.Transactions = append(b.Transactions, txn)
+	}
+	return b
 }
@@ -1 +1,159 @@
 package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestSourceBlock(t *testing.T) {
+	parent := types.BlockID{1}
+	txns := []types.Transaction{{ArbitraryData: [][]byte{{1}}}, {ArbitraryData: [][]byte{{2}}}}
+	b := sourceBlock(parent, txns)
+	if b.ParentID != parent {
+		t.Error("Block not built on the parent")
+	}
+	if len(b.Transactions) != 2 {
+		t.Error("Expected 2 transactions, got", len(b.Transactions))
+	}
+
+	large := types.Transaction{ArbitraryData: [][]byte{make([]byte, types.BlockSizeLimit/2)}}
+	b = sourceBlock(parent, []types.Transaction{large, large, large})
+	if len(b.Transactions) != 1 {
+		t.Error("Expected the transactions to be limited to the block size, got", len(b.Transactions))
+	}
+}
+
+func TestTemplateClearedOnConsensusChange(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	sc.template = &Template{}
+	if template, err := sc.BlockTemplate(); err != nil || !template.Created.IsZero() {
+		t.Error("Cached template not returned")
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{testBlock(1, 0)}})
+	if sc.template != nil {
+		t.Error("Template not cleared on a consensus change")
+	}
+}
+
+func TestTemplateBuildTimings(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{})
+	defer cleanup()
+	mock.Transactions = []types.Transaction{{ArbitraryData: [][]byte{{1}}}, {ArbitraryData: [][]byte{{2}}}}
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	builds := sc.TemplateBuilds()
+	if len(builds) != 1 {
+		t.Fatal("Expected the timings of 1 build, got", len(builds))
+	}
+	build := builds[0]
+	if build.Transactions != 2 || build.Size == 0 || !build.Created.Equal(template.Created) {
+		t.Error("Unexpected build", build)
+	}
+	if build.Total() != build.Mempool+build.Coinbase+build.Serialization {
+		t.Error("Total is not the sum of the phases")
+	}
+
+	for i := 0; i < templateBuildsLength+1; i++ {
+		sc.newSourceBlock()
+	}
+	if n := len(sc.TemplateBuilds()); n != templateBuildsLength {
+		t.Error("Expected", templateBuildsLength, "builds to be kept, got", n)
+	}
+}
+
+func TestTemplateRefresh(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{TemplateRefreshInterval: time.Hour})
+	defer cleanup()
+	var templates []Template
+	sc.SetTemplateHandler(func(template Template) { templates = append(templates, template) })
+
+	first, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Clean {
+		t.Error("First template is not clean")
+	}
+	if cached, _ := sc.BlockTemplate(); !cached.Created.Equal(first.Created) {
+		t.Error("Template rebuilt before the refresh interval")
+	}
+
+	// An expired template is rebuilt with the new transactions on the same
+	// parent, the work on it stays valid.
+	mock.Transactions = []types.Transaction{{ArbitraryData: [][]byte{{1}}}}
+	sc.template.Created = time.Now().Add(-time.Hour)
+	refreshed, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Clean || len(refreshed.Block.Transactions) != 1 || refreshed.Block.ParentID != first.Block.ParentID {
+		t.Error("Expected a refresh with the new transaction, got clean", refreshed.Clean, "and", len(refreshed.Block.Transactions), "transactions")
+	}
+
+	mock.Mine(1)
+	sc.pendingChanges.Wait()
+	next, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.Clean || next.Block.ParentID == first.Block.ParentID {
+		t.Error("Template on a new block is not clean")
+	}
+	if len(templates) != 3 || templates[1].Clean || !templates[2].Clean {
+		t.Error("Expected the handler to be called with every new template, got", len(templates))
+	}
+}
+
+func TestThreadedTemplateRefresh(t *testing.T) {
+	sc, _, cleanup := newMockShareChain(t, Config{TemplateRefreshInterval: 20 * time.Millisecond})
+	defer cleanup()
+	refreshed := make(chan Template, 10)
+	sc.SetTemplateHandler(func(template Template) { refreshed <- template })
+
+	first, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-refreshed
+	select {
+	case template := <-refreshed:
+		if template.Clean || !template.Created.After(first.Created) {
+			t.Error("Expected a newer template that is not clean")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Template not refreshed")
+	}
+}
+
+func TestTemplateNotRefreshedWhenDisabled(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	sc.template = &Template{}
+	if template, err := sc.BlockTemplate(); err != nil || !template.Created.IsZero() {
+		t.Error("Template refreshed without a refresh interval")
+	}
+}
+
+func BenchmarkBlockTemplate(b *testing.B) {
+	sc, mock, cleanup := newMockShareChain(b, Config{})
+	defer cleanup()
+	for i := 0; i < 1000; i++ {
+		mock.Transactions = append(mock.Transactions, types.Transaction{ArbitraryData: [][]byte{make([]byte, 1000)}})
+	}
+	for i := 0; i < ShareChainLength; i++ {
+		sc.AddShare(Share{Miner: types.UnlockHash{byte(i)}.String()})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sc.newSourceBlock(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
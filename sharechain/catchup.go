@@ -0,0 +1,99 @@
+package sharechain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//syncCheckInterval is the time between two checks if the consensus set is synced with the network
+const syncCheckInterval = 10 * time.Second
+
+var errCatchingUp = errors.New("catching up with the network, found blocks do not mature until the consensus set is synced")
+
+//ConsensusStatus describes the view of the sharechain on the consensus set
+type ConsensusStatus struct {
+	Height types.BlockHeight `json:"height"`
+	//CatchingUp is true while the consensus set is not synced with the network,
+	// found blocks do not mature and shares are accepted between the catchupstarted and catchupfinished audit entries.
+	CatchingUp      bool      `json:"catchingup"`
+	CatchingUpSince time.Time `json:"catchingupsince,omitempty"`
+	//BlocksBehind is estimated from the timestamp of the current block while catching up
+	BlocksBehind types.BlockHeight `json:"blocksbehind"`
+	//QueuedChanges is the number of consensus changes waiting to be processed, a full queue means the pool can not keep up
+	QueuedChanges int `json:"queuedchanges"`
+	//Lag is the number of blocks the sharechain is behind the consensus set because the consensus changes are not processed yet
+	Lag types.BlockHeight `json:"lag"`
+}
+
+//ConsensusStatus returns the height of the sharechain and if it is catching up with the network
+func (sc *ShareChain) ConsensusStatus() (status ConsensusStatus) {
+	// The consensus set is queried without holding the lock of the sharechain,
+	// it calls ProcessConsensusChange with its own lock held.
+	current := sc.Siad.ConsensusSet().CurrentBlock()
+	sc.mu.RLock()
+	status.Height = sc.height
+	status.CatchingUp = !sc.catchingUpSince.IsZero()
+	status.CatchingUpSince = sc.catchingUpSince
+	sc.mu.RUnlock()
+	status.QueuedChanges = sc.QueuedConsensusChanges()
+	status.Lag = sc.ConsensusLag()
+	if status.CatchingUp {
+		if behind := types.CurrentTimestamp() - current.Timestamp; behind > 0 {
+			status.BlocksBehind = types.BlockHeight(behind) / types.BlockFrequency
+		}
+	}
+	return
+}
+
+// updateSync enters the catch-up mode when the consensus set is not synced
+// and leaves it once it is, the maturity of the found blocks paused while
+// catching up is updated right away.
+func (sc *ShareChain) updateSync(synced bool) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	catchingUp := !sc.catchingUpSince.IsZero()
+	if synced != catchingUp {
+		return nil
+	}
+	if !synced {
+		sc.catchingUpSince = time.Now()
+		sc.recordAudit(AuditEntry{Event: AuditCatchUpStarted})
+		sc.log.Println("Consensus set is not synced, catching up with the network")
+		return nil
+	}
+	sc.log.Println("Consensus set synced after catching up since", sc.catchingUpSince)
+	sc.catchingUpSince = time.Time{}
+	sc.recordAudit(AuditEntry{Event: AuditCatchUpFinished})
+	audited := len(sc.unsavedAudit)
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		return sc.updateMaturity(tx)
+	})
+	if err != nil {
+		sc.unsavedAudit = sc.unsavedAudit[:audited]
+	}
+	return err
+}
+
+// threadedMonitorSync checks if the consensus set is synced every
+// syncCheckInterval until the sharechain is closed.
+func (sc *ShareChain) threadedMonitorSync() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(syncCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case <-ticker.C:
+		}
+		if err := sc.updateSync(sc.Siad.ConsensusSet().Synced()); err != nil {
+			sc.log.Println("Error leaving the catch-up mode:", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//CheckpointStatus indicates if the sharechain matches a checkpoint
+type CheckpointStatus string
+
+const (
+	//CheckpointPending is the status of a checkpoint above the height of the sharechain
+	CheckpointPending CheckpointStatus = "pending"
+	//CheckpointVerified is the status of a checkpoint that matches the share at its height
+	CheckpointVerified CheckpointStatus = "verified"
+	//CheckpointMismatch is the status of a checkpoint that does not match the share at its height
+	CheckpointMismatch CheckpointStatus = "mismatch"
+)
+
+var errCheckpointFormat = errors.New("checkpoint must be given as <height>:<share block id>")
+
+//Checkpoint is a trusted share: the share with sequence number Height has to be the block with the given id.
+// The sharechain up to a checkpoint is final.
+type Checkpoint struct {
+	Height  uint64        `json:"height"`
+	BlockID types.BlockID `json:"blockid"`
+}
+
+//ParseCheckpoint parses a checkpoint given as <height>:<share block id>
+func ParseCheckpoint(s string) (checkpoint Checkpoint, err error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		err = errCheckpointFormat
+		return
+	}
+	if checkpoint.Height, err = strconv.ParseUint(parts[0], 10, 64); err != nil || checkpoint.Height == 0 {
+		err = errCheckpointFormat
+		return
+	}
+	var id crypto.Hash
+	if err = id.LoadString(parts[1]); err != nil {
+		err = errCheckpointFormat
+		return
+	}
+	checkpoint.BlockID = types.BlockID(id)
+	return
+}
+
+//CheckpointInfo is a checkpoint with its status
+type CheckpointInfo struct {
+	Checkpoint
+	Status CheckpointStatus `json:"status"`
+}
+
+//Checkpoints returns the configured checkpoints with their status, shares that are not written to disk yet are pending
+func (sc *ShareChain) Checkpoints() (checkpoints []CheckpointInfo, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	checkpoints = make([]CheckpointInfo, 0, len(sc.config.Checkpoints))
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		for _, checkpoint := range sc.config.Checkpoints {
+			status, err := checkpointStatus(tx, checkpoint)
+			if err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, CheckpointInfo{Checkpoint: checkpoint, Status: status})
+		}
+		return nil
+	})
+	return
+}
+
+// verifyCheckpoints returns an error if a stored share does not match a
+// checkpoint.
+func (sc *ShareChain) verifyCheckpoints() error {
+	checkpoints, err := sc.Checkpoints()
+	if err != nil {
+		return err
+	}
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Status == CheckpointMismatch {
+			return fmt.Errorf("share %v of the sharechain does not match checkpoint %v, the database is not the trusted sharechain", checkpoint.Height, checkpoint.BlockID)
+		}
+	}
+	return nil
+}
+
+// checkpointStatus compares a checkpoint with the share stored at its height.
+func checkpointStatus(tx *bolt.Tx, checkpoint Checkpoint) (CheckpointStatus, error) {
+	raw := tx.Bucket(Shares).Get(shareKey(checkpoint.Height))
+	if raw == nil {
+		return CheckpointPending, nil
+	}
+	var share Share
+	if err := encoding.Unmarshal(raw, &share); err != nil {
+		return "", err
+	}
+	if share.BlockID != checkpoint.BlockID {
+		return CheckpointMismatch, nil
+	}
+	return CheckpointVerified, nil
+}
@@ -0,0 +1,48 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestParseCheckpoint(t *testing.T) {
+	id := types.BlockID{1, 2, 3}
+	checkpoint, err := ParseCheckpoint("42:" + id.String())
+	if err != nil || checkpoint.Height != 42 || checkpoint.BlockID != id {
+		t.Error("Expected checkpoint 42 for", id, "got", checkpoint, err)
+	}
+	for _, invalid := range []string{"", "42", "0:" + id.String(), "x:" + id.String(), "42:abc", "1:2:3"} {
+		if _, err = ParseCheckpoint(invalid); err == nil {
+			t.Error("Invalid checkpoint", invalid, "accepted")
+		}
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	for i := byte(1); i <= 3; i++ {
+		sc.AddShare(Share{BlockID: types.BlockID{i}})
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc.config.Checkpoints = []Checkpoint{{Height: 2, BlockID: types.BlockID{2}}, {Height: 10, BlockID: types.BlockID{10}}}
+	checkpoints, err := sc.Checkpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 2 || checkpoints[0].Status != CheckpointVerified || checkpoints[1].Status != CheckpointPending {
+		t.Error("Expected a verified and a pending checkpoint, got", checkpoints)
+	}
+	if err = sc.verifyCheckpoints(); err != nil {
+		t.Error(err)
+	}
+
+	sc.config.Checkpoints = []Checkpoint{{Height: 3, BlockID: types.BlockID{2}}}
+	if err = sc.verifyCheckpoints(); err == nil {
+		t.Error("Sharechain that does not match a checkpoint accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//DefaultConsensusQueueSize is the default number of consensus changes that are queued for processing
+const DefaultConsensusQueueSize = 100
+
+//DefaultMaxConsensusLag is the default number of blocks the processing of the consensus changes can lag behind
+// the consensus set before the node reports that it is degraded
+const DefaultMaxConsensusLag = 6
+
+var errConsensusLag = errors.New("the pool can not keep up with the consensus set")
+
+//queueFullLogInterval limits how often a full consensus change queue is logged
+const queueFullLogInterval = time.Minute
+
+//ProcessConsensusChange queues a consensus change for processing, so the pool never stalls the consensus set.
+// If the queue is full, the pool can not keep up and the consensus set waits until there is room again.
+func (sc *ShareChain) ProcessConsensusChange(cc modules.ConsensusChange) {
+	sc.pendingChanges.Add(1)
+	sc.queueMutex.Lock()
+	sc.queuedBlocks += len(cc.AppliedBlocks) - len(cc.RevertedBlocks)
+	sc.queueMutex.Unlock()
+	select {
+	case sc.consensusChanges <- cc:
+		return
+	default:
+	}
+	sc.queueMutex.Lock()
+	if now := time.Now(); now.Sub(sc.queueFullLogged) >= queueFullLogInterval {
+		sc.queueFullLogged = now
+		sc.log.Println("Consensus change queue of", cap(sc.consensusChanges), "changes is full, the pool can not keep up with the consensus set")
+	}
+	sc.queueMutex.Unlock()
+	sc.consensusChanges <- cc
+}
+
+//QueuedConsensusChanges returns the number of consensus changes waiting to be processed
+func (sc *ShareChain) QueuedConsensusChanges() int {
+	return len(sc.consensusChanges)
+}
+
+//ConsensusLag returns the number of blocks the consensus changes that are queued or being processed add to the chain,
+// the number of blocks the view of the sharechain is behind the consensus set
+func (sc *ShareChain) ConsensusLag() types.BlockHeight {
+	sc.queueMutex.Lock()
+	defer sc.queueMutex.Unlock()
+	if sc.queuedBlocks <= 0 {
+		return 0
+	}
+	return types.BlockHeight(sc.queuedBlocks)
+}
+
+//Lagging returns an error while the sharechain is more than MaxConsensusLag blocks behind the consensus set,
+// the templates might be built on a stale tip. The consensus set itself is not queried, it might be blocked on the full queue.
+func (sc *ShareChain) Lagging() error {
+	if sc.config.MaxConsensusLag == 0 {
+		return nil
+	}
+	if lag := sc.ConsensusLag(); lag > sc.config.MaxConsensusLag {
+		return fmt.Errorf("%v: %v blocks behind, more than the %v allowed", errConsensusLag, lag, sc.config.MaxConsensusLag)
+	}
+	return nil
+}
+
+// threadedProcessConsensusChanges processes the queued consensus changes in
+// order until the sharechain is closed. Changes that are still queued are not
+// marked as processed, they are delivered again after a restart.
+func (sc *ShareChain) threadedProcessConsensusChanges() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case cc := <-sc.consensusChanges:
+			sc.processConsensusChange(cc)
+			sc.queueMutex.Lock()
+			sc.queuedBlocks -= len(cc.AppliedBlocks) - len(cc.RevertedBlocks)
+			sc.queueMutex.Unlock()
+			sc.templateAfterChange()
+			sc.pendingChanges.Done()
+		}
+	}
+}
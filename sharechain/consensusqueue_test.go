@@ -0,0 +1,71 @@
+package sharechain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsensusQueue(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{ConsensusQueueSize: 1})
+	defer cleanup()
+
+	// A busy pool does not process the changes, the consensus set waits once
+	// the queue is full.
+	sc.mu.Lock()
+	mined := make(chan struct{})
+	go func() {
+		mock.Mine(3)
+		close(mined)
+	}()
+	for i := 0; i < 100 && sc.QueuedConsensusChanges() < 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-mined:
+		t.Error("Consensus set did not wait for a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if queued := sc.QueuedConsensusChanges(); queued != 1 {
+		t.Error("Expected 1 queued change, got", queued)
+	}
+	sc.mu.Unlock()
+
+	<-mined
+	sc.pendingChanges.Wait()
+	if status := sc.ConsensusStatus(); status.Height != 3 || status.QueuedChanges != 0 {
+		t.Error("Expected all changes processed up to height 3, got", status)
+	}
+}
+
+func TestConsensusLag(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{MaxConsensusLag: 2})
+	defer cleanup()
+
+	// The changes of a busy pool are queued, the processing lags behind.
+	sc.mu.Lock()
+	mock.Mine(4)
+	if lag := sc.ConsensusLag(); lag != 4 {
+		t.Error("Expected a lag of 4 blocks, got", lag)
+	}
+	if err := sc.Lagging(); err == nil || !strings.Contains(err.Error(), errConsensusLag.Error()) {
+		t.Error("Expected the node to be degraded by the lag, got", err)
+	}
+	sc.mu.Unlock()
+
+	sc.pendingChanges.Wait()
+	if status := sc.ConsensusStatus(); status.Height != 4 || status.Lag != 0 {
+		t.Error("Expected no lag at height 4, got", status)
+	}
+	if err := sc.Lagging(); err != nil {
+		t.Error("Node lagging after catching up:", err)
+	}
+
+	sc.config.MaxConsensusLag = 0
+	sc.mu.Lock()
+	mock.Mine(4)
+	if err := sc.Lagging(); err != nil {
+		t.Error("Lag checked while disabled:", err)
+	}
+	sc.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+package sharechain
+
+import (
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//EarningsBreakdown splits what a miner address is paid by state. The Earnings add up to the Matured payouts plus the
+// Credits minus the Debits. Miners are paid by the miner payouts of the found blocks, nothing is held back below a
+// threshold.
+type EarningsBreakdown struct {
+	//Pending are the payouts of the found blocks that did not mature yet, they are not part of the Earnings
+	Pending types.Currency
+	//Matured are the payouts of the matured blocks, they are final and spendable by the miner
+	Matured types.Currency
+	//Credits and Debits are the adjustments of the earnings the operator settles with the miner outside of the pool
+	Credits types.Currency
+	Debits  types.Currency
+	//Earnings are the matured payouts corrected by the adjustments
+	Earnings types.Currency
+}
+
+//EarningsBreakdown returns the pending and matured payouts, the adjustments and the earnings of a miner address
+func (sc *ShareChain) EarningsBreakdown(address types.UnlockHash) (breakdown EarningsBreakdown, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	breakdown = EarningsBreakdown{Pending: types.ZeroCurrency, Matured: types.ZeroCurrency, Credits: types.ZeroCurrency, Debits: types.ZeroCurrency, Earnings: types.ZeroCurrency}
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+			var fb FoundBlock
+			if err := encoding.Unmarshal(v, &fb); err != nil {
+				return err
+			}
+			for _, payout := range fb.Payouts {
+				if payout.UnlockHash != address {
+					continue
+				}
+				switch fb.Status {
+				case BlockPending:
+					breakdown.Pending = breakdown.Pending.Add(payout.Value)
+				case BlockMatured:
+					breakdown.Matured = breakdown.Matured.Add(payout.Value)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		adjustments, err := storedAdjustments(tx)
+		if err != nil {
+			return err
+		}
+		for _, adjustment := range adjustments {
+			if adjustment.Address != address {
+				continue
+			}
+			if adjustment.Debit {
+				breakdown.Debits = breakdown.Debits.Add(adjustment.Value)
+			} else {
+				breakdown.Credits = breakdown.Credits.Add(adjustment.Value)
+			}
+		}
+		if raw := tx.Bucket(Earnings).Get(address[:]); raw != nil {
+			return encoding.Unmarshal(raw, &breakdown.Earnings)
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,70 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestEarningsBreakdown(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 2})
+	defer cleanup()
+	address := types.UnlockHash{1}
+
+	matured := testBlock(1, 1000)
+	pending := types.Block{Nonce: types.BlockNonce{2}, MinerPayouts: []types.SiacoinOutput{
+		{Value: types.NewCurrency64(300), UnlockHash: address},
+		{Value: types.NewCurrency64(700), UnlockHash: types.UnlockHash{3}},
+	}}
+	orphaned := testBlock(4, 0)
+	orphaned.MinerPayouts = []types.SiacoinOutput{{Value: types.NewCurrency64(50), UnlockHash: address}}
+	for _, b := range []types.Block{matured, orphaned} {
+		if err := sc.AddFoundBlock(b, types.UnlockHash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{matured, orphaned}})
+	sc.processConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{orphaned}, AppliedBlocks: []types.Block{testBlock(5, 0)}})
+	if err := sc.AddFoundBlock(pending, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{pending}})
+
+	if _, err := sc.AdjustEarnings(Adjustment{Address: address, Value: types.NewCurrency64(100), Reason: "refund"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AdjustEarnings(Adjustment{Address: address, Value: types.NewCurrency64(40), Debit: true, Reason: "correction"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AdjustEarnings(Adjustment{Address: types.UnlockHash{3}, Value: types.NewCurrency64(5), Reason: "other miner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	breakdown, err := sc.EarningsBreakdown(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		value    types.Currency
+		expected uint64
+	}{
+		{"pending", breakdown.Pending, 300},
+		{"matured", breakdown.Matured, 1000},
+		{"credits", breakdown.Credits, 100},
+		{"debits", breakdown.Debits, 40},
+		{"earnings", breakdown.Earnings, 1060},
+	} {
+		if test.value.Cmp(types.NewCurrency64(test.expected)) != 0 {
+			t.Error("Expected", test.name, "of", test.expected, "got", test.value)
+		}
+	}
+	if breakdown.Matured.Add(breakdown.Credits).Cmp(breakdown.Earnings.Add(breakdown.Debits)) != 0 {
+		t.Error("Earnings do not add up to the matured payouts and the adjustments:", breakdown)
+	}
+
+	if breakdown, err = sc.EarningsBreakdown(types.UnlockHash{9}); err != nil || !breakdown.Earnings.IsZero() || !breakdown.Pending.IsZero() {
+		t.Error("Expected an empty breakdown for an unknown address, got", breakdown, err)
+	}
+}
@@ -0,0 +1,26 @@
+package sharechain
+
+import (
+	"github.com/siapool/p2pool/events"
+)
+
+//Events returns the bus the sharechain publishes its events on: accepted shares, found and orphaned blocks,
+// payouts and reorgs. Found blocks and payouts are published once they are written to the database.
+func (sc *ShareChain) Events() *events.Bus {
+	return sc.bus
+}
+
+// publishAudit publishes the found blocks, orphaned blocks and payouts among
+// audit entries that are written to the database.
+func (sc *ShareChain) publishAudit(entries []AuditEntry) {
+	for _, entry := range entries {
+		switch entry.Event {
+		case AuditBlockFound:
+			sc.bus.Publish(events.BlockFound{BlockID: entry.BlockID, Finder: entry.Address, Reward: entry.Value, Timestamp: entry.Timestamp})
+		case AuditBlockOrphaned:
+			sc.bus.Publish(events.BlockOrphaned{BlockID: entry.BlockID, Timestamp: entry.Timestamp})
+		case AuditPayout:
+			sc.bus.Publish(events.Payout{BlockID: entry.BlockID, Address: entry.Address, Value: entry.Value, Timestamp: entry.Timestamp})
+		}
+	}
+}
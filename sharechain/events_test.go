@@ -0,0 +1,42 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/events"
+)
+
+func TestEvents(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 1})
+	defer cleanup()
+	s := sc.Events().Subscribe(10, nil)
+	defer s.Close()
+	finder := types.UnlockHash{1}
+
+	sc.AddShare(testShares(finder)[0])
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, finder); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{found}})
+	sc.processConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{found}, AppliedBlocks: []types.Block{testBlock(2, 0), testBlock(3, 0)}})
+
+	if e, ok := (<-s.Events()).(events.ShareAccepted); !ok || e.Miner != finder.String()+".rig" || e.Timestamp == 0 {
+		t.Error("Expected the accepted share, got", e)
+	}
+	if e, ok := (<-s.Events()).(events.BlockFound); !ok || e.BlockID != found.ID() || e.Finder != finder {
+		t.Error("Expected the found block, got", e)
+	}
+	if e, ok := (<-s.Events()).(events.Payout); !ok || e.Address != finder || e.Value.Cmp(types.NewCurrency64(1000)) != 0 || e.Timestamp == 0 {
+		t.Error("Expected the payout, got", e)
+	}
+	if e, ok := (<-s.Events()).(events.Reorg); !ok || e.Reverted != 1 || e.Applied != 2 || e.Height != 2 {
+		t.Error("Expected the reorg, got", e)
+	}
+	if n := len(s.Events()); n != 0 {
+		t.Error("Expected no more events, got", n)
+	}
+}
@@ -0,0 +1,324 @@
+package sharechain
+
+import (
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+
+	"github.com/siapool/p2pool/events"
+)
+
+//BlockStatus indicates if the payouts of a found block are final
+type BlockStatus string
+
+const (
+	//BlockPending is the status of a found block that does not have enough confirmations yet
+	BlockPending BlockStatus = "pending"
+	//BlockMatured is the status of a found block of which the payouts are final
+	BlockMatured BlockStatus = "matured"
+	//BlockOrphaned is the status of a found block that is no longer part of the main chain
+	BlockOrphaned BlockStatus = "orphaned"
+	//BlockSubmissionFailed is the status of a found block the consensus set did not accept, it can be resubmitted
+	BlockSubmissionFailed BlockStatus = "submissionfailed"
+)
+
+//DefaultBlockMaturity is the default number of confirmations required before the payouts of a found block are final
+const DefaultBlockMaturity = 144
+
+//DefaultMaxReorgDepth is the default maximum number of blocks a reorg can revert before the accounting stops.
+// Reorgs of more than a few blocks do not happen on a healthy network, a low limit means a deep reorg needs
+// the attention of the operator instead of silently orphaning pending blocks or reverting matured payouts.
+const DefaultMaxReorgDepth = 10
+
+//FoundBlock is a block found by the pool together with the payouts it contains
+type FoundBlock struct {
+	ID            types.BlockID         `json:"id"`
+	Height        types.BlockHeight     `json:"height"`
+	Timestamp     types.Timestamp       `json:"timestamp"`
+	Finder        types.UnlockHash      `json:"finder"`
+	Payouts       []types.SiacoinOutput `json:"payouts"`
+	Status        BlockStatus           `json:"status"`
+	Confirmations types.BlockHeight     `json:"confirmations"`
+	//ShareIndex is the sequence number of the last share in the pplns window of the block, the window is cut off at the
+	// last share accepted before the template of the block was built, or before it was registered with AddFoundBlock
+	ShareIndex uint64 `json:"shareindex"`
+}
+
+//Reward returns the total value of the payouts of the block
+func (fb *FoundBlock) Reward() (reward types.Currency) {
+	reward = types.ZeroCurrency
+	for _, payout := range fb.Payouts {
+		reward = reward.Add(payout.Value)
+	}
+	return
+}
+
+//MaturesIn returns the number of confirmations a pending block still needs before its payouts are final at the
+// given maturity, 0 for blocks that are not pending
+func (fb *FoundBlock) MaturesIn(maturity types.BlockHeight) types.BlockHeight {
+	if fb.Status != BlockPending || fb.Confirmations >= maturity {
+		return 0
+	}
+	return maturity - fb.Confirmations
+}
+
+//BlockMaturity returns the number of confirmations a found block needs before its payouts are final
+func (sc *ShareChain) BlockMaturity() types.BlockHeight {
+	return sc.config.BlockMaturity
+}
+
+//AddFoundBlock registers a block found by the pool as pending, its payouts were generated from the current pplns window.
+// It needs to be called before the block is submitted to the consensus set.
+// Shares accepted afterwards are never part of the window of the block, the LateShares policy decides
+// if the ones built on the same parent count toward the next block.
+func (sc *ShareChain) AddFoundBlock(b types.Block, finder types.UnlockHash) error {
+	window := sc.PPLNSWindow()
+	sc.mu.RLock()
+	shareIndex := sc.totalShares
+	sc.mu.RUnlock()
+	return sc.addFoundBlock(b, finder, shareIndex, window)
+}
+
+//AddMinerBlock registers a block a miner found with the block of template that MinerBlock returned, like AddFoundBlock.
+// The window of the block is the pplns window of the template, so its payouts can be replayed from the shares.
+func (sc *ShareChain) AddMinerBlock(template Template, b types.Block, finder types.UnlockHash) error {
+	return sc.addFoundBlock(b, finder, template.ShareIndex, template.windowSize)
+}
+
+// addFoundBlock registers a found block with the pplns window of size window
+// that ends at the share with sequence number shareIndex.
+func (sc *ShareChain) addFoundBlock(b types.Block, finder types.UnlockHash, shareIndex uint64, window int) error {
+	expected := sc.expectedShares()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	fb := FoundBlock{
+		ID:         b.ID(),
+		Height:     sc.height + 1,
+		Timestamp:  b.Timestamp,
+		Finder:     finder,
+		Payouts:    b.MinerPayouts,
+		Status:     BlockPending,
+		ShareIndex: shareIndex,
+	}
+	var r Round
+	err := sc.db.Update(func(tx *bolt.Tx) (err error) {
+		if tx.Bucket(FoundBlocks).Get(fb.ID[:]) != nil {
+			return errRepeatInsert
+		}
+		if r, err = sc.endRound(tx, fb, expected); err != nil {
+			return err
+		}
+		if err := putRewardSplit(tx, newRewardSplit(fb, sc.config.Fee, sc.config.FeeAddress)); err != nil {
+			return err
+		}
+		if err := addBlockFound(tx); err != nil {
+			return err
+		}
+		if err := putPPLNSWindow(tx, fb.ID, window); err != nil {
+			return err
+		}
+		if err := putSolverWeight(tx, fb.ID, sc.config.solverWeight()); err != nil {
+			return err
+		}
+		return putFoundBlock(tx, fb)
+	})
+	if err == nil {
+		sc.startRound(r)
+		sc.foundParent = b.ParentID
+		sc.recordAudit(AuditEntry{Event: AuditBlockFound, BlockID: fb.ID, Address: finder, Value: fb.Reward()})
+		sc.publishAudit(sc.unsavedAudit[len(sc.unsavedAudit)-1:])
+	}
+	return err
+}
+
+//FoundBlocks returns all blocks found by the pool
+func (sc *ShareChain) FoundBlocks() (blocks []FoundBlock, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	blocks = make([]FoundBlock, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+			var fb FoundBlock
+			if err := encoding.Unmarshal(v, &fb); err != nil {
+				return err
+			}
+			blocks = append(blocks, fb)
+			return nil
+		})
+	})
+	return
+}
+
+// processConsensusChange keeps track of the confirmations of the found
+// blocks. Payouts of a found block are added to the earnings of the miners
+// once the block has matured, a found block that is reverted before maturity
+// is marked as orphaned.
+func (sc *ShareChain) processConsensusChange(cc modules.ConsensusChange) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.invalidateTemplate()
+	if sc.halted != nil {
+		return
+	}
+	if depth := types.BlockHeight(len(cc.RevertedBlocks)); depth > sc.config.MaxReorgDepth {
+		// The change is not marked as processed, so it is delivered again after
+		// a restart with a higher limit.
+		sc.halted = fmt.Errorf("reorg of %v blocks exceeds the maximum reorg depth of %v, accounting stopped at height %v", depth, sc.config.MaxReorgDepth, sc.height)
+		sc.log.Critical(sc.halted, "- check the chain and restart with a higher --max-reorg-depth to accept it")
+		return
+	}
+	audited := len(sc.unsavedAudit)
+
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range cc.RevertedBlocks {
+			if err := sc.setFoundBlockInPath(tx, b.ID(), false); err != nil {
+				return err
+			}
+			if b.ID() != types.GenesisID {
+				sc.height--
+			}
+		}
+		for _, b := range cc.AppliedBlocks {
+			if b.ID() != types.GenesisID {
+				sc.height++
+			}
+			if err := sc.setFoundBlockInPath(tx, b.ID(), true); err != nil {
+				return err
+			}
+		}
+		// While catching up the payouts are not final, the maturity is updated
+		// once the consensus set is synced.
+		if sc.catchingUpSince.IsZero() {
+			if err := sc.updateMaturity(tx); err != nil {
+				return err
+			}
+		}
+		sc.lastChange = cc.ID
+		return sc.saveConsensusState(tx)
+	})
+	if err != nil {
+		// Nothing was changed, so neither are the events audited.
+		sc.unsavedAudit = sc.unsavedAudit[:audited]
+		sc.log.Critical("Error processing consensus change:", err)
+		return
+	}
+	sc.publishAudit(sc.unsavedAudit[audited:])
+	if len(cc.RevertedBlocks) > 0 {
+		sc.bus.Publish(events.Reorg{Reverted: len(cc.RevertedBlocks), Applied: len(cc.AppliedBlocks), Height: sc.height, Timestamp: types.CurrentTimestamp()})
+	}
+}
+
+// setFoundBlockInPath marks a found block as pending when it is applied to the
+// main chain or as orphaned when it is reverted. Blocks not found by the pool
+// are ignored.
+func (sc *ShareChain) setFoundBlockInPath(tx *bolt.Tx, id types.BlockID, inPath bool) error {
+	raw := tx.Bucket(FoundBlocks).Get(id[:])
+	if raw == nil {
+		return nil
+	}
+	var fb FoundBlock
+	if err := encoding.Unmarshal(raw, &fb); err != nil {
+		return err
+	}
+	if fb.Status == BlockMatured {
+		sc.log.Critical("Matured block", id, "reverted")
+		return nil
+	}
+	if inPath {
+		fb.Status = BlockPending
+		fb.Height = sc.height
+		if sc.inPath == nil {
+			sc.inPath = make(map[types.BlockID]bool)
+		}
+		sc.inPath[id] = true
+		// A block of which the submission timed out can still be accepted.
+		if err := tx.Bucket(FailedSubmissions).Delete(id[:]); err != nil {
+			return err
+		}
+	} else {
+		delete(sc.inPath, id)
+		fb.Status = BlockOrphaned
+		fb.Confirmations = 0
+		sc.log.Println("Found block", id, "orphaned")
+	}
+	return putFoundBlock(tx, fb)
+}
+
+// updateMaturity updates the confirmations of the pending blocks and adds the
+// payouts of the blocks that reached the required maturity to the earnings.
+func (sc *ShareChain) updateMaturity(tx *bolt.Tx) error {
+	var pending []FoundBlock
+	err := tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+		var fb FoundBlock
+		if err := encoding.Unmarshal(v, &fb); err != nil {
+			return err
+		}
+		if fb.Status == BlockPending && fb.Height <= sc.height {
+			pending = append(pending, fb)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, fb := range pending {
+		fb.Confirmations = sc.height - fb.Height + 1
+		if fb.Confirmations >= sc.config.BlockMaturity {
+			fb.Status = BlockMatured
+			for _, payout := range fb.Payouts {
+				if payout.Value.IsZero() {
+					continue
+				}
+				if err = addEarnings(tx, payout.UnlockHash, payout.Value); err != nil {
+					return err
+				}
+				if err = addPaid(tx, payout.Value); err != nil {
+					return err
+				}
+				sc.recordAudit(AuditEntry{Event: AuditPayout, BlockID: fb.ID, Address: payout.UnlockHash, Value: payout.Value})
+			}
+			sc.log.Println("Found block", fb.ID, "matured")
+		}
+		if err = putFoundBlock(tx, fb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Ready returns an error if the accounting stopped because of a reorg deeper than the MaxReorgDepth or a failed
+// self-check, if the last self-check failed, while catching up with the network or while the gateway has fewer
+// than MinPeers peers
+func (sc *ShareChain) Ready() error {
+	sc.mu.RLock()
+	halted, inconsistency, catchingUp := sc.halted, sc.inconsistency, !sc.catchingUpSince.IsZero()
+	sc.mu.RUnlock()
+	if halted != nil {
+		return halted
+	}
+	if inconsistency != nil {
+		return inconsistency
+	}
+	if catchingUp {
+		return errCatchingUp
+	}
+	return sc.checkPeers()
+}
+
+//Earnings returns the matured payouts of a miner address
+func (sc *ShareChain) Earnings(address types.UnlockHash) (earnings types.Currency, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	earnings = types.ZeroCurrency
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(Earnings).Get(address[:])
+		if raw == nil {
+			return nil
+		}
+		return encoding.Unmarshal(raw, &earnings)
+	})
+	return
+}
@@ -0,0 +1,140 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/events"
+)
+
+func newTestShareChain(t testing.TB, config Config) (sc *ShareChain, cleanup func()) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.setDefaults()
+	sc = &ShareChain{persistDir: dir, Target: StartTarget, config: config, bus: events.NewBus()}
+	if err = sc.initPersist(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup = func() {
+		sc.db.Close()
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+func testBlock(nonce byte, value uint64) types.Block {
+	return types.Block{
+		Nonce:        types.BlockNonce{nonce},
+		MinerPayouts: []types.SiacoinOutput{{Value: types.NewCurrency64(value), UnlockHash: types.UnlockHash{nonce}}},
+	}
+}
+
+func statusOf(t *testing.T, sc *ShareChain, id types.BlockID) BlockStatus {
+	blocks, err := sc.FoundBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fb := range blocks {
+		if fb.ID == id {
+			return fb.Status
+		}
+	}
+	t.Fatal("block", id, "not found")
+	return ""
+}
+
+func TestBlockMaturity(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 3})
+	defer cleanup()
+
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != errRepeatInsert {
+		t.Error("Expected", errRepeatInsert, "got", err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{found}})
+	if status := statusOf(t, sc, found.ID()); status != BlockPending {
+		t.Error("Expected pending block, got", status)
+	}
+
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{testBlock(2, 0)}})
+	earnings, _ := sc.Earnings(types.UnlockHash{1})
+	if !earnings.IsZero() {
+		t.Error("Earnings added before maturity:", earnings)
+	}
+
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{testBlock(3, 0)}})
+	if status := statusOf(t, sc, found.ID()); status != BlockMatured {
+		t.Error("Expected matured block, got", status)
+	}
+	earnings, _ = sc.Earnings(types.UnlockHash{1})
+	if earnings.Cmp(types.NewCurrency64(1000)) != 0 {
+		t.Error("Expected earnings of 1000, got", earnings)
+	}
+}
+
+func TestBlockOrphanedBeforeMaturity(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 3})
+	defer cleanup()
+
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{found}})
+	sc.processConsensusChange(modules.ConsensusChange{
+		RevertedBlocks: []types.Block{found},
+		AppliedBlocks:  []types.Block{testBlock(2, 0), testBlock(3, 0), testBlock(4, 0)},
+	})
+	if status := statusOf(t, sc, found.ID()); status != BlockOrphaned {
+		t.Error("Expected orphaned block, got", status)
+	}
+	earnings, _ := sc.Earnings(types.UnlockHash{1})
+	if !earnings.IsZero() {
+		t.Error("Earnings added for an orphaned block:", earnings)
+	}
+}
+
+func TestMaxReorgDepth(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 3, MaxReorgDepth: 1})
+	defer cleanup()
+
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{ID: modules.ConsensusChangeID{1}, AppliedBlocks: []types.Block{testBlock(2, 0), found}})
+	if err := sc.Ready(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc.processConsensusChange(modules.ConsensusChange{
+		ID:             modules.ConsensusChangeID{2},
+		RevertedBlocks: []types.Block{found, testBlock(2, 0)},
+		AppliedBlocks:  []types.Block{testBlock(3, 0), testBlock(4, 0), testBlock(5, 0)},
+	})
+	if sc.Ready() == nil {
+		t.Error("Reorg deeper than the maximum accepted")
+	}
+	if status := statusOf(t, sc, found.ID()); status != BlockPending {
+		t.Error("Expected the block to stay pending, got", status)
+	}
+	if sc.lastChange != (modules.ConsensusChangeID{1}) || sc.height != 2 {
+		t.Error("Refused reorg processed, at change", sc.lastChange, "height", sc.height)
+	}
+
+	// Later changes build on the refused reorg and are ignored as well.
+	sc.processConsensusChange(modules.ConsensusChange{ID: modules.ConsensusChangeID{3}, AppliedBlocks: []types.Block{testBlock(6, 0)}})
+	if sc.lastChange != (modules.ConsensusChangeID{1}) {
+		t.Error("Change processed after a refused reorg")
+	}
+}
@@ -0,0 +1,261 @@
+package sharechain
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//JournalFilename is the name of the journal in the sharechain directory
+	JournalFilename = "sharechain.journal"
+	//MaxJournalEntries is the number of entries the journal holds, entries beyond it are only written to the database
+	MaxJournalEntries = 100000
+	// journalQueueSize is the number of entries waiting for the journal
+	// writer, an entry is left out of the journal if the queue is full.
+	journalQueueSize = 10000
+	// maxJournalEntrySize is the largest encoded journal entry that is read.
+	maxJournalEntrySize = 1 << 16
+)
+
+// keyJournalPosition is the number of the last journal entry that is written
+// to the database, stored in the ShareChainPool bucket.
+var keyJournalPosition = []byte("JournalPosition")
+
+// journalEntry is a share or, if the Audit event is set, an audit entry that
+// is buffered until the next flush. Entries are numbered in the order they
+// are buffered.
+type journalEntry struct {
+	Number uint64
+	Share  Share
+	Audit  AuditEntry
+}
+
+func (sc *ShareChain) journalFilename() string {
+	return filepath.Join(sc.persistDir, JournalFilename)
+}
+
+// journalAdd numbers an entry and hands it to the journal writer without
+// waiting for it. The caller needs to hold the lock.
+func (sc *ShareChain) journalAdd(entry journalEntry) {
+	sc.journalNumber++
+	if sc.journal == nil {
+		return
+	}
+	entry.Number = sc.journalNumber
+	select {
+	case sc.journal <- entry:
+		sc.journalQueueFull = false
+	default:
+		if !sc.journalQueueFull {
+			sc.log.Println("WARN: journal writer is behind, entries are only kept in memory until they are written to the database")
+		}
+		sc.journalQueueFull = true
+		sc.countJournalDrop()
+	}
+}
+
+// countJournalDrop counts an entry that is left out of the journal.
+func (sc *ShareChain) countJournalDrop() {
+	sc.journalMutex.Lock()
+	sc.journalDropped++
+	sc.journalMutex.Unlock()
+}
+
+//JournalDropped returns the number of entries left out of the journal since the start because the journal writer was
+// behind or the journal was full, they are only kept in memory until they are written to the database
+func (sc *ShareChain) JournalDropped() uint64 {
+	sc.journalMutex.Lock()
+	defer sc.journalMutex.Unlock()
+	return sc.journalDropped
+}
+
+// journalCompact tells the journal writer that the entries up to number are
+// written to the database, only the latest number is kept for the writer.
+func (sc *ShareChain) journalCompact(number uint64) {
+	if sc.journal == nil {
+		return
+	}
+	select {
+	case <-sc.journalFlushed:
+	default:
+	}
+	sc.journalFlushed <- number
+}
+
+// openJournal starts an empty journal, the entries of the previous one are
+// replayed when the database is loaded. Without the Journal option a
+// previous journal is removed.
+func (sc *ShareChain) openJournal() (err error) {
+	if !sc.config.Journal {
+		if err = os.Remove(sc.journalFilename()); os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if sc.journalFile, err = os.Create(sc.journalFilename()); err != nil {
+		return
+	}
+	sc.journal = make(chan journalEntry, journalQueueSize)
+	sc.journalFlushed = make(chan uint64, 1)
+	return
+}
+
+// threadedWriteJournal appends the buffered entries to the journal and drops
+// the entries that are written to the database, until the sharechain is
+// closed. The file is not synced, the journal survives a crash of the process
+// and the database stays the durable record.
+func (sc *ShareChain) threadedWriteJournal() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	var pending []journalEntry
+	var flushed uint64
+	var full bool
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case entry := <-sc.journal:
+			if entry.Number <= flushed {
+				continue
+			}
+			if len(pending) >= MaxJournalEntries {
+				if !full {
+					sc.log.Println("WARN: journal is full, entries are only kept in memory until they are written to the database")
+				}
+				full = true
+				sc.countJournalDrop()
+				continue
+			}
+			if err := encoding.WriteObject(sc.journalFile, entry); err != nil {
+				sc.log.Println("Error writing the journal:", err)
+				continue
+			}
+			pending = append(pending, entry)
+		case flushed = <-sc.journalFlushed:
+			i := 0
+			for i < len(pending) && pending[i].Number <= flushed {
+				i++
+			}
+			if i == 0 {
+				continue
+			}
+			pending = append([]journalEntry(nil), pending[i:]...)
+			full = false
+			if err := sc.rewriteJournal(pending); err != nil {
+				sc.log.Println("Error compacting the journal:", err)
+			}
+		}
+	}
+}
+
+// rewriteJournal replaces the journal with the entries that are not written
+// to the database yet.
+func (sc *ShareChain) rewriteJournal(entries []journalEntry) error {
+	tmp := sc.journalFilename() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		if err = encoding.WriteObject(w, entry); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, sc.journalFilename())
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	sc.journalFile.Close()
+	sc.journalFile = f
+	return nil
+}
+
+// readJournal reads the entries of a journal file, a missing journal has no
+// entries. Reading stops at an entry that was cut off by a crash.
+func readJournal(filename string) (entries []journalEntry, torn bool, err error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		var entry journalEntry
+		if rerr := encoding.ReadObject(r, &entry, maxJournalEntrySize); rerr != nil {
+			torn = rerr != io.EOF
+			return
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// replayJournal writes the journal entries that were not written to the
+// database before the last shutdown, so the shares and the rounds are loaded
+// with them.
+func (sc *ShareChain) replayJournal(tx *bolt.Tx) error {
+	b := tx.Bucket(ShareChainPool)
+	sc.journalNumber = 0
+	if raw := b.Get(keyJournalPosition); raw != nil {
+		if err := encoding.Unmarshal(raw, &sc.journalNumber); err != nil {
+			return err
+		}
+	}
+	entries, torn, err := readJournal(sc.journalFilename())
+	if err != nil {
+		return err
+	}
+	if torn {
+		sc.log.Println("WARN: ignoring the incomplete last entry of the journal")
+	}
+	var shares []Share
+	var audit []AuditEntry
+	for _, entry := range entries {
+		if entry.Number <= sc.journalNumber {
+			continue
+		}
+		if entry.Audit.Event == "" {
+			shares = append(shares, entry.Share)
+		} else {
+			audit = append(audit, entry.Audit)
+		}
+		sc.journalNumber = entry.Number
+	}
+	if len(shares) == 0 && len(audit) == 0 {
+		return nil
+	}
+	sc.log.Println("Replaying", len(shares), "shares and", len(audit), "audit entries from the journal")
+	if err = putShares(tx, shares); err != nil {
+		return err
+	}
+	if err = putAudit(tx, audit); err != nil {
+		return err
+	}
+	return putJournalPosition(tx, sc.journalNumber)
+}
+
+// putJournalPosition records the number of the last journal entry that is
+// written to the database.
+func putJournalPosition(tx *bolt.Tx, number uint64) error {
+	return tx.Bucket(ShareChainPool).Put(keyJournalPosition, encoding.Marshal(number))
+}
@@ -0,0 +1,134 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/siad"
+)
+
+// waitForJournal waits until the journal holds n entries.
+func waitForJournal(t *testing.T, sc *ShareChain, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		entries, _, err := readJournal(sc.journalFilename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected", n, "journal entries, got", len(entries))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// crash stops the sharechain without writing the buffered shares.
+func crash(sc *ShareChain) {
+	sc.Siad.ConsensusSet().Unsubscribe(sc)
+	sc.tg.Stop()
+	sc.journalFile.Close()
+	sc.db.Close()
+}
+
+func TestJournalCrashRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mock := siad.NewMock()
+	config := Config{Journal: true, ShareFlushInterval: time.Hour}
+	sc, err := New(mock, dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner := types.UnlockHash{1}.String()
+	for i := 0; i < 5; i++ {
+		sc.AddShare(Share{BlockID: types.BlockID{byte(i)}, Miner: miner})
+	}
+	// Every share is journaled with its audit entry.
+	waitForJournal(t, sc, 10)
+	crash(sc)
+
+	// A crash can cut off the entry that was being written.
+	f, err := os.OpenFile(sc.journalFilename(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{100, 0, 0})
+	f.Close()
+
+	if sc, err = New(mock, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	summary, _ := sc.GetPPLNSSummary()
+	if sc.totalShares != 5 || summary[miner] != 5 || sc.CurrentRound().Shares != 5 {
+		t.Error("Expected the 5 shares to be replayed, got", sc.totalShares, summary, sc.CurrentRound().Shares)
+	}
+	if entries, _ := sc.Audit(time.Unix(0, 0)); len(entries) != 5 {
+		t.Error("Expected the 5 audit entries to be replayed, got", len(entries))
+	}
+	if entries, _, _ := readJournal(sc.journalFilename()); len(entries) != 0 {
+		t.Error("Replayed entries left in the journal")
+	}
+
+	// The entries written to the database are dropped from the journal and
+	// not replayed twice.
+	sc.AddShare(Share{BlockID: types.BlockID{5}, Miner: miner})
+	waitForJournal(t, sc, 2)
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	waitForJournal(t, sc, 0)
+	sc.AddShare(Share{BlockID: types.BlockID{6}, Miner: miner})
+	waitForJournal(t, sc, 2)
+	crash(sc)
+	if sc, err = New(mock, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if sc.totalShares != 7 {
+		t.Error("Expected 7 shares after the second crash, got", sc.totalShares)
+	}
+	if entries, _ := sc.Audit(time.Unix(0, 0)); len(entries) != 7 {
+		t.Error("Expected 7 audit entries after the second crash, got", len(entries))
+	}
+}
+
+func TestJournalWithoutOption(t *testing.T) {
+	sc, _, cleanup := newMockShareChain(t, Config{})
+	defer cleanup()
+	sc.AddShare(Share{Miner: types.UnlockHash{1}.String()})
+	if sc.journal != nil {
+		t.Error("Journal written without the Journal option")
+	}
+	if _, err := os.Stat(sc.journalFilename()); !os.IsNotExist(err) {
+		t.Error("Journal file created without the Journal option")
+	}
+}
+
+func TestJournalDropped(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	// Without a writer the queue of a single entry is full after the first.
+	sc.journal = make(chan journalEntry, 1)
+	sc.mu.Lock()
+	for i := 0; i < 3; i++ {
+		sc.journalAdd(journalEntry{Share: Share{BlockID: types.BlockID{byte(i)}}})
+	}
+	sc.mu.Unlock()
+	if dropped := sc.JournalDropped(); dropped != 2 {
+		t.Error("Expected 2 dropped entries, got", dropped)
+	}
+	if sc.journalNumber != 3 {
+		t.Error("Expected the dropped entries to be numbered, got", sc.journalNumber)
+	}
+	sc.journal = nil
+}
@@ -0,0 +1,30 @@
+package sharechain
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//LateSharePolicy decides what happens to a late share, a share built on the same parent as a block the pool just found.
+// Such a share was submitted for the job of the found block but is accepted after the find.
+type LateSharePolicy string
+
+const (
+	//LateSharesNext counts late shares toward the pplns window of the next block
+	LateSharesNext LateSharePolicy = "next"
+	//LateSharesDrop does not count late shares at all, the work was done on a job that is closed
+	LateSharesDrop LateSharePolicy = "drop"
+)
+
+var errInvalidLateSharePolicy = errors.New("late share policy must be next or drop")
+
+func validLateSharePolicy(policy LateSharePolicy) bool {
+	return policy == LateSharesNext || policy == LateSharesDrop
+}
+
+// lateShare returns if a share is built on the parent of the last found block
+// and accepted after it, the caller needs to hold the lock.
+func (sc *ShareChain) lateShare(share Share) bool {
+	return sc.foundParent != (types.BlockID{}) && share.ParentID == sc.foundParent
+}
@@ -0,0 +1,71 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestLateShares(t *testing.T) {
+	for _, policy := range []LateSharePolicy{LateSharesNext, LateSharesDrop} {
+		config := Config{BlockMaturity: 1, LateShares: policy}
+		sc, cleanup := newTestShareChain(t, config)
+
+		a, b := types.UnlockHash{1}, types.UnlockHash{2}
+		parent := types.BlockID{7}
+		for _, share := range testShares(a, b) {
+			share.ParentID = parent
+			if !sc.AddShare(share) {
+				t.Error(policy, "share before the find not counted")
+			}
+		}
+		block := types.Block{ParentID: parent, Nonce: types.BlockNonce{1}}
+		block.MinerPayouts, _ = sc.GenerateMinerPayouts(a, types.NewCurrency64(1e6))
+		if err := sc.AddFoundBlock(block, a); err != nil {
+			t.Fatal(err)
+		}
+
+		// A share for the job of the found block straddles the find, a share
+		// on the found block is the start of the next window.
+		late := testShares(b)[0]
+		late.ParentID = parent
+		if counted := sc.AddShare(late); counted != (policy == LateSharesNext) {
+			t.Error(policy, "expected the late share counted", policy == LateSharesNext, "got", counted)
+		}
+		next := testShares(b)[0]
+		next.ParentID = block.ID()
+		if !sc.AddShare(next) {
+			t.Error(policy, "share on the found block not counted")
+		}
+		expected := uint64(4)
+		if policy == LateSharesDrop {
+			expected = 3
+		}
+		if sc.totalShares != expected {
+			t.Error(policy, "expected", expected, "shares, got", sc.totalShares)
+		}
+		blocks, err := sc.FoundBlocks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 1 || blocks[0].ShareIndex != 2 {
+			t.Error(policy, "expected the window of the found block to end at share 2, got", blocks)
+		}
+
+		// The window stays the same when the payouts are replayed.
+		if err = sc.flushShares(); err != nil {
+			t.Fatal(err)
+		}
+		sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+		sc.db.Close()
+		discrepancies, err := Recompute(sc.persistDir, config, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(discrepancies) != 0 {
+			t.Error(policy, "unexpected discrepancies:", discrepancies)
+		}
+		cleanup()
+	}
+}
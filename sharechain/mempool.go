@@ -0,0 +1,105 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//DefaultMempoolTimeout is the default time listing the transactions of the transaction pool can take
+const DefaultMempoolTimeout = 2 * time.Second
+
+//MempoolPolicy decides what happens to a block template while the transaction pool is unavailable
+type MempoolPolicy string
+
+const (
+	//MempoolEmpty builds the template without transactions, the miners keep mining on the miner payouts alone
+	MempoolEmpty MempoolPolicy = "empty"
+	//MempoolFail fails building the template, no work is handed out until the transaction pool answers again
+	MempoolFail MempoolPolicy = "fail"
+)
+
+var (
+	errInvalidMempoolPolicy = errors.New("mempool policy must be empty or fail")
+	errMempoolUnavailable   = errors.New("the transaction pool is unavailable")
+	errMempoolTimeout       = errors.New("listing the transactions of the transaction pool timed out")
+)
+
+func validMempoolPolicy(policy MempoolPolicy) bool {
+	return policy == MempoolEmpty || policy == MempoolFail
+}
+
+// mempoolCall is a listing of the transactions of the transaction pool, done
+// is closed once txns or err are set.
+type mempoolCall struct {
+	done chan struct{}
+	txns []types.Transaction
+	err  error
+}
+
+//Degraded returns why the current block templates are built without transactions, nil while the transaction pool is available
+func (sc *ShareChain) Degraded() error {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.mempoolDegraded
+}
+
+// mempoolTransactions lists the transactions of the transaction pool and
+// gives up waiting after the MempoolTimeout. A listing that hangs is not
+// started again until it returns, templates built in the meantime join it.
+func (sc *ShareChain) mempoolTransactions() ([]types.Transaction, error) {
+	sc.mu.Lock()
+	call := sc.mempoolCall
+	if call == nil {
+		call = &mempoolCall{done: make(chan struct{})}
+		sc.mempoolCall = call
+		go sc.threadedListTransactions(call)
+	}
+	sc.mu.Unlock()
+	timeout := time.NewTimer(sc.config.MempoolTimeout)
+	defer timeout.Stop()
+	select {
+	case <-call.done:
+		return call.txns, call.err
+	case <-timeout.C:
+		return nil, errMempoolTimeout
+	}
+}
+
+// threadedListTransactions completes a listing of the transaction pool, a
+// panicking transaction pool fails the listing instead of the node.
+func (sc *ShareChain) threadedListTransactions(call *mempoolCall) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.txns, call.err = nil, fmt.Errorf("the transaction pool failed: %v", r)
+		}
+		sc.mu.Lock()
+		sc.mempoolCall = nil
+		sc.mu.Unlock()
+		close(call.done)
+	}()
+	tpool := sc.Siad.TransactionPool()
+	if tpool == nil {
+		call.err = errMempoolUnavailable
+		return
+	}
+	call.txns = tpool.TransactionList()
+}
+
+// setMempoolDegraded records the outcome of listing the transaction pool for
+// a template, switching to and from templates without transactions is
+// logged.
+func (sc *ShareChain) setMempoolDegraded(err error) {
+	sc.mu.Lock()
+	previous := sc.mempoolDegraded
+	sc.mempoolDegraded = err
+	sc.mu.Unlock()
+	if err != nil && previous == nil {
+		sc.log.Println("Building block templates without transactions:", err)
+	}
+	if err == nil && previous != nil {
+		sc.log.Println("The transaction pool is available again, block templates include transactions")
+	}
+}
@@ -0,0 +1,105 @@
+package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestEmptyMempool(t *testing.T) {
+	sc, _, cleanup := newMockShareChain(t, Config{FeeAddress: types.UnlockHash{9}})
+	defer cleanup()
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Block.Transactions) != 0 || len(template.Block.MinerPayouts) == 0 {
+		t.Error("Expected a template with only the miner payouts, got", len(template.Block.Transactions), "transactions")
+	}
+	if err = sc.Degraded(); err != nil {
+		t.Error("An empty transaction pool degraded the node:", err)
+	}
+}
+
+func TestMempoolError(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{FeeAddress: types.UnlockHash{9}})
+	defer cleanup()
+	mock.Transactions = []types.Transaction{{ArbitraryData: [][]byte{{1}}}}
+	mock.TransactionListFunc = func() []types.Transaction {
+		panic("transaction pool closed")
+	}
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Block.Transactions) != 0 || len(template.Block.MinerPayouts) == 0 {
+		t.Error("Expected a template with only the miner payouts, got", len(template.Block.Transactions), "transactions")
+	}
+	if sc.Degraded() == nil {
+		t.Error("Node not degraded while the transaction pool fails")
+	}
+
+	mock.TransactionListFunc = nil
+	if template, err = sc.newSourceBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Block.Transactions) != 1 || sc.Degraded() != nil {
+		t.Error("Expected the transactions once the transaction pool recovered, got", len(template.Block.Transactions), "and", sc.Degraded())
+	}
+}
+
+func TestMempoolTimeout(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{MempoolTimeout: 20 * time.Millisecond})
+	defer cleanup()
+	release, listings := make(chan struct{}), make(chan struct{}, 10)
+	mock.TransactionListFunc = func() []types.Transaction {
+		listings <- struct{}{}
+		<-release
+		return []types.Transaction{{ArbitraryData: [][]byte{{1}}}}
+	}
+
+	for i := 0; i < 2; i++ {
+		template, err := sc.newSourceBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(template.Block.Transactions) != 0 {
+			t.Error("Expected a template without transactions while the transaction pool hangs")
+		}
+	}
+	if err := sc.Degraded(); err != errMempoolTimeout {
+		t.Error("Expected", errMempoolTimeout, "got", err)
+	}
+	if n := len(listings); n != 1 {
+		t.Error("Expected the hanging listing to be joined, got", n, "listings")
+	}
+
+	close(release)
+	template, err := sc.newSourceBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Block.Transactions) != 1 || sc.Degraded() != nil {
+		t.Error("Expected the transactions once the transaction pool answers, got", len(template.Block.Transactions), "and", sc.Degraded())
+	}
+}
+
+func TestMempoolFailPolicy(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{MempoolUnavailable: MempoolFail})
+	defer cleanup()
+	mock.TransactionListFunc = func() []types.Transaction {
+		panic("transaction pool closed")
+	}
+	if _, err := sc.BlockTemplate(); err == nil {
+		t.Error("Template built while the transaction pool fails")
+	}
+	if sc.template != nil || sc.Degraded() != nil {
+		t.Error("Failed template stored or degraded")
+	}
+
+	if _, err := New(nil, "", Config{MempoolUnavailable: "wait"}); err != errInvalidMempoolPolicy {
+		t.Error("Expected", errInvalidMempoolPolicy, "got", err)
+	}
+}
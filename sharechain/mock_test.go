@@ -0,0 +1,213 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/siad"
+)
+
+func newMockShareChain(t testing.TB, config Config) (sc *ShareChain, mock *siad.Mock, cleanup func()) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock = siad.NewMock()
+	sc, err = New(mock, dir, config)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup = func() {
+		sc.Close()
+		os.RemoveAll(dir)
+	}
+	sc.pendingChanges.Wait()
+	return
+}
+
+func TestFoundBlockWithMock(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{BlockMaturity: 2})
+	defer cleanup()
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.Height != 1 || template.Block.ParentID != types.GenesisID {
+		t.Error("Template not built on the current block:", template.Height, template.Block.ParentID)
+	}
+	b := template.Block
+	if err = sc.AddFoundBlock(b, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mock.ConsensusSet().AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, b.ID()); status != BlockPending {
+		t.Error("Expected pending block, got", status)
+	}
+	if sc.template != nil {
+		t.Error("Template not cleared after a new block")
+	}
+	mock.Mine(1)
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, b.ID()); status != BlockMatured {
+		t.Error("Expected matured block, got", status)
+	}
+}
+
+func TestOrphanedBlockWithMock(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{BlockMaturity: 10})
+	defer cleanup()
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.AddFoundBlock(template.Block, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	mock.ConsensusSet().AcceptBlock(template.Block)
+	mock.Reorg(1, types.Block{ParentID: types.GenesisID, Timestamp: 1}, types.Block{Timestamp: 2})
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, template.Block.ID()); status != BlockOrphaned {
+		t.Error("Expected orphaned block, got", status)
+	}
+}
+
+func TestCatchUpWithMock(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{BlockMaturity: 2})
+	defer cleanup()
+	if err := sc.Ready(); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.SetSynced(false)
+	if err := sc.updateSync(mock.ConsensusSet().Synced()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Ready(); err != errCatchingUp {
+		t.Error("Expected", errCatchingUp, "got", err)
+	}
+	if status := sc.ConsensusStatus(); !status.CatchingUp || status.BlocksBehind == 0 {
+		t.Error("Expected to be catching up with blocks behind, got", status)
+	}
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := template.Block
+	if err = sc.AddFoundBlock(b, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mock.ConsensusSet().AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	mock.Mine(2)
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, b.ID()); status != BlockPending {
+		t.Error("Block matured while catching up, status", status)
+	}
+
+	mock.SetSynced(true)
+	if err = sc.updateSync(mock.ConsensusSet().Synced()); err != nil {
+		t.Fatal(err)
+	}
+	if status := statusOf(t, sc, b.ID()); status != BlockMatured {
+		t.Error("Expected the block to mature once synced, got", status)
+	}
+	if err = sc.Ready(); err != nil {
+		t.Error(err)
+	}
+
+	entries, err := sc.Audit(time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []AuditEvent
+	for _, entry := range entries {
+		if entry.Event == AuditCatchUpStarted || entry.Event == AuditCatchUpFinished {
+			events = append(events, entry.Event)
+		}
+	}
+	if len(events) != 2 || events[0] != AuditCatchUpStarted || events[1] != AuditCatchUpFinished {
+		t.Error("Expected the catch-up to be audited, got", events)
+	}
+}
+
+func TestOverlappingPendingBlocksWithMock(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{BlockMaturity: 5, PPLNSWindow: 4})
+	defer cleanup()
+
+	// Three blocks are found in a row, each after shares of another miner.
+	var blocks []types.Block
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 2; j++ {
+			sc.AddShare(Share{BlockID: types.BlockID{byte(i), byte(j)}, Miner: types.UnlockHash{byte(i + 1)}.String()})
+		}
+		template, err := sc.BlockTemplate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := template.Block
+		if err = sc.AddFoundBlock(b, types.UnlockHash{}); err != nil {
+			t.Fatal(err)
+		}
+		if err = mock.ConsensusSet().AcceptBlock(b); err != nil {
+			t.Fatal(err)
+		}
+		sc.pendingChanges.Wait()
+		blocks = append(blocks, b)
+	}
+
+	found, err := sc.FoundBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[types.BlockID]FoundBlock)
+	for _, fb := range found {
+		byID[fb.ID] = fb
+	}
+	expected := make(map[types.UnlockHash]types.Currency)
+	for i, b := range blocks {
+		fb := byID[b.ID()]
+		if fb.Status != BlockPending || fb.ShareIndex != uint64(2*(i+1)) || fb.MaturesIn(sc.BlockMaturity()) != types.BlockHeight(2+i) {
+			t.Error("Block", i, "expected pending with its own window and maturity, got", fb.Status, fb.ShareIndex, fb.MaturesIn(sc.BlockMaturity()))
+		}
+		// The window of the first block only has the first miner, the
+		// windows of the later blocks the last 4 shares.
+		miners := make(map[types.UnlockHash]bool)
+		for _, payout := range fb.Payouts {
+			miners[payout.UnlockHash] = true
+			expected[payout.UnlockHash] = expected[payout.UnlockHash].Add(payout.Value)
+		}
+		if !miners[types.UnlockHash{byte(i + 1)}] || miners[types.UnlockHash{byte(i + 2)}] || (i > 0) != miners[types.UnlockHash{byte(i)}] {
+			t.Error("Block", i, "does not pay the miners of its own window:", fb.Payouts)
+		}
+	}
+
+	// The blocks mature one after the other, each adding its own payouts.
+	mock.Mine(2)
+	sc.pendingChanges.Wait()
+	statuses := []BlockStatus{statusOf(t, sc, blocks[0].ID()), statusOf(t, sc, blocks[1].ID()), statusOf(t, sc, blocks[2].ID())}
+	if statuses[0] != BlockMatured || statuses[1] != BlockPending || statuses[2] != BlockPending {
+		t.Error("Expected only the first block to mature, got", statuses)
+	}
+	mock.Mine(2)
+	sc.pendingChanges.Wait()
+	for address, value := range expected {
+		earnings, err := sc.Earnings(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if earnings.Cmp(value) != 0 {
+			t.Error("Expected earnings of", value, "for", address, "got", earnings)
+		}
+	}
+}
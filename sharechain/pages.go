@@ -0,0 +1,166 @@
+package sharechain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//DefaultPageSize is the number of items in a page if no limit is given
+	DefaultPageSize = 100
+	//MaxPageSize is the maximum number of items in a page
+	MaxPageSize = 1000
+)
+
+//ErrInvalidCursor is returned for a cursor that was not returned with a page
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+//PageQuery selects a page of a list that is ordered by time.
+// From and To limit the list to the items in that time range, 0 leaves the range open.
+// The page starts after the Cursor returned with the previous page, it takes precedence over From.
+// Limit is the number of items in the page, DefaultPageSize if 0 and at most MaxPageSize.
+type PageQuery struct {
+	From   types.Timestamp
+	To     types.Timestamp
+	Cursor string
+	Limit  int
+}
+
+func (q PageQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return q.Limit
+}
+
+// beforeEnd returns true if a timestamp is not past the end of the range.
+func (q PageQuery) beforeEnd(timestamp types.Timestamp) bool {
+	return q.To == 0 || timestamp <= q.To
+}
+
+// start returns the key the page starts at, from if there is no cursor. The
+// key of a cursor is the last item of the previous page, skip is set so it is
+// not repeated.
+func (q PageQuery) start(from []byte) (start []byte, skip bool, err error) {
+	if q.Cursor == "" {
+		return from, false, nil
+	}
+	start, err = base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || len(start) != len(from) {
+		return nil, false, ErrInvalidCursor
+	}
+	return start, true, nil
+}
+
+//SharePage returns a page of the shares written to disk, oldest first, and the cursor of the next page.
+// The cursor is empty if there are no more shares. Shares are only ever appended after the last page,
+// so paging does not skip or repeat a share while new ones are accepted. Buffered shares are listed
+// once they are written to disk.
+func (sc *ShareChain) SharePage(q PageQuery) (shares []Share, next string, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	shares = make([]Share, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(Shares)
+		start, skip, err := q.start(shareKey(firstShareSince(b, q.From)))
+		if err != nil {
+			return err
+		}
+		var share Share
+		next, err = walkPage(b.Cursor(), start, skip, q.limit(), func(v []byte) (bool, bool, error) {
+			share = Share{}
+			if err := encoding.Unmarshal(v, &share); err != nil {
+				return false, false, err
+			}
+			return q.beforeEnd(share.Timestamp), true, nil
+		}, func() {
+			shares = append(shares, share)
+		})
+		return err
+	})
+	return
+}
+
+//PayoutPage returns a page of the payouts of the matured blocks, oldest first, and the cursor of the next page.
+// Only the payouts to address are listed if it is not nil. Like the shares, payouts are listed once they are
+// written to disk.
+func (sc *ShareChain) PayoutPage(q PageQuery, address *types.UnlockHash) (payouts []AuditEntry, next string, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	payouts = make([]AuditEntry, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		start, skip, err := q.start(auditKey(q.From, 0))
+		if err != nil {
+			return err
+		}
+		var payout AuditEntry
+		next, err = walkPage(tx.Bucket(Payouts).Cursor(), start, skip, q.limit(), func(v []byte) (bool, bool, error) {
+			payout = AuditEntry{}
+			if err := encoding.Unmarshal(v, &payout); err != nil {
+				return false, false, err
+			}
+			return q.beforeEnd(payout.Timestamp), address == nil || payout.Address == *address, nil
+		}, func() {
+			payouts = append(payouts, payout)
+		})
+		return err
+	})
+	return
+}
+
+// walkPage walks the items of a bucket from start until limit items are kept
+// or the end of the time range is reached. match decodes an item and returns
+// if it is within the time range and if it belongs in the page, keep adds the
+// decoded item to the page. The cursor of the next page is only returned if
+// there is a next item.
+func walkPage(c *bolt.Cursor, start []byte, skip bool, limit int, match func(v []byte) (inRange, matches bool, err error), keep func()) (next string, err error) {
+	k, v := c.Seek(start)
+	if skip && k != nil && bytes.Equal(k, start) {
+		k, v = c.Next()
+	}
+	var last []byte
+	kept := 0
+	for ; k != nil; k, v = c.Next() {
+		inRange, matches, err := match(v)
+		if err != nil || !inRange {
+			return "", err
+		}
+		if !matches {
+			continue
+		}
+		if kept == limit {
+			return base64.RawURLEncoding.EncodeToString(last), nil
+		}
+		keep()
+		last = k
+		kept++
+	}
+	return "", nil
+}
+
+// firstShareSince returns the sequence number of the first stored share
+// accepted at or after a time. The timestamps of the shares are in the order
+// of their sequence numbers, so it is found with a binary search.
+func firstShareSince(b *bolt.Bucket, since types.Timestamp) uint64 {
+	k, _ := b.Cursor().Last()
+	if since == 0 || k == nil {
+		return 1
+	}
+	stored := shareSeq(k)
+	return 1 + uint64(sort.Search(int(stored), func(i int) bool {
+		var share Share
+		if err := encoding.Unmarshal(b.Get(shareKey(uint64(i)+1)), &share); err != nil {
+			return false
+		}
+		return share.Timestamp >= since
+	}))
+}
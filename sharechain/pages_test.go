@@ -0,0 +1,137 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+// allShares pages through the shares with the given limit.
+func allShares(t *testing.T, sc *ShareChain, q PageQuery) (shares []Share, pages int) {
+	for {
+		page, next, err := sc.SharePage(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, page...)
+		pages++
+		if next == "" {
+			return
+		}
+		q.Cursor = next
+	}
+}
+
+func TestSharePagination(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	for i := 0; i < 5; i++ {
+		sc.AddShare(Share{BlockID: types.BlockID{byte(i)}, Miner: "a"})
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	if shares, pages := allShares(t, sc, PageQuery{Limit: 2}); len(shares) != 5 || pages != 3 {
+		t.Fatal("Expected 5 shares on 3 pages, got", len(shares), "on", pages)
+	}
+
+	// Shares accepted between two pages are listed after the shares of
+	// the first page, none is skipped or repeated.
+	first, next, err := sc.SharePage(PageQuery{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 5; i < 8; i++ {
+		sc.AddShare(Share{BlockID: types.BlockID{byte(i)}, Miner: "a"})
+	}
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := allShares(t, sc, PageQuery{Limit: 3, Cursor: next})
+	shares := append(first, rest...)
+	if len(shares) != 8 {
+		t.Fatal("Expected 8 shares, got", len(shares))
+	}
+	for i, share := range shares {
+		if share.BlockID != (types.BlockID{byte(i)}) {
+			t.Error("Share", i, "out of order:", share.BlockID)
+		}
+	}
+
+	if _, _, err = sc.SharePage(PageQuery{Cursor: "invalid"}); err != ErrInvalidCursor {
+		t.Error("Expected an invalid cursor error, got", err)
+	}
+}
+
+func TestSharePageTimeRange(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		for i := uint64(1); i <= 6; i++ {
+			share := Share{BlockID: types.BlockID{byte(i)}, Timestamp: types.Timestamp(10 * i)}
+			if err := tx.Bucket(Shares).Put(shareKey(i), encoding.Marshal(share)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, _ := allShares(t, sc, PageQuery{From: 25, To: 50, Limit: 1})
+	if len(shares) != 3 || shares[0].Timestamp != 30 || shares[2].Timestamp != 50 {
+		t.Error("Expected the shares from 30 to 50, got", shares)
+	}
+	if shares, _ = allShares(t, sc, PageQuery{From: 70}); len(shares) != 0 {
+		t.Error("Expected no shares after the last one, got", shares)
+	}
+}
+
+func TestPayoutPage(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	entries := []AuditEntry{
+		{Timestamp: 10, Event: AuditShare, Miner: "a"},
+		{Timestamp: 20, Event: AuditPayout, Address: a, Value: types.NewCurrency64(1)},
+		{Timestamp: 20, Event: AuditPayout, Address: b, Value: types.NewCurrency64(2)},
+		{Timestamp: 30, Event: AuditBlockFound},
+		{Timestamp: 40, Event: AuditPayout, Address: a, Value: types.NewCurrency64(3)},
+	}
+	if err := sc.db.Update(func(tx *bolt.Tx) error { return putAudit(tx, entries) }); err != nil {
+		t.Fatal(err)
+	}
+
+	payouts, next, err := sc.PayoutPage(PageQuery{Limit: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payouts) != 2 || payouts[0].Address != a || payouts[1].Address != b || next == "" {
+		t.Fatal("Unexpected first page of payouts:", payouts, next)
+	}
+	if payouts, next, err = sc.PayoutPage(PageQuery{Limit: 2, Cursor: next}, nil); err != nil || len(payouts) != 1 || payouts[0].Timestamp != 40 || next != "" {
+		t.Error("Unexpected last page of payouts:", payouts, next, err)
+	}
+	if payouts, _, err = sc.PayoutPage(PageQuery{}, &a); err != nil || len(payouts) != 2 {
+		t.Error("Expected 2 payouts to a, got", payouts, err)
+	}
+	if payouts, _, err = sc.PayoutPage(PageQuery{From: 30}, &b); err != nil || len(payouts) != 0 {
+		t.Error("Expected no payouts to b since 30, got", payouts, err)
+	}
+
+	// Databases without the index get it from the audit log.
+	err = sc.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(Payouts); err != nil {
+			return err
+		}
+		return sc.createShareChainDB(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payouts, _, err = sc.PayoutPage(PageQuery{}, nil); err != nil || len(payouts) != 3 {
+		t.Error("Expected 3 payouts from the audit log, got", payouts, err)
+	}
+}
@@ -1,14 +1,56 @@
 package sharechain
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
-//GenerateMinerPayouts creates a list of pplns payouts
-// An extra payment of 0 to a random address is added to have a unique merkleroot for every generated set of payouts
+const (
+	//FinderBonus is the part of the reward, in 0.1%, that goes to the miner that solved the block
+	FinderBonus = 5
+	//MaxFee is the highest pool fee, in 0.01%, the whole reward
+	MaxFee = 10000
+)
+
+var (
+	errInvalidFee = fmt.Errorf("pool fee must be between 0 and %v (in 0.01%%)", MaxFee)
+	errNoPayee    = errors.New("no miner to pay the reward to: no finder, no shares in the pplns window and no fee address")
+	errNoFinder   = errors.New("the block of a miner needs the address of the miner")
+)
+
+func validFee(fee int) bool {
+	return fee >= 0 && fee <= MaxFee
+}
+
+//GenerateMinerPayouts creates a list of pplns payouts with minerAddress as the finder from the current pplns window,
+// the zero address pays no finder bonus.
+// errNoPayee is returned if nobody can be paid: no finder, no shares in the window and no fee address.
 func (sc *ShareChain) GenerateMinerPayouts(minerAddress types.UnlockHash, subsidy types.Currency) (payouts []types.SiacoinOutput, err error) {
+	window, _, _ := sc.windowShares()
+	return sc.generatePayouts(window, minerAddress, subsidy)
+}
+
+//MinerBlock returns the block of a template a miner works on, the miner with minerAddress is the finder and gets the FinderBonus
+// if it solves the block. The payouts are generated from the pplns window of the template, shares accepted since the template
+// was built do not change them.
+func (sc *ShareChain) MinerBlock(template Template, minerAddress types.UnlockHash) (b types.Block, err error) {
+	if minerAddress == (types.UnlockHash{}) {
+		return types.Block{}, errNoFinder
+	}
+	b = template.Block
+	b.MinerPayouts, err = sc.generatePayouts(template.window, minerAddress, b.CalculateSubsidy(template.Height))
+	return
+}
 
+// generatePayouts creates the pplns payouts of a window. An extra payment of 0
+// to a random address is added to have a unique merkleroot for every generated
+// set of payouts.
+func (sc *ShareChain) generatePayouts(window []Share, finder types.UnlockHash, subsidy types.Currency) (payouts []types.SiacoinOutput, err error) {
 	//Create a random address to have a unique merkleroot for every generated set of payouts
 	randomBytes, err := crypto.RandBytes(crypto.HashSize)
 	if err != nil {
@@ -17,15 +59,122 @@ func (sc *ShareChain) GenerateMinerPayouts(minerAddress types.UnlockHash, subsid
 	randomAddress := types.UnlockHash{}
 	copy(randomAddress[:], randomBytes)
 
-	payouts = []types.SiacoinOutput{
-		types.SiacoinOutput{
-			Value:      subsidy,
-			UnlockHash: minerAddress,
-		},
-		types.SiacoinOutput{
-			Value:      types.ZeroCurrency,
-			UnlockHash: randomAddress,
-		},
+	payouts = pplnsPayouts(window, finder, subsidy, sc.config.Fee, sc.config.FeeAddress, sc.config.solverWeight())
+	if !subsidy.IsZero() && len(payouts) == 0 {
+		return nil, errNoPayee
+	}
+
+	payouts = append(payouts, types.SiacoinOutput{
+		Value:      types.ZeroCurrency,
+		UnlockHash: randomAddress,
+	})
+	return
+}
+
+// windowShares returns a copy of the shares in the current pplns window, the
+// size of the window and the sequence number of the last share.
+func (sc *ShareChain) windowShares() (window []Share, size int, shareIndex uint64) {
+	size = sc.PPLNSWindow()
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return append([]Share(nil), lastShares(sc.shares, size)...), size, sc.totalShares
+}
+
+//pplnsPayouts distributes a reward over the miners of the shares in the window.
+// The pool fee (in 0.01%) goes to the fee address, without a fee address no fee is taken. The finder of the block gets the FinderBonus and
+// the remainder is split according to the number of shares of every miner. The share of the finder that solved
+// the block, which is not in the window, counts solverWeight times.
+// Rounding dust goes to the finder. Without a finder, the zero address, there is no bonus and the dust goes
+// to the miner with the most shares, the lowest address among equals. Without a finder and without shares the
+// remainder goes to the fee address, and if there is none nothing is paid: the zero address is never paid.
+// A fee or bonus larger than what is left of the reward takes the rest, so the payouts never exceed the reward.
+// The result only depends on the input, payouts are sorted by address.
+func pplnsPayouts(window []Share, finder types.UnlockHash, reward types.Currency, fee int, feeAddress types.UnlockHash, solverWeight uint64) (payouts []types.SiacoinOutput) {
+	amounts := make(map[types.UnlockHash]types.Currency)
+	remainder := reward
+
+	if fee > 0 && feeAddress != (types.UnlockHash{}) {
+		feeAmount := reward.Mul64(uint64(fee)).Div64(10000)
+		if feeAmount.Cmp(remainder) > 0 {
+			feeAmount = remainder
+		}
+		amounts[feeAddress] = feeAmount
+		remainder = remainder.Sub(feeAmount)
 	}
+	if finder != (types.UnlockHash{}) {
+		bonus := reward.Mul64(FinderBonus).Div64(1000)
+		if bonus.Cmp(remainder) > 0 {
+			bonus = remainder
+		}
+		remainder = remainder.Sub(bonus)
+		amounts[finder] = amounts[finder].Add(bonus)
+	}
+
+	shareCounts := make(map[types.UnlockHash]uint64)
+	var totalShares uint64
+	for _, share := range window {
+		address, err := MinerAddress(share.Miner)
+		if err != nil || address == (types.UnlockHash{}) {
+			continue
+		}
+		shareCounts[address]++
+		totalShares++
+	}
+	if finder != (types.UnlockHash{}) && solverWeight > 0 {
+		shareCounts[finder] += solverWeight
+		totalShares += solverWeight
+	}
+	distributed := types.ZeroCurrency
+	if totalShares > 0 {
+		for address, count := range shareCounts {
+			amount := remainder.Mul64(count).Div64(totalShares)
+			amounts[address] = amounts[address].Add(amount)
+			distributed = distributed.Add(amount)
+		}
+	}
+	dust := finder
+	if finder == (types.UnlockHash{}) && totalShares > 0 {
+		dust = topMiner(shareCounts)
+	}
+	if dust == (types.UnlockHash{}) {
+		dust = feeAddress
+	}
+	if dust != (types.UnlockHash{}) {
+		amounts[dust] = amounts[dust].Add(remainder.Sub(distributed))
+	}
+
+	for address, amount := range amounts {
+		if amount.IsZero() {
+			continue
+		}
+		payouts = append(payouts, types.SiacoinOutput{Value: amount, UnlockHash: address})
+	}
+	sort.Sort(payoutsByAddress(payouts))
 	return
 }
+
+// topMiner returns the address with the most shares, the lowest address
+// among equals.
+func topMiner(shareCounts map[types.UnlockHash]uint64) (top types.UnlockHash) {
+	var most uint64
+	for address, count := range shareCounts {
+		if count > most || (count == most && string(address[:]) < string(top[:])) {
+			top, most = address, count
+		}
+	}
+	return
+}
+
+//MinerAddress parses the address of a miner, an optional rigname after a '.' is ignored
+func MinerAddress(miner string) (address types.UnlockHash, err error) {
+	err = address.LoadString(strings.SplitN(miner, ".", 2)[0])
+	return
+}
+
+type payoutsByAddress []types.SiacoinOutput
+
+func (p payoutsByAddress) Len() int      { return len(p) }
+func (p payoutsByAddress) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p payoutsByAddress) Less(i, j int) bool {
+	return string(p[i].UnlockHash[:]) < string(p[j].UnlockHash[:])
+}
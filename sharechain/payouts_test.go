@@ -1 +1,270 @@
 package sharechain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func testShares(miners ...types.UnlockHash) (shares []Share) {
+	for _, miner := range miners {
+		shares = append(shares, Share{Miner: miner.String() + ".rig"})
+	}
+	return
+}
+
+func sumPayouts(payouts []types.SiacoinOutput) (total types.Currency) {
+	total = types.ZeroCurrency
+	for _, payout := range payouts {
+		total = total.Add(payout.Value)
+	}
+	return
+}
+
+func TestPPLNSPayouts(t *testing.T) {
+	a, b, feeAddress := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	window := testShares(a, a, a, b)
+	reward := types.NewCurrency64(1000003)
+
+	payouts := pplnsPayouts(window, b, reward, 200, feeAddress, 0)
+	if total := sumPayouts(payouts); total.Cmp(reward) != 0 {
+		t.Error("Payouts sum up to", total, "instead of", reward)
+	}
+	expected := map[types.UnlockHash]uint64{
+		// 2% fee
+		feeAddress: 20000,
+		// 3/4 of the remaining 97.5%
+		a: 731252,
+		// 0.5% finder bonus + 1/4 of the remaining 97.5% + rounding dust
+		b: 5000 + 243750 + 1,
+	}
+	for _, payout := range payouts {
+		if payout.Value.Cmp(types.NewCurrency64(expected[payout.UnlockHash])) != 0 {
+			t.Error("Expected", expected[payout.UnlockHash], "for", payout.UnlockHash, "got", payout.Value)
+		}
+	}
+
+	if again := pplnsPayouts(window, b, reward, 200, feeAddress, 0); !reflect.DeepEqual(payouts, again) {
+		t.Error("Payouts are not deterministic")
+	}
+}
+
+func TestPPLNSPayoutsEmptyWindow(t *testing.T) {
+	finder := types.UnlockHash{1}
+	payouts := pplnsPayouts(nil, finder, types.NewCurrency64(1000), 0, types.UnlockHash{}, 0)
+	if len(payouts) != 1 || payouts[0].UnlockHash != finder || payouts[0].Value.Cmp(types.NewCurrency64(1000)) != 0 {
+		t.Error("Expected the full reward for the finder, got", payouts)
+	}
+}
+
+func TestPPLNSPayoutsEmptyWindowWithoutFinder(t *testing.T) {
+	feeAddress := types.UnlockHash{9}
+	reward := types.NewCurrency64(1000)
+
+	// Nobody mined yet, the rest goes to the fee address.
+	payouts := pplnsPayouts(nil, types.UnlockHash{}, reward, 200, feeAddress, 0)
+	if len(payouts) != 1 || payouts[0].UnlockHash != feeAddress || payouts[0].Value.Cmp(reward) != 0 {
+		t.Error("Expected the full reward for the fee address, got", payouts)
+	}
+
+	// Without a fee address there is nobody to pay, the zero address is never paid.
+	if payouts = pplnsPayouts(nil, types.UnlockHash{}, reward, 200, types.UnlockHash{}, 0); len(payouts) != 0 {
+		t.Error("Expected no payouts, got", payouts)
+	}
+}
+
+func TestPPLNSPayoutsSaturate(t *testing.T) {
+	finder, feeAddress := types.UnlockHash{1}, types.UnlockHash{9}
+	reward := types.NewCurrency64(1000)
+	for _, fee := range []int{9951, MaxFee} {
+		payouts := pplnsPayouts(testShares(finder), finder, reward, fee, feeAddress, 0)
+		if total := sumPayouts(payouts); total.Cmp(reward) != 0 {
+			t.Error("Payouts with a fee of", fee, "sum up to", total, "instead of", reward)
+		}
+	}
+}
+
+func TestInvalidFee(t *testing.T) {
+	for _, fee := range []int{-1, MaxFee + 1} {
+		if _, err := New(nil, "", Config{Fee: fee}); err != errInvalidFee {
+			t.Error("Expected", errInvalidFee, "for a fee of", fee, "got", err)
+		}
+	}
+}
+
+func TestPPLNSPayoutsWithoutFee(t *testing.T) {
+	a, b, c, feeAddress := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}, types.UnlockHash{9}
+	reward := types.NewCurrency64(1000)
+
+	// Without a finder the dust of 1000/3 goes to the miner with the most shares.
+	payouts := pplnsPayouts(testShares(a, b, b), types.UnlockHash{}, reward, 0, feeAddress, 0)
+	if total := sumPayouts(payouts); total.Cmp(reward) != 0 {
+		t.Error("Payouts sum up to", total, "instead of", reward)
+	}
+	expected := map[types.UnlockHash]uint64{a: 333, b: 667}
+	if len(payouts) != len(expected) {
+		t.Error("Expected only payouts to the miners, got", payouts)
+	}
+	for _, payout := range payouts {
+		if payout.Value.Cmp(types.NewCurrency64(expected[payout.UnlockHash])) != 0 {
+			t.Error("Expected", expected[payout.UnlockHash], "for", payout.UnlockHash, "got", payout.Value)
+		}
+	}
+
+	// Among equals the lowest address gets it.
+	payouts = pplnsPayouts(testShares(c, b, a), types.UnlockHash{}, reward, 0, types.UnlockHash{}, 0)
+	if len(payouts) != 3 || payouts[0].UnlockHash != a || payouts[0].Value.Cmp(types.NewCurrency64(334)) != 0 {
+		t.Error("Expected the dust for", a, "got", payouts)
+	}
+}
+
+func TestFeeWithoutAddress(t *testing.T) {
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	reward := types.NewCurrency64(1000)
+	payouts := pplnsPayouts(testShares(a, b), types.UnlockHash{}, reward, 200, types.UnlockHash{}, 0)
+	if len(payouts) != 2 || payouts[0].Value.Cmp(types.NewCurrency64(500)) != 0 || payouts[1].Value.Cmp(types.NewCurrency64(500)) != 0 {
+		t.Error("Expected the reward to be split between the miners without a fee address, got", payouts)
+	}
+
+	// The default fee without a fee address pays nothing to the zero address.
+	sc, _, cleanup := newMockShareChain(t, Config{Fee: 200})
+	defer cleanup()
+	for _, share := range testShares(a, b) {
+		sc.AddShare(share)
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payout := range template.Block.MinerPayouts {
+		if payout.UnlockHash == (types.UnlockHash{}) && !payout.Value.IsZero() {
+			t.Error("Fee paid to the zero address:", payout.Value)
+		}
+	}
+	if split := newRewardSplit(FoundBlock{Payouts: template.Block.MinerPayouts}, 200, types.UnlockHash{}); !split.Fee.IsZero() || split.FeePercentage != 0 {
+		t.Error("Expected no fee in the reward split without a fee address, got", split.Fee, split.FeePercentage)
+	}
+}
+
+func TestFreePoolTemplate(t *testing.T) {
+	feeAddress := types.UnlockHash{9}
+	sc, _, cleanup := newMockShareChain(t, Config{Fee: 0, FeeAddress: feeAddress})
+	defer cleanup()
+	for _, share := range testShares(types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{2}) {
+		sc.AddShare(share)
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := template.Block
+	if total, subsidy := sumPayouts(b.MinerPayouts), b.CalculateSubsidy(template.Height); total.Cmp(subsidy) != 0 {
+		t.Error("Payouts of a free pool sum up to", total, "instead of the subsidy", subsidy)
+	}
+	for _, payout := range b.MinerPayouts {
+		if payout.UnlockHash == feeAddress {
+			t.Error("Free pool pays", payout.Value, "to the fee address")
+		}
+	}
+}
+
+func TestTemplateWithoutFinder(t *testing.T) {
+	feeAddress := types.UnlockHash{9}
+	sc, _, cleanup := newMockShareChain(t, Config{Fee: 200, FeeAddress: feeAddress})
+	defer cleanup()
+	for _, share := range testShares(types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{2}) {
+		sc.AddShare(share)
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := template.Block
+	// The fee address only gets the fee, the finder bonus is split over the
+	// miners in the window.
+	fee := b.CalculateSubsidy(template.Height).Mul64(200).Div64(10000)
+	for _, payout := range b.MinerPayouts {
+		if payout.UnlockHash == feeAddress && payout.Value.Cmp(fee) != 0 {
+			t.Error("Template pays", payout.Value, "to the fee address instead of the fee", fee)
+		}
+	}
+}
+
+func TestEmptyWindowTemplate(t *testing.T) {
+	miner := types.UnlockHash{1}
+	sc, _, cleanup := newMockShareChain(t, Config{Fee: 200})
+	defer cleanup()
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nobody can be paid yet, the zero address is never paid.
+	if len(template.Block.MinerPayouts) != 0 {
+		t.Error("Expected no payouts without shares and fee address, got", template.Block.MinerPayouts)
+	}
+	b, err := sc.MinerBlock(template, miner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subsidy := b.CalculateSubsidy(template.Height)
+	for _, payout := range b.MinerPayouts {
+		if !payout.Value.IsZero() && payout.UnlockHash != miner {
+			t.Error("Block of the miner pays", payout.Value, "to", payout.UnlockHash)
+		}
+	}
+	if total := sumPayouts(b.MinerPayouts); total.Cmp(subsidy) != 0 {
+		t.Error("Payouts sum up to", total, "instead of the subsidy", subsidy)
+	}
+	if _, err := sc.MinerBlock(template, types.UnlockHash{}); err != errNoFinder {
+		t.Error("Expected", errNoFinder, "got", err)
+	}
+
+	// With a fee address the template pays the fee address.
+	feeAddress := types.UnlockHash{9}
+	sc, _, cleanup = newMockShareChain(t, Config{Fee: 200, FeeAddress: feeAddress})
+	defer cleanup()
+	if template, err = sc.BlockTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, payout := range template.Block.MinerPayouts {
+		if payout.UnlockHash == feeAddress && payout.Value.Cmp(subsidy) != 0 {
+			t.Error("Expected the subsidy", subsidy, "for the fee address, got", payout.Value)
+		}
+	}
+}
+
+func TestMinerBlock(t *testing.T) {
+	a, b, late := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	sc, _, cleanup := newMockShareChain(t, Config{})
+	defer cleanup()
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shares accepted after the template was built do not change the payouts.
+	sc.AddShare(testShares(late)[0])
+
+	block, err := sc.MinerBlock(template, late)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subsidy := block.CalculateSubsidy(template.Height)
+	if total := sumPayouts(block.MinerPayouts); total.Cmp(subsidy) != 0 {
+		t.Error("Payouts sum up to", total, "instead of the subsidy", subsidy)
+	}
+	expected := pplnsPayouts(testShares(a, b, b), late, subsidy, sc.config.Fee, sc.config.FeeAddress, sc.config.solverWeight())
+	if payouts := block.MinerPayouts[:len(block.MinerPayouts)-1]; !reflect.DeepEqual(payouts, expected) {
+		t.Error("Expected the payouts of the template window with", late, "as the finder, got", payouts)
+	}
+	if block.ParentID != template.Block.ParentID || len(block.Transactions) != len(template.Block.Transactions) {
+		t.Error("Block of the miner differs from the template")
+	}
+	if size := len(encoding.Marshal(block)); uint64(size) > sc.config.MaxTemplateSize {
+		t.Error("Block of the miner is", size, "bytes, more than the maximum template size")
+	}
+}
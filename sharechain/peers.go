@@ -0,0 +1,86 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	//DefaultMinPeers is the default number of gateway peers the node needs before it hands out work
+	DefaultMinPeers = 3
+	//peerCheckInterval is the time between two attempts to connect to the bootstrap peers while there are too few peers
+	peerCheckInterval = 30 * time.Second
+)
+
+var errTooFewPeers = errors.New("too few gateway peers")
+
+//checkPeers returns an error if the gateway has fewer than MinPeers peers.
+// A poorly connected node might build on a stale tip and direct the miners onto a doomed fork.
+func (sc *ShareChain) checkPeers() error {
+	if sc.config.MinPeers <= 0 {
+		return nil
+	}
+	if peers := len(sc.Siad.Gateway().Peers()); peers < sc.config.MinPeers {
+		return fmt.Errorf("%v: %v of the required %v", errTooFewPeers, peers, sc.config.MinPeers)
+	}
+	return nil
+}
+
+// connectBootstrapPeers tries to connect to the bootstrap peers the gateway is
+// not connected to while it has fewer than MinPeers peers. Every address is
+// dialed at most once per call, a peer the gateway connected to on its own in
+// the meantime counts as connected. The dials are sequential, so once the
+// sharechain is closing Close waits for at most the dial in progress.
+func (sc *ShareChain) connectBootstrapPeers() {
+	g := sc.Siad.Gateway()
+	connected := make(map[modules.NetAddress]bool)
+	for _, peer := range g.Peers() {
+		connected[peer.NetAddress] = true
+	}
+	dialed := make(map[modules.NetAddress]bool)
+	for _, addr := range modules.BootstrapPeers {
+		if len(connected) >= sc.config.MinPeers {
+			return
+		}
+		if connected[addr] || dialed[addr] {
+			continue
+		}
+		select {
+		case <-sc.tg.StopChan():
+			return
+		default:
+		}
+		dialed[addr] = true
+		if err := g.Connect(addr); err != nil && !strings.Contains(err.Error(), "already connected") {
+			sc.log.Debugln("Could not connect to bootstrap peer", addr, ":", err)
+			continue
+		}
+		connected[addr] = true
+	}
+}
+
+// threadedConnectPeers connects to the bootstrap peers every
+// peerCheckInterval while there are too few peers, until the sharechain is
+// closed.
+func (sc *ShareChain) threadedConnectPeers() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(peerCheckInterval)
+	defer ticker.Stop()
+	for {
+		if sc.checkPeers() != nil {
+			sc.connectBootstrapPeers()
+		}
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package sharechain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/siapool/p2pool/siad"
+)
+
+func TestMinPeers(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{MinPeers: 2})
+	defer cleanup()
+	mock := siad.NewMock()
+	sc.Siad = mock
+
+	refused := modules.BootstrapPeers[0]
+	mock.ConnectFunc = func(addr modules.NetAddress) error {
+		if addr == refused {
+			return errors.New("refused")
+		}
+		return nil
+	}
+	if err := sc.Ready(); err == nil || !strings.Contains(err.Error(), errTooFewPeers.Error()) {
+		t.Error("Expected too few peers, got", err)
+	}
+	if _, err := sc.BlockTemplate(); err == nil {
+		t.Error("Template handed out without peers")
+	}
+
+	sc.connectBootstrapPeers()
+	peers := mock.Gateway().Peers()
+	if len(peers) != 2 {
+		t.Fatal("Expected 2 peers, got", peers)
+	}
+	for _, peer := range peers {
+		if peer.NetAddress == refused {
+			t.Error("Connected to a refused peer")
+		}
+	}
+	if err := sc.Ready(); err != nil {
+		t.Error(err)
+	}
+
+	sc.config.MinPeers = 0
+	mock.Gateway().Disconnect(peers[0].NetAddress)
+	mock.Gateway().Disconnect(peers[1].NetAddress)
+	if err := sc.Ready(); err != nil {
+		t.Error("Peers checked while disabled:", err)
+	}
+}
+
+func TestConnectBootstrapPeersOnce(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{MinPeers: len(modules.BootstrapPeers) + 1})
+	defer cleanup()
+	mock := siad.NewMock()
+	sc.Siad = mock
+
+	// Two peers connect on their own while they are dialed.
+	dials := make(map[modules.NetAddress]int)
+	mock.ConnectFunc = func(addr modules.NetAddress) error {
+		dials[addr]++
+		if addr == modules.BootstrapPeers[0] || addr == modules.BootstrapPeers[1] {
+			return errors.New("already connected to " + string(addr))
+		}
+		return errors.New("refused")
+	}
+	sc.connectBootstrapPeers()
+	for _, addr := range modules.BootstrapPeers {
+		if dials[addr] != 1 {
+			t.Error(addr, "dialed", dials[addr], "times")
+		}
+	}
+
+	// No more dials once the sharechain is closing.
+	dials = make(map[modules.NetAddress]int)
+	mock.ConnectFunc = func(addr modules.NetAddress) error {
+		dials[addr]++
+		sc.tg.Stop()
+		return errors.New("refused")
+	}
+	sc.connectBootstrapPeers()
+	if len(dials) != 1 {
+		t.Error("Expected a single dial after the stop, got", dials)
+	}
+}
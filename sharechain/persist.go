@@ -2,11 +2,14 @@ package sharechain
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/bolt"
 )
@@ -29,29 +32,161 @@ var (
 	// ShareChainPool is a database bucket storing the current value of the
 	// ShareChain pool.
 	ShareChainPool = []byte("ShareChainPool")
+
+	// Shares is a database bucket storing the accepted shares, keyed by
+	// sequence number.
+	Shares = []byte("Shares")
+
+	// FoundBlocks is a database bucket storing the blocks found by the pool,
+	// keyed by block id.
+	FoundBlocks = []byte("FoundBlocks")
+
+	// Earnings is a database bucket storing the matured payouts per miner
+	// address.
+	Earnings = []byte("Earnings")
+
+	// ConsensusState is a database bucket storing the last processed
+	// consensus change and the corresponding height.
+	ConsensusState = []byte("ConsensusState")
+
+	// Audit is a database bucket storing the append-only audit log, keyed by
+	// timestamp and sequence number.
+	Audit = []byte("Audit")
+
+	// RewardSplits is a database bucket storing the reward distribution of
+	// the found blocks at the time they were found, keyed by block id.
+	RewardSplits = []byte("RewardSplits")
+
+	// AuthorizedAddresses is a database bucket storing the miner addresses
+	// that are allowed to mine on a pool that requires authorization.
+	AuthorizedAddresses = []byte("AuthorizedAddresses")
+
+	// TotalsBucket is a database bucket storing the lifetime counters of the
+	// pool.
+	TotalsBucket = []byte("Totals")
+
+	// PPLNSWindows is a database bucket storing the number of shares in the
+	// pplns window of the found blocks, keyed by block id.
+	PPLNSWindows = []byte("PPLNSWindows")
+
+	// Webhooks is a database bucket storing the notification webhooks of the
+	// miners, keyed by webhook id.
+	Webhooks = []byte("Webhooks")
+
+	// FailedSubmissions is a database bucket storing the found blocks the
+	// consensus set did not accept, keyed by block id.
+	FailedSubmissions = []byte("FailedSubmissions")
+
+	// Rounds is a database bucket storing the summaries of the completed
+	// rounds, keyed by round number.
+	Rounds = []byte("Rounds")
+
+	// Payouts is a database bucket storing the payout entries of the audit
+	// log, keyed like the audit log.
+	Payouts = []byte("Payouts")
+
+	// Adjustments is a database bucket storing the manual adjustments of the
+	// earnings of the miners, keyed like the audit log.
+	Adjustments = []byte("Adjustments")
+
+	// PoolPeers is a database bucket storing the peers that relayed valid
+	// shares with the time they were last seen, keyed by address.
+	PoolPeers = []byte("PoolPeers")
+
+	// SolverWeights is a database bucket storing the number of shares the
+	// solving share of a found block counted for, by block id. Blocks
+	// distributed with linear weighting are not stored.
+	SolverWeights = []byte("SolverWeights")
+
+	// Rejects is a database bucket storing the rejected shares while
+	// LogRejects is set, keyed like the audit log.
+	Rejects = []byte("Rejects")
+
+	keyChangeID = []byte("ChangeID")
+	keyHeight   = []byte("Height")
 )
 
 // createShareChainDB initialzes the sharechain portions of the database.
 func (sc *ShareChain) createShareChainDB(tx *bolt.Tx) error {
+	// Databases created before the totals were kept compute them from the
+	// existing data.
+	newTotals := tx.Bucket(TotalsBucket) == nil
+	// The payouts of databases created before they were indexed are taken
+	// from the audit log.
+	newPayouts := tx.Bucket(Payouts) == nil
+
 	// Enumerate and create the database buckets.
 	buckets := [][]byte{
 		ShareChainPool,
+		Shares,
+		FoundBlocks,
+		Earnings,
+		ConsensusState,
+		Audit,
+		RewardSplits,
+		AuthorizedAddresses,
+		TotalsBucket,
+		PPLNSWindows,
+		Webhooks,
+		FailedSubmissions,
+		Rounds,
+		Payouts,
+		Adjustments,
+		PoolPeers,
+		SolverWeights,
+		Rejects,
 	}
 	for _, bucket := range buckets {
-		_, err := tx.CreateBucket(bucket)
+		_, err := tx.CreateBucketIfNotExists(bucket)
 		if err != nil {
 			return err
 		}
 	}
 
+	if newPayouts {
+		if err := indexPayouts(tx); err != nil {
+			return err
+		}
+	}
+	if newTotals {
+		return initTotals(tx)
+	}
 	return nil
 }
 
 // loadDB pulls all the shares that have been saved to disk into memory, using
-// them to fill out the ShareChain.
+// them to fill out the ShareChain. An unreadable database is recovered if the
+// Recover option is set.
 func (sc *ShareChain) loadDB() error {
+	filename := filepath.Join(sc.persistDir, DatabaseFilename)
+	err := sc.openAndLoadDB(filename)
+	if err == nil {
+		return nil
+	}
+	if !sc.config.Recover {
+		return fmt.Errorf("sharechain database %s is unreadable, it might be corrupted (%v): restore a backup with the restore command or start with --recover", filename, err)
+	}
+	if err = sc.recoverDB(filename, err); err != nil {
+		return err
+	}
+	return sc.openAndLoadDB(filename)
+}
+
+// openAndLoadDB opens the database and loads it. Bolt panics on some
+// corrupted pages, such a panic is returned as an error.
+func (sc *ShareChain) openAndLoadDB(filename string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil && sc.db != nil {
+			sc.db.Close()
+			sc.db = nil
+		}
+	}()
+
 	// Open the database - a new bolt database will be created if none exists.
-	err := sc.openDB(filepath.Join(sc.persistDir, DatabaseFilename))
+	err = sc.openDB(filename)
 	if err != nil {
 		return err
 	}
@@ -63,7 +198,21 @@ func (sc *ShareChain) loadDB() error {
 			return sc.initDB(tx)
 		}
 
-		return nil
+		// Databases created by older versions might miss some buckets.
+		err := sc.createShareChainDB(tx)
+		if err != nil {
+			return err
+		}
+		if err = sc.replayJournal(tx); err != nil {
+			return err
+		}
+		if err = sc.loadShares(tx); err != nil {
+			return err
+		}
+		if err = sc.loadRound(tx); err != nil {
+			return err
+		}
+		return sc.loadConsensusState(tx)
 	})
 }
 
@@ -87,7 +236,7 @@ func (sc *ShareChain) initPersist() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return sc.verifyCheckpoints()
 }
 
 // openDB loads the set database and populates it with the necessary buckets
@@ -96,6 +245,9 @@ func (sc *ShareChain) openDB(filename string) (err error) {
 	if err == persist.ErrBadVersion {
 		return sc.replaceDatabase(filename)
 	}
+	if err == bolt.ErrTimeout {
+		return errors.New("sharechain database " + filename + " is locked by another process, is another pool instance running or still shutting down?")
+	}
 	if err != nil {
 		return errors.New("error opening sharechain database: " + err.Error())
 	}
@@ -136,3 +288,42 @@ func (sc *ShareChain) replaceDatabase(filename string) error {
 	}
 	return nil
 }
+
+// loadConsensusState restores the last processed consensus change and height.
+func (sc *ShareChain) loadConsensusState(tx *bolt.Tx) error {
+	b := tx.Bucket(ConsensusState)
+	if id := b.Get(keyChangeID); id != nil {
+		copy(sc.lastChange[:], id)
+	}
+	if height := b.Get(keyHeight); height != nil {
+		return encoding.Unmarshal(height, &sc.height)
+	}
+	return nil
+}
+
+// saveConsensusState stores the last processed consensus change and height.
+func (sc *ShareChain) saveConsensusState(tx *bolt.Tx) error {
+	b := tx.Bucket(ConsensusState)
+	err := b.Put(keyChangeID, sc.lastChange[:])
+	if err != nil {
+		return err
+	}
+	return b.Put(keyHeight, encoding.Marshal(sc.height))
+}
+
+// putFoundBlock stores a found block.
+func putFoundBlock(tx *bolt.Tx, fb FoundBlock) error {
+	return tx.Bucket(FoundBlocks).Put(fb.ID[:], encoding.Marshal(fb))
+}
+
+// addEarnings adds value to the matured earnings of a miner address.
+func addEarnings(tx *bolt.Tx, address types.UnlockHash, value types.Currency) error {
+	b := tx.Bucket(Earnings)
+	current := types.ZeroCurrency
+	if raw := b.Get(address[:]); raw != nil {
+		if err := encoding.Unmarshal(raw, &current); err != nil {
+			return err
+		}
+	}
+	return b.Put(address[:], encoding.Marshal(current.Add(value)))
+}
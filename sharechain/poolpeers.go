@@ -0,0 +1,170 @@
+package sharechain
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//MaxPoolPeers is the number of pool peers that are remembered, peers that relay a valid share afterwards are not stored
+	MaxPoolPeers = 1000
+	// poolPeerSeenInterval is the age of the stored last seen time of a pool
+	// peer after which it is written again, so a peer relaying many shares
+	// does not cause a database write for every share.
+	poolPeerSeenInterval = time.Hour
+)
+
+//PoolPeer is a node of the pool network, a configured seed or a peer that relayed a valid share
+type PoolPeer struct {
+	Address modules.NetAddress `json:"address"`
+	//LastSeen is the time the peer relayed a valid share, to the poolPeerSeenInterval, it is 0 for a seed that never did
+	LastSeen  types.Timestamp `json:"lastseen"`
+	Seed      bool            `json:"seed"`
+	Connected bool            `json:"connected"`
+}
+
+// loadPoolPeers reads the pool peers stored by a previous run, unless they
+// are to be forgotten.
+func (sc *ShareChain) loadPoolPeers() error {
+	sc.poolPeersMutex.Lock()
+	defer sc.poolPeersMutex.Unlock()
+	sc.poolPeers = make(map[modules.NetAddress]types.Timestamp)
+	if sc.config.ForgetPoolPeers {
+		return nil
+	}
+	return sc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(PoolPeers).ForEach(func(k, v []byte) error {
+			var seen types.Timestamp
+			if err := encoding.Unmarshal(v, &seen); err != nil {
+				return err
+			}
+			sc.poolPeers[modules.NetAddress(k)] = seen
+			return nil
+		})
+	})
+}
+
+// recordPoolPeer remembers a peer that relayed a valid share at now. It is
+// not written again while it was seen within the poolPeerSeenInterval, and
+// not at all if the pool peers are forgotten or the sharechain is closing.
+func (sc *ShareChain) recordPoolPeer(peer modules.NetAddress, now time.Time) error {
+	if err := sc.tg.Add(); err != nil {
+		return nil
+	}
+	defer sc.tg.Done()
+	sc.poolPeersMutex.Lock()
+	defer sc.poolPeersMutex.Unlock()
+	if sc.poolPeers == nil {
+		sc.poolPeers = make(map[modules.NetAddress]types.Timestamp)
+	}
+	seen := types.Timestamp(now.Unix())
+	last, known := sc.poolPeers[peer]
+	if (known && seen-last < types.Timestamp(poolPeerSeenInterval.Seconds())) || (!known && len(sc.poolPeers) >= MaxPoolPeers) {
+		return nil
+	}
+	if !sc.config.ForgetPoolPeers {
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		err := sc.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(PoolPeers).Put([]byte(peer), encoding.Marshal(seen))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sc.poolPeers[peer] = seen
+	return nil
+}
+
+//PoolPeers returns the configured seeds and the remembered pool peers sorted by address, and if the gateway is connected to them
+func (sc *ShareChain) PoolPeers() []PoolPeer {
+	known := make(map[modules.NetAddress]*PoolPeer)
+	sc.poolPeersMutex.Lock()
+	for addr, seen := range sc.poolPeers {
+		known[addr] = &PoolPeer{Address: addr, LastSeen: seen}
+	}
+	sc.poolPeersMutex.Unlock()
+	for _, addr := range sc.config.PoolPeers {
+		if known[addr] == nil {
+			known[addr] = &PoolPeer{Address: addr}
+		}
+		known[addr].Seed = true
+	}
+	if sc.Siad != nil {
+		for _, peer := range sc.Siad.Gateway().Peers() {
+			if p := known[peer.NetAddress]; p != nil {
+				p.Connected = true
+			}
+		}
+	}
+	peers := make([]PoolPeer, 0, len(known))
+	for _, p := range known {
+		peers = append(peers, *p)
+	}
+	sort.Sort(poolPeersByAddress(peers))
+	return peers
+}
+
+type poolPeersByAddress []PoolPeer
+
+func (p poolPeersByAddress) Len() int           { return len(p) }
+func (p poolPeersByAddress) Less(i, j int) bool { return p[i].Address < p[j].Address }
+func (p poolPeersByAddress) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+//IsPoolPeer returns if an address is a configured seed or a remembered pool peer
+func (sc *ShareChain) IsPoolPeer(addr modules.NetAddress) bool {
+	for _, seed := range sc.config.PoolPeers {
+		if seed == addr {
+			return true
+		}
+	}
+	sc.poolPeersMutex.Lock()
+	defer sc.poolPeersMutex.Unlock()
+	_, known := sc.poolPeers[addr]
+	return known
+}
+
+// connectPoolPeers tries to connect to the pool peers the gateway is not
+// connected to. Like connectBootstrapPeers it dials every address once and
+// stops once the sharechain is closing.
+func (sc *ShareChain) connectPoolPeers() {
+	g := sc.Siad.Gateway()
+	for _, peer := range sc.PoolPeers() {
+		if peer.Connected {
+			continue
+		}
+		select {
+		case <-sc.tg.StopChan():
+			return
+		default:
+		}
+		if err := g.Connect(peer.Address); err != nil && !strings.Contains(err.Error(), "already connected") {
+			sc.log.Debugln("Could not connect to pool peer", peer.Address, ":", err)
+		}
+	}
+}
+
+// threadedConnectPoolPeers connects to the pool peers at startup and every
+// peerCheckInterval afterwards, until the sharechain is closed.
+func (sc *ShareChain) threadedConnectPoolPeers() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(peerCheckInterval)
+	defer ticker.Stop()
+	for {
+		sc.connectPoolPeers()
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/siad"
+)
+
+// waitConnected waits until the gateway of the mock is connected to addr.
+func waitConnected(t *testing.T, mock *siad.Mock, addr modules.NetAddress) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		for _, peer := range mock.Gateway().Peers() {
+			if peer.NetAddress == addr {
+				return
+			}
+		}
+	}
+	t.Fatal("Not connected to", addr)
+}
+
+func TestPoolPeers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	seed, good := modules.NetAddress("seed:9981"), modules.NetAddress("good:9981")
+	config := Config{PoolPeers: []modules.NetAddress{seed}}
+	mock := siad.NewMock()
+	sc, err := New(mock, dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitConnected(t, mock, seed)
+
+	valid, junk := relayedBlocks(sc)
+	if err = sc.RelayedShare(good, valid); err != nil {
+		t.Fatal(err)
+	}
+	sc.RelayedShare("bad:9981", junk)
+	peers := sc.PoolPeers()
+	if len(peers) != 2 || peers[0].Address != good || peers[0].Seed || peers[0].Connected || peers[1] != (PoolPeer{Address: seed, Seed: true, Connected: true}) {
+		t.Fatal("Unexpected pool peers", peers)
+	}
+	if !sc.IsPoolPeer(good) || !sc.IsPoolPeer(seed) || sc.IsPoolPeer("bad:9981") {
+		t.Error("Pool peers not recognized")
+	}
+
+	// The last seen time is only written again after the poolPeerSeenInterval.
+	seen := peers[0].LastSeen
+	last := time.Unix(int64(seen), 0)
+	if err = sc.recordPoolPeer(good, last.Add(poolPeerSeenInterval-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.loadPoolPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if peers = sc.PoolPeers(); peers[0].LastSeen != seen {
+		t.Error("Last seen time written within the interval:", peers[0].LastSeen)
+	}
+	if err = sc.recordPoolPeer(good, last.Add(poolPeerSeenInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.loadPoolPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if peers = sc.PoolPeers(); peers[0].LastSeen != seen+types.Timestamp(poolPeerSeenInterval.Seconds()) {
+		t.Error("Last seen time not written after the interval:", peers[0].LastSeen)
+	}
+	if err = sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is written once the sharechain is closed, the stored peers are
+	// connected after a restart.
+	if err = sc.recordPoolPeer("late:9981", time.Now()); err != nil {
+		t.Error(err)
+	}
+	mock = siad.NewMock()
+	sc, err = New(mock, dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitConnected(t, mock, good)
+	if peers = sc.PoolPeers(); len(peers) != 2 {
+		t.Error("Unexpected pool peers after a restart", peers)
+	}
+	sc.Close()
+
+	config.ForgetPoolPeers = true
+	mock = siad.NewMock()
+	if sc, err = New(mock, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if peers = sc.PoolPeers(); len(peers) != 1 || peers[0].Address != seed {
+		t.Error("Stored pool peers not forgotten", peers)
+	}
+}
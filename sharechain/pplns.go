@@ -0,0 +1,112 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//DefaultPPLNSWindow is the default number of shares in the pplns window of a block
+	DefaultPPLNSWindow = ShareChainLength
+	//MaxPPLNSWindow is the largest pplns window, the sharechain keeps at most this many shares in memory
+	MaxPPLNSWindow = 10 * ShareChainLength
+)
+
+var errInvalidPPLNSWindow = fmt.Errorf("pplns window must be between 1 and %v shares or a positive multiple of the expected shares per block", MaxPPLNSWindow)
+
+//ParsePPLNSWindow parses a pplns window given in shares ("8640") or as a multiple of the expected shares per block ("2x")
+func ParsePPLNSWindow(s string) (shares int, multiple float64, err error) {
+	if strings.HasSuffix(s, "x") {
+		multiple, err = strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+		if err != nil || !validPPLNSMultiple(multiple) {
+			return 0, 0, errInvalidPPLNSWindow
+		}
+		return
+	}
+	shares, err = strconv.Atoi(s)
+	if err != nil || shares <= 0 || shares > MaxPPLNSWindow {
+		return 0, 0, errInvalidPPLNSWindow
+	}
+	return
+}
+
+func validPPLNSMultiple(multiple float64) bool {
+	return multiple > 0 && !math.IsInf(multiple, 0) && !math.IsNaN(multiple)
+}
+
+//validPPLNSWindow checks the pplns window of a config, either a number of shares or a multiple is set after the defaults
+func (config *Config) validPPLNSWindow() error {
+	if config.PPLNSWindowMultiple != 0 {
+		if config.PPLNSWindow != 0 || !validPPLNSMultiple(config.PPLNSWindowMultiple) {
+			return errInvalidPPLNSWindow
+		}
+		return nil
+	}
+	if config.PPLNSWindow <= 0 || config.PPLNSWindow > MaxPPLNSWindow {
+		return errInvalidPPLNSWindow
+	}
+	return nil
+}
+
+//PPLNSWindow returns the number of shares in the pplns window of the next block.
+// A window set as a multiple of the expected shares per block follows the share ratio and the network difficulty,
+// it is limited to the MaxPPLNSWindow.
+func (sc *ShareChain) PPLNSWindow() int {
+	if sc.config.PPLNSWindowMultiple == 0 {
+		return sc.config.PPLNSWindow
+	}
+	window := sc.config.PPLNSWindowMultiple / sc.DifficultyRatio()
+	if math.IsNaN(window) || window > MaxPPLNSWindow {
+		return MaxPPLNSWindow
+	}
+	if window < 1 {
+		return 1
+	}
+	return int(window)
+}
+
+//shareCapacity returns the number of shares kept in memory, enough for the largest pplns window the config allows
+func (sc *ShareChain) shareCapacity() int {
+	if sc.config.PPLNSWindowMultiple == 0 {
+		return sc.config.PPLNSWindow
+	}
+	return MaxPPLNSWindow
+}
+
+//lastShares returns the last window shares of the shares kept in memory
+func lastShares(shares []Share, window int) []Share {
+	if len(shares) > window {
+		return shares[len(shares)-window:]
+	}
+	return shares
+}
+
+// putPPLNSWindow stores the size of the pplns window of a found block, so the
+// payouts can be replayed after the window setting changed.
+func putPPLNSWindow(tx *bolt.Tx, id types.BlockID, window int) error {
+	return tx.Bucket(PPLNSWindows).Put(id[:], encoding.Marshal(uint64(window)))
+}
+
+// pplnsWindowOf returns the size of the pplns window of a found block, blocks
+// found before the window was recorded used the ShareChainLength.
+func pplnsWindowOf(tx *bolt.Tx, id types.BlockID) (window uint64, err error) {
+	b := tx.Bucket(PPLNSWindows)
+	if b == nil {
+		return ShareChainLength, nil
+	}
+	raw := b.Get(id[:])
+	if raw == nil {
+		return ShareChainLength, nil
+	}
+	if err = encoding.Unmarshal(raw, &window); err == nil && window == 0 {
+		err = errors.New("pplns window of block " + id.String() + " is empty")
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package sharechain
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/pow"
+)
+
+func TestParsePPLNSWindow(t *testing.T) {
+	for _, test := range []struct {
+		window   string
+		shares   int
+		multiple float64
+		valid    bool
+	}{
+		{window: "8640", shares: 8640, valid: true},
+		{window: "2x", multiple: 2, valid: true},
+		{window: "0.5x", multiple: 0.5, valid: true},
+		{window: "0", valid: false},
+		{window: "-10", valid: false},
+		{window: strconv.Itoa(MaxPPLNSWindow + 1), valid: false},
+		{window: "0x", valid: false},
+		{window: "x", valid: false},
+		{window: "many", valid: false},
+	} {
+		shares, multiple, err := ParsePPLNSWindow(test.window)
+		if (err == nil) != test.valid || shares != test.shares || multiple != test.multiple {
+			t.Error("Expected", test.shares, test.multiple, test.valid, "for", test.window, "got", shares, multiple, err)
+		}
+	}
+	if config := (Config{PPLNSWindow: 100, PPLNSWindowMultiple: 2}); config.validPPLNSWindow() == nil {
+		t.Error("Both a window in shares and a multiple accepted")
+	}
+}
+
+func TestPPLNSWindow(t *testing.T) {
+	config := Config{BlockMaturity: 1, PPLNSWindow: 2}
+	sc, cleanup := newTestShareChain(t, config)
+	defer cleanup()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := sc.GetPPLNSSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary) != 1 || summary[testShares(b)[0].Miner] != 2 {
+		t.Error("Expected only the last 2 shares in the summary, got", summary)
+	}
+	reward := types.NewCurrency64(1e6)
+	block := types.Block{Nonce: types.BlockNonce{1}}
+	block.MinerPayouts, _ = sc.GenerateMinerPayouts(a, reward)
+	for _, payout := range block.MinerPayouts {
+		if payout.UnlockHash == a && payout.Value.Cmp(reward.Mul64(FinderBonus).Div64(1000)) != 0 {
+			t.Error("Share outside the window paid to", a, payout.Value)
+		}
+	}
+	if err = sc.AddFoundBlock(block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	sc.db.Close()
+
+	// The recorded window is used to replay the payouts, whatever the current setting.
+	discrepancies, err := Recompute(sc.persistDir, Config{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Error("Unexpected discrepancies:", discrepancies)
+	}
+}
+
+func TestPPLNSWindowMultiple(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{ShareRatio: 0.001, PPLNSWindowMultiple: 2})
+	defer cleanup()
+	// A block takes 1000 shares on average, so the window holds 2000.
+	mock.Target = pow.TargetFromDifficulty(pow.DifficultyFromTarget(StartTarget) * 1e6)
+	sc.updateShareTarget(sc.NetworkTarget())
+	if window := sc.PPLNSWindow(); math.Abs(float64(window)-2000) > 1 {
+		t.Error("Expected a window of 2000 shares, got", window)
+	}
+	// The window is limited when the shares are a tiny part of a block.
+	sc.SetShareRatio(1e-9)
+	if window := sc.PPLNSWindow(); window != MaxPPLNSWindow {
+		t.Error("Expected the maximum window, got", window)
+	}
+	if sc.shareCapacity() != MaxPPLNSWindow {
+		t.Error("Expected the maximum window to be kept in memory, got", sc.shareCapacity())
+	}
+}
@@ -0,0 +1,159 @@
+package sharechain
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+var errNoDatabase = errors.New("no sharechain database found")
+
+//Discrepancy is a difference between the stored and the recomputed earnings of a miner address
+type Discrepancy struct {
+	Address  types.UnlockHash
+	Stored   types.Currency
+	Expected types.Currency
+}
+
+//Recompute replays the pplns payouts of all matured found blocks from the stored shares with the fee of every block, applies the
+// adjustments of the earnings on top and compares the result with the stored earnings. If fix is true, the stored earnings are replaced by the recomputed ones.
+// The sharechain database in persistDir can not be in use by a running node.
+func Recompute(persistDir string, config Config, fix bool) (discrepancies []Discrepancy, err error) {
+	config.setDefaults()
+	filename := filepath.Join(persistDir, DatabaseFilename)
+	if _, err = os.Stat(filename); os.IsNotExist(err) {
+		return nil, errNoDatabase
+	}
+	db, err := persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return nil, errors.New("error opening sharechain database: " + err.Error())
+	}
+	defer db.Close()
+
+	recompute := func(tx *bolt.Tx) error {
+		if !dbInitialized(tx) {
+			return errNoDatabase
+		}
+		expected, err := recomputeEarnings(tx, config)
+		if err != nil {
+			return err
+		}
+		discrepancies, err = compareEarnings(tx, expected)
+		if err != nil || !fix || len(discrepancies) == 0 {
+			return err
+		}
+		return replaceEarnings(tx, expected)
+	}
+	if fix {
+		err = db.Update(recompute)
+	} else {
+		err = db.View(recompute)
+	}
+	return
+}
+
+// recomputeEarnings calculates the earnings of every miner address from the
+// pplns windows of the matured found blocks, with the fee that applied to
+// every block, and the adjustments. A debit that
+// exceeds the recomputed earnings is not applied, it shows as a discrepancy.
+func recomputeEarnings(tx *bolt.Tx, config Config) (earnings map[types.UnlockHash]types.Currency, err error) {
+	earnings = make(map[types.UnlockHash]types.Currency)
+	err = tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+		var fb FoundBlock
+		if err := encoding.Unmarshal(v, &fb); err != nil {
+			return err
+		}
+		if fb.Status != BlockMatured {
+			return nil
+		}
+		size, err := pplnsWindowOf(tx, fb.ID)
+		if err != nil {
+			return err
+		}
+		window, err := shareWindow(tx, fb.ShareIndex, size)
+		if err != nil {
+			return err
+		}
+		solverWeight, err := solverWeightOf(tx, fb.ID)
+		if err != nil {
+			return err
+		}
+		fee, feeAddress, err := blockFeeOf(tx, fb.ID, config)
+		if err != nil {
+			return err
+		}
+		for _, payout := range pplnsPayouts(window, fb.Finder, fb.Reward(), fee, feeAddress, solverWeight) {
+			earnings[payout.UnlockHash] = earnings[payout.UnlockHash].Add(payout.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	adjustments, err := storedAdjustments(tx)
+	for _, adjustment := range adjustments {
+		earnings[adjustment.Address], _ = adjustment.apply(earnings[adjustment.Address])
+	}
+	return
+}
+
+// compareEarnings returns the differences between the stored and the expected
+// earnings, sorted by address.
+func compareEarnings(tx *bolt.Tx, expected map[types.UnlockHash]types.Currency) (discrepancies []Discrepancy, err error) {
+	stored := make(map[types.UnlockHash]types.Currency)
+	err = tx.Bucket(Earnings).ForEach(func(k, v []byte) error {
+		var address types.UnlockHash
+		copy(address[:], k)
+		var value types.Currency
+		if err := encoding.Unmarshal(v, &value); err != nil {
+			return err
+		}
+		stored[address] = value
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for address, value := range stored {
+		if expected[address].Cmp(value) != 0 {
+			discrepancies = append(discrepancies, Discrepancy{Address: address, Stored: value, Expected: expected[address]})
+		}
+	}
+	for address, value := range expected {
+		if _, found := stored[address]; !found && !value.IsZero() {
+			discrepancies = append(discrepancies, Discrepancy{Address: address, Stored: types.ZeroCurrency, Expected: value})
+		}
+	}
+	sort.Sort(discrepanciesByAddress(discrepancies))
+	return
+}
+
+// replaceEarnings replaces all stored earnings by the given ones.
+func replaceEarnings(tx *bolt.Tx, earnings map[types.UnlockHash]types.Currency) error {
+	if err := tx.DeleteBucket(Earnings); err != nil {
+		return err
+	}
+	if _, err := tx.CreateBucket(Earnings); err != nil {
+		return err
+	}
+	for address, value := range earnings {
+		if err := addEarnings(tx, address, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type discrepanciesByAddress []Discrepancy
+
+func (d discrepanciesByAddress) Len() int      { return len(d) }
+func (d discrepanciesByAddress) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d discrepanciesByAddress) Less(i, j int) bool {
+	return string(d[i].Address[:]) < string(d[j].Address[:])
+}
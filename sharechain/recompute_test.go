@@ -0,0 +1,122 @@
+package sharechain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestRecompute(t *testing.T) {
+	config := Config{BlockMaturity: 1, Fee: 100, FeeAddress: types.UnlockHash{9}}
+	sc, cleanup := newTestShareChain(t, config)
+	defer cleanup()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	block := types.Block{Nonce: types.BlockNonce{1}}
+	block.MinerPayouts, _ = sc.GenerateMinerPayouts(a, types.NewCurrency64(1e6))
+	if err := sc.AddFoundBlock(block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	sc.db.Close()
+
+	discrepancies, err := Recompute(sc.persistDir, config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Fatal("Unexpected discrepancies:", discrepancies)
+	}
+
+	// Tamper with the stored earnings
+	sc.db = nil
+	if err = sc.openDB(filepath.Join(sc.persistDir, DatabaseFilename)); err != nil {
+		t.Fatal(err)
+	}
+	err = sc.db.Update(func(tx *bolt.Tx) error {
+		return addEarnings(tx, b, types.NewCurrency64(1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.db.Close()
+
+	discrepancies, err = Recompute(sc.persistDir, config, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 1 || discrepancies[0].Address != b {
+		t.Fatal("Expected a discrepancy for", b, "got", discrepancies)
+	}
+	if discrepancies[0].Stored.Cmp(discrepancies[0].Expected.Add(types.NewCurrency64(1))) != 0 {
+		t.Error("Unexpected discrepancy", discrepancies[0])
+	}
+	if discrepancies, err = Recompute(sc.persistDir, config, false); err != nil || len(discrepancies) != 0 {
+		t.Error("Discrepancies remain after fixing:", discrepancies, err)
+	}
+
+	// The fee of the block applies, not the current fee of the pool.
+	changed := Config{BlockMaturity: 1, Fee: 300, FeeAddress: types.UnlockHash{8}}
+	if discrepancies, err = Recompute(sc.persistDir, changed, false); err != nil || len(discrepancies) != 0 {
+		t.Error("Discrepancies after a fee change:", discrepancies, err)
+	}
+}
+
+func TestRecomputeMinerBlock(t *testing.T) {
+	config := Config{BlockMaturity: 1, Fee: 100, FeeAddress: types.UnlockHash{9}}
+	sc, cleanup := newTestShareChain(t, config)
+	defer cleanup()
+
+	a, b, late := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	window, size, shareIndex := sc.windowShares()
+	template := Template{Block: types.Block{Nonce: types.BlockNonce{1}}, Height: 1, ShareIndex: shareIndex, window: window, windowSize: size}
+	block, err := sc.MinerBlock(template, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shares arrive between the template build and the block find.
+	for _, share := range testShares(late, late) {
+		sc.AddShare(share)
+	}
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.AddMinerBlock(template, block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	sc.db.Close()
+
+	discrepancies, err := Recompute(sc.persistDir, config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Error("Unexpected discrepancies:", discrepancies)
+	}
+}
+
+func TestRecomputeWithoutDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sharechain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err = Recompute(dir, Config{}, false); err != errNoDatabase {
+		t.Error("Expected", errNoDatabase, "got", err)
+	}
+}
@@ -0,0 +1,101 @@
+package sharechain
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//DefaultRejectRetention is the default time rejected shares are kept in the reject log
+const DefaultRejectRetention = 24 * time.Hour
+
+// maxUnsavedRejects is the number of rejected shares that are buffered until
+// the next write to disk, further rejects are left out of the log.
+const maxUnsavedRejects = 10000
+
+//RejectReason is why a share was rejected
+type RejectReason string
+
+const (
+	//RejectStale is the reason for a share for a job that is no longer valid
+	RejectStale RejectReason = "stale"
+	//RejectSkewed is the reason for a share with a header timestamp too far from the time of the pool
+	RejectSkewed RejectReason = "skewed"
+	//RejectLowDifficulty is the reason for a share that does not meet the difficulty of its connection or the share target
+	RejectLowDifficulty RejectReason = "lowdifficulty"
+	//RejectDuplicate is the reason for a share that was submitted before
+	RejectDuplicate RejectReason = "duplicate"
+)
+
+//Reject is a rejected share in the reject log, Timestamp is the time the share was received
+type Reject struct {
+	Timestamp       types.Timestamp `json:"timestamp"`
+	Miner           string          `json:"miner"`
+	Reason          RejectReason    `json:"reason"`
+	Job             string          `json:"job"`
+	HeaderTimestamp types.Timestamp `json:"headertimestamp"`
+}
+
+//RecordReject adds a rejected share to the reject log if LogRejects is set. Like the audit entries, it is buffered
+// and written to disk together with the shares, so logging never delays the answer to the miner.
+func (sc *ShareChain) RecordReject(reject Reject) {
+	if !sc.config.LogRejects {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.unsavedRejects) < maxUnsavedRejects {
+		sc.unsavedRejects = append(sc.unsavedRejects, reject)
+	}
+}
+
+//Rejects returns the rejected shares since the given time, oldest first and at most MaxAuditEntries.
+// If address is not empty only the shares of that miner address are returned.
+func (sc *ShareChain) Rejects(address types.UnlockHash, since time.Time) (rejects []Reject, err error) {
+	rejects = make([]Reject, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(Rejects).Cursor()
+		for k, v := c.Seek(auditKey(types.Timestamp(since.Unix()), 0)); k != nil && len(rejects) < MaxAuditEntries; k, v = c.Next() {
+			var reject Reject
+			if err := encoding.Unmarshal(v, &reject); err != nil {
+				return err
+			}
+			if address != (types.UnlockHash{}) {
+				if miner, err := MinerAddress(reject.Miner); err != nil || miner != address {
+					continue
+				}
+			}
+			rejects = append(rejects, reject)
+		}
+		return nil
+	})
+	return
+}
+
+// putRejects appends rejected shares to the reject log and removes the
+// rejects received before cutoff.
+func putRejects(tx *bolt.Tx, rejects []Reject, cutoff types.Timestamp) error {
+	if len(rejects) == 0 {
+		return nil
+	}
+	b := tx.Bucket(Rejects)
+	for _, reject := range rejects {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err = b.Put(auditKey(reject.Timestamp, seq), encoding.Marshal(reject)); err != nil {
+			return err
+		}
+	}
+	end := auditKey(cutoff, 0)
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < string(end); k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestRejects(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{LogRejects: true, RejectRetention: time.Hour})
+	defer cleanup()
+	now := types.CurrentTimestamp()
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	sc.RecordReject(Reject{Timestamp: now - 7200, Miner: a.String(), Reason: RejectStale, Job: "1"})
+	sc.RecordReject(Reject{Timestamp: now, Miner: a.String() + ".rig1", Reason: RejectStale, Job: "2"})
+	sc.RecordReject(Reject{Timestamp: now, Miner: b.String(), Reason: RejectSkewed, Job: "3"})
+	if rejects, _ := sc.Rejects(types.UnlockHash{}, time.Unix(0, 0)); len(rejects) != 0 {
+		t.Error("Rejects written before the flush")
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reject older than the retention is removed when the rejects are
+	// written.
+	rejects, err := sc.Rejects(types.UnlockHash{}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejects) != 2 || rejects[0].Job != "2" || rejects[1].Job != "3" {
+		t.Error("Expected the 2 recent rejects, got", rejects)
+	}
+	if rejects, _ = sc.Rejects(a, time.Unix(0, 0)); len(rejects) != 1 || rejects[0].Reason != RejectStale {
+		t.Error("Expected the reject of the worker of the address, got", rejects)
+	}
+	if rejects, _ = sc.Rejects(types.UnlockHash{}, time.Unix(int64(now)+1, 0)); len(rejects) != 0 {
+		t.Error("Expected no rejects after the since time, got", rejects)
+	}
+}
+
+func TestRejectsNotLogged(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	sc.RecordReject(Reject{Timestamp: types.CurrentTimestamp(), Reason: RejectStale})
+	if len(sc.unsavedRejects) != 0 {
+		t.Error("Reject recorded without LogRejects")
+	}
+	for i := 0; i < maxUnsavedRejects+1; i++ {
+		sc.config.LogRejects = true
+		sc.RecordReject(Reject{Timestamp: types.CurrentTimestamp(), Reason: RejectStale})
+	}
+	if len(sc.unsavedRejects) != maxUnsavedRejects {
+		t.Error("Expected", maxUnsavedRejects, "buffered rejects, got", len(sc.unsavedRejects))
+	}
+}
@@ -0,0 +1,109 @@
+package sharechain
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/pow"
+)
+
+const (
+	//MinReputation is the reputation score below which a peer relaying shares is disconnected
+	MinReputation = 0.2
+	// minReputationShares is the number of shares a peer needs to relay
+	// before it can be disconnected for its reputation
+	minReputationShares = 10
+)
+
+var errShareTarget = errors.New("share does not meet the sharechain target")
+
+//PeerReputation counts the valid and invalid shares a peer relayed
+type PeerReputation struct {
+	Valid   uint64 `json:"valid"`
+	Invalid uint64 `json:"invalid"`
+}
+
+//Score is the estimated fraction of valid shares relayed by the peer, a peer without shares scores 0.5
+func (r PeerReputation) Score() float64 {
+	return float64(r.Valid+1) / float64(r.Valid+r.Invalid+2)
+}
+
+//RelayedShare checks the proof of work of a share relayed by a peer and updates the reputation of the peer.
+// A peer whose reputation drops below MinReputation is disconnected, a peer relaying a valid share is remembered as a pool peer.
+func (sc *ShareChain) RelayedShare(peer modules.NetAddress, b types.Block) error {
+	var err error
+	if !pow.MeetsTarget(crypto.Hash(b.ID()), sc.ShareTarget()) {
+		err = errShareTarget
+	}
+	if err == nil {
+		if perr := sc.recordPoolPeer(peer, time.Now()); perr != nil {
+			sc.log.Println("Error storing pool peer", peer, ":", perr)
+		}
+	}
+	if sc.updateReputation(peer, err == nil) && sc.Siad != nil {
+		sc.log.Println("Disconnecting peer", peer, "relaying invalid shares")
+		sc.Siad.Gateway().Disconnect(peer)
+	}
+	return err
+}
+
+// updateReputation counts a share relayed by peer and returns if the peer
+// should be disconnected.
+func (sc *ShareChain) updateReputation(peer modules.NetAddress, valid bool) (disconnect bool) {
+	sc.reputationMutex.Lock()
+	defer sc.reputationMutex.Unlock()
+	if sc.reputation == nil {
+		sc.reputation = make(map[modules.NetAddress]*PeerReputation)
+	}
+	r, found := sc.reputation[peer]
+	if !found {
+		r = &PeerReputation{}
+		sc.reputation[peer] = r
+	}
+	if valid {
+		r.Valid++
+	} else {
+		r.Invalid++
+	}
+	if r.Valid+r.Invalid < minReputationShares || r.Score() >= MinReputation {
+		return false
+	}
+	// Forget a disconnected peer, it starts over if it reconnects.
+	delete(sc.reputation, peer)
+	return true
+}
+
+//Reputation returns the reputation of a peer
+func (sc *ShareChain) Reputation(peer modules.NetAddress) PeerReputation {
+	sc.reputationMutex.Lock()
+	defer sc.reputationMutex.Unlock()
+	if r, found := sc.reputation[peer]; found {
+		return *r
+	}
+	return PeerReputation{}
+}
+
+//RelayOrder sorts the peers to relay shares to by descending reputation
+func (sc *ShareChain) RelayOrder(peers []modules.NetAddress) []modules.NetAddress {
+	ordered := peersByReputation{peers: append([]modules.NetAddress(nil), peers...), scores: make(map[modules.NetAddress]float64)}
+	for _, peer := range peers {
+		ordered.scores[peer] = sc.Reputation(peer).Score()
+	}
+	sort.Stable(ordered)
+	return ordered.peers
+}
+
+type peersByReputation struct {
+	peers  []modules.NetAddress
+	scores map[modules.NetAddress]float64
+}
+
+func (p peersByReputation) Len() int      { return len(p.peers) }
+func (p peersByReputation) Swap(i, j int) { p.peers[i], p.peers[j] = p.peers[j], p.peers[i] }
+func (p peersByReputation) Less(i, j int) bool {
+	return p.scores[p.peers[i]] > p.scores[p.peers[j]]
+}
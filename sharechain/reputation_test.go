@@ -0,0 +1,51 @@
+package sharechain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// relayedBlocks returns a block that meets the share target it sets and one
+// that does not.
+func relayedBlocks(sc *ShareChain) (valid, junk types.Block) {
+	valid, junk = types.Block{Nonce: types.BlockNonce{1}}, types.Block{Nonce: types.BlockNonce{2}}
+	validID, junkID := valid.ID(), junk.ID()
+	if bytes.Compare(validID[:], junkID[:]) > 0 {
+		valid, junk = junk, valid
+	}
+	sc.Target = types.Target(valid.ID())
+	return
+}
+
+func TestReputation(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	valid, junk := relayedBlocks(sc)
+
+	good, bad := modules.NetAddress("good:9981"), modules.NetAddress("bad:9981")
+	for i := 0; i < 5; i++ {
+		if err := sc.RelayedShare(good, valid); err != nil {
+			t.Fatal(err)
+		}
+		if err := sc.RelayedShare(bad, junk); err != errShareTarget {
+			t.Fatal("Expected", errShareTarget, "got", err)
+		}
+	}
+	if r := sc.Reputation(bad); r.Invalid != 5 || r.Score() >= sc.Reputation(good).Score() {
+		t.Error("Unexpected reputation of a peer relaying junk:", r)
+	}
+	if order := sc.RelayOrder([]modules.NetAddress{bad, "new:9981", good}); order[0] != good || order[1] != "new:9981" || order[2] != bad {
+		t.Error("Peers not ordered by reputation:", order)
+	}
+
+	// The peer relaying junk is disconnected once enough shares are seen.
+	for i := 0; i < minReputationShares; i++ {
+		sc.RelayedShare(bad, junk)
+	}
+	if r := sc.Reputation(bad); r.Valid+r.Invalid >= minReputationShares {
+		t.Error("Peer below the minimum reputation not disconnected:", r)
+	}
+}
@@ -0,0 +1,100 @@
+package sharechain
+
+import (
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//RewardSplit is the distribution of the reward of a found block as it was when the block was found.
+// It is stored once and never updated, so it stays the same when the payout logic or the fee change later on.
+type RewardSplit struct {
+	BlockID types.BlockID     `json:"blockid"`
+	Height  types.BlockHeight `json:"height"`
+	//Subsidy is the total reward of the block, the block subsidy plus the transaction fees
+	Subsidy types.Currency `json:"subsidy"`
+	//FeePercentage is the pool fee in 0.01% that applied when the block was found
+	FeePercentage int              `json:"feepercentage"`
+	Fee           types.Currency   `json:"fee"`
+	FeeAddress    types.UnlockHash `json:"feeaddress"`
+	//Payouts are the parts of the subsidy paid to the miners, they add up to the subsidy minus the fee
+	Payouts []types.SiacoinOutput `json:"payouts"`
+}
+
+//newRewardSplit splits the payouts of a block in the pool fee and the parts of the miners,
+// without a fee address no fee is taken
+func newRewardSplit(fb FoundBlock, fee int, feeAddress types.UnlockHash) RewardSplit {
+	if feeAddress == (types.UnlockHash{}) {
+		fee = 0
+	}
+	split := RewardSplit{
+		BlockID:       fb.ID,
+		Height:        fb.Height,
+		Subsidy:       fb.Reward(),
+		FeePercentage: fee,
+		Fee:           types.ZeroCurrency,
+		FeeAddress:    feeAddress,
+		Payouts:       make([]types.SiacoinOutput, 0, len(fb.Payouts)),
+	}
+	if fee > 0 {
+		split.Fee = split.Subsidy.Mul64(uint64(fee)).Div64(10000)
+	}
+	// The fee is taken from the payouts to the fee address, which can also receive a part as miner.
+	unassigned := split.Fee
+	for _, payout := range fb.Payouts {
+		if payout.UnlockHash == feeAddress && !unassigned.IsZero() {
+			taken := unassigned
+			if payout.Value.Cmp(taken) < 0 {
+				taken = payout.Value
+			}
+			payout.Value = payout.Value.Sub(taken)
+			unassigned = unassigned.Sub(taken)
+		}
+		if payout.Value.IsZero() {
+			continue
+		}
+		split.Payouts = append(split.Payouts, payout)
+	}
+	// A block that pays less than the fee to the fee address has a lower fee.
+	split.Fee = split.Fee.Sub(unassigned)
+	return split
+}
+
+//RewardSplit returns the reward distribution of a found block, blocks found before reward splits were recorded do not have one
+func (sc *ShareChain) RewardSplit(id types.BlockID) (split RewardSplit, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(RewardSplits).Get(id[:])
+		if raw == nil {
+			return errNilItem
+		}
+		return encoding.Unmarshal(raw, &split)
+	})
+	return
+}
+
+// blockFeeOf returns the pool fee and fee address that applied to a found
+// block, recorded in its reward split. Blocks found before the reward splits
+// were recorded use the fee of the config.
+func blockFeeOf(tx *bolt.Tx, id types.BlockID, config Config) (fee int, feeAddress types.UnlockHash, err error) {
+	raw := tx.Bucket(RewardSplits).Get(id[:])
+	if raw == nil {
+		return config.Fee, config.FeeAddress, nil
+	}
+	var split RewardSplit
+	if err = encoding.Unmarshal(raw, &split); err != nil {
+		return
+	}
+	return split.FeePercentage, split.FeeAddress, nil
+}
+
+// putRewardSplit stores the reward split of a found block, an existing split is
+// never replaced.
+func putRewardSplit(tx *bolt.Tx, split RewardSplit) error {
+	b := tx.Bucket(RewardSplits)
+	if b.Get(split.BlockID[:]) != nil {
+		return errRepeatInsert
+	}
+	return b.Put(split.BlockID[:], encoding.Marshal(split))
+}
@@ -0,0 +1,52 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestRewardSplit(t *testing.T) {
+	a, b, feeAddress := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	sc, cleanup := newTestShareChain(t, Config{Fee: 200, FeeAddress: feeAddress})
+	defer cleanup()
+
+	subsidy := types.NewCurrency64(1000003)
+	// The fee address also mines, so it receives a part next to the fee.
+	found := types.Block{
+		Nonce:        types.BlockNonce{1},
+		MinerPayouts: pplnsPayouts(testShares(a, a, feeAddress, b), b, subsidy, 200, feeAddress, 0),
+	}
+	if err := sc.AddFoundBlock(found, b); err != nil {
+		t.Fatal(err)
+	}
+	split, err := sc.RewardSplit(found.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if split.Subsidy.Cmp(subsidy) != 0 {
+		t.Error("Expected subsidy", subsidy, "got", split.Subsidy)
+	}
+	if split.Fee.Cmp(types.NewCurrency64(20000)) != 0 || split.FeeAddress != feeAddress || split.FeePercentage != 200 {
+		t.Error("Expected a 2% fee of 20000 to", feeAddress, "got", split.Fee, split.FeePercentage, split.FeeAddress)
+	}
+	if total := sumPayouts(split.Payouts); total.Cmp(subsidy.Sub(split.Fee)) != 0 {
+		t.Error("Parts sum up to", total, "instead of the subsidy minus the fee", subsidy.Sub(split.Fee))
+	}
+	if len(split.Payouts) != 3 {
+		t.Error("Expected a part for all 3 miners, got", split.Payouts)
+	}
+
+	// The split is taken when the block is found and does not change with the configuration.
+	sc.config.Fee = 0
+	if err = sc.AddFoundBlock(found, b); err != errRepeatInsert {
+		t.Error("Expected", errRepeatInsert, "got", err)
+	}
+	if again, _ := sc.RewardSplit(found.ID()); again.Fee.Cmp(split.Fee) != 0 {
+		t.Error("Stored split changed, fee", again.Fee)
+	}
+
+	if _, err = sc.RewardSplit(types.BlockID{}); err != errNilItem {
+		t.Error("Expected", errNilItem, "for an unknown block, got", err)
+	}
+}
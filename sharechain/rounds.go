@@ -0,0 +1,161 @@
+package sharechain
+
+import (
+	"encoding/binary"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//Round is the time between two blocks found by the pool, the first round starts at the first share ever accepted
+type Round struct {
+	Number uint64 `json:"number"`
+	//Start is the time the previous block was found, End the time the block of the round was found
+	Start types.Timestamp `json:"start"`
+	End   types.Timestamp `json:"end"`
+	//Shares is the number of shares accepted during the round, LastShare the sequence number of the last of them
+	Shares    uint64 `json:"shares"`
+	LastShare uint64 `json:"lastshare"`
+	//Miners is the number of miner addresses that contributed shares
+	Miners  int               `json:"miners"`
+	BlockID types.BlockID     `json:"blockid"`
+	Height  types.BlockHeight `json:"height"`
+	//ExpectedShares is the number of shares it takes on average to find a block at the difficulties when the round ended,
+	// it is 0 if the network difficulty was not known
+	ExpectedShares uint64 `json:"expectedshares"`
+}
+
+//Luck returns the expected shares divided by the shares of the round, above 1 the block was found faster than
+// average. It is 0 if the expected shares are unknown or no share was accepted during the round.
+func (r Round) Luck() float64 {
+	if r.Shares == 0 {
+		return 0
+	}
+	return float64(r.ExpectedShares) / float64(r.Shares)
+}
+
+// round is the round in progress.
+type round struct {
+	start      types.Timestamp
+	firstShare uint64
+	miners     map[types.UnlockHash]struct{}
+}
+
+// addShare counts the miner of a share toward the round.
+func (r *round) addShare(share Share) {
+	if r.miners == nil {
+		r.miners = make(map[types.UnlockHash]struct{})
+	}
+	if address, err := MinerAddress(share.Miner); err == nil {
+		r.miners[address] = struct{}{}
+	}
+}
+
+//Rounds returns the completed rounds, oldest first
+func (sc *ShareChain) Rounds() (rounds []Round, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	rounds = make([]Round, 0)
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(Rounds).ForEach(func(k, v []byte) error {
+			var r Round
+			if err := encoding.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			rounds = append(rounds, r)
+			return nil
+		})
+	})
+	return
+}
+
+//CurrentRound returns the round in progress, it has no block and ends now
+func (sc *ShareChain) CurrentRound() Round {
+	expected := sc.expectedShares()
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.currentRound(types.CurrentTimestamp(), expected)
+}
+
+// currentRound summarizes the round in progress as if it ended at end, the
+// caller needs to hold the lock.
+func (sc *ShareChain) currentRound(end types.Timestamp, expected uint64) Round {
+	start := sc.round.start
+	if start == 0 {
+		// No share accepted yet.
+		start = end
+	}
+	return Round{
+		Number:         sc.roundNumber + 1,
+		Start:          start,
+		End:            end,
+		Shares:         sc.totalShares - sc.round.firstShare,
+		LastShare:      sc.totalShares,
+		Miners:         len(sc.round.miners),
+		Height:         sc.height + 1,
+		ExpectedShares: expected,
+	}
+}
+
+// expectedShares returns the number of shares it takes on average to find a
+// block, 0 if the network difficulty is not known.
+func (sc *ShareChain) expectedShares() uint64 {
+	if sc.Siad == nil {
+		return 0
+	}
+	if ratio := sc.DifficultyRatio(); ratio > 0 {
+		return uint64(1/ratio + 0.5)
+	}
+	return 0
+}
+
+// endRound stores the summary of the round in progress as the round of the
+// found block, the caller needs to hold the lock and start the next round
+// once the transaction is committed.
+func (sc *ShareChain) endRound(tx *bolt.Tx, fb FoundBlock, expected uint64) (r Round, err error) {
+	r = sc.currentRound(types.CurrentTimestamp(), expected)
+	r.BlockID, r.Height = fb.ID, fb.Height
+	err = tx.Bucket(Rounds).Put(roundKey(r.Number), encoding.Marshal(r))
+	return
+}
+
+// startRound starts a new round after r, the caller needs to hold the lock.
+func (sc *ShareChain) startRound(r Round) {
+	sc.roundNumber = r.Number
+	sc.round = round{start: r.End, firstShare: r.LastShare, miners: make(map[types.UnlockHash]struct{})}
+}
+
+// loadRound restores the round in progress from the last completed round and
+// the shares accepted since.
+func (sc *ShareChain) loadRound(tx *bolt.Tx) error {
+	sc.roundNumber = 0
+	sc.round = round{miners: make(map[types.UnlockHash]struct{})}
+	if b := tx.Bucket(Rounds); b != nil {
+		if k, v := b.Cursor().Last(); k != nil {
+			var last Round
+			if err := encoding.Unmarshal(v, &last); err != nil {
+				return err
+			}
+			sc.startRound(last)
+		}
+	}
+	c := tx.Bucket(Shares).Cursor()
+	for k, v := c.Seek(shareKey(sc.round.firstShare + 1)); k != nil; k, v = c.Next() {
+		var share Share
+		if err := encoding.Unmarshal(v, &share); err != nil {
+			return err
+		}
+		if sc.round.start == 0 {
+			sc.round.start = share.Timestamp
+		}
+		sc.round.addShare(share)
+	}
+	return nil
+}
+
+func roundKey(number uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, number)
+	return k
+}
@@ -0,0 +1,71 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestRounds(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+
+	if current := sc.CurrentRound(); current.Number != 1 || current.Shares != 0 || current.Miners != 0 || current.End != current.Start {
+		t.Error("Unexpected first round without shares:", current)
+	}
+	for _, share := range testShares(a, b, a) {
+		sc.AddShare(share)
+	}
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, a); err != nil {
+		t.Fatal(err)
+	}
+	rounds, err := sc.Rounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 1 || rounds[0].Number != 1 || rounds[0].Shares != 3 || rounds[0].Miners != 2 || rounds[0].BlockID != found.ID() {
+		t.Fatal("Expected a first round of 3 shares by 2 miners, got", rounds)
+	}
+	if rounds[0].Start == 0 || rounds[0].End < rounds[0].Start {
+		t.Error("First round does not start at its first share:", rounds[0])
+	}
+
+	// Shares after the find belong to the next round.
+	sc.AddShare(testShares(b)[0])
+	current := sc.CurrentRound()
+	if current.Number != 2 || current.Shares != 1 || current.Miners != 1 || current.Start != rounds[0].End {
+		t.Error("Unexpected second round:", current)
+	}
+
+	// The round in progress is restored from the database.
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	sc.db.Close()
+	reloaded := &ShareChain{persistDir: sc.persistDir, config: sc.config}
+	if err = reloaded.initPersist(); err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.db.Close()
+	if restored := reloaded.CurrentRound(); restored.Number != 2 || restored.Shares != 1 || restored.Miners != 1 || restored.Start != current.Start {
+		t.Error("Expected the second round to be restored, got", restored)
+	}
+}
+
+func TestRoundLuck(t *testing.T) {
+	for _, test := range []struct {
+		round Round
+		luck  float64
+	}{
+		{Round{ExpectedShares: 100, Shares: 50}, 2},
+		{Round{ExpectedShares: 100, Shares: 200}, 0.5},
+		{Round{ExpectedShares: 100}, 0},
+		{Round{Shares: 10}, 0},
+	} {
+		if luck := test.round.Luck(); luck != test.luck {
+			t.Error("Expected luck", test.luck, "for", test.round.ExpectedShares, "expected and", test.round.Shares, "shares, got", luck)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package sharechain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//DefaultSelfCheckInterval is the default time between two consistency checks of the sharechain
+const DefaultSelfCheckInterval = 10 * time.Minute
+
+//SelfCheck verifies that the state of the sharechain is consistent and returns the first inconsistency found:
+// the earnings of the miners minus the adjustments and the matured payouts add up to the total paid, the stored shares are numbered
+// without gaps up to the number of accepted shares and the newest share in memory is the newest share stored.
+// It decodes only the found blocks, the earnings and the newest share, so it is cheap enough to run on a live node.
+func (sc *ShareChain) SelfCheck() error {
+	// Shares being written are neither buffered nor stored.
+	sc.flushMutex.Lock()
+	defer sc.flushMutex.Unlock()
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.db.View(func(tx *bolt.Tx) error {
+		if err := checkBalances(tx); err != nil {
+			return err
+		}
+		return sc.checkShares(tx)
+	})
+}
+
+// checkBalances verifies the sum of the earnings, corrected by the
+// adjustments, and the sum of the payouts of the matured blocks against the
+// total paid.
+func checkBalances(tx *bolt.Tx) error {
+	paid := types.ZeroCurrency
+	if err := getTotal(tx, keyPaid, &paid); err != nil {
+		return err
+	}
+	earnings := types.ZeroCurrency
+	err := tx.Bucket(Earnings).ForEach(func(k, v []byte) error {
+		var value types.Currency
+		if err := encoding.Unmarshal(v, &value); err != nil {
+			return err
+		}
+		earnings = earnings.Add(value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The adjustments move the earnings away from the total paid.
+	adjustments, err := storedAdjustments(tx)
+	if err != nil {
+		return err
+	}
+	credited, debited := types.ZeroCurrency, types.ZeroCurrency
+	for _, adjustment := range adjustments {
+		if adjustment.Debit {
+			debited = debited.Add(adjustment.Value)
+		} else {
+			credited = credited.Add(adjustment.Value)
+		}
+	}
+	if earnings.Add(debited).Cmp(paid.Add(credited)) != 0 {
+		return fmt.Errorf("earnings of %v do not add up to the total paid of %v with %v credited and %v debited", earnings, paid, credited, debited)
+	}
+	matured := types.ZeroCurrency
+	err = tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+		var fb FoundBlock
+		if err := encoding.Unmarshal(v, &fb); err != nil {
+			return err
+		}
+		if fb.Status == BlockMatured {
+			matured = matured.Add(fb.Reward())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if matured.Cmp(paid) != 0 {
+		return fmt.Errorf("payouts of the matured blocks of %v do not add up to the total paid of %v", matured, paid)
+	}
+	return nil
+}
+
+// checkShares verifies the stored and buffered shares against the number of
+// accepted shares, the caller needs to hold the lock and the flush mutex.
+func (sc *ShareChain) checkShares(tx *bolt.Tx) error {
+	b := tx.Bucket(Shares)
+	k, v := b.Cursor().Last()
+	var stored uint64
+	if k != nil {
+		stored = shareSeq(k)
+	}
+	if n := uint64(b.Stats().KeyN); n != stored {
+		return fmt.Errorf("the newest stored share is number %v but %v shares are stored", stored, n)
+	}
+	if total := stored + uint64(len(sc.unsavedShares)); total != sc.totalShares {
+		return fmt.Errorf("%v shares are stored or buffered but %v were accepted", total, sc.totalShares)
+	}
+	if len(sc.unsavedShares) > 0 || k == nil {
+		return nil
+	}
+	var newest Share
+	if err := encoding.Unmarshal(v, &newest); err != nil {
+		return err
+	}
+	if len(sc.shares) == 0 || sc.shares[len(sc.shares)-1].BlockID != newest.BlockID {
+		return fmt.Errorf("the newest share in memory is not the newest stored share %v", newest.BlockID)
+	}
+	return nil
+}
+
+// threadedSelfCheck runs the SelfCheck every SelfCheckInterval until the
+// sharechain is closed. An inconsistency is reported by Ready until a later
+// check passes, with SelfCheckHalt the accounting stops until the node is
+// restarted.
+func (sc *ShareChain) threadedSelfCheck() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(sc.config.SelfCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case <-ticker.C:
+			sc.runSelfCheck()
+		}
+	}
+}
+
+// runSelfCheck runs the SelfCheck and records its outcome.
+func (sc *ShareChain) runSelfCheck() {
+	err := sc.SelfCheck()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if err != nil && sc.inconsistency == nil {
+		sc.log.Println("Sharechain self-check failed:", err)
+	} else if err == nil && sc.inconsistency != nil {
+		sc.log.Println("Sharechain self-check passed again")
+	}
+	sc.inconsistency = err
+	if err != nil && sc.config.SelfCheckHalt && sc.halted == nil {
+		sc.halted = fmt.Errorf("sharechain inconsistent, accounting stopped at height %v: %v", sc.height, err)
+		sc.log.Critical(sc.halted, "- check the database with the recompute command and restart the node")
+	}
+}
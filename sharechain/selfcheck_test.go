@@ -0,0 +1,86 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestSelfCheck(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 1})
+	defer cleanup()
+	if err := sc.SelfCheck(); err != nil {
+		t.Fatal("Empty sharechain inconsistent:", err)
+	}
+
+	for i := byte(0); i < 3; i++ {
+		sc.AddShare(Share{Miner: "a", BlockID: types.BlockID{i}})
+	}
+	if err := sc.SelfCheck(); err != nil {
+		t.Error("Buffered shares inconsistent:", err)
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	found := testBlock(1, 1000)
+	if err := sc.AddFoundBlock(found, types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{found}})
+	if err := sc.SelfCheck(); err != nil {
+		t.Error("Matured block inconsistent:", err)
+	}
+
+	sc.shares[len(sc.shares)-1].BlockID = types.BlockID{9}
+	if err := sc.SelfCheck(); err == nil {
+		t.Error("Newest share in memory differs from the stored one without an inconsistency")
+	}
+	sc.shares[len(sc.shares)-1].BlockID = types.BlockID{2}
+
+	sc.totalShares++
+	if err := sc.SelfCheck(); err == nil {
+		t.Error("Lost share not detected")
+	}
+	sc.totalShares--
+
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		return addEarnings(tx, types.UnlockHash{2}, types.NewCurrency64(1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.SelfCheck(); err == nil {
+		t.Error("Earnings above the total paid not detected")
+	}
+}
+
+func TestSelfCheckReady(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	sc.runSelfCheck()
+	if err := sc.Ready(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc.totalShares++
+	sc.runSelfCheck()
+	if err := sc.Ready(); err == nil || sc.halted != nil {
+		t.Error("Expected an inconsistency without halting, got", err, sc.halted)
+	}
+	sc.totalShares--
+	sc.runSelfCheck()
+	if err := sc.Ready(); err != nil {
+		t.Error("Expected the node to be ready after a passing check, got", err)
+	}
+
+	sc.config.SelfCheckHalt = true
+	sc.totalShares++
+	sc.runSelfCheck()
+	sc.totalShares--
+	sc.runSelfCheck()
+	if err := sc.Ready(); err == nil || sc.halted == nil {
+		t.Error("Expected the accounting to stay halted, got", err)
+	}
+}
@@ -2,11 +2,16 @@ package sharechain
 
 import (
 	"math/big"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/demotemutex"
+	"github.com/siapool/p2pool/events"
 	"github.com/siapool/p2pool/siad"
 )
 
@@ -26,7 +31,7 @@ var StartTarget = types.RootDepth.MulDifficulty(big.NewRat(StartHashesPerShare,
 type ShareChain struct {
 
 	//Siad is the handler towards the sia daemon
-	Siad *siad.Siad
+	Siad siad.Daemon
 
 	// Utilities
 	db         *persist.BoltDatabase
@@ -38,37 +43,343 @@ type ShareChain struct {
 	// goroutines have exited before returning from Close().
 	tg siasync.ThreadGroup
 
-	Target types.Target
+	// Target is the target a share has to meet, it follows the network
+	// target if a share ratio is set.
+	Target     types.Target
+	shareRatio float64
+
+	config Config
+
+	// shares are the last shares up to the shareCapacity, unsavedShares and
+	// unsavedAudit are the shares and audit entries that are not written to
+	// disk yet.
+	shares        []Share
+	unsavedShares []Share
+	unsavedAudit  []AuditEntry
+	// unsavedRejects are the rejected shares that are not written to disk
+	// yet.
+	unsavedRejects []Reject
+	flushSignal    chan struct{}
+	// flushMutex is held while buffered shares are written to disk.
+	flushMutex sync.Mutex
+	// journal hands the buffered shares and audit entries to the journal
+	// writer, it is nil without the Journal option. journalNumber is the
+	// number of the last buffered entry, journalFlushed the number of the
+	// last entry that is written to the database.
+	journal        chan journalEntry
+	journalFlushed chan uint64
+	journalNumber  uint64
+	journalFile    *os.File
+	// journalQueueFull is set while entries are dropped because the journal
+	// writer is behind, so the drop is logged once.
+	journalQueueFull bool
+	// journalDropped is the number of entries left out of the journal, it is
+	// guarded by the journalMutex since the journal writer counts as well.
+	journalDropped uint64
+	journalMutex   sync.Mutex
+	// totalShares is the number of shares ever added, it is the sequence
+	// number of the last share in the database once all shares are written.
+	totalShares uint64
+
+	// height and lastChange track the consensus set as seen by the
+	// sharechain.
+	height     types.BlockHeight
+	lastChange modules.ConsensusChangeID
+	// halted is set when a consensus change reverts more than MaxReorgDepth
+	// blocks, no further changes are processed until the node is restarted.
+	halted error
+	// inconsistency is the outcome of the last failed self-check, it is nil
+	// once a check passes.
+	inconsistency error
+	// catchingUpSince is the time the consensus set stopped being synced with
+	// the network, it is zero while it is synced.
+	catchingUpSince time.Time
+
+	// consensusChanges queues the changes of the consensus set for
+	// processing, pendingChanges counts the changes that are queued or being
+	// processed and queuedBlocks the blocks they add to the chain.
+	consensusChanges chan modules.ConsensusChange
+	pendingChanges   sync.WaitGroup
+	queueMutex       sync.Mutex // protects following
+	queueFullLogged  time.Time
+	queuedBlocks     int
+
+	// foundParent is the parent of the last block found by the pool, shares
+	// built on it afterwards are late.
+	foundParent types.BlockID
+	// bus publishes the events of the sharechain.
+	bus *events.Bus
+	// round is the round in progress, roundNumber the number of the last
+	// completed round.
+	round       round
+	roundNumber uint64
+	// inPath are the found blocks applied to the main chain since the node
+	// started.
+	inPath map[types.BlockID]bool
+
+	// template is the current block template, it is cleared when the
+	// consensus set changes and created again on demand. templateGeneration
+	// counts the invalidations, templateHandler is called with every new
+	// template.
+	template           *Template
+	templateGeneration uint64
+	templateHandler    func(Template)
+	templateBuilds     templateBuilds
+	// mempoolCall is the listing of the transaction pool in progress,
+	// mempoolDegraded is set while templates are built without transactions
+	// because the transaction pool is unavailable.
+	mempoolCall     *mempoolCall
+	mempoolDegraded error
+
+	// started is the start of the current run, uptimeRecorded is the time up
+	// to which the uptime is recorded in the database.
+	started        time.Time
+	uptimeRecorded time.Time
+
+	reputationMutex sync.Mutex // protects following
+	reputation      map[modules.NetAddress]*PeerReputation
+
+	poolPeersMutex sync.Mutex // protects following
+	// poolPeers are the stored pool peers with the time they were last seen.
+	poolPeers map[modules.NetAddress]types.Timestamp
+}
+
+//Config holds the settings of the sharechain, zero values are replaced by the defaults
+type Config struct {
+	//BlockMaturity is the number of confirmations a found block needs before its payouts are final
+	BlockMaturity types.BlockHeight
+	//ShareFlushInterval is the maximum time accepted shares are buffered before they are written to disk
+	ShareFlushInterval time.Duration
+	//ShareBatchSize is the number of buffered shares that triggers a write to disk before the flush interval
+	ShareBatchSize int
+	//Journal appends the buffered shares and audit entries to a journal file, the entries a crash kept from
+	// being written to disk are replayed from it at the next start
+	Journal bool
+	//Fee is the pool fee in 0.01%
+	Fee int
+	//FeeAddress is the address the pool fee is paid to
+	FeeAddress types.UnlockHash
+	//MaxReorgDepth is the maximum number of blocks a consensus change can revert before the accounting stops,
+	// deeper reorgs require the operator to restart the node with a higher limit
+	MaxReorgDepth types.BlockHeight
+	//SlowTemplateThreshold is the build time of a block template above which a warning is logged
+	SlowTemplateThreshold time.Duration
+	//MempoolTimeout is the time listing the transactions of the transaction pool can take while building a template
+	MempoolTimeout time.Duration
+	//MempoolUnavailable is the policy for templates built while the transaction pool fails or exceeds the MempoolTimeout
+	MempoolUnavailable MempoolPolicy
+	//TemplateRefreshInterval is the age at which a block template is rebuilt with the current transactions,
+	// 0 disables the refresh so a template lasts until the consensus set changes
+	TemplateRefreshInterval time.Duration
+	//MaxTemplateSize is the size in bytes a block template can have, MaxTemplateTransactions the number of
+	// transactions, 0 does not limit the number. The transactions with the highest fee per byte are picked first.
+	MaxTemplateSize         uint64
+	MaxTemplateTransactions int
+	//Checkpoints are trusted shares the stored sharechain has to match
+	Checkpoints []Checkpoint
+	//ShareRatio is the share difficulty as a fraction of the network difficulty, 0 uses the fixed StartTarget.
+	// Shares are never easier than the StartTarget.
+	ShareRatio float64
+	//ConsensusQueueSize is the number of consensus changes that are queued for processing
+	ConsensusQueueSize int
+	//MaxConsensusLag is the number of blocks the processing of the consensus changes can lag behind the consensus set
+	// before the node reports that it is degraded, 0 disables the check
+	MaxConsensusLag types.BlockHeight
+	//PPLNSWindow is the number of shares in the pplns window of a block,
+	// PPLNSWindowMultiple sets it instead as a multiple of the expected number of shares per block
+	PPLNSWindow         int
+	PPLNSWindowMultiple float64
+	//ShareWeighting decides how much the shares count in the pplns distribution, SolverWeight is the
+	// number of shares the share that solved a block counts for with WeightingSolver
+	ShareWeighting ShareWeighting
+	SolverWeight   int
+	//LateShares is the policy for shares built on the parent of a found block that are accepted after the find
+	LateShares LateSharePolicy
+	//PoolPeers are the addresses of other nodes of the pool network the gateway connects to, in addition to the Sia bootstrap peers
+	PoolPeers []modules.NetAddress
+	//ForgetPoolPeers does not store the peers that relay valid shares, only the PoolPeers are connected after a restart
+	ForgetPoolPeers bool
+	//MinPeers is the number of gateway peers the node needs before it hands out work, 0 disables the check
+	MinPeers int
+	//SubmitTimeout is the time a single submission of a found block to the consensus set can take
+	SubmitTimeout time.Duration
+	//SubmitAttempts is the number of times a found block is submitted before it is recorded as failed
+	SubmitAttempts int
+	//LogRejects records every rejected share in the reject log, RejectRetention is the time they are kept
+	LogRejects      bool
+	RejectRetention time.Duration
+	//SelfCheckInterval is the time between two consistency checks of the sharechain, 0 disables the checks
+	SelfCheckInterval time.Duration
+	//SelfCheckHalt stops the accounting, and with it the payouts, when a consistency check fails
+	SelfCheckHalt bool
+	//Recover moves an unreadable database aside and restores the BackupFilename in the persist directory instead
+	Recover bool
+}
+
+func (config *Config) setDefaults() {
+	if config.BlockMaturity == 0 {
+		config.BlockMaturity = DefaultBlockMaturity
+	}
+	if config.SlowTemplateThreshold == 0 {
+		config.SlowTemplateThreshold = DefaultSlowTemplateThreshold
+	}
+	if config.MaxReorgDepth == 0 {
+		config.MaxReorgDepth = DefaultMaxReorgDepth
+	}
+	if config.ShareFlushInterval == 0 {
+		config.ShareFlushInterval = DefaultShareFlushInterval
+	}
+	if config.ShareBatchSize == 0 {
+		config.ShareBatchSize = DefaultShareBatchSize
+	}
+	if config.ConsensusQueueSize <= 0 {
+		config.ConsensusQueueSize = DefaultConsensusQueueSize
+	}
+	if config.SubmitTimeout == 0 {
+		config.SubmitTimeout = DefaultSubmitTimeout
+	}
+	if config.SubmitAttempts <= 0 {
+		config.SubmitAttempts = DefaultSubmitAttempts
+	}
+	if config.RejectRetention <= 0 {
+		config.RejectRetention = DefaultRejectRetention
+	}
+	if config.LateShares == "" {
+		config.LateShares = LateSharesNext
+	}
+	if config.ShareWeighting == "" {
+		config.ShareWeighting = WeightingLinear
+	}
+	if config.SolverWeight == 0 {
+		config.SolverWeight = DefaultSolverWeight
+	}
+	if config.MaxTemplateSize == 0 {
+		config.MaxTemplateSize = types.BlockSizeLimit
+	}
+	if config.MempoolTimeout <= 0 {
+		config.MempoolTimeout = DefaultMempoolTimeout
+	}
+	if config.MempoolUnavailable == "" {
+		config.MempoolUnavailable = MempoolEmpty
+	}
+	if config.PPLNSWindow == 0 && config.PPLNSWindowMultiple == 0 {
+		config.PPLNSWindow = DefaultPPLNSWindow
+	}
 }
 
 // New returns a new ShareChain.
 // If there is an existing sharechain database present in the persist directory, it is loaded.
-func New(siadaemon *siad.Siad, persistDir string) (sc *ShareChain, err error) {
-
+func New(siadaemon siad.Daemon, persistDir string, config Config) (sc *ShareChain, err error) {
+	config.setDefaults()
 	sc = &ShareChain{
 		Siad: siadaemon,
 
 		persistDir: persistDir,
 
 		Target: StartTarget,
+
+		config:     config,
+		shareRatio: config.ShareRatio,
+
+		flushSignal:      make(chan struct{}, 1),
+		consensusChanges: make(chan modules.ConsensusChange, config.ConsensusQueueSize),
+		bus:              events.NewBus(),
+	}
+	if !validFee(config.Fee) {
+		return nil, errInvalidFee
+	}
+	if !validShareRatio(config.ShareRatio) {
+		return nil, errInvalidShareRatio
+	}
+	if err = config.validPPLNSWindow(); err != nil {
+		return nil, err
+	}
+	if !validLateSharePolicy(config.LateShares) {
+		return nil, errInvalidLateSharePolicy
+	}
+	if !validMempoolPolicy(config.MempoolUnavailable) {
+		return nil, errInvalidMempoolPolicy
+	}
+	if !validTemplateSize(config.MaxTemplateSize) {
+		return nil, errInvalidTemplateSize
+	}
+	if config.MaxTemplateTransactions < 0 {
+		return nil, errInvalidTemplateTransactions
+	}
+	if !validShareWeighting(config.ShareWeighting) {
+		return nil, errInvalidShareWeighting
+	}
+	if config.SolverWeight < 1 || config.SolverWeight > MaxSolverWeight {
+		return nil, errInvalidSolverWeight
 	}
 
 	// Initialize the persistence structures.
 	err = sc.initPersist()
+	if err != nil {
+		return
+	}
+	if err = sc.loadPoolPeers(); err != nil {
+		return
+	}
+	if err = sc.openJournal(); err != nil {
+		return
+	}
+	if config.Journal {
+		go sc.threadedWriteJournal()
+	}
+	go sc.threadedFlushShares()
+	sc.started = time.Now()
+	sc.uptimeRecorded = sc.started
+	go sc.threadedRecordUptime()
+	if config.SelfCheckInterval > 0 {
+		go sc.threadedSelfCheck()
+	}
+	if config.TemplateRefreshInterval > 0 {
+		go sc.threadedRefreshTemplate()
+	}
+	sc.updateShareTarget(sc.NetworkTarget())
+
+	// Found blocks only mature once the consensus set is synced.
+	if err = sc.updateSync(siadaemon.ConsensusSet().Synced()); err != nil {
+		return
+	}
+	go sc.threadedMonitorSync()
+	go sc.threadedConnectPoolPeers()
+	if config.MinPeers > 0 {
+		go sc.threadedConnectPeers()
+	}
 
+	// Subscribe to the consensus set to keep track of the found blocks.
+	go sc.threadedProcessConsensusChanges()
+	err = siadaemon.ConsensusSet().ConsensusSetSubscribe(sc, sc.lastChange)
+	if err == modules.ErrInvalidConsensusChangeID {
+		sc.lastChange = modules.ConsensusChangeBeginning
+		sc.height = 0
+		err = siadaemon.ConsensusSet().ConsensusSetSubscribe(sc, sc.lastChange)
+	}
 	return
 }
 
-//Share is a block with a lower difficulty target
-type Share struct {
-	BlockID   types.BlockID
-	ParentID  types.BlockID
-	Timestamp types.Timestamp
-	Miner     string
+//Close unsubscribes from the consensus set, writes the buffered shares to disk and closes the database
+func (sc *ShareChain) Close() error {
+	sc.Siad.ConsensusSet().Unsubscribe(sc)
+	if err := sc.tg.Stop(); err != nil {
+		return err
+	}
+	if err := sc.flushShares(); err != nil {
+		sc.log.Println("Error writing buffered shares:", err)
+	}
+	if err := sc.recordUptime(time.Now()); err != nil {
+		sc.log.Println("Error recording the uptime:", err)
+	}
+	if sc.journalFile != nil {
+		sc.journalFile.Close()
+	}
+	return sc.db.Close()
 }
 
-//GetPPLNSSummary returns a mapping between miner addresses and the number of shares they found (within the ShareChainLength last number of shares)
-func (sc *ShareChain) GetPPLNSSummary() (sharesummary map[string]int, err error) {
-	//TODO
-	return
+//DifficultyRatio returns the ratio between the share difficulty and the difficulty of the sia network
+func (sc *ShareChain) DifficultyRatio() float64 {
+	ratio, _ := new(big.Rat).Quo(sc.NetworkTarget().Rat(), sc.ShareTarget().Rat()).Float64()
+	return ratio
 }
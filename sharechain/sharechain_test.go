@@ -4,7 +4,7 @@ import (
 	"math/big"
 	"testing"
 
-	"github.com/rivine/rivine/types"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 func TestTarget(t *testing.T) {
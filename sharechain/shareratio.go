@@ -0,0 +1,75 @@
+package sharechain
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var errInvalidShareRatio = errors.New("share ratio must be between 0 and 1, 0 uses the fixed start target")
+
+//validShareRatio returns if a ratio between the share and the network difficulty can be used
+func validShareRatio(ratio float64) bool {
+	return !math.IsNaN(ratio) && ratio >= 0 && ratio <= 1
+}
+
+//shareTarget returns the target of a share for a ratio between the share and the network difficulty.
+// A ratio of 0 uses the StartTarget, shares are never easier than the StartTarget.
+func shareTarget(networkTarget types.Target, ratio float64) types.Target {
+	if ratio == 0 {
+		return StartTarget
+	}
+	target := networkTarget.MulDifficulty(new(big.Rat).SetFloat64(ratio))
+	if target.Cmp(StartTarget) > 0 {
+		return StartTarget
+	}
+	return target
+}
+
+//NetworkTarget returns the target of the next block of the sia network
+func (sc *ShareChain) NetworkTarget() types.Target {
+	cs := sc.Siad.ConsensusSet()
+	target, _ := cs.ChildTarget(cs.CurrentBlock().ID())
+	return target
+}
+
+//ShareTarget returns the target a share has to meet
+func (sc *ShareChain) ShareTarget() types.Target {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.Target
+}
+
+//ShareRatio returns the configured ratio between the share and the network difficulty, 0 if the StartTarget is used
+func (sc *ShareChain) ShareRatio() float64 {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.shareRatio
+}
+
+//SetShareRatio changes the ratio between the share and the network difficulty, the share target is recomputed
+// and the next template uses it. A higher ratio gives fewer, harder shares and a smaller sharechain.
+// Shares that are already in the sharechain keep counting as one share each.
+func (sc *ShareChain) SetShareRatio(ratio float64) error {
+	if !validShareRatio(ratio) {
+		return errInvalidShareRatio
+	}
+	networkTarget := sc.NetworkTarget()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.shareRatio = ratio
+	sc.Target = shareTarget(networkTarget, ratio)
+	sc.invalidateTemplate()
+	sc.log.Println("Share ratio set to", ratio, ", share target", sc.Target)
+	return nil
+}
+
+// updateShareTarget recomputes the share target for a network target.
+func (sc *ShareChain) updateShareTarget(networkTarget types.Target) types.Target {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.Target = shareTarget(networkTarget, sc.shareRatio)
+	return sc.Target
+}
@@ -0,0 +1,65 @@
+package sharechain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/pow"
+)
+
+func TestShareTarget(t *testing.T) {
+	networkTarget := pow.TargetFromDifficulty(pow.DifficultyFromTarget(StartTarget) * 1e6)
+	if target := shareTarget(networkTarget, 0); target != StartTarget {
+		t.Error("Expected the start target without a ratio, got", target)
+	}
+	if target := shareTarget(networkTarget, 1e-3); math.Abs(pow.DifficultyFromTarget(target)/pow.DifficultyFromTarget(networkTarget)-1e-3) > 1e-9 {
+		t.Error("Expected a share of 1/1000 of the network difficulty, got", pow.DifficultyFromTarget(target))
+	}
+	if target := shareTarget(networkTarget, 1e-9); target != StartTarget {
+		t.Error("Share easier than the start target", target)
+	}
+}
+
+func TestSetShareRatio(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{})
+	defer cleanup()
+	mock.Target = pow.TargetFromDifficulty(pow.DifficultyFromTarget(StartTarget) * 1e6)
+
+	for _, ratio := range []float64{-1, 2, math.NaN()} {
+		if err := sc.SetShareRatio(ratio); err == nil {
+			t.Error("Invalid share ratio", ratio, "accepted")
+		}
+	}
+	if sc.ShareTarget() != StartTarget {
+		t.Fatal("Share target changed by an invalid ratio")
+	}
+
+	if _, err := sc.BlockTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetShareRatio(1e-3); err != nil {
+		t.Fatal(err)
+	}
+	if sc.ShareRatio() != 1e-3 || sc.ShareTarget() != shareTarget(mock.Target, 1e-3) {
+		t.Error("Share target not recomputed for the new ratio")
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.ShareTarget != sc.ShareTarget() {
+		t.Error("Template does not use the new share target")
+	}
+
+	// The share target follows the network target.
+	mock.Target = types.RootDepth
+	mock.Mine(1)
+	sc.pendingChanges.Wait()
+	if template, err = sc.BlockTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	if template.ShareTarget != StartTarget {
+		t.Error("Expected the start target for an easy network, got", template.ShareTarget)
+	}
+}
@@ -0,0 +1,203 @@
+package sharechain
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+
+	"github.com/siapool/p2pool/events"
+)
+
+const (
+	//DefaultShareFlushInterval is the default maximum time accepted shares are buffered before they are written to disk
+	DefaultShareFlushInterval = time.Second
+	//DefaultShareBatchSize is the default number of buffered shares that triggers a write to disk
+	DefaultShareBatchSize = 1000
+)
+
+//Share is a block with a lower difficulty target
+type Share struct {
+	BlockID  types.BlockID
+	ParentID types.BlockID
+	//Timestamp is the time the share was accepted by the pool, not the timestamp of the block set by the miner
+	Timestamp types.Timestamp
+	Miner     string
+}
+
+//AddShare adds an accepted share to the sharechain and returns if it is counted.
+// The share is immediately taken into account for the pplns summary but is buffered and
+// written to disk in a batch with other shares to limit the number of database transactions.
+// The timestamp of the share is set to the current time so miner clocks do not affect the accounting,
+// it is taken under the lock so the order of the shares and of their timestamps is the same.
+// A late share, built on the parent of a block the pool found before, is handled according to the LateShares policy.
+func (sc *ShareChain) AddShare(share Share) (counted bool) {
+	sc.mu.Lock()
+	share.Timestamp = types.CurrentTimestamp()
+	if sc.lateShare(share) && sc.config.LateShares == LateSharesDrop {
+		sc.mu.Unlock()
+		sc.log.Debugln("Dropped late share", share.BlockID, "of", share.Miner)
+		return false
+	}
+	sc.shares = lastShares(append(sc.shares, share), sc.shareCapacity())
+	sc.unsavedShares = append(sc.unsavedShares, share)
+	sc.journalAdd(journalEntry{Share: share})
+	if sc.round.start == 0 {
+		sc.round.start = share.Timestamp
+	}
+	sc.round.addShare(share)
+	sc.recordAudit(AuditEntry{Timestamp: share.Timestamp, Event: AuditShare, BlockID: share.BlockID, Miner: share.Miner})
+	sc.totalShares++
+	batchFull := len(sc.unsavedShares) >= sc.config.ShareBatchSize
+	sc.mu.Unlock()
+	sc.bus.Publish(events.ShareAccepted{BlockID: share.BlockID, Miner: share.Miner, Timestamp: share.Timestamp})
+
+	if batchFull {
+		select {
+		case sc.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+//GetPPLNSSummary returns a mapping between miner addresses and the number of shares they found (within the PPLNSWindow last number of shares)
+func (sc *ShareChain) GetPPLNSSummary() (sharesummary map[string]int, err error) {
+	window := sc.PPLNSWindow()
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	sharesummary = make(map[string]int)
+	for _, share := range lastShares(sc.shares, window) {
+		sharesummary[share.Miner]++
+	}
+	return
+}
+
+// flushShares writes the buffered shares, audit entries and rejects to disk in
+// a single transaction, together with the position in the journal they reach.
+func (sc *ShareChain) flushShares() error {
+	sc.flushMutex.Lock()
+	defer sc.flushMutex.Unlock()
+	sc.mu.Lock()
+	batch, audit, rejects, journaled := sc.unsavedShares, sc.unsavedAudit, sc.unsavedRejects, sc.journalNumber
+	sc.unsavedShares, sc.unsavedAudit, sc.unsavedRejects = nil, nil, nil
+	sc.mu.Unlock()
+	if len(batch) == 0 && len(audit) == 0 && len(rejects) == 0 {
+		return nil
+	}
+	cutoff := types.Timestamp(time.Now().Add(-sc.config.RejectRetention).Unix())
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		if err := putShares(tx, batch); err != nil {
+			return err
+		}
+		if err := putAudit(tx, audit); err != nil {
+			return err
+		}
+		if err := putRejects(tx, rejects, cutoff); err != nil {
+			return err
+		}
+		return putJournalPosition(tx, journaled)
+	})
+	if err == nil {
+		sc.journalCompact(journaled)
+	} else {
+		// Keep the shares buffered so they are retried on the next flush.
+		sc.mu.Lock()
+		sc.unsavedShares = append(batch, sc.unsavedShares...)
+		sc.unsavedAudit = append(audit, sc.unsavedAudit...)
+		sc.unsavedRejects = append(rejects, sc.unsavedRejects...)
+		sc.mu.Unlock()
+	}
+	return err
+}
+
+// threadedFlushShares writes the buffered shares to disk every
+// ShareFlushInterval or sooner if the batch is full, until the sharechain is
+// closed.
+func (sc *ShareChain) threadedFlushShares() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(sc.config.ShareFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case <-ticker.C:
+		case <-sc.flushSignal:
+		}
+		if err := sc.flushShares(); err != nil {
+			sc.log.Println("Error writing shares:", err)
+		}
+	}
+}
+
+// loadShares loads the last shares up to the shareCapacity from disk.
+func (sc *ShareChain) loadShares(tx *bolt.Tx) error {
+	var shares []Share
+	c := tx.Bucket(Shares).Cursor()
+	sc.totalShares = 0
+	if k, _ := c.Last(); k != nil {
+		sc.totalShares = shareSeq(k)
+	}
+	for k, v := c.Last(); k != nil && len(shares) < sc.shareCapacity(); k, v = c.Prev() {
+		var share Share
+		if err := encoding.Unmarshal(v, &share); err != nil {
+			return err
+		}
+		shares = append(shares, share)
+	}
+	// Reverse the shares so the oldest share comes first.
+	for i, j := 0, len(shares)-1; i < j; i, j = i+1, j-1 {
+		shares[i], shares[j] = shares[j], shares[i]
+	}
+	sc.shares = shares
+	return nil
+}
+
+// putShares appends shares to the database.
+func putShares(tx *bolt.Tx, shares []Share) error {
+	b := tx.Bucket(Shares)
+	for _, share := range shares {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err = b.Put(shareKey(seq), encoding.Marshal(share)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//shareWindow returns the size shares up to and including the share with sequence number last
+func shareWindow(tx *bolt.Tx, last, size uint64) (window []Share, err error) {
+	first := uint64(1)
+	if last > size {
+		first = last - size + 1
+	}
+	c := tx.Bucket(Shares).Cursor()
+	for k, v := c.Seek(shareKey(first)); k != nil && shareSeq(k) <= last; k, v = c.Next() {
+		var share Share
+		if err = encoding.Unmarshal(v, &share); err != nil {
+			return
+		}
+		window = append(window, share)
+	}
+	return
+}
+
+//shareKey encodes the sequence number of a share big endian so the shares are sorted in the database
+func shareKey(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
+
+func shareSeq(k []byte) uint64 {
+	return binary.BigEndian.Uint64(k)
+}
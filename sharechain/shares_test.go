@@ -0,0 +1,99 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestAddShare(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{ShareBatchSize: 100})
+	defer cleanup()
+
+	sc.AddShare(Share{Miner: "a"})
+	sc.AddShare(Share{Miner: "a"})
+	sc.AddShare(Share{Miner: "b"})
+
+	summary, err := sc.GetPPLNSSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary["a"] != 2 || summary["b"] != 1 {
+		t.Error("Buffered shares not taken into account:", summary)
+	}
+
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.unsavedShares) != 0 {
+		t.Error("Shares still buffered after a flush")
+	}
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		sc.shares = nil
+		return sc.loadShares(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.shares) != 3 || sc.shares[0].Miner != "a" || sc.shares[2].Miner != "b" {
+		t.Error("Unexpected shares loaded from disk:", sc.shares)
+	}
+}
+
+func TestAddShareTimestamp(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{ShareBatchSize: 100})
+	defer cleanup()
+
+	before := types.CurrentTimestamp()
+	sc.AddShare(Share{Miner: "a", Timestamp: 1})
+	if ts := sc.shares[0].Timestamp; ts < before || ts > types.CurrentTimestamp() {
+		t.Error("Share not timestamped by the server:", ts)
+	}
+}
+
+func TestAddShareSignalsFullBatch(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{ShareBatchSize: 2})
+	defer cleanup()
+	sc.flushSignal = make(chan struct{}, 1)
+
+	sc.AddShare(Share{Miner: "a"})
+	select {
+	case <-sc.flushSignal:
+		t.Error("Flush requested before the batch is full")
+	default:
+	}
+	sc.AddShare(Share{Miner: "a"})
+	select {
+	case <-sc.flushSignal:
+	default:
+		t.Error("No flush requested for a full batch")
+	}
+}
+
+func benchmarkAddShare(b *testing.B, batchSize int) {
+	sc, cleanup := newTestShareChain(b, Config{ShareBatchSize: batchSize})
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sc.AddShare(Share{Miner: "a"})
+		if len(sc.unsavedShares) >= batchSize {
+			if err := sc.flushShares(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	if err := sc.flushShares(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkAddShareUnbatched writes every share in its own transaction.
+func BenchmarkAddShareUnbatched(b *testing.B) {
+	benchmarkAddShare(b, 1)
+}
+
+// BenchmarkAddShareBatched writes the shares in batches of the default size.
+func BenchmarkAddShareBatched(b *testing.B) {
+	benchmarkAddShare(b, DefaultShareBatchSize)
+}
@@ -0,0 +1,191 @@
+package sharechain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//DefaultSubmitTimeout is the default time a single submission of a found block to the consensus set can take
+	DefaultSubmitTimeout = 10 * time.Second
+	//DefaultSubmitAttempts is the default number of times a found block is submitted before it is recorded as failed
+	DefaultSubmitAttempts = 3
+)
+
+// submitBackoff is the wait before the first resubmission of a found block,
+// it doubles with every attempt.
+var submitBackoff = time.Second
+
+var (
+	errSubmitTimeout      = errors.New("submitting the block to the consensus set timed out")
+	errNoFailedSubmission = errors.New("block is not a failed submission")
+)
+
+//FailedSubmission is a found block the consensus set did not accept, it is kept so it can be resubmitted
+type FailedSubmission struct {
+	Block    types.Block
+	Error    string
+	Attempts int
+	Time     types.Timestamp
+}
+
+//SubmitBlock registers a block found by the pool with AddFoundBlock and submits it to the consensus set.
+// A submission that fails or takes longer than the SubmitTimeout is retried up to SubmitAttempts times.
+// If all attempts fail, the block is marked as BlockSubmissionFailed and kept for ResubmitBlock.
+// SubmitBlock returns within SubmitAttempts times the SubmitTimeout plus the backoff, even if the consensus set hangs.
+func (sc *ShareChain) SubmitBlock(b types.Block, finder types.UnlockHash) error {
+	if err := sc.AddFoundBlock(b, finder); err != nil {
+		return err
+	}
+	return sc.submitFound(b)
+}
+
+//SubmitMinerBlock registers a block a miner found with the block of template that MinerBlock returned with AddMinerBlock
+// and submits it like SubmitBlock.
+func (sc *ShareChain) SubmitMinerBlock(template Template, b types.Block, finder types.UnlockHash) error {
+	if err := sc.AddMinerBlock(template, b, finder); err != nil {
+		return err
+	}
+	return sc.submitFound(b)
+}
+
+// submitFound submits a registered found block, it is kept as a failed
+// submission if all attempts fail.
+func (sc *ShareChain) submitFound(b types.Block) error {
+	attempts, err := sc.submit(b)
+	if err == nil {
+		return nil
+	}
+	sc.log.Println("Submitting found block", b.ID(), "failed after", attempts, "attempts:", err)
+	if ferr := sc.putFailedSubmission(FailedSubmission{Block: b, Error: err.Error(), Attempts: attempts, Time: types.CurrentTimestamp()}); ferr != nil {
+		sc.log.Critical("Error recording the failed submission of block", b.ID(), ":", ferr)
+	}
+	return err
+}
+
+//ResubmitBlock submits a block of which the submission failed again, the block is pending again if it is accepted
+func (sc *ShareChain) ResubmitBlock(id types.BlockID) error {
+	failed, exists, err := sc.FailedSubmission(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errNoFailedSubmission
+	}
+	attempts, err := sc.submit(failed.Block)
+	if err != nil {
+		failed.Error, failed.Attempts, failed.Time = err.Error(), failed.Attempts+attempts, types.CurrentTimestamp()
+		if ferr := sc.putFailedSubmission(failed); ferr != nil {
+			return ferr
+		}
+		return err
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.db.Update(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(FoundBlocks).Get(id[:])
+		if raw == nil {
+			return errNilItem
+		}
+		var fb FoundBlock
+		if err := encoding.Unmarshal(raw, &fb); err != nil {
+			return err
+		}
+		// The consensus change applying the block may have been processed
+		// already.
+		if fb.Status == BlockSubmissionFailed {
+			fb.Status = BlockPending
+			if err := putFoundBlock(tx, fb); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(FailedSubmissions).Delete(id[:])
+	})
+}
+
+//FailedSubmission returns the failed submission of a found block, exists is false if its submission did not fail
+func (sc *ShareChain) FailedSubmission(id types.BlockID) (failed FailedSubmission, exists bool, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(FailedSubmissions).Get(id[:])
+		if raw == nil {
+			return nil
+		}
+		exists = true
+		return encoding.Unmarshal(raw, &failed)
+	})
+	return
+}
+
+// submit submits a block to the consensus set until it is accepted or the
+// SubmitAttempts are used up, it returns the number of attempts made.
+func (sc *ShareChain) submit(b types.Block) (attempts int, err error) {
+	backoff := submitBackoff
+	for attempts = 1; ; attempts++ {
+		err = sc.acceptBlock(b)
+		// A block that does not extend the longest fork is valid, it is
+		// orphaned rather than failed.
+		if err == nil || err == modules.ErrBlockKnown || err == modules.ErrNonExtendingBlock {
+			return attempts, nil
+		}
+		if attempts >= sc.config.SubmitAttempts {
+			return
+		}
+		sc.log.Println("Submitting found block", b.ID(), "failed, retrying in", backoff, ":", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// acceptBlock passes a block to the consensus set and gives up waiting after
+// the SubmitTimeout. A submission that times out can still succeed later, a
+// retry then fails with ErrBlockKnown.
+func (sc *ShareChain) acceptBlock(b types.Block) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- sc.Siad.ConsensusSet().AcceptBlock(b)
+	}()
+	timeout := time.NewTimer(sc.config.SubmitTimeout)
+	defer timeout.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timeout.C:
+		return errSubmitTimeout
+	}
+}
+
+// putFailedSubmission stores a failed submission and marks its found block
+// as BlockSubmissionFailed, unless a submission that timed out made it into
+// the main chain in the meantime.
+func (sc *ShareChain) putFailedSubmission(failed FailedSubmission) error {
+	id := failed.Block.ID()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.inPath[id] {
+		return nil
+	}
+	return sc.db.Update(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(FoundBlocks).Get(id[:])
+		if raw == nil {
+			return errNilItem
+		}
+		var fb FoundBlock
+		if err := encoding.Unmarshal(raw, &fb); err != nil {
+			return err
+		}
+		if fb.Status == BlockPending {
+			fb.Status = BlockSubmissionFailed
+			if err := putFoundBlock(tx, fb); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(FailedSubmissions).Put(id[:], encoding.Marshal(failed))
+	})
+}
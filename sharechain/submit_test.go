@@ -0,0 +1,93 @@
+package sharechain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestSubmitBlockRetry(t *testing.T) {
+	defer func(backoff time.Duration) { submitBackoff = backoff }(submitBackoff)
+	submitBackoff = time.Millisecond
+	sc, mock, cleanup := newMockShareChain(t, Config{SubmitAttempts: 3})
+	defer cleanup()
+
+	failures := 2
+	mock.AcceptBlockFunc = func(types.Block) error {
+		if failures > 0 {
+			failures--
+			return errors.New("transient")
+		}
+		return nil
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.SubmitBlock(template.Block, types.UnlockHash{}); err != nil {
+		t.Fatal("Block not submitted after transient failures:", err)
+	}
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, template.Block.ID()); status != BlockPending {
+		t.Error("Expected pending block, got", status)
+	}
+}
+
+func TestSubmitBlockFailed(t *testing.T) {
+	defer func(backoff time.Duration) { submitBackoff = backoff }(submitBackoff)
+	submitBackoff = time.Millisecond
+	sc, mock, cleanup := newMockShareChain(t, Config{SubmitAttempts: 2, SubmitTimeout: 50 * time.Millisecond})
+	defer cleanup()
+
+	// The attempts hang until release is closed, later ones fail at once.
+	release := make(chan struct{})
+	mock.AcceptBlockFunc = func(types.Block) error {
+		<-release
+		return errors.New("rejected")
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := template.Block
+	start := time.Now()
+	if err = sc.SubmitBlock(b, types.UnlockHash{}); err != errSubmitTimeout {
+		t.Error("Expected", errSubmitTimeout, "got", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Submission blocked for", elapsed)
+	}
+	close(release)
+
+	if status := statusOf(t, sc, b.ID()); status != BlockSubmissionFailed {
+		t.Error("Expected a failed submission, got", status)
+	}
+	failed, exists, err := sc.FailedSubmission(b.ID())
+	if err != nil || !exists || failed.Block.ID() != b.ID() || failed.Attempts != 2 {
+		t.Fatal("Expected the raw block to be kept after 2 attempts, got", failed.Attempts, exists, err)
+	}
+
+	if err = sc.ResubmitBlock(b.ID()); err == nil {
+		t.Error("Rejected block resubmitted")
+	}
+	if failed, _, _ = sc.FailedSubmission(b.ID()); failed.Attempts != 4 || failed.Error != "rejected" {
+		t.Error("Expected the failed resubmission to be recorded, got", failed.Attempts, failed.Error)
+	}
+
+	mock.AcceptBlockFunc = nil
+	if err = sc.ResubmitBlock(b.ID()); err != nil {
+		t.Fatal(err)
+	}
+	sc.pendingChanges.Wait()
+	if status := statusOf(t, sc, b.ID()); status != BlockPending {
+		t.Error("Expected pending block after the resubmission, got", status)
+	}
+	if _, exists, _ = sc.FailedSubmission(b.ID()); exists {
+		t.Error("Failed submission kept after the block was accepted")
+	}
+	if err = sc.ResubmitBlock(b.ID()); err != errNoFailedSubmission {
+		t.Error("Expected", errNoFailedSubmission, "got", err)
+	}
+}
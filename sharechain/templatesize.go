@@ -0,0 +1,134 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	//MinTemplateSize is the smallest MaxTemplateSize, enough for the header and the miner payouts
+	MinTemplateSize = 10e3
+	//MinerTransactionSize is the room every template leaves for a transaction the miner adds to its block,
+	// the stratum server adds the extranonce in the arbitrary data of one
+	MinerTransactionSize = 256
+	// payoutSlack is the number of bytes a miner payout can grow by when the
+	// fees of the selected transactions are added to the reward.
+	payoutSlack = 16
+)
+
+var errInvalidTemplateSize = fmt.Errorf("maximum template size must be between %v and %v bytes", uint64(MinTemplateSize), uint64(types.BlockSizeLimit))
+
+var errInvalidTemplateTransactions = errors.New("maximum number of template transactions can not be negative")
+
+var errTemplateTooLarge = errors.New("block template exceeds the maximum template size")
+
+func validTemplateSize(size uint64) bool {
+	return size >= MinTemplateSize && size <= types.BlockSizeLimit
+}
+
+// selectTransactions picks the transactions with the highest fee per byte
+// until maxCount, if it is positive, or maxSize is reached. A transaction that
+// spends an output created by another transaction of the list is only picked
+// together with that transaction, and the picked transactions keep the order
+// of the list, so parents still come before their children.
+func selectTransactions(txns []types.Transaction, maxCount int, maxSize uint64) (selected []types.Transaction) {
+	candidates := make([]txnCandidate, len(txns))
+	creators := make(map[crypto.Hash]int)
+	for i, txn := range txns {
+		candidates[i] = txnCandidate{index: i, size: uint64(len(encoding.Marshal(txn))), fees: types.ZeroCurrency}
+		for _, fee := range txn.MinerFees {
+			candidates[i].fees = candidates[i].fees.Add(fee)
+		}
+		for j := range txn.SiacoinOutputs {
+			creators[crypto.Hash(txn.SiacoinOutputID(uint64(j)))] = i
+		}
+		for j := range txn.SiafundOutputs {
+			creators[crypto.Hash(txn.SiafundOutputID(uint64(j)))] = i
+		}
+		for j := range txn.FileContracts {
+			creators[crypto.Hash(txn.FileContractID(uint64(j)))] = i
+		}
+	}
+	for i, txn := range txns {
+		var spent []crypto.Hash
+		for _, input := range txn.SiacoinInputs {
+			spent = append(spent, crypto.Hash(input.ParentID))
+		}
+		for _, input := range txn.SiafundInputs {
+			spent = append(spent, crypto.Hash(input.ParentID))
+		}
+		for _, revision := range txn.FileContractRevisions {
+			spent = append(spent, crypto.Hash(revision.ParentID))
+		}
+		for _, proof := range txn.StorageProofs {
+			spent = append(spent, crypto.Hash(proof.ParentID))
+		}
+		for _, id := range spent {
+			if parent, found := creators[id]; found && parent != i {
+				candidates[i].parents = append(candidates[i].parents, parent)
+			}
+		}
+	}
+
+	order := make(txnsByFeeRate, len(candidates))
+	copy(order, candidates)
+	sort.Stable(order)
+	picked := make([]bool, len(txns))
+	var count int
+	var size uint64
+	for _, c := range order {
+		if picked[c.index] {
+			continue
+		}
+		// The transaction with the parents that are not picked yet.
+		pkg := make(map[int]bool)
+		var pkgSize uint64
+		stack := []int{c.index}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if picked[i] || pkg[i] {
+				continue
+			}
+			pkg[i] = true
+			pkgSize += candidates[i].size
+			stack = append(stack, candidates[i].parents...)
+		}
+		if (maxCount > 0 && count+len(pkg) > maxCount) || size+pkgSize > maxSize {
+			continue
+		}
+		for i := range pkg {
+			picked[i] = true
+		}
+		count += len(pkg)
+		size += pkgSize
+	}
+	for i, txn := range txns {
+		if picked[i] {
+			selected = append(selected, txn)
+		}
+	}
+	return
+}
+
+type txnCandidate struct {
+	index   int
+	size    uint64
+	fees    types.Currency
+	parents []int
+}
+
+// txnsByFeeRate sorts transactions by descending fee per byte.
+type txnsByFeeRate []txnCandidate
+
+func (t txnsByFeeRate) Len() int      { return len(t) }
+func (t txnsByFeeRate) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t txnsByFeeRate) Less(i, j int) bool {
+	// fees[i]/size[i] > fees[j]/size[j] without dividing.
+	return t[i].fees.Mul64(t[j].size).Cmp(t[j].fees.Mul64(t[i].size)) > 0
+}
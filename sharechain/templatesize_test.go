@@ -0,0 +1,75 @@
+package sharechain
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestSelectTransactions(t *testing.T) {
+	txn := func(data byte, fee uint64) types.Transaction {
+		return types.Transaction{ArbitraryData: [][]byte{{data}}, MinerFees: []types.Currency{types.NewCurrency64(fee)}}
+	}
+	txns := []types.Transaction{txn(1, 1), txn(2, 3), txn(3, 2)}
+	selected := selectTransactions(txns, 2, types.BlockSizeLimit)
+	if len(selected) != 2 || selected[0].ArbitraryData[0][0] != 2 || selected[1].ArbitraryData[0][0] != 3 {
+		t.Error("Expected the 2 transactions with the highest fees in their order, got", selected)
+	}
+
+	size := uint64(len(encoding.Marshal(txns[0])))
+	if selected = selectTransactions(txns, 0, 2*size); len(selected) != 2 {
+		t.Error("Expected the size to limit the transactions to 2, got", len(selected))
+	}
+
+	// A child with a high fee is only picked with its parent.
+	parent := types.Transaction{SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(1)}}}
+	child := txn(4, 100)
+	child.SiacoinInputs = []types.SiacoinInput{{ParentID: parent.SiacoinOutputID(0)}}
+	txns = []types.Transaction{parent, txn(5, 50), child}
+	selected = selectTransactions(txns, 2, types.BlockSizeLimit)
+	if len(selected) != 2 || selected[0].ID() != parent.ID() || selected[1].ID() != child.ID() {
+		t.Error("Expected the child with its parent, got", selected)
+	}
+	if selected = selectTransactions(txns, 1, types.BlockSizeLimit); len(selected) != 1 || selected[0].ID() == child.ID() {
+		t.Error("Expected the child to be left out without room for its parent, got", selected)
+	}
+}
+
+func TestMaxTemplateSize(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{MaxTemplateSize: MinTemplateSize})
+	defer cleanup()
+	for i := 0; i < 10000; i++ {
+		mock.Transactions = append(mock.Transactions, types.Transaction{
+			ArbitraryData: [][]byte{{byte(i), byte(i >> 8)}},
+			MinerFees:     []types.Currency{types.NewCurrency64(uint64(i))},
+		})
+	}
+	for i := 0; i < 100; i++ {
+		sc.AddShare(Share{Miner: types.UnlockHash{byte(i)}.String()})
+	}
+
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := len(encoding.Marshal(template.Block))
+	if size > MinTemplateSize || template.Size != size {
+		t.Error("Expected a template of at most", MinTemplateSize, "bytes, got", size, "reported as", template.Size)
+	}
+	if len(template.Block.Transactions) == 0 || len(template.Block.Transactions) == len(mock.Transactions) {
+		t.Error("Expected the transactions to be limited, got", len(template.Block.Transactions))
+	}
+	// The most paying transactions are at the end of the transaction pool.
+	last := len(mock.Transactions) - 1
+	if included := template.Block.Transactions[len(template.Block.Transactions)-1]; included.ID() != mock.Transactions[last].ID() {
+		t.Error("Transaction with the highest fee not included")
+	}
+
+	if _, err := New(nil, "", Config{MaxTemplateSize: types.BlockSizeLimit + 1}); err != errInvalidTemplateSize {
+		t.Error("Expected", errInvalidTemplateSize, "got", err)
+	}
+	if _, err := New(nil, "", Config{MaxTemplateTransactions: -1}); err != errInvalidTemplateTransactions {
+		t.Error("Expected", errInvalidTemplateTransactions, "got", err)
+	}
+}
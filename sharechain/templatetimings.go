@@ -0,0 +1,62 @@
+package sharechain
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultSlowTemplateThreshold is the default build time of a block template above which a warning is logged
+	DefaultSlowTemplateThreshold = 500 * time.Millisecond
+	//templateBuildsLength is the number of recent template builds kept for the timing percentiles
+	templateBuildsLength = 100
+)
+
+//TemplateBuild is the time spent building a block template, split in the selection of the transactions from the
+// transaction pool, the generation of the miner payouts and the serialization of the block
+type TemplateBuild struct {
+	Created       time.Time
+	Mempool       time.Duration
+	Coinbase      time.Duration
+	Serialization time.Duration
+	Transactions  int
+	//Size is the size of the serialized block in bytes
+	Size int
+}
+
+//Total returns the time spent in all phases of the build
+func (tb TemplateBuild) Total() time.Duration {
+	return tb.Mempool + tb.Coinbase + tb.Serialization
+}
+
+// templateBuilds keeps the most recent template builds.
+type templateBuilds struct {
+	mutex  sync.Mutex
+	builds []TemplateBuild
+}
+
+func (t *templateBuilds) add(build TemplateBuild) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.builds = append(t.builds, build)
+	if len(t.builds) > templateBuildsLength {
+		t.builds = t.builds[len(t.builds)-templateBuildsLength:]
+	}
+}
+
+//TemplateBuilds returns the timings of the most recent template builds, the last build comes last
+func (sc *ShareChain) TemplateBuilds() []TemplateBuild {
+	sc.templateBuilds.mutex.Lock()
+	defer sc.templateBuilds.mutex.Unlock()
+	return append([]TemplateBuild(nil), sc.templateBuilds.builds...)
+}
+
+// recordTemplateBuild keeps the timings of a build and logs a warning if it
+// took longer than the SlowTemplateThreshold.
+func (sc *ShareChain) recordTemplateBuild(build TemplateBuild) {
+	sc.templateBuilds.add(build)
+	if total := build.Total(); total > sc.config.SlowTemplateThreshold {
+		sc.log.Println("Slow block template: building it took", total, "for", build.Transactions, "transactions (mempool", build.Mempool,
+			"coinbase", build.Coinbase, "serialization", build.Serialization, ")")
+	}
+}
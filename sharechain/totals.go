@@ -0,0 +1,161 @@
+package sharechain
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//uptimeInterval is the time between two updates of the lifetime uptime in the database
+const uptimeInterval = time.Minute
+
+var (
+	keyBlocksFound = []byte("BlocksFound")
+	keyPaid        = []byte("Paid")
+	keyUptime      = []byte("Uptime")
+)
+
+//Totals are the lifetime counters of the pool, they accumulate across restarts
+type Totals struct {
+	//Shares is the number of shares ever accepted
+	Shares uint64
+	//BlocksFound is the number of blocks ever found, including the orphaned ones
+	BlocksFound uint64
+	//Paid is the sum of the payouts of the matured blocks
+	Paid types.Currency
+	//Uptime is the time the pool has been running, including the current run
+	Uptime time.Duration
+	//Started is the start of the current run
+	Started time.Time
+}
+
+// getTotal decodes a counter of the Totals bucket, a missing counter is left
+// at its zero value.
+func getTotal(tx *bolt.Tx, key []byte, value interface{}) error {
+	raw := tx.Bucket(TotalsBucket).Get(key)
+	if raw == nil {
+		return nil
+	}
+	return encoding.Unmarshal(raw, value)
+}
+
+// addBlockFound increments the number of found blocks.
+func addBlockFound(tx *bolt.Tx) error {
+	var found uint64
+	if err := getTotal(tx, keyBlocksFound, &found); err != nil {
+		return err
+	}
+	return tx.Bucket(TotalsBucket).Put(keyBlocksFound, encoding.Marshal(found+1))
+}
+
+// addPaid adds a matured payout to the total paid.
+func addPaid(tx *bolt.Tx, value types.Currency) error {
+	paid := types.ZeroCurrency
+	if err := getTotal(tx, keyPaid, &paid); err != nil {
+		return err
+	}
+	return tx.Bucket(TotalsBucket).Put(keyPaid, encoding.Marshal(paid.Add(value)))
+}
+
+// initTotals computes the counters of a database created before the Totals
+// bucket existed from the found blocks and earnings.
+func initTotals(tx *bolt.Tx) error {
+	b := tx.Bucket(TotalsBucket)
+	var found uint64
+	err := tx.Bucket(FoundBlocks).ForEach(func(k, v []byte) error {
+		found++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	paid := types.ZeroCurrency
+	err = tx.Bucket(Earnings).ForEach(func(k, v []byte) error {
+		var earnings types.Currency
+		if err := encoding.Unmarshal(v, &earnings); err != nil {
+			return err
+		}
+		paid = paid.Add(earnings)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = b.Put(keyBlocksFound, encoding.Marshal(found)); err != nil {
+		return err
+	}
+	return b.Put(keyPaid, encoding.Marshal(paid))
+}
+
+//Totals returns the lifetime counters of the pool
+func (sc *ShareChain) Totals() (totals Totals, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	totals.Shares = sc.totalShares
+	totals.Started = sc.started
+	totals.Paid = types.ZeroCurrency
+	var uptime uint64
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		if err := getTotal(tx, keyBlocksFound, &totals.BlocksFound); err != nil {
+			return err
+		}
+		if err := getTotal(tx, keyPaid, &totals.Paid); err != nil {
+			return err
+		}
+		return getTotal(tx, keyUptime, &uptime)
+	})
+	totals.Uptime = time.Duration(uptime) * time.Second
+	if !sc.uptimeRecorded.IsZero() {
+		totals.Uptime += time.Since(sc.uptimeRecorded)
+	}
+	return
+}
+
+// recordUptime adds the time since the uptime was last recorded to the
+// lifetime uptime in the database. Only whole seconds are recorded, the
+// remainder is carried over to the next record.
+func (sc *ShareChain) recordUptime(now time.Time) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.uptimeRecorded.IsZero() {
+		return nil
+	}
+	seconds := uint64(now.Sub(sc.uptimeRecorded) / time.Second)
+	if seconds == 0 {
+		return nil
+	}
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		var uptime uint64
+		if err := getTotal(tx, keyUptime, &uptime); err != nil {
+			return err
+		}
+		return tx.Bucket(TotalsBucket).Put(keyUptime, encoding.Marshal(uptime+seconds))
+	})
+	if err == nil {
+		sc.uptimeRecorded = sc.uptimeRecorded.Add(time.Duration(seconds) * time.Second)
+	}
+	return err
+}
+
+// threadedRecordUptime records the uptime every uptimeInterval until the
+// sharechain is closed.
+func (sc *ShareChain) threadedRecordUptime() {
+	if sc.tg.Add() != nil {
+		return
+	}
+	defer sc.tg.Done()
+	ticker := time.NewTicker(uptimeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.tg.StopChan():
+			return
+		case now := <-ticker.C:
+			if err := sc.recordUptime(now); err != nil {
+				sc.log.Println("Error recording the uptime:", err)
+			}
+		}
+	}
+}
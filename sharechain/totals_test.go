@@ -0,0 +1,116 @@
+package sharechain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestTotals(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{BlockMaturity: 1})
+	defer cleanup()
+
+	for i := byte(1); i <= 2; i++ {
+		if err := sc.AddFoundBlock(testBlock(i, 100), types.UnlockHash{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sc.AddFoundBlock(testBlock(1, 100), types.UnlockHash{1}); err != errRepeatInsert {
+		t.Fatal("Expected a repeated insert, got", err)
+	}
+	sc.AddShare(Share{Miner: "a"})
+	sc.height = 1
+	err := sc.db.Update(sc.updateMaturity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals, err := sc.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.BlocksFound != 2 || totals.Shares != 1 || totals.Paid.Cmp(types.NewCurrency64(200)) != 0 {
+		t.Error("Unexpected totals", totals)
+	}
+}
+
+func TestRecordUptime(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	start := time.Now()
+	sc.uptimeRecorded = start
+	if err := sc.recordUptime(start.Add(90 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.recordUptime(start.Add(150*time.Second + 500*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	sc.uptimeRecorded = time.Time{}
+	totals, err := sc.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.Uptime != 150*time.Second {
+		t.Error("Expected an uptime of 150s, got", totals.Uptime)
+	}
+}
+
+func TestInitTotals(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		if err := putFoundBlock(tx, FoundBlock{ID: types.BlockID{1}}); err != nil {
+			return err
+		}
+		if err := addEarnings(tx, types.UnlockHash{1}, types.NewCurrency64(7)); err != nil {
+			return err
+		}
+		if err := addEarnings(tx, types.UnlockHash{2}, types.NewCurrency64(5)); err != nil {
+			return err
+		}
+		// A database of an older version does not have the totals.
+		if err := tx.DeleteBucket(TotalsBucket); err != nil {
+			return err
+		}
+		return sc.createShareChainDB(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals, err := sc.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.BlocksFound != 1 || totals.Paid.Cmp(types.NewCurrency64(12)) != 0 {
+		t.Error("Totals not computed from the existing data", totals)
+	}
+}
+
+func TestTotalsSurviveRestart(t *testing.T) {
+	sc, mock, cleanup := newMockShareChain(t, Config{})
+	defer cleanup()
+	if err := sc.AddFoundBlock(testBlock(1, 100), types.UnlockHash{1}); err != nil {
+		t.Fatal(err)
+	}
+	sc.AddShare(Share{Miner: "a"})
+	sc.uptimeRecorded = sc.uptimeRecorded.Add(-time.Minute)
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := New(mock, sc.persistDir, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	restarted.pendingChanges.Wait()
+	totals, err := restarted.Totals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.BlocksFound != 1 || totals.Shares != 1 || totals.Uptime < time.Minute {
+		t.Error("Totals lost after a restart", totals)
+	}
+}
@@ -0,0 +1,118 @@
+package sharechain
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	//MaxWebhooksPerAddress is the maximum number of webhooks registered for a miner address
+	MaxWebhooksPerAddress = 3
+	//MaxWebhooks is the maximum number of webhooks registered for all miner addresses together
+	MaxWebhooks = 1000
+)
+
+//ErrTooManyWebhooks is returned when an address already has MaxWebhooksPerAddress webhooks
+var ErrTooManyWebhooks = errors.New("an address can have at most 3 webhooks")
+
+//ErrWebhookLimit is returned when the pool already has MaxWebhooks webhooks
+var ErrWebhookLimit = errors.New("the pool can have at most 1000 webhooks")
+
+//Webhook is a url the pool posts to when it finds a block or when a payout to the address matures.
+// The ID is only known to whoever registered the webhook, it is required to delete it.
+type Webhook struct {
+	ID      string           `json:"id,omitempty"`
+	Address types.UnlockHash `json:"address"`
+	URL     string           `json:"url"`
+	Created types.Timestamp  `json:"created"`
+}
+
+//AddWebhook registers a webhook for a miner address and returns it with its new ID
+func (sc *ShareChain) AddWebhook(address types.UnlockHash, url string) (hook Webhook, err error) {
+	id, err := crypto.RandBytes(16)
+	if err != nil {
+		return
+	}
+	hook = Webhook{ID: hex.EncodeToString(id), Address: address, URL: url, Created: types.CurrentTimestamp()}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	err = sc.db.Update(func(tx *bolt.Tx) error {
+		hooks, err := webhooks(tx, nil)
+		if err != nil {
+			return err
+		}
+		if len(hooks) >= MaxWebhooks {
+			return ErrWebhookLimit
+		}
+		var registered int
+		for _, h := range hooks {
+			if h.Address == address {
+				registered++
+			}
+		}
+		if registered >= MaxWebhooksPerAddress {
+			return ErrTooManyWebhooks
+		}
+		return tx.Bucket(Webhooks).Put([]byte(hook.ID), encoding.Marshal(hook))
+	})
+	return
+}
+
+//DeleteWebhook removes a registered webhook, errNilItem is returned if there is none with the ID
+func (sc *ShareChain) DeleteWebhook(id string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(Webhooks)
+		if b.Get([]byte(id)) == nil {
+			return errNilItem
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+//Webhook returns a registered webhook by its ID, exists is false if there is none
+func (sc *ShareChain) Webhook(id string) (hook Webhook, exists bool, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(Webhooks).Get([]byte(id))
+		if raw == nil {
+			return nil
+		}
+		exists = true
+		return encoding.Unmarshal(raw, &hook)
+	})
+	return
+}
+
+//Webhooks returns the webhooks registered for a miner address, or all webhooks if address is nil
+func (sc *ShareChain) Webhooks(address *types.UnlockHash) (hooks []Webhook, err error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	err = sc.db.View(func(tx *bolt.Tx) error {
+		hooks, err = webhooks(tx, address)
+		return err
+	})
+	return
+}
+
+func webhooks(tx *bolt.Tx, address *types.UnlockHash) (hooks []Webhook, err error) {
+	hooks = make([]Webhook, 0)
+	err = tx.Bucket(Webhooks).ForEach(func(k, v []byte) error {
+		var hook Webhook
+		if err := encoding.Unmarshal(v, &hook); err != nil {
+			return err
+		}
+		if address == nil || hook.Address == *address {
+			hooks = append(hooks, hook)
+		}
+		return nil
+	})
+	return
+}
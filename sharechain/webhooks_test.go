@@ -0,0 +1,80 @@
+package sharechain
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestWebhooks(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+
+	hook, err := sc.AddWebhook(a, "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.ID == "" || hook.Address != a {
+		t.Error("Expected a webhook with an id for", a, "got", hook)
+	}
+	for i := 1; i < MaxWebhooksPerAddress; i++ {
+		if _, err = sc.AddWebhook(a, "https://example.com/a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = sc.AddWebhook(a, "https://example.com/a"); err != ErrTooManyWebhooks {
+		t.Error("Expected", ErrTooManyWebhooks, "got", err)
+	}
+	if _, err = sc.AddWebhook(b, "https://example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, _ := sc.Webhooks(&a); len(hooks) != MaxWebhooksPerAddress {
+		t.Error("Expected", MaxWebhooksPerAddress, "webhooks for", a, "got", hooks)
+	}
+	if hooks, _ := sc.Webhooks(nil); len(hooks) != MaxWebhooksPerAddress+1 {
+		t.Error("Expected", MaxWebhooksPerAddress+1, "webhooks, got", hooks)
+	}
+
+	if stored, exists, err := sc.Webhook(hook.ID); err != nil || !exists || stored.URL != hook.URL {
+		t.Error("Expected", hook, "got", stored, exists, err)
+	}
+	if err = sc.DeleteWebhook(hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := sc.Webhook(hook.ID); exists {
+		t.Error("Deleted webhook still registered")
+	}
+	if err = sc.DeleteWebhook(hook.ID); err != errNilItem {
+		t.Error("Expected", errNilItem, "deleting an unknown webhook, got", err)
+	}
+}
+
+func TestWebhookLimit(t *testing.T) {
+	sc, cleanup := newTestShareChain(t, Config{})
+	defer cleanup()
+	err := sc.db.Update(func(tx *bolt.Tx) error {
+		for i := 0; i < MaxWebhooks; i++ {
+			hook := Webhook{ID: strconv.Itoa(i), Address: types.UnlockHash{byte(i), byte(i >> 8)}, URL: "https://example.com"}
+			if err := tx.Bucket(Webhooks).Put([]byte(hook.ID), encoding.Marshal(hook)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sc.AddWebhook(types.UnlockHash{0xff, 0xff}, "https://example.com"); err != ErrWebhookLimit {
+		t.Error("Expected", ErrWebhookLimit, "got", err)
+	}
+	if err = sc.DeleteWebhook("0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sc.AddWebhook(types.UnlockHash{0xff, 0xff}, "https://example.com"); err != nil {
+		t.Error("Expected room for a webhook after a delete, got", err)
+	}
+}
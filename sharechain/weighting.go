@@ -0,0 +1,75 @@
+package sharechain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/bolt"
+)
+
+//ShareWeighting decides how much the shares count in the pplns distribution of a block reward
+type ShareWeighting string
+
+const (
+	//WeightingLinear counts every share in the pplns window once
+	WeightingLinear ShareWeighting = "linear"
+	//WeightingSolver counts every share in the pplns window once and the share that solved the block SolverWeight times,
+	// in addition to the FinderBonus. The finder is the miner of the block MinerBlock returned,
+	// a block registered without a finder is distributed linearly.
+	WeightingSolver ShareWeighting = "solver"
+)
+
+const (
+	//DefaultSolverWeight is the default number of shares the share that solved a block counts for with WeightingSolver
+	DefaultSolverWeight = 10
+	//MaxSolverWeight is the highest SolverWeight
+	MaxSolverWeight = 1000
+)
+
+var (
+	errInvalidShareWeighting = errors.New("share weighting must be linear or solver")
+	errInvalidSolverWeight   = fmt.Errorf("solver weight must be between 1 and %d", MaxSolverWeight)
+)
+
+func validShareWeighting(weighting ShareWeighting) bool {
+	return weighting == WeightingLinear || weighting == WeightingSolver
+}
+
+// solverWeight returns the number of shares the solving share of a block
+// counts for, 0 with linear weighting.
+func (config Config) solverWeight() uint64 {
+	if config.ShareWeighting != WeightingSolver {
+		return 0
+	}
+	return uint64(config.SolverWeight)
+}
+
+//SolverWeight returns the number of shares the share that solved a block counts for in its pplns distribution,
+// 0 with linear weighting
+func (sc *ShareChain) SolverWeight() uint64 {
+	return sc.config.solverWeight()
+}
+
+// putSolverWeight records the weight the solving share of a found block
+// counted for, nothing is recorded for linear weighting.
+func putSolverWeight(tx *bolt.Tx, id types.BlockID, weight uint64) error {
+	if weight == 0 {
+		return nil
+	}
+	return tx.Bucket(SolverWeights).Put(id[:], encoding.Marshal(weight))
+}
+
+// solverWeightOf returns the weight the solving share of a found block
+// counted for, blocks without a recorded weight were distributed linearly.
+func solverWeightOf(tx *bolt.Tx, id types.BlockID) (weight uint64, err error) {
+	b := tx.Bucket(SolverWeights)
+	if b == nil {
+		return 0, nil
+	}
+	if raw := b.Get(id[:]); raw != nil {
+		err = encoding.Unmarshal(raw, &weight)
+	}
+	return
+}
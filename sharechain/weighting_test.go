@@ -0,0 +1,129 @@
+package sharechain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestSolverWeighting(t *testing.T) {
+	a, b, feeAddress := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	window := testShares(a, a, a, b)
+	reward := types.NewCurrency64(1000003)
+
+	// b solves the block and counts for 1 + 4 of 8 shares.
+	payouts := pplnsPayouts(window, b, reward, 200, feeAddress, 4)
+	if total := sumPayouts(payouts); total.Cmp(reward) != 0 {
+		t.Fatal("Payouts of", total, "do not add up to the reward of", reward)
+	}
+	remainder := reward.Sub(reward.Mul64(200).Div64(10000)).Sub(reward.Mul64(FinderBonus).Div64(1000))
+	for _, payout := range payouts {
+		if payout.UnlockHash == a && payout.Value.Cmp(remainder.Mul64(3).Div64(8)) != 0 {
+			t.Error("Unexpected payout of", payout.Value, "to the miner of 3 shares")
+		}
+	}
+	linear := pplnsPayouts(window, b, reward, 200, feeAddress, 0)
+	if payouts[1].UnlockHash != b || payouts[1].Value.Cmp(linear[1].Value) <= 0 {
+		t.Error("Solver not rewarded:", payouts, linear)
+	}
+
+	// Without a finder the distribution is linear.
+	if free, linear := pplnsPayouts(window, types.UnlockHash{}, reward, 0, feeAddress, 4), pplnsPayouts(window, types.UnlockHash{}, reward, 0, feeAddress, 0); len(free) != len(linear) || free[0].Value.Cmp(linear[0].Value) != 0 {
+		t.Error("Solver weight applied without a finder:", free)
+	}
+}
+
+func TestShareWeightingConfig(t *testing.T) {
+	for _, config := range []Config{{ShareWeighting: "quadratic"}, {ShareWeighting: WeightingSolver, SolverWeight: -1}, {SolverWeight: MaxSolverWeight + 1}} {
+		if _, err := New(nil, "", config); err != errInvalidShareWeighting && err != errInvalidSolverWeight {
+			t.Error("Invalid weighting accepted:", config.ShareWeighting, config.SolverWeight, err)
+		}
+	}
+	if w := (Config{ShareWeighting: WeightingLinear, SolverWeight: 5}).solverWeight(); w != 0 {
+		t.Error("Linear weighting has a solver weight of", w)
+	}
+}
+
+func TestRecomputeSolverWeighting(t *testing.T) {
+	config := Config{BlockMaturity: 1, ShareWeighting: WeightingSolver, SolverWeight: 5}
+	sc, cleanup := newTestShareChain(t, config)
+	defer cleanup()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	if err := sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	block := types.Block{Nonce: types.BlockNonce{1}}
+	block.MinerPayouts, _ = sc.GenerateMinerPayouts(a, types.NewCurrency64(1e6))
+	if err := sc.AddFoundBlock(block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	sc.db.Close()
+
+	// The weight of the block is stored, the recompute does not depend on the
+	// current weighting.
+	discrepancies, err := Recompute(sc.persistDir, Config{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Error("Unexpected discrepancies:", discrepancies)
+	}
+}
+
+func TestMinerBlockSolverWeighting(t *testing.T) {
+	config := Config{BlockMaturity: 1, Fee: 100, FeeAddress: types.UnlockHash{9}, ShareWeighting: WeightingSolver, SolverWeight: 5}
+	sc, _, cleanup := newMockShareChain(t, config)
+	defer cleanup()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	for _, share := range testShares(a, b, b) {
+		sc.AddShare(share)
+	}
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := sc.MinerBlock(template, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The miner of the block is the finder, it gets the bonus and its share
+	// counts for the solver weight.
+	subsidy := block.CalculateSubsidy(template.Height)
+	expected := pplnsPayouts(testShares(a, b, b), a, subsidy, 100, config.FeeAddress, 5)
+	if payouts := block.MinerPayouts[:len(block.MinerPayouts)-1]; !reflect.DeepEqual(payouts, expected) {
+		t.Error("Expected the solver weighted payouts with the miner as the finder, got", payouts)
+	}
+	linear := pplnsPayouts(testShares(a, b, b), types.UnlockHash{}, subsidy, 100, config.FeeAddress, 5)
+	if block.MinerPayouts[0].UnlockHash != a || block.MinerPayouts[0].Value.Cmp(linear[0].Value) <= 0 {
+		t.Error("Finder not rewarded:", block.MinerPayouts, linear)
+	}
+
+	sc.AddShare(testShares(b)[0])
+	if err = sc.flushShares(); err != nil {
+		t.Fatal(err)
+	}
+	if err = sc.AddMinerBlock(template, block, a); err != nil {
+		t.Fatal(err)
+	}
+	sc.processConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	if earnings, err := sc.Earnings(a); err != nil || earnings.Cmp(expected[0].Value) != 0 {
+		t.Fatal("Expected earnings of", expected[0].Value, "for the finder, got", earnings, err)
+	}
+	sc.db.Close()
+
+	discrepancies, err := Recompute(sc.persistDir, Config{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Error("Unexpected discrepancies:", discrepancies)
+	}
+}
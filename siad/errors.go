@@ -0,0 +1,36 @@
+package siad
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// wsaeaddrinuse is the error windows returns for an address in use, the
+// syscall package does not define it.
+const wsaeaddrinuse = syscall.Errno(10048)
+
+//AddressInUse returns true if err is the error of listening on an address another process listens on
+func AddressInUse(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if syscallErr, ok := err.(*os.SyscallError); ok {
+		err = syscallErr.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return false
+	}
+	return errno == syscall.EADDRINUSE || runtime.GOOS == "windows" && errno == wsaeaddrinuse
+}
+
+//DatabaseLocked returns true if err is the error of opening a database that another process has open.
+// The siad modules only keep the message of the error, so it is matched as well.
+func DatabaseLocked(err error) bool {
+	return err == bolt.ErrTimeout || err != nil && strings.HasSuffix(err.Error(), ": "+bolt.ErrTimeout.Error())
+}
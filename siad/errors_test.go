@@ -0,0 +1,44 @@
+package siad
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+func TestAddressInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, err = net.Listen("tcp", l.Addr().String())
+	if err == nil {
+		t.Fatal("Listened twice on", l.Addr())
+	}
+	if !AddressInUse(err) {
+		t.Error("Address in use not detected:", err)
+	}
+	if _, err = net.Listen("tcp", "127.0.0.1:notaport"); AddressInUse(err) || AddressInUse(nil) {
+		t.Error("Other listen error detected as address in use:", err)
+	}
+}
+
+func TestDatabaseLocked(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		locked bool
+	}{
+		{bolt.ErrTimeout, true},
+		{errors.New("error opening consensus database: " + bolt.ErrTimeout.Error()), true},
+		{errors.New("read tcp: i/o timeout"), false},
+		{errors.New("error opening consensus database: invalid database"), false},
+		{nil, false},
+	} {
+		if locked := DatabaseLocked(test.err); locked != test.locked {
+			t.Error("Expected", test.locked, "for", test.err, "got", locked)
+		}
+	}
+}
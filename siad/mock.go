@@ -0,0 +1,265 @@
+package siad
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var errMockOrphan = errors.New("block does not extend the current block")
+
+//Mock is an in-memory Daemon for testing the pool without a consensus set.
+// The chain starts at the genesis block and blocks are accepted without validation,
+// AcceptBlockFunc can reject blocks. Methods of the modules that are not used by the pool panic.
+type Mock struct {
+	mu          sync.Mutex
+	blocks      []types.Block
+	subscribers []modules.ConsensusSetSubscriber
+	unsynced    bool
+
+	//Target is the target returned for the child of any block
+	Target types.Target
+	//Transactions are the transactions in the transaction pool
+	Transactions []types.Transaction
+	//TransactionListFunc is called instead of listing the Transactions, it can block or panic to simulate
+	// a transaction pool that is unavailable
+	TransactionListFunc func() []types.Transaction
+	//PeerList are the peers of the gateway, disconnected peers are removed
+	PeerList []modules.Peer
+	//AcceptBlockFunc is called before a block is accepted, a non-nil error rejects the block
+	AcceptBlockFunc func(types.Block) error
+	//ConnectFunc is called before a peer is connected, a non-nil error refuses the connection
+	ConnectFunc func(modules.NetAddress) error
+}
+
+//NewMock creates a Mock with the genesis block as current block
+func NewMock() *Mock {
+	return &Mock{
+		blocks: []types.Block{types.GenesisBlock},
+		Target: types.RootTarget,
+	}
+}
+
+//ConsensusSet returns the mocked consensus module
+func (m *Mock) ConsensusSet() modules.ConsensusSet {
+	return mockConsensusSet{m: m}
+}
+
+//Gateway returns the mocked gateway module
+func (m *Mock) Gateway() modules.Gateway {
+	return mockGateway{m: m}
+}
+
+//TransactionPool returns the mocked transaction pool module
+func (m *Mock) TransactionPool() modules.TransactionPool {
+	return mockTransactionPool{m: m}
+}
+
+//Mine adds n empty blocks on top of the current block
+func (m *Mock) Mine(n int) {
+	for i := 0; i < n; i++ {
+		m.mu.Lock()
+		b := types.Block{
+			ParentID:  m.blocks[len(m.blocks)-1].ID(),
+			Timestamp: m.blocks[len(m.blocks)-1].Timestamp + 1,
+		}
+		m.mu.Unlock()
+		m.ConsensusSet().AcceptBlock(b)
+	}
+}
+
+//Reorg reverts the last depth blocks and applies the given blocks instead in a single consensus change
+func (m *Mock) Reorg(depth int, blocks ...types.Block) {
+	m.mu.Lock()
+	reverted := make([]types.Block, 0, depth)
+	for i := 0; i < depth && len(m.blocks) > 1; i++ {
+		reverted = append(reverted, m.blocks[len(m.blocks)-1])
+		m.blocks = m.blocks[:len(m.blocks)-1]
+	}
+	m.blocks = append(m.blocks, blocks...)
+	subscribers := append([]modules.ConsensusSetSubscriber(nil), m.subscribers...)
+	m.mu.Unlock()
+	m.notify(subscribers, reverted, blocks)
+}
+
+//SetSynced sets if the consensus set reports it is synced with the network, a new Mock is synced
+func (m *Mock) SetSynced(synced bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unsynced = !synced
+}
+
+// notify sends a consensus change to the subscribers.
+func (m *Mock) notify(subscribers []modules.ConsensusSetSubscriber, reverted, applied []types.Block) {
+	m.mu.Lock()
+	synced := !m.unsynced
+	m.mu.Unlock()
+	cc := modules.ConsensusChange{RevertedBlocks: reverted, AppliedBlocks: applied, Synced: synced}
+	if len(applied) > 0 {
+		cc.ID = changeID(applied[len(applied)-1])
+	}
+	for _, s := range subscribers {
+		s.ProcessConsensusChange(cc)
+	}
+}
+
+// changeID is the id of the consensus change that applied b.
+func changeID(b types.Block) modules.ConsensusChangeID {
+	return modules.ConsensusChangeID(crypto.HashObject(b.ID()))
+}
+
+type mockConsensusSet struct {
+	modules.ConsensusSet
+	m *Mock
+}
+
+func (cs mockConsensusSet) AcceptBlock(b types.Block) error {
+	m := cs.m
+	m.mu.Lock()
+	if b.ParentID != m.blocks[len(m.blocks)-1].ID() {
+		m.mu.Unlock()
+		return errMockOrphan
+	}
+	if m.AcceptBlockFunc != nil {
+		if err := m.AcceptBlockFunc(b); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+	}
+	m.blocks = append(m.blocks, b)
+	subscribers := append([]modules.ConsensusSetSubscriber(nil), m.subscribers...)
+	m.mu.Unlock()
+	m.notify(subscribers, nil, []types.Block{b})
+	return nil
+}
+
+func (cs mockConsensusSet) BlockAtHeight(height types.BlockHeight) (types.Block, bool) {
+	cs.m.mu.Lock()
+	defer cs.m.mu.Unlock()
+	if int(height) >= len(cs.m.blocks) {
+		return types.Block{}, false
+	}
+	return cs.m.blocks[height], true
+}
+
+func (cs mockConsensusSet) ChildTarget(types.BlockID) (types.Target, bool) {
+	cs.m.mu.Lock()
+	defer cs.m.mu.Unlock()
+	return cs.m.Target, true
+}
+
+func (cs mockConsensusSet) CurrentBlock() types.Block {
+	cs.m.mu.Lock()
+	defer cs.m.mu.Unlock()
+	return cs.m.blocks[len(cs.m.blocks)-1]
+}
+
+func (cs mockConsensusSet) Height() types.BlockHeight {
+	cs.m.mu.Lock()
+	defer cs.m.mu.Unlock()
+	return types.BlockHeight(len(cs.m.blocks) - 1)
+}
+
+func (cs mockConsensusSet) Synced() bool {
+	cs.m.mu.Lock()
+	defer cs.m.mu.Unlock()
+	return !cs.m.unsynced
+}
+
+//ConsensusSetSubscribe sends the blocks after the given change to the subscriber in a single consensus change
+func (cs mockConsensusSet) ConsensusSetSubscribe(s modules.ConsensusSetSubscriber, start modules.ConsensusChangeID) error {
+	m := cs.m
+	m.mu.Lock()
+	first := 0
+	if start != modules.ConsensusChangeBeginning {
+		first = -1
+		for i, b := range m.blocks {
+			if changeID(b) == start {
+				first = i + 1
+			}
+		}
+		if first < 0 {
+			m.mu.Unlock()
+			return modules.ErrInvalidConsensusChangeID
+		}
+	}
+	applied := append([]types.Block(nil), m.blocks[first:]...)
+	m.subscribers = append(m.subscribers, s)
+	m.mu.Unlock()
+	if len(applied) > 0 {
+		m.notify([]modules.ConsensusSetSubscriber{s}, nil, applied)
+	}
+	return nil
+}
+
+func (cs mockConsensusSet) Unsubscribe(s modules.ConsensusSetSubscriber) {
+	m := cs.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, subscriber := range m.subscribers {
+		if subscriber == s {
+			m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+type mockGateway struct {
+	modules.Gateway
+	m *Mock
+}
+
+func (g mockGateway) Peers() []modules.Peer {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	return append([]modules.Peer(nil), g.m.PeerList...)
+}
+
+//Connect adds a peer to the PeerList, ConnectFunc can refuse the connection
+func (g mockGateway) Connect(addr modules.NetAddress) error {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	if g.m.ConnectFunc != nil {
+		if err := g.m.ConnectFunc(addr); err != nil {
+			return err
+		}
+	}
+	for _, peer := range g.m.PeerList {
+		if peer.NetAddress == addr {
+			return errors.New("already connected to " + string(addr))
+		}
+	}
+	g.m.PeerList = append(g.m.PeerList, modules.Peer{NetAddress: addr})
+	return nil
+}
+
+func (g mockGateway) Disconnect(addr modules.NetAddress) error {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	for i, peer := range g.m.PeerList {
+		if peer.NetAddress == addr {
+			g.m.PeerList = append(g.m.PeerList[:i], g.m.PeerList[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not connected to " + string(addr))
+}
+
+type mockTransactionPool struct {
+	modules.TransactionPool
+	m *Mock
+}
+
+func (tp mockTransactionPool) TransactionList() []types.Transaction {
+	tp.m.mu.Lock()
+	list := tp.m.TransactionListFunc
+	txns := append([]types.Transaction(nil), tp.m.Transactions...)
+	tp.m.mu.Unlock()
+	if list != nil {
+		return list()
+	}
+	return txns
+}
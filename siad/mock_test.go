@@ -0,0 +1,43 @@
+package siad
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+type changeRecorder struct {
+	changes []modules.ConsensusChange
+}
+
+func (r *changeRecorder) ProcessConsensusChange(cc modules.ConsensusChange) {
+	r.changes = append(r.changes, cc)
+}
+
+func TestMockSubscribe(t *testing.T) {
+	m := NewMock()
+	m.Mine(2)
+	cs := m.ConsensusSet()
+	first := &changeRecorder{}
+	if err := cs.ConsensusSetSubscribe(first, modules.ConsensusChangeBeginning); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.changes) != 1 || len(first.changes[0].AppliedBlocks) != 3 {
+		t.Fatal("Expected the genesis and 2 mined blocks in one change, got", first.changes)
+	}
+	m.Mine(1)
+	if len(first.changes) != 2 || cs.Height() != 3 {
+		t.Error("New block not sent to the subscriber")
+	}
+
+	resumed := &changeRecorder{}
+	if err := cs.ConsensusSetSubscribe(resumed, first.changes[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.changes) != 1 || len(resumed.changes[0].AppliedBlocks) != 1 {
+		t.Error("Expected only the block after the last change, got", resumed.changes)
+	}
+	if err := cs.ConsensusSetSubscribe(&changeRecorder{}, modules.ConsensusChangeID{1}); err != modules.ErrInvalidConsensusChangeID {
+		t.Error("Expected", modules.ErrInvalidConsensusChangeID, "got", err)
+	}
+}
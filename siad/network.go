@@ -0,0 +1,29 @@
+package siad
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+//Network is the sia network the linked siad modules are built for: standard, dev or testing
+const Network = build.Release
+
+//CheckNetwork returns an error if the pool is configured for another network than the linked siad modules,
+// or if the genesis block of the consensus set is not the genesis block of the network
+func CheckNetwork(network string, cs modules.ConsensusSet) error {
+	if network != Network {
+		return fmt.Errorf("the pool is configured for the %s network but the siad modules are built for the %s network", network, Network)
+	}
+	genesis, ok := cs.BlockAtHeight(0)
+	if !ok {
+		return errors.New("the consensus set has no genesis block")
+	}
+	if genesis.ID() != types.GenesisID {
+		return fmt.Errorf("the genesis block %v of the consensus set is not the genesis block %v of the %s network, the consensus directory belongs to another network", genesis.ID(), types.GenesisID, Network)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package siad
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/api"
@@ -12,46 +15,97 @@ import (
 	log "github.com/Sirupsen/logrus"
 )
 
+//Daemon gives access to the siad modules used by the pool, it is implemented by Siad and by Mock for testing
+type Daemon interface {
+	ConsensusSet() modules.ConsensusSet
+	Gateway() modules.Gateway
+	TransactionPool() modules.TransactionPool
+}
+
+//DefaultDir is the directory the siad modules persist their data in if no module directory is given
+const DefaultDir = "p2pooldata/siad"
+
 //Siad is the reference to the siad modules
 type Siad struct {
 	RPCAddr string
 	APIAddr string
 	srv     *Server
+
+	//GatewayDir, ConsensusDir and TransactionPoolDir are the persist directories of the modules,
+	// they default to a subdirectory of DefaultDir
+	GatewayDir         string
+	ConsensusDir       string
+	TransactionPoolDir string
+
+	cs    modules.ConsensusSet
+	g     modules.Gateway
+	tpool modules.TransactionPool
 }
 
 //Start starts the siad daemon with the consensus, gateway and transactionpool modules
+// If a module fails to load, the modules that were already loaded are closed in reverse order.
 func (s *Siad) Start() (err error) {
 
 	// Create the server and start serving daemon routes immediately.
 	log.Infoln("Loading siad...")
 	s.srv, err = NewServer(s.APIAddr)
+	if AddressInUse(err) {
+		return addressInUseError("siad api", s.APIAddr)
+	}
 	if err != nil {
-		return err
+		return errors.New("error creating siad api server: " + err.Error())
 	}
 
+	// closers are the already loaded modules, closed in reverse order
+	// if loading a next module fails.
+	closers := []io.Closer{s.srv}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(closers) - 1; i >= 0; i-- {
+			if closeErr := closers[i].Close(); closeErr != nil {
+				log.Errorln("Error closing siad module after failed start:", closeErr)
+			}
+		}
+	}()
+
 	servErrs := make(chan error)
 	go func() {
 		servErrs <- s.srv.Serve()
 	}()
 
 	log.Infoln("Loading siad/gateway...")
-	g, err := gateway.New(s.RPCAddr, true, filepath.Join("p2pooldata/siad", modules.GatewayDir))
+	g, err := gateway.New(s.RPCAddr, true, moduleDir(s.GatewayDir, modules.GatewayDir))
+	if AddressInUse(err) {
+		return addressInUseError("siad/gateway rpc", s.RPCAddr)
+	}
 	if err != nil {
-		return
+		return errors.New("error loading siad/gateway: " + err.Error())
 	}
+	closers = append(closers, g)
 
 	log.Infoln("Loading siad/consensus...")
-	cs, err := consensus.New(g, true, filepath.Join("p2pooldata/siad", modules.ConsensusDir))
+	cs, err := consensus.New(g, true, moduleDir(s.ConsensusDir, modules.ConsensusDir))
+	if DatabaseLocked(err) {
+		return databaseLockedError("siad/consensus", moduleDir(s.ConsensusDir, modules.ConsensusDir))
+	}
 	if err != nil {
-		return
+		return errors.New("error loading siad/consensus: " + err.Error())
 	}
+	closers = append(closers, cs)
 
 	log.Infoln("Loading siad/transaction pool...")
-	tpool, err := transactionpool.New(cs, g, filepath.Join("p2pooldata/siad", modules.TransactionPoolDir))
+	tpool, err := transactionpool.New(cs, g, moduleDir(s.TransactionPoolDir, modules.TransactionPoolDir))
+	if DatabaseLocked(err) {
+		return databaseLockedError("siad/transaction pool", moduleDir(s.TransactionPoolDir, modules.TransactionPoolDir))
+	}
 	if err != nil {
-		return err
+		return errors.New("error loading siad/transaction pool: " + err.Error())
 	}
 
+	s.cs, s.g, s.tpool = cs, g, tpool
+
 	a := api.New("Sia-Agent", "", cs, nil, g, nil, nil, nil, tpool, nil)
 
 	// connect the API to the server
@@ -65,8 +119,48 @@ func (s *Siad) Start() (err error) {
 	return
 }
 
-//Close stops the siad daemon
+// addressInUseError is the error of a listener address that is taken, usually
+// by an instance that is still running or shutting down.
+func addressInUseError(listener, address string) error {
+	return fmt.Errorf("%v address %v is already in use, is another siad or pool instance running or still shutting down?", listener, address)
+}
+
+// databaseLockedError is the error of a module database another process has
+// open.
+func databaseLockedError(module, dir string) error {
+	return fmt.Errorf("the %v database in %v is locked by another process, is another siad or pool instance using the same directory?", module, dir)
+}
+
+//moduleDir returns dir or the default directory of the module if dir is empty
+func moduleDir(dir, module string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Join(DefaultDir, module)
+}
+
+//ConsensusSet returns the embedded consensus module
+func (s *Siad) ConsensusSet() modules.ConsensusSet {
+	return s.cs
+}
+
+//Gateway returns the embedded gateway module
+func (s *Siad) Gateway() modules.Gateway {
+	return s.g
+}
+
+//TransactionPool returns the embedded transaction pool module
+func (s *Siad) TransactionPool() modules.TransactionPool {
+	return s.tpool
+}
+
+//Close stops the siad daemon, closing the modules in reverse order of loading
 func (s *Siad) Close() (err error) {
-	err = s.srv.Close()
+	closers := []io.Closer{s.tpool, s.cs, s.g, s.srv}
+	for _, closer := range closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
 	return
 }
@@ -1 +1,22 @@
 package siad
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+func TestCheckNetwork(t *testing.T) {
+	m := NewMock()
+	if err := CheckNetwork(Network, m.ConsensusSet()); err != nil {
+		t.Error(err)
+	}
+	if err := CheckNetwork("othernet", m.ConsensusSet()); err == nil {
+		t.Error("Other network accepted")
+	}
+
+	m.blocks[0] = types.Block{Timestamp: types.GenesisTimestamp + 1}
+	if err := CheckNetwork(Network, m.ConsensusSet()); err == nil {
+		t.Error("Other genesis block accepted")
+	}
+}
@@ -0,0 +1,76 @@
+package stratum
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//CompatMode is the stratum dialect a connection is handled with, it is negotiated from the mining.subscribe handshake
+type CompatMode string
+
+const (
+	//CompatStandard is used for miners that identify themselves with a user agent, they get all notifications
+	// including the extensions like client.show_message
+	CompatStandard CompatMode = "standard"
+	//CompatMinimal is used for miners that subscribe without a user agent, these are often bare-bones
+	// implementations of the protocol so only mining.set_difficulty and mining.notify are sent
+	CompatMinimal CompatMode = "minimal"
+)
+
+//parseUserAgent splits a user agent like "sgminer/5.5.4" or "cgminer 4.9.2" in the name and version of the client
+func parseUserAgent(agent string) (client, version string) {
+	agent = strings.TrimSpace(agent)
+	if i := strings.IndexAny(agent, "/ "); i >= 0 {
+		return agent[:i], strings.TrimSpace(agent[i+1:])
+	}
+	return agent, ""
+}
+
+//subscribeParams returns the user agent and session id of a mining.subscribe request.
+// Miners send no params at all, null params, null placeholders or additional parameters like the host and port,
+// anything that is not a string is treated as absent.
+func subscribeParams(params []interface{}) (agent, session string) {
+	if len(params) > 0 {
+		agent, _ = params[0].(string)
+	}
+	if len(params) > 1 {
+		session, _ = params[1].(string)
+	}
+	return
+}
+
+//negotiate sets the compatibility mode of the connection from the user agent of the subscribe request
+func (c *ClientConnection) negotiate(agent string) {
+	mode := CompatStandard
+	if strings.TrimSpace(agent) == "" {
+		mode = CompatMinimal
+	}
+	client, version := parseUserAgent(agent)
+	c.server.clientconnectionmutex.Lock()
+	c.MinerVersion = agent
+	c.compatMode = mode
+	c.server.clientconnectionmutex.Unlock()
+	if client == "" {
+		client = "unknown"
+	}
+	log.Infoln("Stratum connection", c.id, "from", c.remoteIP, "detected client", client, version, "using", mode, "compatibility mode")
+}
+
+//CompatMode returns the negotiated compatibility mode of the connection, before the subscribe it is CompatStandard
+func (c *ClientConnection) CompatMode() CompatMode {
+	c.server.clientconnectionmutex.Lock()
+	defer c.server.clientconnectionmutex.Unlock()
+	return c.compatMode
+}
+
+//MiningExtranonceSubscribeHandler handles the mining.extranonce.subscribe request some miners send after subscribing.
+// The extranonce1 of a connection never changes, so the request is acknowledged without ever sending mining.set_extranonce.
+func (c *ClientConnection) MiningExtranonceSubscribeHandler(m message) {
+	c.server.clientconnectionmutex.Lock()
+	c.extranonceSubscribed = true
+	c.server.clientconnectionmutex.Unlock()
+	if err := c.Reply(m.ID, true, nil); err != nil {
+		c.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	for _, test := range []struct {
+		agent, client, version string
+	}{
+		{agent: "sgminer/5.5.4", client: "sgminer", version: "5.5.4"},
+		{agent: "cgminer 4.9.2", client: "cgminer", version: "4.9.2"},
+		{agent: "gominer", client: "gominer"},
+		{agent: "", client: ""},
+	} {
+		if client, version := parseUserAgent(test.agent); client != test.client || version != test.version {
+			t.Error("Expected", test.client, test.version, "for", test.agent, "got", client, version)
+		}
+	}
+}
+
+// handshake runs the requests of a miner in order on a new connection and
+// returns all messages the miner received until the reply to the last request.
+func handshake(t *testing.T, server *Server, requests ...message) (c *ClientConnection, received []message) {
+	messages, respond := clientMessages()
+	c = newTestConnection(server, respond)
+	server.connections = append(server.connections, c)
+	go func() {
+		for _, m := range requests {
+			c.dispatch(m)
+		}
+	}()
+	last := requests[len(requests)-1].ID
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case m := <-messages:
+			received = append(received, m)
+			if m.ID == last && m.Method == "" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("No reply to", requests[len(requests)-1].Method)
+		}
+	}
+}
+
+func TestMinerHandshakes(t *testing.T) {
+	for _, test := range []struct {
+		miner                string
+		requests             []message
+		mode                 CompatMode
+		extranonceSubscribed bool
+	}{
+		{
+			// sgminer style: a user agent and an extranonce subscription before authorizing
+			miner: "sgminer",
+			requests: []message{
+				{ID: 1, Method: "mining.subscribe", Params: []interface{}{"sgminer/5.5.4"}},
+				{ID: 2, Method: "mining.extranonce.subscribe", Params: []interface{}{}},
+				{ID: 3, Method: "mining.authorize", Params: []interface{}{"miner.rig1", "x"}},
+			},
+			mode:                 CompatStandard,
+			extranonceSubscribed: true,
+		},
+		{
+			// NiceHash style: null session placeholder followed by the host and port
+			miner: "nicehash",
+			requests: []message{
+				{ID: 1, Method: "mining.subscribe", Params: []interface{}{"NiceHash/1.0.0", nil, "pool.example.com", float64(3333)}},
+				{ID: 2, Method: "mining.authorize", Params: []interface{}{"miner.rig2", "x"}},
+				{ID: 3, Method: "mining.extranonce.subscribe"},
+			},
+			mode:                 CompatStandard,
+			extranonceSubscribed: true,
+		},
+		{
+			// gominer style: a bare subscribe without params
+			miner: "gominer",
+			requests: []message{
+				{ID: 1, Method: "mining.subscribe"},
+				{ID: 2, Method: "mining.authorize", Params: []interface{}{"miner.rig3"}},
+			},
+			mode: CompatMinimal,
+		},
+	} {
+		server := &Server{difficulty: 1}
+		server.SetMOTD("Welcome")
+		c, received := handshake(t, server, test.requests...)
+		motd := false
+		for _, m := range received {
+			if m.Error != nil {
+				t.Error(test.miner, "got an error reply", m)
+			}
+			if m.Method == "client.show_message" {
+				motd = true
+			}
+		}
+		if expected := test.mode == CompatStandard; motd != expected {
+			t.Error(test.miner, "expected the motd", expected, "got", motd)
+		}
+		info, ok := server.Connection(c.id)
+		if !ok {
+			t.Fatal("Connection of", test.miner, "not found")
+		}
+		if info.CompatMode != test.mode || info.ExtranonceSubscribed != test.extranonceSubscribed {
+			t.Error(test.miner, "expected mode", test.mode, "and extranonce subscription", test.extranonceSubscribed, "got", info)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package stratum
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+//ConnectionInfo is a snapshot of an open client connection
+type ConnectionInfo struct {
+	ID           uint64 `json:"id"`
+	User         string `json:"user"`
+	MinerVersion string `json:"minerversion"`
+	//CompatMode is the negotiated stratum dialect and ExtranonceSubscribed if the miner sent mining.extranonce.subscribe
+	CompatMode           CompatMode `json:"compatmode"`
+	ExtranonceSubscribed bool       `json:"extranoncesubscribed"`
+	RemoteIP             string     `json:"remoteip"`
+	Extranonce1          string     `json:"extranonce1"`
+	Difficulty           float64    `json:"difficulty"`
+	//Connected is the unix time the connection was accepted and Age the seconds since then
+	Connected int64   `json:"connected"`
+	Age       float64 `json:"age"`
+	//LastActivity is the unix time the last message of the client was received
+	LastActivity int64 `json:"lastactivity"`
+}
+
+//info returns the snapshot of the connection, the caller needs to hold the clientconnectionmutex
+func (c *ClientConnection) info(now time.Time) ConnectionInfo {
+	return ConnectionInfo{
+		ID:                   c.id,
+		User:                 c.User,
+		MinerVersion:         c.MinerVersion,
+		CompatMode:           c.compatMode,
+		ExtranonceSubscribed: c.extranonceSubscribed,
+		RemoteIP:             c.remoteIP,
+		Extranonce1:          hex.EncodeToString(c.extranonce1),
+		Difficulty:           c.Difficulty(),
+		Connected:            c.connected.Unix(),
+		Age:                  now.Sub(c.connected).Seconds(),
+		LastActivity:         c.lastActive().Unix(),
+	}
+}
+
+//isClosed returns if the connection is closed, it might not be removed from the server yet
+func (c *ClientConnection) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+//Connections returns the snapshots of the open client connections
+func (server *Server) Connections() (connections []ConnectionInfo) {
+	now := time.Now()
+	server.clientconnectionmutex.Lock()
+	defer server.clientconnectionmutex.Unlock()
+	connections = make([]ConnectionInfo, 0, len(server.connections))
+	for _, c := range server.connections {
+		if !c.isClosed() {
+			connections = append(connections, c.info(now))
+		}
+	}
+	return
+}
+
+//Connection returns the snapshot of the open client connection with the given id,
+// ok is false if there is no such connection or if it is closed
+func (server *Server) Connection(id uint64) (info ConnectionInfo, ok bool) {
+	server.clientconnectionmutex.Lock()
+	defer server.clientconnectionmutex.Unlock()
+	for _, c := range server.connections {
+		if c.id == id && !c.isClosed() {
+			return c.info(time.Now()), true
+		}
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package stratum
+
+import "testing"
+
+func TestConnection(t *testing.T) {
+	server := &Server{difficulty: 4}
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	server.connections = append(server.connections, c)
+	go c.MiningAuthorizeHandler(message{ID: 1, Method: "mining.authorize", Params: []interface{}{"miner.rig1"}})
+	nextNotification(t, messages, "mining.set_difficulty")
+
+	info, ok := server.Connection(c.id)
+	if !ok {
+		t.Fatal("Open connection not found")
+	}
+	if info.ID != c.id || info.User != "miner.rig1" || info.Difficulty != 4 || len(info.Extranonce1) != 2*len(c.extranonce1) {
+		t.Error("Unexpected connection info", info)
+	}
+	if connections := server.Connections(); len(connections) != 1 || connections[0].ID != c.id {
+		t.Error("Expected the open connection, got", connections)
+	}
+	if _, ok := server.Connection(c.id + 1); ok {
+		t.Error("Unknown connection found")
+	}
+
+	c.Close()
+	if _, ok := server.Connection(c.id); ok {
+		t.Error("Closed connection found")
+	}
+}
@@ -0,0 +1,104 @@
+package stratum
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//DifficultyClassificationPeriod is the time after connecting during which the difficulty of a connection is
+	// raised to the band of the observed hashrate of its miner address
+	DifficultyClassificationPeriod = 10 * time.Minute
+	//difficultyClassificationWindow is the span of the hashrate history the bands are applied to
+	difficultyClassificationWindow = 10 * time.Minute
+)
+
+var errDifficultyBandFormat = errors.New("invalid difficulty band, use <hashrate>:<difficulty> with positive numbers, for example 1e12:50000")
+
+//DifficultyBand is the minimum difficulty of the connections of miner addresses with at least MinHashrate hashes per second
+type DifficultyBand struct {
+	MinHashrate   float64
+	MinDifficulty float64
+}
+
+//ParseDifficultyBand parses a difficulty band given as <hashrate>:<difficulty>
+func ParseDifficultyBand(s string) (band DifficultyBand, err error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return band, errDifficultyBandFormat
+	}
+	for i, value := range []*float64{&band.MinHashrate, &band.MinDifficulty} {
+		if *value, err = strconv.ParseFloat(parts[i], 64); err != nil || !(*value > 0) || math.IsInf(*value, 0) {
+			return DifficultyBand{}, errDifficultyBandFormat
+		}
+	}
+	return
+}
+
+//bandDifficulty returns the minimum difficulty of the highest band the hashrate reaches, 0 if it reaches none
+func bandDifficulty(bands []DifficultyBand, hashrate float64) (difficulty float64) {
+	var reached float64
+	for _, band := range bands {
+		if hashrate >= band.MinHashrate && band.MinHashrate > reached {
+			reached, difficulty = band.MinHashrate, band.MinDifficulty
+		}
+	}
+	return
+}
+
+// observedHashrate returns the average hashrate of a miner address over the
+// difficultyClassificationWindow, 0 if the address has no history.
+func (server *Server) observedHashrate(address string, now time.Time) float64 {
+	server.historyMutex.Lock()
+	samples := server.minerHistory[address].since(now, difficultyClassificationWindow)
+	server.historyMutex.Unlock()
+	if len(samples) == 0 {
+		return 0
+	}
+	var total float64
+	for _, s := range samples {
+		total += s.Hashrate
+	}
+	return total / float64(len(samples))
+}
+
+// classifyDifficulty raises the difficulty of an authorized connection to the
+// band of the observed hashrate of its miner address, it returns true if the
+// difficulty changed. The hashrate is the one of the address, all its rigs
+// together. A higher difficulty, for example a suggestion of the miner, is
+// kept and a FixedDifficulty is never changed.
+func (server *Server) classifyDifficulty(c *ClientConnection, now time.Time) bool {
+	if len(server.DifficultyBands) == 0 || server.FixedDifficulty > 0 || c.User == "" {
+		return false
+	}
+	minimum := bandDifficulty(server.DifficultyBands, server.observedHashrate(addressOf(c.User), now))
+	if minimum = server.capDifficulty(minimum); minimum <= c.Difficulty() {
+		return false
+	}
+	c.setDifficulty(minimum)
+	return true
+}
+
+// classifyConnections applies the difficulty bands to the connections that
+// connected within the DifficultyClassificationPeriod, the subscribed miners
+// are sent their new difficulty.
+func (server *Server) classifyConnections(now time.Time) {
+	if len(server.DifficultyBands) == 0 {
+		return
+	}
+	server.clientconnectionmutex.Lock()
+	var changed []*ClientConnection
+	for _, c := range server.connections {
+		if now.Sub(c.connected) <= DifficultyClassificationPeriod && server.classifyDifficulty(c, now) && c.subscribed {
+			changed = append(changed, c)
+		}
+	}
+	server.clientconnectionmutex.Unlock()
+	// A slow miner should not delay the others.
+	for _, c := range changed {
+		go c.SendDifficulty()
+	}
+}
@@ -0,0 +1,65 @@
+package stratum
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseDifficultyBand(t *testing.T) {
+	band, err := ParseDifficultyBand("1e12:50000")
+	if err != nil || band != (DifficultyBand{MinHashrate: 1e12, MinDifficulty: 50000}) {
+		t.Error("Unexpected band", band, err)
+	}
+	for _, s := range []string{"", "1e12", "1e12:", ":50000", "0:50000", "1e12:-1", "NaN:1", "1e12:Inf", "1:2:3"} {
+		if _, err := ParseDifficultyBand(s); err == nil {
+			t.Error("Invalid band accepted:", s)
+		}
+	}
+}
+
+func TestDifficultyBands(t *testing.T) {
+	bands := []DifficultyBand{{MinHashrate: 1e12, MinDifficulty: 10000}, {MinHashrate: 1e9, MinDifficulty: 100}}
+	for hashrate, expected := range map[float64]float64{0: 0, 1e8: 0, 1e9: 100, 5e11: 100, 1e12: 10000, 1e15: 10000} {
+		if d := bandDifficulty(bands, hashrate); d != expected {
+			t.Error("Expected difficulty", expected, "for hashrate", hashrate, "got", d)
+		}
+	}
+
+	server := &Server{difficulty: 1, StartDifficulty: 8, SampleInterval: time.Minute, HistoryLength: 10, DifficultyBands: bands}
+	// The address mined about 1.4 Th/s, the other one nothing.
+	server.recordShare("big.rig1", 20000)
+	now := time.Now()
+	server.takeSample(now)
+
+	messages, respond := clientMessages()
+	ramping := newTestConnection(server, respond)
+	defer ramping.Close()
+	ramping.User, ramping.subscribed = "big.rig2", true
+	old := newTestConnection(server, func(net.Conn, message) {})
+	defer old.Close()
+	old.User, old.connected = "big.rig3", now.Add(-DifficultyClassificationPeriod-time.Second)
+	small := newTestConnection(server, func(net.Conn, message) {})
+	defer small.Close()
+	small.User = "small"
+	server.connections = append(server.connections, ramping, old, small)
+
+	server.classifyConnections(now)
+	m := nextNotification(t, messages, "mining.set_difficulty")
+	if len(m.Params) != 1 || m.Params[0] != float64(10000) {
+		t.Error("Expected difficulty 10000, got", m.Params)
+	}
+	if ramping.Difficulty() != 10000 || old.Difficulty() != 8 || small.Difficulty() != 8 {
+		t.Error("Unexpected difficulties", ramping.Difficulty(), old.Difficulty(), small.Difficulty())
+	}
+
+	// A higher suggestion is kept, a fixed difficulty never changes.
+	ramping.setDifficulty(20000)
+	if server.classifyDifficulty(ramping, now) || ramping.Difficulty() != 20000 {
+		t.Error("Band lowered the difficulty to", ramping.Difficulty())
+	}
+	server.FixedDifficulty = 32
+	if server.classifyDifficulty(old, now) {
+		t.Error("Band changed a fixed difficulty")
+	}
+}
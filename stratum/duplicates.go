@@ -0,0 +1,64 @@
+package stratum
+
+import (
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+//DuplicateWorkerPolicy decides what happens when a worker name is authorized on more than one connection,
+// this is often a misconfigured rig. Workers with distinct names are never affected.
+type DuplicateWorkerPolicy string
+
+const (
+	//DuplicateWorkersMerge lets the connections share the statistics of the worker
+	DuplicateWorkersMerge DuplicateWorkerPolicy = "merge"
+	//DuplicateWorkersRename authorizes the new connection with a numbered worker name, for example address.rig1-2
+	DuplicateWorkersRename DuplicateWorkerPolicy = "rename"
+	//DuplicateWorkersReject refuses a worker name that is already connected
+	DuplicateWorkersReject DuplicateWorkerPolicy = "reject"
+)
+
+//workerInUse returns if another open connection than c is authorized as worker,
+// the caller needs to hold the clientconnectionmutex
+func (server *Server) workerInUse(c *ClientConnection, worker string) bool {
+	for _, other := range server.connections {
+		if other != c && other.User == worker && !other.isClosed() {
+			return true
+		}
+	}
+	return false
+}
+
+//renamedWorker returns the n-th name of a duplicate worker, the number is added to the rigname
+func renamedWorker(user string, n int) string {
+	if !strings.Contains(user, ".") {
+		return user + "." + strconv.Itoa(n)
+	}
+	return user + "-" + strconv.Itoa(n)
+}
+
+//claimWorker authorizes the connection as user, a worker name that is already connected is handled according to
+// the DuplicateWorkers policy. It returns the worker name the connection is authorized as, ok is false if it is rejected.
+func (server *Server) claimWorker(c *ClientConnection, user string) (worker string, ok bool) {
+	server.clientconnectionmutex.Lock()
+	defer server.clientconnectionmutex.Unlock()
+	worker = user
+	if server.workerInUse(c, user) {
+		switch server.DuplicateWorkers {
+		case DuplicateWorkersReject:
+			log.Warnln("Worker", user, "is already connected, rejecting the connection from", c.remoteIP)
+			return "", false
+		case DuplicateWorkersRename:
+			for n := 2; server.workerInUse(c, worker); n++ {
+				worker = renamedWorker(user, n)
+			}
+			log.Warnln("Worker", user, "is already connected, authorizing the connection from", c.remoteIP, "as", worker)
+		default:
+			log.Warnln("Worker", user, "is connected more than once, the connections share its statistics")
+		}
+	}
+	c.User = worker
+	return worker, true
+}
@@ -0,0 +1,56 @@
+package stratum
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDuplicateWorkers(t *testing.T) {
+	for _, test := range []struct {
+		policy   DuplicateWorkerPolicy
+		user     string
+		expected string
+		ok       bool
+	}{
+		{policy: "", user: "miner.rig1", expected: "miner.rig1", ok: true},
+		{policy: DuplicateWorkersMerge, user: "miner.rig1", expected: "miner.rig1", ok: true},
+		{policy: DuplicateWorkersRename, user: "miner.rig1", expected: "miner.rig1-3", ok: true},
+		{policy: DuplicateWorkersRename, user: "miner", expected: "miner.2", ok: true},
+		{policy: DuplicateWorkersReject, user: "miner.rig1", ok: false},
+		{policy: DuplicateWorkersReject, user: "miner.rig2", expected: "miner.rig2", ok: true},
+	} {
+		server := &Server{DuplicateWorkers: test.policy}
+		for _, user := range []string{"miner.rig1", "miner.rig1-2", "miner"} {
+			existing := newTestConnection(server, func(net.Conn, message) {})
+			existing.User = user
+			server.connections = append(server.connections, existing)
+			defer existing.Close()
+		}
+		c := newTestConnection(server, func(net.Conn, message) {})
+		worker, ok := server.claimWorker(c, test.user)
+		if ok != test.ok || worker != test.expected {
+			t.Error("Policy", test.policy, "for", test.user, "expected", test.expected, test.ok, "got", worker, ok)
+		}
+		if ok && c.User != worker {
+			t.Error("Connection not authorized as", worker, "got", c.User)
+		}
+		c.Close()
+	}
+}
+
+func TestReconnectedWorkerNotDuplicate(t *testing.T) {
+	server := &Server{DuplicateWorkers: DuplicateWorkersReject}
+	closed := newTestConnection(server, func(net.Conn, message) {})
+	closed.User = "miner.rig1"
+	server.connections = append(server.connections, closed)
+	closed.Close()
+
+	c := newTestConnection(server, func(net.Conn, message) {})
+	defer c.Close()
+	if worker, ok := server.claimWorker(c, "miner.rig1"); !ok || worker != "miner.rig1" {
+		t.Error("Worker of a closed connection rejected")
+	}
+	if worker, ok := server.claimWorker(c, "miner.rig1"); !ok || worker != "miner.rig1" {
+		t.Error("Connection rejected as duplicate of itself")
+	}
+}
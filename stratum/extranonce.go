@@ -0,0 +1,89 @@
+package stratum
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// extraNonce1Size is the number of bytes of the extranonce1 assigned to a
+// client connection
+const extraNonce1Size = 4
+
+// sessionKeySize is the number of random bytes of a new session key
+const sessionKeySize = 8
+
+// deriveExtraNonce1 returns the extranonce1 for a session key. The derivation
+// is keyed with a secret of the server so miners can not pick the extranonce1
+// of another miner by choosing its session key.
+func deriveExtraNonce1(secret []byte, session string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(session))
+	return mac.Sum(nil)[:extraNonce1Size]
+}
+
+// sessionSecret returns the secret used to derive the extranonce1 of a
+// session, it is created on first use. The suffix is only stable for the
+// lifetime of the server, a restarted node hands out new extranonces. The
+// caller needs to hold the clientconnectionmutex.
+func (server *Server) sessionSecret() []byte {
+	if server.extraNonceSecret == nil {
+		server.extraNonceSecret, _ = generateRandomBytes(32)
+	}
+	return server.extraNonceSecret
+}
+
+// extraNonce1InUse returns if the extranonce1 is assigned to a connection
+// other than c. The caller needs to hold the clientconnectionmutex.
+func (server *Server) extraNonce1InUse(extraNonce1 []byte, c *ClientConnection) bool {
+	for _, other := range server.connections {
+		if other != c && bytes.Equal(other.extranonce1, extraNonce1) {
+			return true
+		}
+	}
+	return false
+}
+
+// newSession creates a random session key of which the extranonce1 is not
+// used by any connection. The caller needs to hold the clientconnectionmutex.
+func (server *Server) newSession() (session string, extraNonce1 []byte) {
+	for {
+		key, _ := generateRandomBytes(sessionKeySize)
+		session = hex.EncodeToString(key)
+		extraNonce1 = deriveExtraNonce1(server.sessionSecret(), session)
+		if !server.extraNonce1InUse(extraNonce1, nil) {
+			return
+		}
+	}
+}
+
+//resumeSession assigns the extranonce1 of an earlier session of the miner to the connection.
+// The extranonce1 is derived from the session key, so a miner that reconnects with the session id it received
+// on subscribe gets the same extranonce1 again as long as the server is running.
+// Two live connections never share an extranonce1: if the derived extranonce1 is in use by another connection,
+// the session is not resumed and the connection keeps its own session.
+func (c *ClientConnection) resumeSession(session string) bool {
+	server := c.server
+	server.clientconnectionmutex.Lock()
+	defer server.clientconnectionmutex.Unlock()
+	if session == "" || session == c.session {
+		return session != ""
+	}
+	extraNonce1 := deriveExtraNonce1(server.sessionSecret(), session)
+	if server.extraNonce1InUse(extraNonce1, c) {
+		return false
+	}
+	c.session = session
+	c.extranonce1 = extraNonce1
+	return true
+}
+
+// subscription marks the connection as subscribed and returns its session key
+// and extranonce1.
+func (c *ClientConnection) subscription() (session string, extraNonce1 []byte) {
+	c.server.clientconnectionmutex.Lock()
+	defer c.server.clientconnectionmutex.Unlock()
+	c.subscribed = true
+	return c.session, c.extranonce1
+}
@@ -0,0 +1,47 @@
+package stratum
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExtraNonce1Unique(t *testing.T) {
+	server := &Server{}
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		c := server.NewClientConnection(nil)
+		server.connections = append(server.connections, c)
+		if seen[string(c.extranonce1)] {
+			t.Fatal("Two connections share extranonce1", c.extranonce1)
+		}
+		seen[string(c.extranonce1)] = true
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	server := &Server{}
+	first := server.NewClientConnection(nil)
+	server.connections = append(server.connections, first)
+	session, extranonce1 := first.subscription()
+
+	// A concurrent miner can not take over the extranonce1 of a live session.
+	other := server.NewClientConnection(nil)
+	server.connections = append(server.connections, other)
+	if other.resumeSession(session) {
+		t.Error("Session resumed while it is in use by another connection")
+	}
+	if bytes.Equal(other.extranonce1, extranonce1) {
+		t.Error("Two live connections share an extranonce1")
+	}
+
+	// After a disconnect the miner gets the same extranonce1 again.
+	server.removeConnection(first)
+	reconnected := server.NewClientConnection(nil)
+	server.connections = append(server.connections, reconnected)
+	if !reconnected.resumeSession(session) {
+		t.Fatal("Session not resumed after a reconnect")
+	}
+	if _, resumed := reconnected.subscription(); !bytes.Equal(resumed, extranonce1) {
+		t.Error("Expected extranonce1", extranonce1, "after a reconnect, got", resumed)
+	}
+}
@@ -1,27 +1,47 @@
 package stratum
 
-import "encoding/hex"
+import (
+	"encoding/hex"
+	"math"
+	"time"
 
-//MiningSubscribeHandler handles the mining.subscribe request
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/siapool/p2pool/pow"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+//MiningSubscribeHandler handles the mining.subscribe request.
+// A miner can pass the session id it received on an earlier subscribe as second parameter to get the same extranonce1 again.
+// The compatibility mode of the connection is negotiated from the user agent in the first parameter.
 func (c *ClientConnection) MiningSubscribeHandler(m message) {
-	if m.Params != nil && len(m.Params) > 0 {
-		c.MinerVersion, _ = m.Params[0].(string)
+	agent, session := subscribeParams(m.Params)
+	c.negotiate(agent)
+	if session != "" {
+		c.resumeSession(session)
 	}
+	session, extranonce1 := c.subscription()
 	err := c.Reply(
 		m.ID,
 		[]interface{}{
 			[]interface{}{
-				[]interface{}{"mining.set_difficulty", "b4b6693b72a50c7116db18d6497cac52"},
-				[]interface{}{"mining.notify", "ae6812eb4cd7735a302a8a9dd95cf71f"},
+				[]interface{}{"mining.set_difficulty", session},
+				[]interface{}{"mining.notify", session},
 			},
-			hex.EncodeToString(c.extranonce1),
-			4,
+			hex.EncodeToString(extranonce1),
+			extraNonce2Size,
 		},
 		nil)
 	if err != nil {
 		c.Close()
 		return
 	}
+	c.SendDifficulty()
+	c.SendMOTD()
+	if c.User != "" {
+		c.SendJob()
+	}
 }
 
 //MiningAuthorizeHandler handles the mining.authorize request
@@ -35,8 +55,31 @@ func (c *ClientConnection) MiningAuthorizeHandler(m message) {
 		c.sendErrorAndClose(m.ID, "Invalid mining address")
 		return
 	}
-	//TODO: validate the supplied address.rigname
-	c.User = user
+	if c.server.shareChain != nil {
+		if _, err := sharechain.MinerAddress(user); err != nil {
+			c.sendErrorAndClose(m.ID, "Invalid mining address")
+			return
+		}
+	}
+	if c.server.RequireAuthorization {
+		if reason := c.server.checkAuthorization(user); reason != "" {
+			c.sendErrorAndClose(m.ID, reason)
+			return
+		}
+	}
+	previous := c.User
+	worker, ok := c.server.claimWorker(c, user)
+	if !ok {
+		c.sendErrorAndClose(m.ID, "Worker already connected")
+		return
+	}
+	if worker != previous {
+		if previous != "" {
+			c.server.getMinerStats(previous).disconnect(time.Now())
+		}
+		c.server.getMinerStats(worker).connect()
+	}
+	c.server.classifyDifficulty(c, time.Now())
 
 	err := c.Reply(m.ID, true, nil)
 	if err != nil {
@@ -44,6 +87,77 @@ func (c *ClientConnection) MiningAuthorizeHandler(m message) {
 		return
 	}
 	c.SendDifficulty()
+	c.server.clientconnectionmutex.Lock()
+	subscribed := c.subscribed
+	c.server.clientconnectionmutex.Unlock()
+	if subscribed {
+		c.SendJob()
+	}
+}
+
+//checkAuthorization returns why the address of a user is not allowed to mine, or an empty string if it is authorized
+func (server *Server) checkAuthorization(user string) (reason string) {
+	address, err := sharechain.MinerAddress(user)
+	if err != nil {
+		return "Invalid mining address"
+	}
+	authorized, err := server.shareChain.IsAuthorized(address)
+	if err != nil {
+		log.Errorln("Error checking the authorization of", user, ":", err)
+		return "Authorization unavailable"
+	}
+	if !authorized {
+		return "Mining address not authorized"
+	}
+	return ""
+}
+
+//MiningSuggestDifficultyHandler handles the mining.suggest_difficulty request.
+// The suggested difficulty is only a hint, it is clamped between the difficulty of the sharechain and the MaxDifficulty
+// and a difficulty set by the server afterwards replaces it. Suggestions that are not a positive number are ignored,
+// as are all suggestions when the server has a FixedDifficulty.
+func (c *ClientConnection) MiningSuggestDifficultyHandler(m message) {
+	var suggested float64
+	if len(m.Params) > 0 {
+		suggested, _ = m.Params[0].(float64)
+	}
+	if c.server.FixedDifficulty > 0 {
+		log.Debugln("Ignoring difficulty suggestion", m.Params, "of stratum client", c.User, "with a fixed difficulty")
+		if err := c.Reply(m.ID, false, nil); err != nil {
+			c.Close()
+		}
+		return
+	}
+	difficulty, ok := c.server.clampDifficulty(suggested)
+	if !ok {
+		log.Infoln("Ignoring invalid difficulty suggestion", m.Params, "of stratum client", c.User)
+		if err := c.Reply(m.ID, false, nil); err != nil {
+			c.Close()
+		}
+		return
+	}
+	c.setDifficulty(difficulty)
+	if err := c.Reply(m.ID, true, nil); err != nil {
+		c.Close()
+		return
+	}
+	c.SendDifficulty()
+}
+
+//clampDifficulty limits a suggested difficulty to the difficulty of the sharechain, the MaxDifficulty and the
+// MaxShareDifficulty, it returns false if the suggestion is not a positive number.
+func (server *Server) clampDifficulty(suggested float64) (difficulty float64, ok bool) {
+	if suggested <= 0 || math.IsNaN(suggested) || math.IsInf(suggested, 0) {
+		return 0, false
+	}
+	difficulty = suggested
+	if minimum := server.minimumDifficulty(); difficulty < minimum {
+		difficulty = minimum
+	}
+	if server.MaxDifficulty > 0 && difficulty > server.MaxDifficulty {
+		difficulty = server.MaxDifficulty
+	}
+	return server.capDifficulty(difficulty), true
 }
 
 func (c *ClientConnection) sendErrorAndClose(ID uint64, errormessage string) {
@@ -51,9 +165,27 @@ func (c *ClientConnection) sendErrorAndClose(ID uint64, errormessage string) {
 	c.Close()
 }
 
+//Difficulty returns the current difficulty of the connection
+func (c *ClientConnection) Difficulty() float64 {
+	c.difficultyMutex.Lock()
+	defer c.difficultyMutex.Unlock()
+	return c.difficulty
+}
+
+//Target returns the target a share of the connection has to meet, derived from the current difficulty
+func (c *ClientConnection) Target() types.Target {
+	return pow.TargetFromDifficulty(c.Difficulty())
+}
+
+func (c *ClientConnection) setDifficulty(difficulty float64) {
+	c.difficultyMutex.Lock()
+	defer c.difficultyMutex.Unlock()
+	c.difficulty = difficulty
+}
+
 //SendDifficulty sends the current difficulty to the miner
 func (c *ClientConnection) SendDifficulty() {
-	err := c.Notify("mining.set_difficulty", []interface{}{c.server.difficulty})
+	err := c.Notify("mining.set_difficulty", []interface{}{c.Difficulty()})
 	if err != nil {
 		c.Close()
 	}
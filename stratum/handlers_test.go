@@ -1 +1,289 @@
 package stratum
+
+import (
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/pow"
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/siad"
+)
+
+// clientMessages returns a respond function for newTestConnection that passes
+// all received messages on the returned channel.
+func clientMessages() (chan message, func(net.Conn, message)) {
+	messages := make(chan message, 10)
+	return messages, func(clientSide net.Conn, m message) {
+		messages <- m
+	}
+}
+
+func nextNotification(t *testing.T, messages chan message, method string) message {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case m := <-messages:
+			if m.Method == method {
+				return m
+			}
+		case <-timeout:
+			t.Fatal("No", method, "received")
+		}
+	}
+}
+
+func TestStartDifficultySentAfterSubscribe(t *testing.T) {
+	for _, test := range []struct {
+		minimum, start, expected float64
+	}{
+		{minimum: 2, start: 0, expected: 2},
+		{minimum: 2, start: 1, expected: 2},
+		{minimum: 2, start: 64, expected: 64},
+	} {
+		server := &Server{difficulty: test.minimum, StartDifficulty: test.start}
+		messages, respond := clientMessages()
+		c := newTestConnection(server, respond)
+		go c.MiningSubscribeHandler(message{ID: 1, Method: "mining.subscribe"})
+
+		m := nextNotification(t, messages, "mining.set_difficulty")
+		if len(m.Params) != 1 || m.Params[0] != test.expected {
+			t.Error("Expected difficulty", test.expected, "got", m.Params)
+		}
+		c.Close()
+	}
+}
+
+func TestStartDifficultyPerConnection(t *testing.T) {
+	server := &Server{difficulty: 1, StartDifficulty: 8}
+	first := server.NewClientConnection(nil)
+	first.difficulty = 16
+	second := server.NewClientConnection(nil)
+	if d := second.Difficulty(); d != 8 {
+		t.Error("Expected start difficulty 8 for a new connection, got", d)
+	}
+	if d := first.Difficulty(); d != 16 {
+		t.Error("Expected difficulty 16 for the first connection, got", d)
+	}
+}
+
+func TestSuggestDifficulty(t *testing.T) {
+	for _, test := range []struct {
+		suggested interface{}
+		accepted  bool
+		expected  float64
+	}{
+		{suggested: float64(32), accepted: true, expected: 32},
+		// A miner claiming a tiny hashrate to flood the pool with shares gets the minimum.
+		{suggested: 0.001, accepted: true, expected: 2},
+		// A miner claiming an absurd hashrate is limited to the maximum.
+		{suggested: 1e30, accepted: true, expected: 1024},
+		{suggested: float64(-1), accepted: false, expected: 8},
+		{suggested: "64", accepted: false, expected: 8},
+	} {
+		server := &Server{difficulty: 2, StartDifficulty: 8, MaxDifficulty: 1024}
+		messages, respond := clientMessages()
+		c := newTestConnection(server, respond)
+		c.difficulty = server.startDifficulty()
+		go c.MiningSuggestDifficultyHandler(message{ID: 1, Method: "mining.suggest_difficulty", Params: []interface{}{test.suggested}})
+
+		timeout := time.After(time.Second)
+		var reply message
+		for reply.ID != 1 {
+			select {
+			case reply = <-messages:
+			case <-timeout:
+				t.Fatal("No reply to mining.suggest_difficulty")
+			}
+		}
+		if reply.Result != test.accepted {
+			t.Error("Expected", test.accepted, "for suggestion", test.suggested, "got", reply.Result)
+		}
+		if test.accepted {
+			m := nextNotification(t, messages, "mining.set_difficulty")
+			if len(m.Params) != 1 || m.Params[0] != test.expected {
+				t.Error("Expected difficulty", test.expected, "for suggestion", test.suggested, "got", m.Params)
+			}
+		}
+		if d := c.Difficulty(); d != test.expected {
+			t.Error("Expected difficulty", test.expected, "for suggestion", test.suggested, "got", d)
+		}
+		if target := c.Target(); target != pow.TargetFromDifficulty(test.expected) {
+			t.Error("Expected the target of difficulty", test.expected, "got", target)
+		}
+		c.Close()
+	}
+}
+
+func TestRequireAuthorization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stratum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sc, err := sharechain.New(siad.NewMock(), dir, sharechain.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	authorized, other := types.UnlockHash{1}, types.UnlockHash{2}
+	if err = sc.AuthorizeAddress(authorized); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		require  bool
+		user     string
+		accepted bool
+	}{
+		{require: true, user: authorized.String() + ".rig1", accepted: true},
+		{require: true, user: other.String(), accepted: false},
+		{require: true, user: "invalid", accepted: false},
+		{require: false, user: other.String(), accepted: true},
+	} {
+		server := &Server{shareChain: sc, RequireAuthorization: test.require}
+		messages, respond := clientMessages()
+		c := newTestConnection(server, respond)
+		go c.MiningAuthorizeHandler(message{ID: 1, Method: "mining.authorize", Params: []interface{}{test.user}})
+
+		var reply message
+		select {
+		case reply = <-messages:
+		case <-time.After(time.Second):
+			t.Fatal("No reply to mining.authorize")
+		}
+		if accepted := reply.Result == true; accepted != test.accepted {
+			t.Error("Expected accepted", test.accepted, "for", test.user, "with authorization required", test.require, "got", reply)
+		}
+		c.Close()
+	}
+}
+
+func TestApplyMinimumDifficulty(t *testing.T) {
+	server := &Server{difficulty: 2}
+	messages, respond := clientMessages()
+	low := newTestConnection(server, respond)
+	low.subscribed = true
+	high := newTestConnection(server, func(net.Conn, message) {})
+	high.setDifficulty(64)
+	server.connections = append(server.connections, low, high)
+
+	server.difficulty = 16
+	server.ApplyMinimumDifficulty()
+	m := nextNotification(t, messages, "mining.set_difficulty")
+	if len(m.Params) != 1 || m.Params[0] != float64(16) {
+		t.Error("Expected difficulty 16, got", m.Params)
+	}
+	if low.Difficulty() != 16 || high.Difficulty() != 64 {
+		t.Error("Unexpected difficulties", low.Difficulty(), high.Difficulty())
+	}
+	low.Close()
+	high.Close()
+}
+
+func TestFixedDifficulty(t *testing.T) {
+	server := &Server{difficulty: 2, StartDifficulty: 8, FixedDifficulty: 32}
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	defer c.Close()
+	c.subscribed = true
+	server.connections = append(server.connections, c)
+	if d := c.Difficulty(); d != 32 {
+		t.Error("Expected the fixed difficulty 32 for a new connection, got", d)
+	}
+
+	go c.MiningSuggestDifficultyHandler(message{ID: 1, Method: "mining.suggest_difficulty", Params: []interface{}{float64(64)}})
+	select {
+	case reply := <-messages:
+		if reply.ID != 1 || reply.Result != false {
+			t.Error("Expected the suggestion to be refused, got", reply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No reply to mining.suggest_difficulty")
+	}
+	if d := c.Difficulty(); d != 32 {
+		t.Error("Suggestion changed the fixed difficulty to", d)
+	}
+
+	// A sharechain difficulty above the fixed difficulty raises all connections,
+	// they return to the fixed difficulty once it drops again.
+	for _, minimum := range []float64{64, 2} {
+		server.difficulty = minimum
+		server.ApplyMinimumDifficulty()
+		expected := math.Max(minimum, 32)
+		m := nextNotification(t, messages, "mining.set_difficulty")
+		if len(m.Params) != 1 || m.Params[0] != expected {
+			t.Error("Expected difficulty", expected, "got", m.Params)
+		}
+	}
+}
+
+func TestMaxShareDifficulty(t *testing.T) {
+	server := &Server{difficulty: 2, StartDifficulty: 1e6, MaxShareDifficulty: 1000}
+	c := server.NewClientConnection(nil)
+	if d := c.Difficulty(); d != 1000 {
+		t.Error("Expected the start difficulty to be capped at 1000, got", d)
+	}
+
+	// A miner of 1 Ph/s asking for a difficulty of a share per hour still
+	// finds a share every 4.3 seconds.
+	hashrate := 1e15
+	difficulty, ok := server.clampDifficulty(3600 * hashrate / hashesPerDifficulty)
+	if !ok || difficulty != 1000 {
+		t.Fatal("Expected the suggestion to be capped at 1000, got", difficulty, ok)
+	}
+	if interval := difficulty * hashesPerDifficulty / hashrate; interval > 5 {
+		t.Error("Expected a share at least every 5 seconds, got one every", interval)
+	}
+
+	server.FixedDifficulty = 4096
+	if d := server.startDifficulty(); d != 1000 {
+		t.Error("Expected the fixed difficulty to be capped at 1000, got", d)
+	}
+	// Shares below the sharechain difficulty are not valid, it wins over the cap.
+	server.difficulty = 2048
+	if d := server.startDifficulty(); d != 2048 {
+		t.Error("Expected the sharechain difficulty 2048 above the cap, got", d)
+	}
+	if d, _ := server.clampDifficulty(1e9); d != 2048 {
+		t.Error("Expected the sharechain difficulty 2048 for a capped suggestion, got", d)
+	}
+}
+
+func TestJobSentAfterAuthorize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stratum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sc, err := sharechain.New(siad.NewMock(), dir, sharechain.Config{FeeAddress: types.UnlockHash{9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	server := NewServer("", sc)
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	defer c.Close()
+	go func() {
+		c.dispatch(message{ID: 1, Method: "mining.subscribe"})
+		c.dispatch(message{ID: 2, Method: "mining.authorize", Params: []interface{}{types.UnlockHash{1}.String()}})
+	}()
+	m := nextNotification(t, messages, "mining.notify")
+	if len(m.Params) != 9 || m.Params[8] != true {
+		t.Fatal("Expected a clean job, got", m.Params)
+	}
+	id, template, ok := server.currentJobTemplate()
+	if !ok || m.Params[0] != id {
+		t.Fatal("Expected the current job", id, "got", m.Params[0])
+	}
+	job, found := c.job(id)
+	if !found || job.block.MinerPayouts[0].UnlockHash != (types.UnlockHash{1}) || job.template.Block.ID() != template.Block.ID() {
+		t.Error("The job does not pay the miner as the finder:", job)
+	}
+}
@@ -0,0 +1,38 @@
+package stratum
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+//ListenerFile returns a duplicate of the file descriptor of the listener, so it can be handed off to a new process.
+// The server keeps accepting connections on its own descriptor until it is closed.
+func (server *Server) ListenerFile() (*os.File, error) {
+	server.clientconnectionmutex.Lock()
+	lis := server.lis
+	server.clientconnectionmutex.Unlock()
+	filer, ok := lis.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, errors.New("stratum server is not listening on a tcp socket")
+	}
+	return filer.File()
+}
+
+//Drain stops accepting new connections and gives the open connections up to timeout to finish,
+// the connections that are still open afterwards are closed.
+func (server *Server) Drain(timeout time.Duration) {
+	server.Close()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && len(server.Connections()) > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+	server.clientconnectionmutex.Lock()
+	remaining := append([]*ClientConnection(nil), server.connections...)
+	server.clientconnectionmutex.Unlock()
+	for _, c := range remaining {
+		c.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package stratum
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandoffListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer("", nil)
+	server.AcceptInterval = 0
+	go server.Serve(lis)
+
+	client, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for i := 0; len(server.Connections()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("Connection not accepted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	f, err := server.ListenerFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handedOff, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handedOff.Close()
+
+	// The open connection is closed after the drain timeout, a new
+	// connection is queued on the handed off listener.
+	server.Drain(50 * time.Millisecond)
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = client.Read(make([]byte, 1)); err == nil {
+		t.Error("Connection still open after draining")
+	}
+	next, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal("Handed off listener does not accept connections:", err)
+	}
+	defer next.Close()
+	accepted, err := handedOff.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted.Close()
+}
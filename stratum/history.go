@@ -0,0 +1,159 @@
+package stratum
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	//DefaultSampleInterval is the default time between two hashrate samples
+	DefaultSampleInterval = time.Minute
+	//DefaultHistoryLength is the default number of hashrate samples kept, 24 hours at the default sample interval
+	DefaultHistoryLength = 24 * 60
+	//DefaultMaxMinerHistories is the default maximum number of miner addresses for which the hashrate history is kept
+	DefaultMaxMinerHistories = 10000
+	//maxHistoryPoints is the maximum number of samples returned, longer ranges are downsampled
+	maxHistoryPoints = 360
+	//hashesPerDifficulty is the expected number of hashes required to find a share of difficulty 1
+	hashesPerDifficulty = 1 << 32
+)
+
+//HashrateSample is the average hashrate over a sample interval ending at Timestamp
+type HashrateSample struct {
+	Timestamp int64   `json:"timestamp"`
+	Hashrate  float64 `json:"hashrate"`
+}
+
+// hashrateHistory accumulates the work of accepted shares and keeps a bounded
+// list of hashrate samples, oldest first.
+type hashrateHistory struct {
+	work      float64
+	lastShare time.Time
+	samples   []HashrateSample
+}
+
+func (h *hashrateHistory) sample(now time.Time, interval time.Duration, length int) {
+	h.samples = append(h.samples, HashrateSample{
+		Timestamp: now.Unix(),
+		Hashrate:  h.work * hashesPerDifficulty / interval.Seconds(),
+	})
+	if len(h.samples) > length {
+		h.samples = h.samples[len(h.samples)-length:]
+	}
+	h.work = 0
+}
+
+// since returns the samples within span before now, downsampled by averaging
+// consecutive samples if there are more than maxHistoryPoints.
+func (h *hashrateHistory) since(now time.Time, span time.Duration) (samples []HashrateSample) {
+	samples = make([]HashrateSample, 0)
+	if h == nil {
+		return
+	}
+	from := now.Add(-span).Unix()
+	start := len(h.samples)
+	for start > 0 && h.samples[start-1].Timestamp > from {
+		start--
+	}
+	selected := h.samples[start:]
+	bucketSize := (len(selected) + maxHistoryPoints - 1) / maxHistoryPoints
+	for bucketSize > 0 && len(selected) > 0 {
+		n := bucketSize
+		if n > len(selected) {
+			n = len(selected)
+		}
+		var total float64
+		for _, s := range selected[:n] {
+			total += s.Hashrate
+		}
+		samples = append(samples, HashrateSample{Timestamp: selected[n-1].Timestamp, Hashrate: total / float64(n)})
+		selected = selected[n:]
+	}
+	return
+}
+
+//addressOf strips the optional rigname from the user a miner authorized with
+func addressOf(user string) string {
+	return strings.SplitN(user, ".", 2)[0]
+}
+
+//recordShare adds the work of an accepted share to the pool and miner hashrate history
+func (server *Server) recordShare(user string, difficulty float64) {
+	server.historyMutex.Lock()
+	defer server.historyMutex.Unlock()
+	if server.minerHistory == nil {
+		server.minerHistory = make(map[string]*hashrateHistory)
+	}
+	address := addressOf(user)
+	h, found := server.minerHistory[address]
+	if !found {
+		if server.MaxMinerHistories > 0 && len(server.minerHistory) >= server.MaxMinerHistories {
+			server.evictMinerHistory()
+		}
+		h = &hashrateHistory{}
+		server.minerHistory[address] = h
+	}
+	h.work += difficulty
+	h.lastShare = time.Now()
+	server.poolHistory.work += difficulty
+}
+
+// evictMinerHistory removes the history of the least recently active miner
+// address. The caller needs to hold the historyMutex.
+func (server *Server) evictMinerHistory() {
+	var oldest string
+	var oldestShare time.Time
+	for address, h := range server.minerHistory {
+		if oldest == "" || h.lastShare.Before(oldestShare) {
+			oldest, oldestShare = address, h.lastShare
+		}
+	}
+	delete(server.minerHistory, oldest)
+}
+
+// takeSample samples the pool and miner hashrates. Miners that did not submit a
+// share for the entire history length are pruned.
+func (server *Server) takeSample(now time.Time) {
+	server.historyMutex.Lock()
+	defer server.historyMutex.Unlock()
+	server.poolHistory.sample(now, server.SampleInterval, server.HistoryLength)
+	retention := time.Duration(server.HistoryLength) * server.SampleInterval
+	for address, h := range server.minerHistory {
+		if now.Sub(h.lastShare) > retention {
+			delete(server.minerHistory, address)
+			continue
+		}
+		h.sample(now, server.SampleInterval, server.HistoryLength)
+	}
+}
+
+//sampleHashrates takes a hashrate sample every SampleInterval until the server is closed
+func (server *Server) sampleHashrates() {
+	ticker := time.NewTicker(server.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.closed:
+			return
+		case now := <-ticker.C:
+			server.takeSample(now)
+			server.pruneMinerStats(now)
+			server.classifyConnections(now)
+		}
+	}
+}
+
+//PoolHistory returns the pool hashrate samples within span
+func (server *Server) PoolHistory(span time.Duration) []HashrateSample {
+	server.historyMutex.Lock()
+	defer server.historyMutex.Unlock()
+	return server.poolHistory.since(time.Now(), span)
+}
+
+//MinerHistory returns the hashrate samples of an address within span,
+// an empty list is returned for unknown or inactive addresses.
+func (server *Server) MinerHistory(address string, span time.Duration) []HashrateSample {
+	server.historyMutex.Lock()
+	defer server.historyMutex.Unlock()
+	return server.minerHistory[address].since(time.Now(), span)
+}
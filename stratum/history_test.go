@@ -0,0 +1,73 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinerHistory(t *testing.T) {
+	server := &Server{SampleInterval: time.Second, HistoryLength: 10}
+	server.recordShare("address.rig1", 1)
+	server.recordShare("address.rig2", 1)
+	server.recordShare("other", 3)
+	server.takeSample(time.Now())
+
+	samples := server.MinerHistory("address", time.Hour)
+	if len(samples) != 1 {
+		t.Fatal("Expected 1 sample, got", len(samples))
+	}
+	if expected := float64(2 * hashesPerDifficulty); samples[0].Hashrate != expected {
+		t.Error("Expected hashrate", expected, "got", samples[0].Hashrate)
+	}
+	if pool := server.PoolHistory(time.Hour); len(pool) != 1 || pool[0].Hashrate != 5*hashesPerDifficulty {
+		t.Error("Unexpected pool history", pool)
+	}
+	if samples = server.MinerHistory("unknown", time.Hour); samples == nil || len(samples) != 0 {
+		t.Error("Expected an empty series for an unknown address, got", samples)
+	}
+}
+
+func TestMinerHistoryPruned(t *testing.T) {
+	server := &Server{SampleInterval: time.Second, HistoryLength: 10}
+	server.recordShare("address", 1)
+	server.takeSample(time.Now().Add(11 * time.Second))
+	if samples := server.MinerHistory("address", time.Hour); len(samples) != 0 {
+		t.Error("Inactive miner was not pruned")
+	}
+}
+
+func TestHistoryDownsampled(t *testing.T) {
+	h := &hashrateHistory{}
+	now := time.Now()
+	for i := 0; i < 2*maxHistoryPoints; i++ {
+		h.work = float64(i % 2)
+		h.sample(now.Add(time.Duration(i+1-2*maxHistoryPoints)*time.Second), time.Second, 4*maxHistoryPoints)
+	}
+	samples := h.since(now, time.Hour)
+	if len(samples) != maxHistoryPoints {
+		t.Fatal("Expected", maxHistoryPoints, "samples, got", len(samples))
+	}
+	if expected := float64(hashesPerDifficulty) / 2; samples[0].Hashrate != expected {
+		t.Error("Expected averaged hashrate", expected, "got", samples[0].Hashrate)
+	}
+	if samples = h.since(now, 10*time.Second); len(samples) != 10 {
+		t.Error("Expected 10 samples within 10 seconds, got", len(samples))
+	}
+}
+
+func TestMinerHistoryEvicted(t *testing.T) {
+	server := &Server{SampleInterval: time.Second, HistoryLength: 10, MaxMinerHistories: 2}
+	server.recordShare("a", 1)
+	server.recordShare("b", 1)
+	server.minerHistory["a"].lastShare = time.Now().Add(-time.Minute)
+	server.recordShare("c", 1)
+	if len(server.minerHistory) != 2 {
+		t.Fatal("Expected 2 miner histories, got", len(server.minerHistory))
+	}
+	if _, found := server.minerHistory["a"]; found {
+		t.Error("Least recently active miner was not evicted")
+	}
+	if _, found := server.minerHistory["c"]; !found {
+		t.Error("New miner history not added")
+	}
+}
@@ -0,0 +1,115 @@
+package stratum
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	//DefaultStaleGraceWindow is the default time shares for the previous job are still accepted after a new job is created
+	DefaultStaleGraceWindow = 2 * time.Second
+	//DefaultClockSkewTolerance is the default maximum difference between the timestamp of a share and the time of the server
+	DefaultClockSkewTolerance = 2 * time.Minute
+)
+
+// jobStatus indicates if a share submitted for a job is still acceptable
+type jobStatus int
+
+const (
+	// jobCurrent is the status of a share for the current job
+	jobCurrent jobStatus = iota
+	// jobNearStale is the status of a share for the previous job submitted
+	// within the grace window, it is accepted
+	jobNearStale
+	// jobStale is the status of a share for an older job or for the previous
+	// job after the grace window, it is rejected
+	jobStale
+)
+
+//newJob replaces the current job by a new one and returns the id of the new job.
+// If the new job is clean, the replaced job becomes the previous job and its shares are accepted as near-stale during the grace window.
+// Otherwise the new job only refreshes the replaced one and the shares for the replaced job are still current.
+func (server *Server) newJob(now time.Time, clean bool) (id string) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	server.jobSeq++
+	id = strconv.FormatUint(server.jobSeq, 16)
+	server.previousJob = server.currentJob
+	server.previousJobReplaced = now
+	server.previousJobValid = !clean
+	server.currentJob = id
+	return
+}
+
+//checkJob returns the status of a share submitted for the job with the given id
+func (server *Server) checkJob(id string, now time.Time) jobStatus {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	if id != "" && id == server.currentJob {
+		return jobCurrent
+	}
+	if id != "" && id == server.previousJob && server.previousJobValid {
+		return jobCurrent
+	}
+	if id != "" && id == server.previousJob && now.Sub(server.previousJobReplaced) <= server.StaleGraceWindow {
+		return jobNearStale
+	}
+	return jobStale
+}
+
+//acceptJob returns if a share of user for the job with the given id can be accepted,
+// near-stale and stale shares are counted in the statistics of the miner.
+func (server *Server) acceptJob(user, id string, now time.Time) bool {
+	return server.judgeJob(user, id, now) != ShareRejected
+}
+
+//judgeJob returns the verdict for a share of user for the job with the given id, near-stale shares are judged by
+// the share policy. Near-stale shares the policy rejects are counted as stale.
+func (server *Server) judgeJob(user, id string, now time.Time) Verdict {
+	status := server.checkJob(id, now)
+	verdict := ShareAccepted
+	switch status {
+	case jobNearStale:
+		if verdict = server.sharePolicy().NearStale; verdict == ShareRejected {
+			status = jobStale
+		}
+	case jobStale:
+		verdict = ShareRejected
+	}
+	server.getMinerStats(user).addJobStatus(status)
+	return verdict
+}
+
+//acceptTimestamp returns if a share of user with the given header timestamp can be accepted.
+// The timestamp is supplied by the miner and only checked for plausibility, accounting always uses the time
+// the share is received. A share that differs more than the ClockSkewTolerance from the server time implies a job
+// that is far too old (or from the future) and is rejected and counted in the statistics of the miner,
+// unless it is slightly skewed and the share policy accepts it.
+func (server *Server) acceptTimestamp(user string, timestamp types.Timestamp, now time.Time) bool {
+	return server.judgeTimestamp(user, timestamp, now) != ShareRejected
+}
+
+//judgeTimestamp returns the verdict for a share of user with the given header timestamp, slightly skewed shares
+// are judged by the share policy.
+func (server *Server) judgeTimestamp(user string, timestamp types.Timestamp, now time.Time) Verdict {
+	if server.ClockSkewTolerance <= 0 {
+		return ShareAccepted
+	}
+	skew := now.Sub(time.Unix(int64(timestamp), 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= server.ClockSkewTolerance {
+		return ShareAccepted
+	}
+	verdict := ShareRejected
+	if skew <= 2*server.ClockSkewTolerance {
+		verdict = server.sharePolicy().SlightlySkewed
+	}
+	if verdict == ShareRejected {
+		server.getMinerStats(user).addSkewedShare()
+	}
+	return verdict
+}
@@ -0,0 +1,105 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/sharechain"
+)
+
+func TestStaleGraceWindow(t *testing.T) {
+	server := &Server{StaleGraceWindow: time.Second}
+	now := time.Now()
+	first := server.newJob(now, true)
+	if status := server.checkJob(first, now); status != jobCurrent {
+		t.Error("Expected current job, got", status)
+	}
+
+	second := server.newJob(now, true)
+	if status := server.checkJob(second, now); status != jobCurrent {
+		t.Error("Expected current job, got", status)
+	}
+	if status := server.checkJob(first, now.Add(time.Second)); status != jobNearStale {
+		t.Error("Expected near-stale job within the grace window, got", status)
+	}
+	if status := server.checkJob(first, now.Add(time.Second+time.Nanosecond)); status != jobStale {
+		t.Error("Expected stale job after the grace window, got", status)
+	}
+
+	server.newJob(now, true)
+	if status := server.checkJob(first, now); status != jobStale {
+		t.Error("Expected stale job for a job older than the previous one, got", status)
+	}
+	if status := server.checkJob("unknown", now); status != jobStale {
+		t.Error("Expected stale job for an unknown job, got", status)
+	}
+}
+
+func TestRefreshedJob(t *testing.T) {
+	server := &Server{StaleGraceWindow: time.Second}
+	now := time.Now()
+	first := server.newJob(now, true)
+	second := server.newJob(now, false)
+	if status := server.checkJob(first, now.Add(time.Minute)); status != jobCurrent {
+		t.Error("Expected the refreshed job to stay current, got", status)
+	}
+	if status := server.checkJob(second, now); status != jobCurrent {
+		t.Error("Expected current job, got", status)
+	}
+
+	server.templateChanged(sharechain.Template{Clean: true})
+	if status := server.checkJob(second, now.Add(time.Minute)); status != jobStale {
+		t.Error("Expected stale job after a clean job, got", status)
+	}
+	if status := server.checkJob(first, now); status != jobStale {
+		t.Error("Expected stale job for a job older than the previous one, got", status)
+	}
+}
+
+func TestStaleSharesCounted(t *testing.T) {
+	server := &Server{StaleGraceWindow: time.Second}
+	now := time.Now()
+	first := server.newJob(now, true)
+	second := server.newJob(now, true)
+
+	if !server.acceptJob("miner", second, now) {
+		t.Error("Share for the current job rejected")
+	}
+	if !server.acceptJob("miner", first, now) {
+		t.Error("Near-stale share rejected")
+	}
+	if server.acceptJob("miner", first, now.Add(2*time.Second)) {
+		t.Error("Stale share accepted")
+	}
+	ms := server.getMinerStats("miner")
+	if ms.NearStaleShares != 1 || ms.StaleShares != 1 {
+		t.Error("Expected 1 near-stale and 1 stale share, got", ms.NearStaleShares, ms.StaleShares)
+	}
+}
+
+func TestClockSkewTolerance(t *testing.T) {
+	server := &Server{ClockSkewTolerance: time.Minute}
+	now := time.Unix(1000000, 0)
+	if !server.acceptTimestamp("a", types.Timestamp(now.Unix()-60), now) {
+		t.Error("Share within the tolerance rejected")
+	}
+	if !server.acceptTimestamp("a", types.Timestamp(now.Unix()+60), now) {
+		t.Error("Share from the future within the tolerance rejected")
+	}
+	if server.acceptTimestamp("a", types.Timestamp(now.Unix()-61), now) {
+		t.Error("Share with an old timestamp accepted")
+	}
+	if server.acceptTimestamp("a", types.Timestamp(now.Unix()+61), now) {
+		t.Error("Share from the future accepted")
+	}
+	if skewed := server.getMinerStats("a").SkewedShares; skewed != 2 {
+		t.Error("Expected 2 skewed shares, got", skewed)
+	}
+
+	server.ClockSkewTolerance = 0
+	if !server.acceptTimestamp("a", 0, now) {
+		t.Error("Share rejected with the check disabled")
+	}
+}
@@ -0,0 +1,47 @@
+package stratum
+
+import (
+	"sync"
+	"time"
+)
+
+//shareLatenciesLength is the number of recent shares kept for the latency percentiles
+const shareLatenciesLength = 1000
+
+//ShareLatency is the time from receiving a share to its verdict
+type ShareLatency struct {
+	Received time.Time
+	Latency  time.Duration
+}
+
+// shareLatencies keeps the latencies of the most recent shares in a ring, so
+// the memory stays bounded however many shares are submitted.
+type shareLatencies struct {
+	mutex   sync.Mutex
+	samples []ShareLatency
+	next    int
+}
+
+func (l *shareLatencies) add(sample ShareLatency) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) < shareLatenciesLength {
+		l.samples = append(l.samples, sample)
+		return
+	}
+	l.samples[l.next] = sample
+	l.next = (l.next + 1) % shareLatenciesLength
+}
+
+//ShareLatencies returns the latencies of the most recent validated shares, the last share comes last
+func (server *Server) ShareLatencies() []ShareLatency {
+	server.shareLatencies.mutex.Lock()
+	defer server.shareLatencies.mutex.Unlock()
+	l := &server.shareLatencies
+	return append(append([]ShareLatency(nil), l.samples[l.next:]...), l.samples[:l.next]...)
+}
+
+// recordShareLatency keeps the time since a share was received.
+func (server *Server) recordShareLatency(received time.Time) {
+	server.shareLatencies.add(ShareLatency{Received: received, Latency: time.Since(received)})
+}
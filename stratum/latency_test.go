@@ -0,0 +1,30 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShareLatencies(t *testing.T) {
+	server := &Server{}
+	start := time.Unix(1000000, 0)
+	for i := 0; i < shareLatenciesLength+10; i++ {
+		server.shareLatencies.add(ShareLatency{Received: start.Add(time.Duration(i) * time.Second), Latency: time.Duration(i)})
+	}
+	samples := server.ShareLatencies()
+	if len(samples) != shareLatenciesLength {
+		t.Fatal("Expected", shareLatenciesLength, "samples, got", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Latency != time.Duration(i+10) {
+			t.Fatal("Expected the samples in order, got", sample.Latency, "at", i)
+		}
+	}
+
+	server = &Server{}
+	received := time.Now().Add(-time.Second)
+	server.ValidateShare("a", "unknown", 0, received)
+	if samples = server.ShareLatencies(); len(samples) != 1 || samples[0].Received != received || samples[0].Latency < time.Second {
+		t.Error("Rejected share not recorded:", samples)
+	}
+}
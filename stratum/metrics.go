@@ -0,0 +1,35 @@
+package stratum
+
+//Metric is a named value describing the state of the stratum server
+type Metric struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+//Metrics returns the current sizes of the in-memory state of the server
+func (server *Server) Metrics() []Metric {
+	server.clientconnectionmutex.Lock()
+	connections := len(server.connections)
+	server.clientconnectionmutex.Unlock()
+
+	server.historyMutex.Lock()
+	minerHistories := len(server.minerHistory)
+	var samples int
+	for _, h := range server.minerHistory {
+		samples += len(h.samples)
+	}
+	samples += len(server.poolHistory.samples)
+	server.historyMutex.Unlock()
+
+	server.statsMutex.Lock()
+	minerStats := len(server.minerStats)
+	server.statsMutex.Unlock()
+
+	return []Metric{
+		{Name: "stratum_connections", Help: "Number of open client connections", Value: float64(connections)},
+		{Name: "stratum_miner_histories", Help: "Number of miner addresses with a hashrate history", Value: float64(minerHistories)},
+		{Name: "stratum_history_samples", Help: "Number of hashrate samples kept in memory", Value: float64(samples)},
+		{Name: "stratum_miner_stats", Help: "Number of miners with statistics", Value: float64(minerStats)},
+	}
+}
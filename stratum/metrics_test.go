@@ -0,0 +1,16 @@
+package stratum
+
+import "testing"
+
+func TestMetrics(t *testing.T) {
+	server := &Server{}
+	server.recordShare("a", 1)
+	server.getMinerStats("a")
+	values := make(map[string]float64)
+	for _, m := range server.Metrics() {
+		values[m.Name] = m.Value
+	}
+	if values["stratum_connections"] != 0 || values["stratum_miner_histories"] != 1 || values["stratum_miner_stats"] != 1 {
+		t.Error("Unexpected metrics", values)
+	}
+}
@@ -0,0 +1,62 @@
+package stratum
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+//MaxMOTDLength is the maximum length in bytes of the message shown to the miners
+const MaxMOTDLength = 256
+
+var errMOTDTooLong = errors.New("message of the day is longer than 256 bytes")
+
+//SetMOTD sets the message of the day that is shown to miners with client.show_message when they subscribe,
+// it is also sent to the miners that are already subscribed. An empty message disables it.
+func (server *Server) SetMOTD(motd string) error {
+	if len(motd) > MaxMOTDLength {
+		return errMOTDTooLong
+	}
+	if !utf8.ValidString(motd) {
+		return errors.New("message of the day is not valid utf-8")
+	}
+	server.motdMutex.Lock()
+	server.motd = motd
+	server.motdMutex.Unlock()
+	if motd == "" {
+		return nil
+	}
+
+	server.clientconnectionmutex.Lock()
+	var subscribed []*ClientConnection
+	for _, c := range server.connections {
+		if c.subscribed {
+			subscribed = append(subscribed, c)
+		}
+	}
+	server.clientconnectionmutex.Unlock()
+	// A slow miner should not delay the others.
+	for _, c := range subscribed {
+		go c.SendMOTD()
+	}
+	return nil
+}
+
+//MOTD returns the current message of the day
+func (server *Server) MOTD() string {
+	server.motdMutex.Lock()
+	defer server.motdMutex.Unlock()
+	return server.motd
+}
+
+//SendMOTD shows the current message of the day to the miner, nothing is sent if there is none
+// or if the connection uses the minimal compatibility mode
+func (c *ClientConnection) SendMOTD() {
+	motd := c.server.MOTD()
+	if motd == "" || c.CompatMode() == CompatMinimal {
+		return
+	}
+	err := c.Notify("client.show_message", []interface{}{motd})
+	if err != nil {
+		c.Close()
+	}
+}
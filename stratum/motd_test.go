@@ -0,0 +1,48 @@
+package stratum
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMOTD(t *testing.T) {
+	server := &Server{difficulty: 1}
+	if err := server.SetMOTD("Maintenance at 12:00 UTC"); err != nil {
+		t.Fatal(err)
+	}
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	defer c.Close()
+	server.connections = append(server.connections, c)
+	go c.MiningSubscribeHandler(message{ID: 1, Method: "mining.subscribe", Params: []interface{}{"sgminer/5.5.4"}})
+
+	m := nextNotification(t, messages, "client.show_message")
+	if len(m.Params) != 1 || m.Params[0] != "Maintenance at 12:00 UTC" {
+		t.Error("Expected the motd, got", m.Params)
+	}
+
+	// An update is broadcast to the subscribed miners.
+	if err := server.SetMOTD("The fee changes to 1%"); err != nil {
+		t.Fatal(err)
+	}
+	m = nextNotification(t, messages, "client.show_message")
+	if len(m.Params) != 1 || m.Params[0] != "The fee changes to 1%" {
+		t.Error("Expected the updated motd, got", m.Params)
+	}
+}
+
+func TestMOTDTooLong(t *testing.T) {
+	server := &Server{}
+	if err := server.SetMOTD("current"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SetMOTD(strings.Repeat("x", MaxMOTDLength+1)); err == nil {
+		t.Error("Too long motd accepted")
+	}
+	if err := server.SetMOTD("\xff"); err == nil {
+		t.Error("Invalid utf-8 accepted")
+	}
+	if motd := server.MOTD(); motd != "current" {
+		t.Error("Rejected motd replaced the current one:", motd)
+	}
+}
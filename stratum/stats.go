@@ -0,0 +1,158 @@
+package stratum
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//DefaultInactiveMinerRetention is the default time the statistics of a miner without connections are kept
+const DefaultInactiveMinerRetention = time.Hour
+
+//MinerStats holds the statistics of a miner, a miner is identified by the user it authorized with
+type MinerStats struct {
+	mutex sync.Mutex // protects following
+	//KeepaliveFailures is the number of times a connection was closed because it did not respond to a keepalive
+	KeepaliveFailures uint64
+	//NearStaleShares is the number of accepted shares for the previous job, submitted within the grace window
+	NearStaleShares uint64
+	//StaleShares is the number of rejected shares for a job that is no longer valid
+	StaleShares uint64
+	//SkewedShares is the number of rejected shares with a timestamp outside the clock skew tolerance
+	SkewedShares uint64
+	//FlaggedShares is the number of accepted borderline shares the share policy flags
+	FlaggedShares uint64
+
+	// connections is the number of open connections authorized as the miner,
+	// disconnected is the time the last one was closed.
+	connections  int
+	disconnected time.Time
+}
+
+func (ms *MinerStats) addKeepaliveFailure() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.KeepaliveFailures++
+}
+
+func (ms *MinerStats) addSkewedShare() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.SkewedShares++
+}
+
+func (ms *MinerStats) addFlaggedShare() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.FlaggedShares++
+}
+
+func (ms *MinerStats) addJobStatus(status jobStatus) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	switch status {
+	case jobNearStale:
+		ms.NearStaleShares++
+	case jobStale:
+		ms.StaleShares++
+	}
+}
+
+func (ms *MinerStats) connect() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.connections++
+}
+
+func (ms *MinerStats) disconnect(now time.Time) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.connections > 0 {
+		ms.connections--
+	}
+	ms.disconnected = now
+}
+
+//inactiveSince returns the time the last connection of the miner was closed, ok is false while it is connected
+func (ms *MinerStats) inactiveSince() (since time.Time, ok bool) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.disconnected, ms.connections == 0
+}
+
+//getMinerStats returns the statistics of a miner, creating them if they do not exist yet
+func (server *Server) getMinerStats(user string) *MinerStats {
+	server.statsMutex.Lock()
+	defer server.statsMutex.Unlock()
+	if server.minerStats == nil {
+		server.minerStats = make(map[string]*MinerStats)
+	}
+	ms, found := server.minerStats[user]
+	if !found {
+		ms = &MinerStats{}
+		server.minerStats[user] = ms
+	}
+	return ms
+}
+
+//MinerInfo is a snapshot of the statistics of a miner
+type MinerInfo struct {
+	User string `json:"user"`
+	//Active is true if the miner has an open connection
+	Active bool `json:"active"`
+	//InactiveSince is the unix time the last connection of an inactive miner was closed
+	InactiveSince     int64  `json:"inactivesince,omitempty"`
+	KeepaliveFailures uint64 `json:"keepalivefailures"`
+	NearStaleShares   uint64 `json:"nearstaleshares"`
+	StaleShares       uint64 `json:"staleshares"`
+	SkewedShares      uint64 `json:"skewedshares"`
+	FlaggedShares     uint64 `json:"flaggedshares"`
+}
+
+//Miners returns the statistics of the miners sorted by user. Disconnected miners are listed as inactive
+// until the InactiveMinerRetention passed, unless HideInactiveMiners is set.
+func (server *Server) Miners() (miners []MinerInfo) {
+	server.statsMutex.Lock()
+	defer server.statsMutex.Unlock()
+	miners = make([]MinerInfo, 0, len(server.minerStats))
+	for user, ms := range server.minerStats {
+		ms.mutex.Lock()
+		info := MinerInfo{
+			User:              user,
+			Active:            ms.connections > 0,
+			KeepaliveFailures: ms.KeepaliveFailures,
+			NearStaleShares:   ms.NearStaleShares,
+			StaleShares:       ms.StaleShares,
+			SkewedShares:      ms.SkewedShares,
+			FlaggedShares:     ms.FlaggedShares,
+		}
+		if !info.Active && !ms.disconnected.IsZero() {
+			info.InactiveSince = ms.disconnected.Unix()
+		}
+		ms.mutex.Unlock()
+		if info.Active || !server.HideInactiveMiners {
+			miners = append(miners, info)
+		}
+	}
+	sort.Sort(minersByUser(miners))
+	return
+}
+
+type minersByUser []MinerInfo
+
+func (m minersByUser) Len() int           { return len(m) }
+func (m minersByUser) Less(i, j int) bool { return m[i].User < m[j].User }
+func (m minersByUser) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// pruneMinerStats removes the statistics of the miners that have been
+// disconnected for longer than the InactiveMinerRetention. Their earnings are
+// kept in the sharechain database.
+func (server *Server) pruneMinerStats(now time.Time) {
+	server.statsMutex.Lock()
+	defer server.statsMutex.Unlock()
+	for user, ms := range server.minerStats {
+		if since, inactive := ms.inactiveSince(); inactive && now.Sub(since) > server.InactiveMinerRetention {
+			delete(server.minerStats, user)
+		}
+	}
+}
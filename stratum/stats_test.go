@@ -0,0 +1,54 @@
+package stratum
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInactiveMinersRetained(t *testing.T) {
+	server := &Server{InactiveMinerRetention: time.Hour}
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	go c.MiningAuthorizeHandler(message{ID: 1, Method: "mining.authorize", Params: []interface{}{"miner.rig1"}})
+	nextNotification(t, messages, "mining.set_difficulty")
+	if miners := server.Miners(); len(miners) != 1 || !miners[0].Active || miners[0].InactiveSince != 0 {
+		t.Fatal("Expected an active miner, got", miners)
+	}
+
+	c.Close()
+	var miners []MinerInfo
+	for i := 0; i < 100 && (len(miners) != 1 || miners[0].Active); i++ {
+		time.Sleep(10 * time.Millisecond)
+		miners = server.Miners()
+	}
+	if len(miners) != 1 || miners[0].Active || miners[0].InactiveSince == 0 {
+		t.Fatal("Expected an inactive miner, got", miners)
+	}
+
+	server.HideInactiveMiners = true
+	if miners := server.Miners(); len(miners) != 0 {
+		t.Error("Inactive miner listed", miners)
+	}
+
+	server.pruneMinerStats(time.Now().Add(59 * time.Minute))
+	if _, found := server.minerStats["miner.rig1"]; !found {
+		t.Error("Miner pruned within the retention")
+	}
+	server.pruneMinerStats(time.Now().Add(61 * time.Minute))
+	if _, found := server.minerStats["miner.rig1"]; found {
+		t.Error("Miner not pruned after the retention")
+	}
+}
+
+func TestActiveMinersNotPruned(t *testing.T) {
+	server := &Server{}
+	ms := server.getMinerStats("a")
+	ms.connect()
+	ms.connect()
+	ms.disconnect(time.Now())
+	server.getMinerStats("b")
+	server.pruneMinerStats(time.Now().Add(time.Second))
+	if miners := server.Miners(); len(miners) != 1 || miners[0].User != "a" || !miners[0].Active {
+		t.Error("Expected only the connected miner a, got", miners)
+	}
+}
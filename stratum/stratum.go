@@ -4,21 +4,23 @@ package stratum
 
 import (
 	"bufio"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"math/big"
 	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/NebulousLabs/Sia/types"
 	log "github.com/Sirupsen/logrus"
 
+	"github.com/siapool/p2pool/pow"
 	"github.com/siapool/p2pool/sharechain"
 )
 
+//DefaultMaxConnections is the default maximum number of simultaneous client connections
+const DefaultMaxConnections = 1000
+
 // message is the structure for both requests, responses and notifications
 type message struct {
 	Method string        `json:"method,omitempty"`
@@ -38,6 +40,8 @@ type NotificationHandler func(args []interface{})
 // ClientConnection maintains a connection to a stratum client and (de)serializes requests/reponses/notifications
 type ClientConnection struct {
 	server *Server
+	// id identifies the connection in the admin api
+	id uint64
 
 	socketMutex sync.Mutex // protects following
 	socket      net.Conn
@@ -51,30 +55,158 @@ type ClientConnection struct {
 	ErrorCallback        ErrorCallback
 	notificationHandlers map[string]NotificationHandler
 
+	// session is the key the extranonce1 is derived from, both and subscribed
+	// are protected by the clientconnectionmutex of the server.
+	session      string
 	extranonce1  []byte
+	subscribed   bool
 	MinerVersion string
 	User         string
+	// compatMode and extranonceSubscribed are protected by the
+	// clientconnectionmutex of the server as well.
+	compatMode           CompatMode
+	extranonceSubscribed bool
+
+	remoteIP  string
+	connected time.Time
+
+	difficultyMutex sync.Mutex // protects following
+	difficulty      float64
+
+	activityMutex sync.Mutex // protects following
+	lastActivity  time.Time
+
+	jobsMutex sync.Mutex // protects following
+	jobs      map[string]*minerJob
+
+	closeOnce sync.Once
+	closed    chan struct{}
 }
 
 //NewClientConnection creates a new ClientConnection given a socket
 func (server *Server) NewClientConnection(socket net.Conn) (c *ClientConnection) {
-	extranonce1 := server.generateExtraNonce1()
-	return &ClientConnection{socket: socket, extranonce1: extranonce1, server: server}
+	session, extranonce1 := server.newSession()
+	now := time.Now()
+	return &ClientConnection{
+		id:           atomic.AddUint64(&server.connectionSeq, 1),
+		socket:       socket,
+		session:      session,
+		extranonce1:  extranonce1,
+		server:       server,
+		difficulty:   server.startDifficulty(),
+		compatMode:   CompatStandard,
+		remoteIP:     remoteIP(socket),
+		connected:    now,
+		lastActivity: now,
+		closed:       make(chan struct{}),
+	}
 }
 
 // Server Listens on a connection for incoming connections
 type Server struct {
+	// connectionSeq is the id of the last created connection, it is accessed
+	// atomically and first in the struct to be 64-bit aligned.
+	connectionSeq uint64
+
 	shareChain *sharechain.ShareChain
+	// difficulty is the minimum difficulty of a server without a sharechain,
+	// otherwise the minimum follows the share target of the sharechain.
 	difficulty float64
 
-	laddr          string
-	maxConnections int
+	laddr string
+
+	//MaxConnections is the maximum number of simultaneous client connections
+	MaxConnections int
+	//MaxConnectionsPerIP is the maximum number of simultaneous client connections from a single ip address, 0 means no limit
+	MaxConnectionsPerIP int
+	//AcceptInterval is the minimum time between accepting two new client connections,
+	// it is doubled for every short lived connection up to MaxAcceptInterval and decays back when the churn stops.
+	AcceptInterval time.Duration
+	//MaxAcceptInterval is the upper bound of the accept interval
+	MaxAcceptInterval time.Duration
+	throttle          acceptThrottle
 
 	lismutex sync.Mutex // protects following
 	lis      net.Listener
 
 	clientconnectionmutex sync.Mutex // protects following
 	connections           []*ClientConnection
+	extraNonceSecret      []byte
+
+	motdMutex sync.Mutex // protects following
+	motd      string
+
+	statsMutex sync.Mutex // protects following
+	minerStats map[string]*MinerStats
+
+	shareLatencies shareLatencies
+
+	historyMutex sync.Mutex // protects following
+	poolHistory  hashrateHistory
+	minerHistory map[string]*hashrateHistory
+
+	//InactiveMinerRetention is the time the statistics of a miner are kept after its last connection closed,
+	// so a miner that reconnects keeps its statistics
+	InactiveMinerRetention time.Duration
+	//HideInactiveMiners leaves the retained miners without a connection out of the Miners
+	HideInactiveMiners bool
+
+	//MaxMinerHistories is the maximum number of miner addresses for which the hashrate history is kept,
+	// the least recently active address is evicted when a new address exceeds the limit
+	MaxMinerHistories int
+	//SampleInterval is the time between two hashrate samples
+	SampleInterval time.Duration
+	//HistoryLength is the number of hashrate samples kept
+	HistoryLength int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	jobsMutex           sync.Mutex // protects following
+	jobSeq              uint64
+	currentJob          string
+	previousJob         string
+	previousJobReplaced time.Time
+	// previousJobValid is set if the current job refreshes the previous one,
+	// the shares for the previous job are then current as well.
+	previousJobValid bool
+	// jobTemplates are the templates of the current and the previous job.
+	jobTemplates map[string]sharechain.Template
+
+	//StaleGraceWindow is the time shares for the previous job are still accepted (as near-stale) after a new job is created
+	StaleGraceWindow time.Duration
+	//ClockSkewTolerance is the maximum difference between the timestamp of a share and the time of the server, 0 disables the check
+	ClockSkewTolerance time.Duration
+	//Strictness decides which borderline shares are accepted, rejected or accepted but flagged, standard if empty
+	Strictness Strictness
+
+	//StartDifficulty is the difficulty assigned to new client connections,
+	// if it is lower than the difficulty of the sharechain, the difficulty of the sharechain is used.
+	StartDifficulty float64
+	//MaxDifficulty is the highest difficulty a miner can suggest, 0 means no limit
+	MaxDifficulty float64
+	//FixedDifficulty assigns every connection the same difficulty and ignores the difficulty suggestions of the miners,
+	// 0 disables it. Like the StartDifficulty it is raised to the difficulty of the sharechain if it is lower.
+	FixedDifficulty float64
+	//MaxShareDifficulty caps the difficulty of every connection, whether it is the StartDifficulty, the FixedDifficulty
+	// or suggested, so even the largest miners submit shares regularly. 0 means no cap, the difficulty of the sharechain
+	// is used if it is higher.
+	MaxShareDifficulty float64
+	//DifficultyBands raise the difficulty of a connection during the DifficultyClassificationPeriod to the minimum of
+	// the band its miner address reaches with its observed hashrate, so big miners do not ramp up from a low difficulty.
+	// The bands do not apply to a FixedDifficulty and are capped by the MaxShareDifficulty.
+	DifficultyBands []DifficultyBand
+
+	//RequireAuthorization only allows miners with an address authorized in the sharechain to mine
+	RequireAuthorization bool
+	//DuplicateWorkers is the policy for a worker name that is authorized on more than one connection,
+	// the connections are merged if it is empty
+	DuplicateWorkers DuplicateWorkerPolicy
+
+	//KeepaliveInterval is the time a client connection can be idle before it is pinged,
+	// clients that remain silent for another interval after the ping are disconnected.
+	// A zero value disables the keepalive.
+	KeepaliveInterval time.Duration
 
 	ErrorCallback        ErrorCallback
 	notificationHandlers map[string]NotificationHandler
@@ -83,20 +215,81 @@ type Server struct {
 //NewServer creates a stratum server for listening on the local network address laddr.
 // During the Accept() call, a listening socket is created ( https://golang.org/pkg/net/#Listen ) using "tcp" as network and laddr as specified.
 func NewServer(laddr string, shareChain *sharechain.ShareChain) (server *Server) {
-	server = &Server{laddr: laddr, shareChain: shareChain, maxConnections: 1000}
-	server.difficulty = targetToDifficulty(shareChain.Target)
+	server = &Server{
+		laddr:                  laddr,
+		shareChain:             shareChain,
+		MaxConnections:         DefaultMaxConnections,
+		MaxConnectionsPerIP:    DefaultMaxConnectionsPerIP,
+		AcceptInterval:         DefaultAcceptInterval,
+		MaxAcceptInterval:      DefaultMaxAcceptInterval,
+		MaxMinerHistories:      DefaultMaxMinerHistories,
+		InactiveMinerRetention: DefaultInactiveMinerRetention,
+		SampleInterval:         DefaultSampleInterval,
+		HistoryLength:          DefaultHistoryLength,
+		StaleGraceWindow:       DefaultStaleGraceWindow,
+		ClockSkewTolerance:     DefaultClockSkewTolerance,
+		closed:                 make(chan struct{}),
+	}
+	if shareChain != nil {
+		shareChain.SetTemplateHandler(server.templateChanged)
+	}
 	return
 }
 
-func targetToDifficulty(target types.Target) (difficulty float64) {
-	//target = targetone/diff
-	diffOneString := "0x00000000ffff0000000000000000000000000000000000000000000000000000"
-	targetOneAsBigInt := &big.Int{}
-	targetOneAsBigInt.SetString(diffOneString, 0)
-	targetOneAsBigRat := &big.Rat{}
-	targetOneAsBigRat.SetInt(targetOneAsBigInt)
-	difficulty, _ = targetOneAsBigRat.Quo(targetOneAsBigRat, target.Rat()).Float64()
-	return
+//minimumDifficulty returns the difficulty of the share target of the sharechain
+func (server *Server) minimumDifficulty() float64 {
+	if server.shareChain == nil {
+		return server.difficulty
+	}
+	return pow.DifficultyFromTarget(server.shareChain.ShareTarget())
+}
+
+//startDifficulty returns the difficulty for a new client connection
+func (server *Server) startDifficulty() float64 {
+	start := server.StartDifficulty
+	if server.FixedDifficulty > 0 {
+		start = server.FixedDifficulty
+	}
+	if minimum := server.minimumDifficulty(); start < minimum {
+		return minimum
+	}
+	return server.capDifficulty(start)
+}
+
+//capDifficulty limits a difficulty to the MaxShareDifficulty, but not below the difficulty of the sharechain
+func (server *Server) capDifficulty(difficulty float64) float64 {
+	if server.MaxShareDifficulty <= 0 || difficulty <= server.MaxShareDifficulty {
+		return difficulty
+	}
+	if minimum := server.minimumDifficulty(); minimum > server.MaxShareDifficulty {
+		return minimum
+	}
+	return server.MaxShareDifficulty
+}
+
+//ApplyMinimumDifficulty raises the difficulty of the connections below the share target of the sharechain,
+// for example after the share ratio changed. With a FixedDifficulty all connections are set to the same difficulty again.
+// The subscribed miners are sent their new difficulty.
+func (server *Server) ApplyMinimumDifficulty() {
+	minimum, fixed := server.minimumDifficulty(), server.FixedDifficulty > 0
+	if fixed {
+		minimum = server.startDifficulty()
+	}
+	server.clientconnectionmutex.Lock()
+	var changed []*ClientConnection
+	for _, c := range server.connections {
+		if d := c.Difficulty(); d < minimum || (fixed && d != minimum) {
+			c.setDifficulty(minimum)
+			if c.subscribed {
+				changed = append(changed, c)
+			}
+		}
+	}
+	server.clientconnectionmutex.Unlock()
+	// A slow miner should not delay the others.
+	for _, c := range changed {
+		go c.SendDifficulty()
+	}
 }
 
 func generateRandomBytes(length int) ([]byte, error) {
@@ -108,35 +301,30 @@ func generateRandomBytes(length int) ([]byte, error) {
 	return b, nil
 }
 
-func (server *Server) generateExtraNonce1() (extraNonce1 []byte) {
-retry:
-	for {
-		extraNonce1, _ = generateRandomBytes(4)
-		for _, c := range server.connections {
-			if hex.EncodeToString(extraNonce1) == hex.EncodeToString(c.extranonce1) {
-				continue retry
-			}
-		}
-		return
-	}
-}
-
 //Accept creates  connections on the listener and serves requests for each incoming connection.
 // Accept blocks until the underlying tcp listener returns a non-nil error or Close is called on the server.
 // The caller typically invokes Accept in a go statement.
 func (server *Server) Accept() (err error) {
+	lis, err := net.Listen("tcp", server.laddr)
+	if err != nil {
+		return
+	}
+	return server.Serve(lis)
+}
+
+//Serve accepts incoming connections on an existing listener, for example one inherited from a previous process.
+// This is a blocking function like Accept.
+func (server *Server) Serve(lis net.Listener) (err error) {
 	func() {
 		server.lismutex.Lock()
 		defer server.lismutex.Unlock()
 		server.clientconnectionmutex.Lock()
 		defer server.clientconnectionmutex.Unlock()
-		server.lis, err = net.Listen("tcp", server.laddr)
+		server.lis = lis
 		server.connections = make([]*ClientConnection, 0, 10)
-		log.Infoln("Listening for incoming stratum connections on", server.laddr)
+		log.Infoln("Listening for incoming stratum connections on", lis.Addr())
 	}()
-	if err != nil {
-		return
-	}
+	go server.sampleHashrates()
 	for {
 		err = func() (err error) {
 			server.lismutex.Lock()
@@ -148,33 +336,117 @@ func (server *Server) Accept() (err error) {
 			server.clientconnectionmutex.Lock()
 			defer server.clientconnectionmutex.Unlock()
 			c := server.NewClientConnection(conn)
-			if len(server.connections) >= server.maxConnections {
-				log.Errorln("Maximum number of client connections reached (", server.maxConnections, "), dropping connection request")
+			if len(server.connections) >= server.MaxConnections {
+				log.Errorln("Maximum number of client connections reached (", server.MaxConnections, "), dropping connection request")
+				c.Close()
+				return
+			}
+			if server.MaxConnectionsPerIP > 0 && server.connectionsFrom(c.remoteIP) >= server.MaxConnectionsPerIP {
+				log.Warnln("Maximum number of client connections from", c.remoteIP, "reached, dropping connection request")
 				c.Close()
+				return
 			}
 
 			server.connections = append(server.connections, c)
 			go c.Listen()
-			//TODO: clean up closed client connections
+			if server.KeepaliveInterval > 0 {
+				go c.keepalive(server.KeepaliveInterval)
+			}
 			return
 		}()
 		if err != nil {
 			return
 		}
+		if !server.waitAccept() {
+			return
+		}
 	}
 }
 
 //Close releases the underlying tcp listener
 func (server *Server) Close() {
+	server.closeOnce.Do(func() {
+		close(server.closed)
+	})
 	if server.lis != nil {
 		server.lis.Close()
 	}
 }
 
+func (server *Server) removeConnection(c *ClientConnection) {
+	if now := time.Now(); now.Sub(c.connected) < shortLivedConnection {
+		server.throttle.churn(server.AcceptInterval, server.MaxAcceptInterval, now)
+	}
+	if c.User != "" {
+		server.getMinerStats(c.User).disconnect(time.Now())
+	}
+	server.clientconnectionmutex.Lock()
+	defer server.clientconnectionmutex.Unlock()
+	for i, conn := range server.connections {
+		if conn == c {
+			server.connections = append(server.connections[:i], server.connections[i+1:]...)
+			return
+		}
+	}
+}
+
 //Close releases the tcp connection
 func (c *ClientConnection) Close() {
-	if c.socket != nil {
-		c.socket.Close()
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		if c.socket != nil {
+			c.socket.Close()
+		}
+	})
+}
+
+func (c *ClientConnection) touch() {
+	c.activityMutex.Lock()
+	defer c.activityMutex.Unlock()
+	c.lastActivity = time.Now()
+}
+
+func (c *ClientConnection) lastActive() time.Time {
+	c.activityMutex.Lock()
+	defer c.activityMutex.Unlock()
+	return c.lastActivity
+}
+
+//keepalive pings the client when it has been idle for the given interval and closes the connection
+// if the client does not send anything, a reply on the ping or any other message, within the next interval.
+// Any message received from the client counts as activity so slow miners that do not submit often but do
+// respond to pings (even with an error because they do not know mining.ping) are not disconnected.
+func (c *ClientConnection) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var pinged bool
+	var pingTime time.Time
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+		}
+		lastActive := c.lastActive()
+		if pinged && lastActive.After(pingTime) {
+			pinged = false
+		}
+		if time.Since(lastActive) < interval {
+			continue
+		}
+		if pinged {
+			log.Infoln("Stratum client", c.User, "did not respond to keepalive, disconnecting")
+			// A client that never authorized has no statistics to count
+			// the failure in.
+			if c.User != "" {
+				c.server.getMinerStats(c.User).addKeepaliveFailure()
+			}
+			c.Close()
+			return
+		}
+		pingTime = time.Now()
+		pinged = true
+		go c.Call("mining.ping", nil)
 	}
 }
 
@@ -196,14 +468,44 @@ func (c *ClientConnection) dispatchNotification(n message) {
 	}
 }
 
+// dispatch handles a received message. Only a message without a method is a
+// reply to a call, the ids of the calls of the server are independent of the
+// ids of the requests of the client and a request with the id of a pending
+// call is still a request.
 func (c *ClientConnection) dispatch(r message) {
+	if r.Method == "" {
+		c.dispatchReply(r)
+		return
+	}
 	if r.ID == 0 {
 		c.dispatchNotification(r)
 		return
 	}
+	switch r.Method {
+	case "mining.subscribe":
+		c.MiningSubscribeHandler(r)
+	case "mining.authorize":
+		c.MiningAuthorizeHandler(r)
+	case "mining.suggest_difficulty":
+		c.MiningSuggestDifficultyHandler(r)
+	case "mining.extranonce.subscribe":
+		c.MiningExtranonceSubscribeHandler(r)
+	case "mining.submit":
+		c.MiningSubmitHandler(r)
+	default:
+		log.Debugln("unknown json-rpc method called on stratum server:", r.Method, "-", r)
+	}
+}
+
+// dispatchReply passes a reply to the pending call with its id, replies to
+// calls that timed out are dropped.
+func (c *ClientConnection) dispatchReply(r message) {
 	c.callsMutex.Lock()
 	defer c.callsMutex.Unlock()
 	cb, found := c.pendingCalls[r.ID]
+	if !found {
+		return
+	}
 	var result interface{}
 	if r.Error != nil {
 		message := ""
@@ -214,18 +516,7 @@ func (c *ClientConnection) dispatch(r message) {
 	} else {
 		result = r.Result
 	}
-	if found {
-		cb <- result
-	} else {
-		switch r.Method {
-		case "mining.subscribe":
-			c.MiningSubscribeHandler(r)
-		case "mining.authorize":
-			c.MiningAuthorizeHandler(r)
-		default:
-			log.Debugln("unknown json-rpc method called on stratum server:", r.Method, "-", r)
-		}
-	}
+	cb <- result
 }
 
 func (c *ClientConnection) dispatchError(err error) {
@@ -237,6 +528,10 @@ func (c *ClientConnection) dispatchError(err error) {
 //Listen reads data from the open connection, deserializes it and dispatches the reponses and notifications
 // This is a blocking function and will continue to listen until an error occurs (io or deserialization)
 func (c *ClientConnection) Listen() {
+	defer func() {
+		c.Close()
+		c.server.removeConnection(c)
+	}()
 	reader := bufio.NewReader(c.socket)
 	for {
 		rawmessage, err := reader.ReadString('\n')
@@ -244,6 +539,7 @@ func (c *ClientConnection) Listen() {
 			c.dispatchError(err)
 			return
 		}
+		c.touch()
 		r := message{}
 		err = json.Unmarshal([]byte(rawmessage), &r)
 		if err != nil {
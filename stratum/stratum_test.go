@@ -1,21 +1,125 @@
 package stratum
 
 import (
-	"encoding/hex"
-	"strconv"
+	"bufio"
+	"encoding/json"
+	"net"
 	"testing"
-
-	"github.com/NebulousLabs/Sia/types"
+	"time"
 )
 
-func TestDifficultyToTarget(t *testing.T) {
-	expectedDiff, _ := strconv.ParseFloat("0.99998474121094105", 64)
-	var target types.Target
-	targetSlice, _ := hex.DecodeString("00000000fffffffffffefffeffff00000001000200020000fffefffcfffbfffd")
-	copy(target[:], targetSlice[:])
-	diff := targetToDifficulty(target)
+// newTestConnection creates a client connection on one end of an in memory pipe,
+// every line written by the server is passed to the respond function on the other end.
+func newTestConnection(server *Server, respond func(clientSide net.Conn, m message)) (c *ClientConnection) {
+	serverSide, clientSide := net.Pipe()
+	c = server.NewClientConnection(serverSide)
+	go func() {
+		reader := bufio.NewReader(clientSide)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			m := message{}
+			json.Unmarshal([]byte(line), &m)
+			respond(clientSide, m)
+		}
+	}()
+	go c.Listen()
+	return
+}
+
+func isClosed(c *ClientConnection) bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestKeepaliveDisconnectsSilentClient(t *testing.T) {
+	server := &Server{}
+	c := newTestConnection(server, func(net.Conn, message) {})
+	c.User = "silent"
+	interval := 20 * time.Millisecond
+	go c.keepalive(interval)
+
+	select {
+	case <-c.closed:
+	case <-time.After(20 * interval):
+		t.Fatal("Silent client was not disconnected")
+	}
+	if failures := server.getMinerStats("silent").KeepaliveFailures; failures != 1 {
+		t.Error("Expected 1 keepalive failure, got", failures)
+	}
+}
+
+func TestKeepaliveKeepsRespondingClient(t *testing.T) {
+	server := &Server{}
+	c := newTestConnection(server, func(clientSide net.Conn, m message) {
+		if m.Method != "mining.ping" {
+			return
+		}
+		// Miners that do not know mining.ping reply with an error, this is still a sign of life
+		reply, _ := json.Marshal(message{ID: m.ID, Error: []interface{}{20, "Unknown method"}})
+		clientSide.Write(append(reply, '\n'))
+	})
+	c.User = "responding"
+	interval := 20 * time.Millisecond
+	go c.keepalive(interval)
 
-	if diff != expectedDiff {
-		t.Error(diff, "returned instead of", expectedDiff)
+	time.Sleep(10 * interval)
+	if isClosed(c) {
+		t.Error("Responding client was disconnected")
+	}
+	c.Close()
+}
+
+func TestKeepaliveUnauthorizedClient(t *testing.T) {
+	server := &Server{}
+	c := newTestConnection(server, func(net.Conn, message) {})
+	interval := 20 * time.Millisecond
+	go c.keepalive(interval)
+
+	select {
+	case <-c.closed:
+	case <-time.After(20 * interval):
+		t.Fatal("Silent client was not disconnected")
+	}
+	if miners := server.Miners(); len(miners) != 0 {
+		t.Error("Expected no statistics for a client that never authorized, got", miners)
+	}
+}
+
+func TestRequestWithIDOfPendingCall(t *testing.T) {
+	server := &Server{}
+	replies := make(chan message, 1)
+	c := newTestConnection(server, func(clientSide net.Conn, m message) {
+		if m.Method != "mining.ping" {
+			replies <- m
+			return
+		}
+		// The client numbers its requests itself, its request can have the id
+		// of the ping it did not answer yet.
+		go func() {
+			request, _ := json.Marshal(message{ID: m.ID, Method: "mining.extranonce.subscribe"})
+			clientSide.Write(append(request, '\n'))
+			reply, _ := json.Marshal(message{ID: m.ID, Result: true})
+			clientSide.Write(append(reply, '\n'))
+		}()
+	})
+	defer c.Close()
+
+	if reply, err := c.Call("mining.ping", nil); err != nil || reply != true {
+		t.Error("Expected the reply of the client to the ping, got", reply, err)
+	}
+	select {
+	case m := <-replies:
+		if m.Result != true {
+			t.Error("Expected the request to be acknowledged, got", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The request with the id of the ping was not handled")
 	}
 }
@@ -0,0 +1,153 @@
+package stratum
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/siapool/p2pool/pow"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+// The error codes of a rejected mining.submit
+const (
+	errorOther         = 20
+	errorStale         = 21
+	errorDuplicate     = 22
+	errorLowDifficulty = 23
+	errorUnauthorized  = 24
+)
+
+var errInvalidSubmit = errors.New("invalid extranonce2, timestamp or nonce")
+
+//MiningSubmitHandler handles the mining.submit request, the params are the worker, the job id, the extranonce2,
+// the timestamp and the nonce. An accepted share is added to the sharechain, a share that also meets the target of
+// the block is submitted to the network as a block found by the miner.
+func (c *ClientConnection) MiningSubmitHandler(m message) {
+	now := time.Now()
+	if c.User == "" || c.server.shareChain == nil {
+		c.replyError(m.ID, errorUnauthorized, "Unauthorized worker")
+		return
+	}
+	if len(m.Params) < 5 {
+		c.replyError(m.ID, errorOther, "Invalid params")
+		return
+	}
+	worker, _ := m.Params[0].(string)
+	id, _ := m.Params[1].(string)
+	if worker != c.User {
+		c.replyError(m.ID, errorUnauthorized, "Unauthorized worker")
+		return
+	}
+	extraNonce2, timestamp, nonce, err := submitParams(m.Params[2:])
+	if err != nil {
+		c.replyError(m.ID, errorOther, "Invalid params")
+		return
+	}
+	if c.server.ValidateShare(c.User, id, timestamp, now) == ShareRejected {
+		if c.server.checkJob(id, now) != jobCurrent {
+			c.replyError(m.ID, errorStale, "Stale share")
+		} else {
+			c.replyError(m.ID, errorOther, "Timestamp out of range")
+		}
+		return
+	}
+	job, found := c.job(id)
+	if !found {
+		c.replyError(m.ID, errorStale, "Job not found")
+		return
+	}
+	reject := sharechain.Reject{Timestamp: types.Timestamp(now.Unix()), Miner: c.User, Job: id, HeaderTimestamp: timestamp}
+	blockID := job.headerID(extraNonce2, timestamp, nonce)
+	if !pow.MeetsTarget(crypto.Hash(blockID), c.Target()) || !pow.MeetsTarget(crypto.Hash(blockID), job.template.ShareTarget) {
+		reject.Reason = sharechain.RejectLowDifficulty
+		c.server.recordReject(reject)
+		c.replyError(m.ID, errorLowDifficulty, "Low difficulty share")
+		return
+	}
+	header := append(append(append([]byte(nil), extraNonce2...), encoding.Marshal(timestamp)...), nonce[:]...)
+	if !c.markSubmitted(job, string(header)) {
+		reject.Reason = sharechain.RejectDuplicate
+		c.server.recordReject(reject)
+		c.replyError(m.ID, errorDuplicate, "Duplicate share")
+		return
+	}
+	c.server.recordShare(c.User, c.Difficulty())
+	c.server.shareChain.AddShare(sharechain.Share{BlockID: blockID, ParentID: job.block.ParentID, Miner: c.User})
+	if pow.MeetsTarget(crypto.Hash(blockID), job.template.Target) {
+		b := job.solvedBlock(extraNonce2, timestamp, nonce)
+		go c.server.submitBlock(job.template, b, c.User)
+	}
+	if err = c.Reply(m.ID, true, nil); err != nil {
+		c.Close()
+	}
+}
+
+// submitParams decodes the extranonce2, timestamp and nonce of a mining.submit,
+// all of them are hex encoded, the timestamp and the nonce in the byte order of
+// the header.
+func submitParams(params []interface{}) (extraNonce2 []byte, timestamp types.Timestamp, nonce types.BlockNonce, err error) {
+	if extraNonce2, err = HexStringToBytes(params[0]); err != nil {
+		return
+	}
+	if len(extraNonce2) != extraNonce2Size {
+		err = errInvalidSubmit
+		return
+	}
+	b, err := HexStringToBytes(params[1])
+	if err != nil {
+		return
+	}
+	if len(b) != len(encoding.Marshal(timestamp)) {
+		err = errInvalidSubmit
+		return
+	}
+	if err = encoding.Unmarshal(b, &timestamp); err != nil {
+		return
+	}
+	if b, err = HexStringToBytes(params[2]); err != nil {
+		return
+	}
+	if len(b) != len(nonce) {
+		err = errInvalidSubmit
+		return
+	}
+	copy(nonce[:], b)
+	return
+}
+
+// markSubmitted remembers a header submitted for a job, it returns false if
+// it was submitted before.
+func (c *ClientConnection) markSubmitted(job *minerJob, header string) bool {
+	c.jobsMutex.Lock()
+	defer c.jobsMutex.Unlock()
+	if job.submitted[header] {
+		return false
+	}
+	job.submitted[header] = true
+	return true
+}
+
+// submitBlock submits a block found by the miner of user to the network.
+func (server *Server) submitBlock(template sharechain.Template, b types.Block, user string) {
+	address, err := sharechain.MinerAddress(user)
+	if err != nil {
+		log.Errorln("Error submitting the block of", user, ":", err)
+		return
+	}
+	log.Infoln("Stratum client", user, "found block", b.ID())
+	if err = server.shareChain.SubmitMinerBlock(template, b, address); err != nil {
+		log.Errorln("Error submitting block", b.ID(), "of", user, ":", err)
+	}
+}
+
+// replyError rejects a request with an error code and message.
+func (c *ClientConnection) replyError(ID uint64, code int, message string) {
+	if err := c.Reply(ID, nil, []interface{}{code, message, nil}); err != nil {
+		c.Close()
+	}
+}
@@ -0,0 +1,124 @@
+package stratum
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/siad"
+)
+
+func TestMiningSubmit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stratum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sc, err := sharechain.New(siad.NewMock(), dir, sharechain.Config{FeeAddress: types.UnlockHash{9}, LogRejects: true, ShareFlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	template, err := sc.BlockTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every header meets the targets, the mock accepts any block.
+	template.Target, template.ShareTarget = types.RootDepth, types.RootDepth
+
+	server := &Server{shareChain: sc, ClockSkewTolerance: DefaultClockSkewTolerance, SampleInterval: time.Minute, HistoryLength: 10}
+	messages, respond := clientMessages()
+	c := newTestConnection(server, respond)
+	defer c.Close()
+	user := types.UnlockHash{1}.String() + ".rig1"
+	c.User = user
+	c.subscribed = true
+	c.setDifficulty(0)
+	server.templateChanged(template)
+	id, _, _ := server.currentJobTemplate()
+	go c.sendJob(id, template, true)
+	notify := nextNotification(t, messages, "mining.notify")
+	if notify.Params[0] != id {
+		t.Fatal("Expected job", id, "got", notify.Params)
+	}
+
+	timestamp := hex.EncodeToString(encoding.Marshal(types.CurrentTimestamp()))
+	nonce := hex.EncodeToString(make([]byte, 8))
+	submit := func(worker, job string) message {
+		go c.dispatch(message{ID: 7, Method: "mining.submit", Params: []interface{}{worker, job, "01020304", timestamp, nonce}})
+		select {
+		case reply := <-messages:
+			return reply
+		case <-time.After(time.Second):
+			t.Fatal("No reply to mining.submit")
+		}
+		return message{}
+	}
+	errorCode := func(reply message) float64 {
+		if len(reply.Error) == 0 {
+			return 0
+		}
+		code, _ := reply.Error[0].(float64)
+		return code
+	}
+
+	if reply := submit(user, id); reply.Result != true {
+		t.Fatal("Share not accepted:", reply)
+	}
+	// The accepted share is in the hashrate history of the miner address.
+	server.takeSample(time.Now())
+	if history := server.MinerHistory(types.UnlockHash{1}.String(), time.Hour); len(history) != 1 {
+		t.Error("Expected a hashrate sample of the miner, got", history)
+	}
+	if reply := submit(user, id); errorCode(reply) != errorDuplicate {
+		t.Error("Expected a duplicate share, got", reply)
+	}
+	if reply := submit(user, "unknown"); errorCode(reply) != errorStale {
+		t.Error("Expected a stale share, got", reply)
+	}
+	if reply := submit(types.UnlockHash{2}.String(), id); errorCode(reply) != errorUnauthorized {
+		t.Error("Expected an unauthorized worker, got", reply)
+	}
+
+	// The shares that reached the validation have a latency, the share of the
+	// unauthorized worker did not.
+	if latencies := server.ShareLatencies(); len(latencies) != 3 {
+		t.Error("Expected the latency of 3 shares, got", latencies)
+	}
+
+	// The reject log is written with the shares.
+	var rejects []sharechain.Reject
+	for deadline := time.Now().Add(time.Second); len(rejects) < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if rejects, err = sc.Rejects(types.UnlockHash{1}, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rejects) != 2 || rejects[0].Reason != sharechain.RejectDuplicate || rejects[1].Reason != sharechain.RejectStale {
+		t.Error("Expected the duplicate and the stale share in the reject log, got", rejects)
+	}
+
+	// The share meets the target of the mock as well, it is submitted as a
+	// block found by the miner.
+	deadline := time.Now().Add(time.Second)
+	for {
+		blocks, err := sc.FoundBlocks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) == 1 {
+			if blocks[0].Finder != (types.UnlockHash{1}) {
+				t.Error("Expected the miner as the finder, got", blocks[0].Finder)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The block of the miner was not submitted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
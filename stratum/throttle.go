@@ -0,0 +1,112 @@
+package stratum
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultAcceptInterval is the default minimum time between accepting two new client connections
+	DefaultAcceptInterval = 10 * time.Millisecond
+	//DefaultMaxAcceptInterval is the default upper bound of the accept interval during a connection flood
+	DefaultMaxAcceptInterval = 5 * time.Second
+	//DefaultMaxConnectionsPerIP is the default maximum number of simultaneous client connections from a single ip address
+	DefaultMaxConnectionsPerIP = 100
+
+	// shortLivedConnection is the lifetime below which a closed connection
+	// counts as churn
+	shortLivedConnection = 10 * time.Second
+	// churnDecay is the time without churn after which the accept interval is
+	// halved again
+	churnDecay = time.Minute
+)
+
+// acceptThrottle adapts the minimum interval between accepting connections to
+// the connection churn, like the acceptInterval of the gateway but doubling on
+// every short lived connection and decaying back to the base interval when the
+// churn stops.
+type acceptThrottle struct {
+	mutex     sync.Mutex // protects following
+	current   time.Duration
+	lastChurn time.Time
+}
+
+// churn registers a short lived connection.
+func (t *acceptThrottle) churn(base, max time.Duration, now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	current := t.decayed(base, now)
+	if current <= 0 {
+		current = time.Millisecond
+	}
+	current *= 2
+	if current > max {
+		current = max
+	}
+	t.current = current
+	t.lastChurn = now
+}
+
+// interval returns the time to wait before accepting the next connection.
+func (t *acceptThrottle) interval(base, max time.Duration, now time.Time) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	interval := t.decayed(base, now)
+	if interval > max {
+		interval = max
+	}
+	return interval
+}
+
+// decayed halves the current interval for every churnDecay without churn, it
+// never returns less than base. The caller needs to hold the mutex.
+func (t *acceptThrottle) decayed(base time.Duration, now time.Time) time.Duration {
+	current := t.current
+	for elapsed := now.Sub(t.lastChurn); elapsed >= churnDecay && current > base; elapsed -= churnDecay {
+		current /= 2
+	}
+	if current < base {
+		current = base
+	}
+	return current
+}
+
+// remoteIP returns the ip address of the remote end of a connection.
+func remoteIP(conn net.Conn) string {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	addr := conn.RemoteAddr()
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// connectionsFrom returns the number of client connections from an ip
+// address. The caller needs to hold the clientconnectionmutex.
+func (server *Server) connectionsFrom(ip string) (count int) {
+	for _, c := range server.connections {
+		if c.remoteIP == ip {
+			count++
+		}
+	}
+	return
+}
+
+// waitAccept blocks for the current accept interval, it returns false if the
+// server is closed in the meantime.
+func (server *Server) waitAccept() bool {
+	interval := server.throttle.interval(server.AcceptInterval, server.MaxAcceptInterval, time.Now())
+	if interval <= 0 {
+		return true
+	}
+	select {
+	case <-server.closed:
+		return false
+	case <-time.After(interval):
+		return true
+	}
+}
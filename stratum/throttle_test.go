@@ -0,0 +1,74 @@
+package stratum
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAcceptThrottle(t *testing.T) {
+	base, max := 10*time.Millisecond, 50*time.Millisecond
+	var throttle acceptThrottle
+	now := time.Now()
+	if interval := throttle.interval(base, max, now); interval != base {
+		t.Error("Expected the base interval without churn, got", interval)
+	}
+	throttle.churn(base, max, now)
+	if interval := throttle.interval(base, max, now); interval != 2*base {
+		t.Error("Expected a doubled interval after churn, got", interval)
+	}
+	for i := 0; i < 10; i++ {
+		throttle.churn(base, max, now)
+	}
+	if interval := throttle.interval(base, max, now); interval != max {
+		t.Error("Expected the interval to be capped at", max, "got", interval)
+	}
+	if interval := throttle.interval(base, max, now.Add(churnDecay)); interval != max/2 {
+		t.Error("Expected the interval to be halved after", churnDecay, "without churn, got", interval)
+	}
+	if interval := throttle.interval(base, max, now.Add(10*churnDecay)); interval != base {
+		t.Error("Expected the interval to decay to the base interval, got", interval)
+	}
+}
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	server := &Server{MaxConnections: 10, MaxConnectionsPerIP: 2, SampleInterval: time.Minute, closed: make(chan struct{})}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.laddr = l.Addr().String()
+	l.Close()
+	go server.Accept()
+	defer server.Close()
+
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		var conn net.Conn
+		for attempt := 0; attempt < 100; attempt++ {
+			if conn, err = net.Dial("tcp", server.laddr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	conns[2].SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = conns[2].Read(make([]byte, 1)); err == nil {
+		t.Fatal("Expected the third connection from the same ip to be closed")
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Error("Third connection from the same ip was not dropped")
+	}
+	server.clientconnectionmutex.Lock()
+	count := server.connectionsFrom("127.0.0.1")
+	server.clientconnectionmutex.Unlock()
+	if count != 2 {
+		t.Error("Expected 2 connections from 127.0.0.1, got", count)
+	}
+}
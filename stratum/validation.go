@@ -0,0 +1,103 @@
+package stratum
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/sharechain"
+)
+
+//Strictness decides what happens to borderline shares: shares for the previous job submitted within the
+// StaleGraceWindow (near-stale) and shares with a timestamp off by more than the ClockSkewTolerance but at most
+// twice the tolerance (slightly skewed). Shares for older jobs and shares skewed even more are always rejected.
+type Strictness string
+
+const (
+	//StrictnessStrict rejects near-stale and slightly skewed shares
+	StrictnessStrict Strictness = "strict"
+	//StrictnessStandard accepts and flags near-stale shares and rejects slightly skewed shares
+	StrictnessStandard Strictness = "standard"
+	//StrictnessFlag accepts and flags near-stale and slightly skewed shares
+	StrictnessFlag Strictness = "flag"
+	//StrictnessLenient accepts near-stale and slightly skewed shares without flagging them
+	StrictnessLenient Strictness = "lenient"
+)
+
+//Verdict is the outcome of the validation of a share, the verdicts are ordered from the most to the least acceptable
+type Verdict int
+
+const (
+	//ShareAccepted is the verdict for a share that counts
+	ShareAccepted Verdict = iota
+	//ShareFlagged is the verdict for a borderline share that counts, it is recorded in the FlaggedShares of the miner
+	ShareFlagged
+	//ShareRejected is the verdict for a share that does not count
+	ShareRejected
+)
+
+var errInvalidStrictness = errors.New("share strictness must be strict, standard, flag or lenient")
+
+//SharePolicy holds the verdicts for the borderline shares
+type SharePolicy struct {
+	NearStale      Verdict
+	SlightlySkewed Verdict
+}
+
+//NewSharePolicy returns the policy of a strictness level, the standard policy if it is empty
+func NewSharePolicy(strictness Strictness) (SharePolicy, error) {
+	switch strictness {
+	case StrictnessStrict:
+		return SharePolicy{NearStale: ShareRejected, SlightlySkewed: ShareRejected}, nil
+	case StrictnessStandard, "":
+		return SharePolicy{NearStale: ShareFlagged, SlightlySkewed: ShareRejected}, nil
+	case StrictnessFlag:
+		return SharePolicy{NearStale: ShareFlagged, SlightlySkewed: ShareFlagged}, nil
+	case StrictnessLenient:
+		return SharePolicy{NearStale: ShareAccepted, SlightlySkewed: ShareAccepted}, nil
+	}
+	return SharePolicy{}, errInvalidStrictness
+}
+
+// sharePolicy returns the policy of the Strictness of the server, an invalid
+// strictness is treated as standard.
+func (server *Server) sharePolicy() SharePolicy {
+	policy, err := NewSharePolicy(server.Strictness)
+	if err != nil {
+		policy, _ = NewSharePolicy(StrictnessStandard)
+	}
+	return policy
+}
+
+//ValidateShare returns the verdict for a share of user for the job with the given id and header timestamp,
+// received at now. The verdict is counted in the statistics of the miner and the time since now in the ShareLatencies,
+// a rejected share is recorded in the reject log of the sharechain.
+func (server *Server) ValidateShare(user, job string, timestamp types.Timestamp, now time.Time) Verdict {
+	defer server.recordShareLatency(now)
+	reject := sharechain.Reject{Timestamp: types.Timestamp(now.Unix()), Miner: user, Job: job, HeaderTimestamp: timestamp}
+	verdict := server.judgeJob(user, job, now)
+	if verdict == ShareRejected {
+		reject.Reason = sharechain.RejectStale
+		server.recordReject(reject)
+		return verdict
+	}
+	if v := server.judgeTimestamp(user, timestamp, now); v > verdict {
+		verdict = v
+	}
+	if verdict == ShareRejected {
+		reject.Reason = sharechain.RejectSkewed
+		server.recordReject(reject)
+	}
+	if verdict == ShareFlagged {
+		server.getMinerStats(user).addFlaggedShare()
+	}
+	return verdict
+}
+
+// recordReject adds a rejected share to the reject log of the sharechain.
+func (server *Server) recordReject(reject sharechain.Reject) {
+	if server.shareChain != nil {
+		server.shareChain.RecordReject(reject)
+	}
+}
@@ -0,0 +1,119 @@
+package stratum
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/siapool/p2pool/sharechain"
+	"github.com/siapool/p2pool/siad"
+)
+
+func TestValidateShareStrictness(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	current := types.Timestamp(now.Unix())
+	slightlySkewed := types.Timestamp(now.Unix() - 90)
+	tooSkewed := types.Timestamp(now.Unix() - 121)
+	for _, test := range []struct {
+		strictness Strictness
+		nearStale  Verdict
+		skewed     Verdict
+	}{
+		{StrictnessStrict, ShareRejected, ShareRejected},
+		{StrictnessStandard, ShareFlagged, ShareRejected},
+		{"", ShareFlagged, ShareRejected},
+		{StrictnessFlag, ShareFlagged, ShareFlagged},
+		{StrictnessLenient, ShareAccepted, ShareAccepted},
+	} {
+		server := &Server{StaleGraceWindow: time.Second, ClockSkewTolerance: time.Minute, Strictness: test.strictness}
+		previous := server.newJob(now, true)
+		job := server.newJob(now, true)
+
+		if verdict := server.ValidateShare("a", job, current, now); verdict != ShareAccepted {
+			t.Error(test.strictness, ": expected a current share to be accepted, got", verdict)
+		}
+		if verdict := server.ValidateShare("a", previous, current, now.Add(time.Second)); verdict != test.nearStale {
+			t.Error(test.strictness, ": expected", test.nearStale, "for a near-stale share, got", verdict)
+		}
+		if verdict := server.ValidateShare("a", previous, current, now.Add(time.Second+time.Nanosecond)); verdict != ShareRejected {
+			t.Error(test.strictness, ": expected a stale share to be rejected, got", verdict)
+		}
+		if verdict := server.ValidateShare("a", job, slightlySkewed, now); verdict != test.skewed {
+			t.Error(test.strictness, ": expected", test.skewed, "for a slightly skewed share, got", verdict)
+		}
+		if verdict := server.ValidateShare("a", job, tooSkewed, now); verdict != ShareRejected {
+			t.Error(test.strictness, ": expected a share skewed beyond twice the tolerance to be rejected, got", verdict)
+		}
+
+		var flagged uint64
+		for _, verdict := range []Verdict{test.nearStale, test.skewed} {
+			if verdict == ShareFlagged {
+				flagged++
+			}
+		}
+		if ms := server.getMinerStats("a"); ms.FlaggedShares != flagged {
+			t.Error(test.strictness, ": expected", flagged, "flagged shares, got", ms.FlaggedShares)
+		}
+	}
+}
+
+func TestValidateShareWorstVerdict(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	server := &Server{StaleGraceWindow: time.Second, ClockSkewTolerance: time.Minute, Strictness: StrictnessFlag}
+	previous := server.newJob(now, true)
+	server.newJob(now, true)
+	if verdict := server.ValidateShare("a", previous, types.Timestamp(now.Unix()-90), now); verdict != ShareFlagged {
+		t.Error("Expected a near-stale and slightly skewed share to be flagged, got", verdict)
+	}
+	if flagged := server.getMinerStats("a").FlaggedShares; flagged != 1 {
+		t.Error("Expected the share to be flagged once, got", flagged)
+	}
+	server.Strictness = StrictnessStandard
+	if verdict := server.ValidateShare("a", previous, types.Timestamp(now.Unix()-90), now); verdict != ShareRejected {
+		t.Error("Expected a near-stale and slightly skewed share to be rejected, got", verdict)
+	}
+}
+
+func TestValidateShareRejectLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stratum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sc, err := sharechain.New(siad.NewMock(), dir, sharechain.Config{LogRejects: true, ShareFlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	now := time.Now()
+	server := &Server{shareChain: sc, StaleGraceWindow: time.Second, ClockSkewTolerance: time.Minute}
+	previous := server.newJob(now, true)
+	job := server.newJob(now, true)
+	later := now.Add(2 * time.Second)
+	server.ValidateShare("a", job, types.Timestamp(now.Unix()), later)
+	server.ValidateShare("a", previous, types.Timestamp(now.Unix()), later)
+	server.ValidateShare("a", job, types.Timestamp(now.Unix()-300), later)
+	sc.Close()
+
+	if sc, err = sharechain.New(siad.NewMock(), dir, sharechain.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	rejects, err := sc.Rejects(types.UnlockHash{}, now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejects) != 2 || rejects[0].Reason != sharechain.RejectStale || rejects[0].Job != previous ||
+		rejects[1].Reason != sharechain.RejectSkewed || rejects[1].HeaderTimestamp != types.Timestamp(now.Unix()-300) {
+		t.Error("Expected the stale and the skewed share in the reject log, got", rejects)
+	}
+}
+
+func TestNewSharePolicy(t *testing.T) {
+	if _, err := NewSharePolicy("paranoid"); err != errInvalidStrictness {
+		t.Error("Expected", errInvalidStrictness, "got", err)
+	}
+}
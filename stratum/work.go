@@ -0,0 +1,254 @@
+package stratum
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/siapool/p2pool/sharechain"
+)
+
+// extraNonce2Size is the number of bytes of the extranonce2 a miner varies
+const extraNonce2Size = 4
+
+// minerJob is the work of a connection for a job: the block of the template
+// that pays the miner of the connection as the finder, and the parts of the
+// mining.notify the miner builds the headers from.
+type minerJob struct {
+	template    sharechain.Template
+	block       types.Block
+	extraNonce1 []byte
+	coinb1      []byte
+	coinb2      []byte
+	branch      []crypto.Hash
+	// submitted are the headers submitted for the job, by extranonce2,
+	// timestamp and nonce.
+	submitted map[string]bool
+}
+
+// minerTransaction returns the transaction a miner adds to its block, the
+// extranonce is its arbitrary data.
+func minerTransaction(extraNonce1, extraNonce2 []byte) types.Transaction {
+	data := append(append(append([]byte(nil), modules.PrefixNonSia[:]...), extraNonce1...), extraNonce2...)
+	return types.Transaction{ArbitraryData: [][]byte{data}}
+}
+
+// newMinerJob creates the work for the block of a miner with the extranonce1
+// of its connection. The transaction of the miner is split around the
+// extranonce, only the empty list of signatures follows it.
+func newMinerJob(template sharechain.Template, b types.Block, extraNonce1 []byte) *minerJob {
+	encoded := encoding.Marshal(minerTransaction(extraNonce1, make([]byte, extraNonce2Size)))
+	signatures := len(encoding.Marshal([]types.TransactionSignature{}))
+	split := len(encoded) - signatures - extraNonce2Size - len(extraNonce1)
+	return &minerJob{
+		template:    template,
+		block:       b,
+		extraNonce1: extraNonce1,
+		coinb1:      encoded[:split],
+		coinb2:      encoded[len(encoded)-signatures:],
+		branch:      merkleBranch(b),
+		submitted:   make(map[string]bool),
+	}
+}
+
+// merkleBranch returns the hashes a miner combines the leaf of its transaction
+// with to get the merkle root of the block, the transaction is the last leaf.
+// The leaves before it form a complete subtree for every bit of their number,
+// each of them is the left sibling of the path from the last leaf to the root,
+// the smallest first.
+func merkleBranch(b types.Block) (branch []crypto.Hash) {
+	var leaves [][]byte
+	for _, payout := range b.MinerPayouts {
+		leaves = append(leaves, encoding.Marshal(payout))
+	}
+	for _, txn := range b.Transactions {
+		leaves = append(leaves, encoding.Marshal(txn))
+	}
+	size := 1
+	for size*2 <= len(leaves) {
+		size *= 2
+	}
+	var start int
+	for ; size > 0 && start < len(leaves); size /= 2 {
+		if len(leaves)&size == 0 {
+			continue
+		}
+		tree := crypto.NewTree()
+		for _, leaf := range leaves[start : start+size] {
+			tree.Push(leaf)
+		}
+		branch = append([]crypto.Hash{tree.Root()}, branch...)
+		start += size
+	}
+	return
+}
+
+// headerID returns the id of the header of the job with the extranonce2,
+// timestamp and nonce of a miner. The merkle root is folded from the branch,
+// as the miner does, without hashing the whole block.
+func (j *minerJob) headerID(extraNonce2 []byte, timestamp types.Timestamp, nonce types.BlockNonce) types.BlockID {
+	leaf := append(append(append(append([]byte{0}, j.coinb1...), j.extraNonce1...), extraNonce2...), j.coinb2...)
+	root := crypto.HashBytes(leaf)
+	for _, sibling := range j.branch {
+		root = crypto.HashBytes(append(append([]byte{1}, sibling[:]...), root[:]...))
+	}
+	return types.BlockHeader{ParentID: j.block.ParentID, Nonce: nonce, Timestamp: timestamp, MerkleRoot: root}.ID()
+}
+
+// solvedBlock returns the block of the job with the extranonce2, timestamp
+// and nonce of a miner.
+func (j *minerJob) solvedBlock(extraNonce2 []byte, timestamp types.Timestamp, nonce types.BlockNonce) types.Block {
+	b := j.block
+	b.Transactions = append(append([]types.Transaction(nil), b.Transactions...), minerTransaction(j.extraNonce1, extraNonce2))
+	b.Timestamp = timestamp
+	b.Nonce = nonce
+	return b
+}
+
+// notifyParams returns the params of the mining.notify of the job: the job
+// id, the parent block, coinb1, coinb2, the merkle branch, the version, the
+// target of the block, the timestamp and if the miner should abandon its
+// previous work.
+func (j *minerJob) notifyParams(id string, clean bool) []interface{} {
+	branch := make([]interface{}, len(j.branch))
+	for i, h := range j.branch {
+		branch[i] = hex.EncodeToString(h[:])
+	}
+	return []interface{}{
+		id,
+		hex.EncodeToString(j.block.ParentID[:]),
+		hex.EncodeToString(j.coinb1),
+		hex.EncodeToString(j.coinb2),
+		branch,
+		"",
+		hex.EncodeToString(j.template.Target[:]),
+		hex.EncodeToString(encoding.Marshal(j.block.Timestamp)),
+		clean,
+	}
+}
+
+//templateChanged starts a new job for a new block template of the sharechain and sends it to the miners
+func (server *Server) templateChanged(template sharechain.Template) {
+	id := server.newJob(time.Now(), template.Clean)
+	server.jobsMutex.Lock()
+	if server.jobTemplates == nil {
+		server.jobTemplates = make(map[string]sharechain.Template)
+	}
+	server.jobTemplates[id] = template
+	for job := range server.jobTemplates {
+		if job != server.currentJob && job != server.previousJob {
+			delete(server.jobTemplates, job)
+		}
+	}
+	server.jobsMutex.Unlock()
+
+	server.clientconnectionmutex.Lock()
+	var miners []*ClientConnection
+	for _, c := range server.connections {
+		if c.subscribed && c.User != "" {
+			miners = append(miners, c)
+		}
+	}
+	server.clientconnectionmutex.Unlock()
+	// A slow miner should not delay the others.
+	for _, c := range miners {
+		go c.sendJob(id, template, template.Clean)
+	}
+}
+
+// currentJobTemplate returns the id and the template of the current job, ok
+// is false if there is none.
+func (server *Server) currentJobTemplate() (id string, template sharechain.Template, ok bool) {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	template, ok = server.jobTemplates[server.currentJob]
+	return server.currentJob, template, ok
+}
+
+// liveJob returns if the job with the given id is the current or the previous
+// job.
+func (server *Server) liveJob(id string) bool {
+	server.jobsMutex.Lock()
+	defer server.jobsMutex.Unlock()
+	return id == server.currentJob || id == server.previousJob
+}
+
+//SendJob sends the current job to a subscribed and authorized miner. If there is no job yet, a block template is
+// requested from the sharechain, the job for it is sent to all miners.
+func (c *ClientConnection) SendJob() {
+	server := c.server
+	if server.shareChain == nil {
+		return
+	}
+	id, template, ok := server.currentJobTemplate()
+	if ok {
+		c.sendJob(id, template, true)
+		return
+	}
+	template, err := server.shareChain.BlockTemplate()
+	if err != nil {
+		log.Infoln("No work for stratum client", c.User, ":", err)
+		return
+	}
+	// A template that was built before the server listened did not start a
+	// job.
+	if _, _, ok = server.currentJobTemplate(); !ok {
+		server.templateChanged(template)
+	}
+}
+
+// sendJob sends the job with the given id and template to the miner with
+// mining.notify, the block of the job pays the address of the miner as the
+// finder.
+func (c *ClientConnection) sendJob(id string, template sharechain.Template, clean bool) {
+	c.server.clientconnectionmutex.Lock()
+	subscribed, extraNonce1 := c.subscribed, c.extranonce1
+	c.server.clientconnectionmutex.Unlock()
+	if !subscribed || c.User == "" {
+		return
+	}
+	address, err := sharechain.MinerAddress(c.User)
+	if err != nil {
+		log.Infoln("No work for stratum client", c.User, ":", err)
+		return
+	}
+	b, err := c.server.shareChain.MinerBlock(template, address)
+	if err != nil {
+		log.Errorln("Error creating the block of stratum client", c.User, ":", err)
+		return
+	}
+	job := newMinerJob(template, b, extraNonce1)
+	c.addJob(id, job)
+	if err = c.Notify("mining.notify", job.notifyParams(id, clean)); err != nil {
+		c.Close()
+	}
+}
+
+// addJob keeps the work of the connection for a job, the work for jobs that
+// are neither the current nor the previous job of the server is dropped.
+func (c *ClientConnection) addJob(id string, job *minerJob) {
+	c.jobsMutex.Lock()
+	defer c.jobsMutex.Unlock()
+	if c.jobs == nil {
+		c.jobs = make(map[string]*minerJob)
+	}
+	c.jobs[id] = job
+	for other := range c.jobs {
+		if !c.server.liveJob(other) {
+			delete(c.jobs, other)
+		}
+	}
+}
+
+// job returns the work of the connection for the job with the given id.
+func (c *ClientConnection) job(id string) (job *minerJob, ok bool) {
+	c.jobsMutex.Lock()
+	defer c.jobsMutex.Unlock()
+	job, ok = c.jobs[id]
+	return
+}
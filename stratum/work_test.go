@@ -0,0 +1,41 @@
+package stratum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+func TestMinerJobHeaderID(t *testing.T) {
+	extraNonce1, extraNonce2 := []byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}
+	for payouts := 0; payouts < 9; payouts++ {
+		for transactions := 0; transactions < 3; transactions++ {
+			b := types.Block{ParentID: types.BlockID{1}, Timestamp: 1000}
+			for i := 0; i < payouts; i++ {
+				b.MinerPayouts = append(b.MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(uint64(i + 1)), UnlockHash: types.UnlockHash{byte(i)}})
+			}
+			for i := 0; i < transactions; i++ {
+				b.Transactions = append(b.Transactions, types.Transaction{ArbitraryData: [][]byte{{byte(i)}}})
+			}
+			job := newMinerJob(sharechain.Template{Block: b}, b, extraNonce1)
+
+			// The miner rebuilds its transaction from the notify params.
+			params := job.notifyParams("1", true)
+			coinb1, _ := hex.DecodeString(params[2].(string))
+			coinb2, _ := hex.DecodeString(params[3].(string))
+			transaction := append(append(append(coinb1, extraNonce1...), extraNonce2...), coinb2...)
+			if !bytes.Equal(transaction, encoding.Marshal(minerTransaction(extraNonce1, extraNonce2))) {
+				t.Fatal("coinb1 and coinb2 do not surround the extranonce of the miner transaction")
+			}
+
+			solved := job.solvedBlock(extraNonce2, 1001, types.BlockNonce{9})
+			if id := job.headerID(extraNonce2, 1001, types.BlockNonce{9}); id != solved.ID() {
+				t.Error("Header id", id, "differs from the id of the block", solved.ID(), "with", payouts, "payouts and", transactions, "transactions")
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+//Package webhooks posts the block and payout events of the sharechain to the webhooks registered by the miners
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	log "github.com/Sirupsen/logrus"
+	"github.com/siapool/p2pool/events"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+const (
+	//DefaultTimeout is the default time a single delivery attempt can take
+	DefaultTimeout = 10 * time.Second
+	//DefaultAttempts is the default number of times a delivery is tried before it is given up
+	DefaultAttempts = 5
+	//DefaultBackoff is the default wait before the first retry, it doubles with every retry
+	DefaultBackoff = 5 * time.Second
+	//MaxURLLength is the maximum length of a webhook url
+	MaxURLLength = 512
+
+	// queueSize is the number of events that can wait for delivery, events
+	// beyond it are dropped.
+	queueSize = 1000
+	// maxDeliveries is the number of deliveries in progress at the same
+	// time.
+	maxDeliveries = 16
+)
+
+var (
+	errInvalidScheme = errors.New("webhook url must be http or https")
+	errHTTPSRequired = errors.New("webhook url must be https")
+	errMissingHost   = errors.New("webhook url has no host")
+	errURLTooLong    = errors.New("webhook url is too long")
+	errInternal      = errors.New("webhook url resolves to an internal address")
+	errRedirect      = errors.New("webhook redirects are not followed")
+)
+
+// internalNetworks are the address ranges a webhook may not be delivered to
+// unless AllowInternal is set.
+var internalNetworks []*net.IPNet
+
+func init() {
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		internalNetworks = append(internalNetworks, network)
+	}
+}
+
+func internal(ip net.IP) bool {
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return ip.IsMulticast()
+}
+
+//Config holds the settings of the dispatcher
+type Config struct {
+	//HTTPSOnly rejects webhooks that are not https
+	HTTPSOnly bool
+	//AllowInternal allows webhooks on loopback, private and link-local addresses
+	AllowInternal bool
+	//Timeout is the time a single delivery attempt can take, DefaultTimeout is used if it is 0
+	Timeout time.Duration
+	//Attempts is the number of times a delivery is tried, DefaultAttempts is used if it is 0
+	Attempts int
+	//Backoff is the wait before the first retry, DefaultBackoff is used if it is 0
+	Backoff time.Duration
+}
+
+//Payload is the json body posted to a webhook
+type Payload struct {
+	Event     sharechain.AuditEvent `json:"event"`
+	BlockID   types.BlockID         `json:"blockid"`
+	Address   types.UnlockHash      `json:"address"`
+	Value     types.Currency        `json:"value"`
+	Timestamp types.Timestamp       `json:"timestamp"`
+}
+
+//Source provides the registered webhooks and the bus of the events to deliver, it is implemented by the sharechain
+type Source interface {
+	Webhooks(address *types.UnlockHash) ([]sharechain.Webhook, error)
+	Events() *events.Bus
+}
+
+//Dispatcher delivers the events of the sharechain to the registered webhooks.
+// A blockfound event is posted to all webhooks, a payout event to the webhooks of the paid address.
+type Dispatcher struct {
+	source Source
+	config Config
+	client *http.Client
+
+	events     *events.Subscription
+	dropped    uint64
+	deliveries chan struct{}
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+//New creates a dispatcher for the webhooks of the source and starts delivering its events
+func New(source Source, config Config) *Dispatcher {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Attempts == 0 {
+		config.Attempts = DefaultAttempts
+	}
+	if config.Backoff == 0 {
+		config.Backoff = DefaultBackoff
+	}
+	d := &Dispatcher{
+		source:     source,
+		config:     config,
+		events:     source.Events().Subscribe(queueSize, delivered),
+		deliveries: make(chan struct{}, maxDeliveries),
+		stop:       make(chan struct{}),
+	}
+	d.client = &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			Dial:                d.dial,
+			TLSHandshakeTimeout: config.Timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errRedirect
+		},
+	}
+	d.wg.Add(1)
+	go d.threadedDispatch()
+	return d
+}
+
+//Close stops delivering events, deliveries in progress are abandoned at their next retry
+func (d *Dispatcher) Close() {
+	d.events.Close()
+	close(d.stop)
+	d.wg.Wait()
+}
+
+//ValidateURL returns an error if a webhook url is not allowed
+func (d *Dispatcher) ValidateURL(raw string) error {
+	if len(raw) > MaxURLLength {
+		return errURLTooLong
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return errInvalidScheme
+	case d.config.HTTPSOnly && u.Scheme != "https":
+		return errHTTPSRequired
+	case u.Host == "":
+		return errMissingHost
+	}
+	if d.config.AllowInternal {
+		return nil
+	}
+	// The address is checked again on every delivery, the host can resolve
+	// to another address by then.
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	_, err = d.resolve(host)
+	return err
+}
+
+// resolve returns the addresses of a host, an error is returned if none of
+// them is allowed.
+func (d *Dispatcher) resolve(host string) (ips []net.IP, err error) {
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if ips, err = net.LookupIP(host); err != nil {
+		return nil, err
+	}
+	if d.config.AllowInternal {
+		return ips, nil
+	}
+	allowed := ips[:0]
+	for _, ip := range ips {
+		if !internal(ip) {
+			allowed = append(allowed, ip)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, errInternal
+	}
+	return allowed, nil
+}
+
+// dial connects to the first allowed address of the host, the resolved
+// address is dialed directly so a hostname can not be rebound to an internal
+// address between the check and the connection.
+func (d *Dispatcher) dial(network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.resolve(host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: d.config.Timeout}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = dialer.Dial(network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// threadedDispatch delivers the events of the subscription until the
+// dispatcher is closed.
+func (d *Dispatcher) threadedDispatch() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case e, ok := <-d.events.Events():
+			if !ok {
+				return
+			}
+			if dropped := d.events.Dropped(); dropped > d.dropped {
+				log.Warnln("Webhook queue was full, dropped", dropped-d.dropped, "events")
+				d.dropped = dropped
+			}
+			d.dispatch(e)
+		}
+	}
+}
+
+// delivered returns true for the events that are delivered to webhooks.
+func delivered(e events.Event) bool {
+	switch e.(type) {
+	case events.BlockFound, events.Payout:
+		return true
+	}
+	return false
+}
+
+// dispatch starts the delivery of an event to every webhook it is meant for.
+func (d *Dispatcher) dispatch(e events.Event) {
+	var payload Payload
+	var address *types.UnlockHash
+	switch e := e.(type) {
+	case events.BlockFound:
+		payload = Payload{Event: sharechain.AuditBlockFound, BlockID: e.BlockID, Address: e.Finder, Value: e.Reward, Timestamp: e.Timestamp}
+	case events.Payout:
+		payload = Payload{Event: sharechain.AuditPayout, BlockID: e.BlockID, Address: e.Address, Value: e.Value, Timestamp: e.Timestamp}
+		address = &e.Address
+	default:
+		return
+	}
+	hooks, err := d.source.Webhooks(address)
+	if err != nil {
+		log.Errorln("Error loading webhooks:", err)
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorln("Error encoding webhook payload:", err)
+		return
+	}
+	for _, hook := range hooks {
+		select {
+		case <-d.stop:
+			return
+		case d.deliveries <- struct{}{}:
+		}
+		d.wg.Add(1)
+		go func(hook sharechain.Webhook) {
+			defer d.wg.Done()
+			defer func() { <-d.deliveries }()
+			d.deliver(hook, body)
+		}(hook)
+	}
+}
+
+// deliver posts the body to a webhook, failed attempts are retried with an
+// exponential backoff.
+func (d *Dispatcher) deliver(hook sharechain.Webhook, body []byte) {
+	backoff := d.config.Backoff
+	for attempt := 1; ; attempt++ {
+		err := d.post(hook.URL, body)
+		if err == nil {
+			return
+		}
+		if attempt >= d.config.Attempts {
+			log.Warnln("Giving up webhook", hook.URL, "of", hook.Address, "after", attempt, "attempts:", err)
+			return
+		}
+		log.Debugln("Webhook", hook.URL, "failed, retrying in", backoff, ":", err)
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) post(target string, body []byte) error {
+	resp, err := d.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("webhook replied " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/siapool/p2pool/events"
+	"github.com/siapool/p2pool/sharechain"
+)
+
+type testSource struct {
+	hooks []sharechain.Webhook
+	bus   *events.Bus
+}
+
+func (s *testSource) Webhooks(address *types.UnlockHash) (hooks []sharechain.Webhook, err error) {
+	for _, hook := range s.hooks {
+		if address == nil || hook.Address == *address {
+			hooks = append(hooks, hook)
+		}
+	}
+	return
+}
+
+func (s *testSource) Events() *events.Bus {
+	if s.bus == nil {
+		s.bus = events.NewBus()
+	}
+	return s.bus
+}
+
+func TestValidateURL(t *testing.T) {
+	d := &Dispatcher{config: Config{HTTPSOnly: true}}
+	for raw, valid := range map[string]bool{
+		"https://93.184.216.34/hook":                                 true,
+		"http://93.184.216.34/hook":                                  false,
+		"ftp://93.184.216.34/hook":                                   false,
+		"https:///hook":                                              false,
+		"https://127.0.0.1/hook":                                     false,
+		"https://10.1.2.3:8080/hook":                                 false,
+		"https://169.254.169.254/":                                   false,
+		"https://[::1]/hook":                                         false,
+		"https://93.184.216.34/" + strings.Repeat("x", MaxURLLength): false,
+	} {
+		if err := d.ValidateURL(raw); (err == nil) != valid {
+			t.Error("Expected valid", valid, "for", raw, "got", err)
+		}
+	}
+	d.config.AllowInternal = true
+	if err := d.ValidateURL("https://127.0.0.1/hook"); err != nil {
+		t.Error("Internal url rejected with AllowInternal:", err)
+	}
+}
+
+func TestDeliverInternal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Webhook delivered to an internal address")
+	}))
+	defer srv.Close()
+	d := New(&testSource{}, Config{})
+	defer d.Close()
+	if err := d.post(srv.URL, []byte("{}")); err == nil {
+		t.Error("Expected an error posting to", srv.URL)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []Payload
+	attempts := 0
+	received := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, payload)
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+
+	a, b := types.UnlockHash{1}, types.UnlockHash{2}
+	source := &testSource{hooks: []sharechain.Webhook{{Address: a, URL: srv.URL + "/a"}}}
+	d := New(source, Config{AllowInternal: true, Backoff: time.Millisecond})
+	defer d.Close()
+
+	source.bus.Publish(events.ShareAccepted{Miner: a.String()})
+	source.bus.Publish(events.Payout{Address: b, Value: types.NewCurrency64(1)})
+	source.bus.Publish(events.Payout{Address: a, Value: types.NewCurrency64(2)})
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook not delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 || len(payloads) != 1 || payloads[0].Address != a || payloads[0].Value.Cmp(types.NewCurrency64(2)) != 0 {
+		t.Error("Expected the payout of", a, "delivered on the second attempt, got", payloads, "after", attempts, "attempts")
+	}
+}